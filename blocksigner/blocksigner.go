@@ -13,22 +13,11 @@ var (
 	SelfIsProducer bool
 )
 
-func GetBlockSignerMaps(elaHeight uint64) *map[common.Address]struct{} {
-	// TODO get from ELA
-	return &Signers
-}
-
 func GetBlockSignersCount() int {
 	// TODO get from ELA
 	return len(Signers)
 }
 
-func ValidateSigner(elaHeight uint64, addr common.Address) bool {
-	signers := GetBlockSignerMaps(elaHeight)
-	_, ok := (*signers)[addr]
-	return ok
-}
-
 func GenRandSingersFromTest() {
 	Signers = make(map[common.Address]struct{})
 	for i := 0; i < defaultTestSignerNumber; i++ {
@@ -4,9 +4,12 @@ import (
 	"errors"
 
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/consensus/pbft"
 	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/pgprotocol/pgp-chain/dpos"
 	"github.com/pgprotocol/pgp-chain/log"
 )
 
@@ -144,6 +147,57 @@ func (a *API) InitArbiterList(arbiters []common.Address, total int, chainID uint
 	return nil
 }
 
+// GetChainStatus returns the polling and arbiter update status of every
+// relayed chain, with the outcome of its recent transactions.
+func (a *API) GetChainStatus() []relayer.ChainStatus {
+	if MsgReleayer == nil {
+		return []relayer.ChainStatus{}
+	}
+	return MsgReleayer.ChainStatus()
+}
+
 func (a *API) GetSignerAddress() string {
-	return a.engine.GetBridgeArbiters().Address()
+	if a.engine.GetBridgeArbiters() == nil {
+		return ""
+	}
+	return a.engine.GetBridgeArbiters().Address().String()
+}
+
+// GetBridgeKeyHandovers returns the handovers signed by the rotations of the
+// bridge key since the node started, along with the ones restored still
+// pending, oldest first. They are only submitted to the bridge contracts
+// defining handoverArbiter, the others can be handed them from here.
+func (a *API) GetBridgeKeyHandovers() []signer.Handover {
+	return a.engine.BridgeHandovers()
+}
+
+// PrivateAPI is the RPC API to rotate the bridge key of the node, it is only
+// served on the private endpoints.
+type PrivateAPI struct {
+	engine *pbft.Pbft
+}
+
+// RotateBridgeKey switches the bridge key to the one of the keystore at
+// keystorePath without a restart. The key is persisted encrypted with the
+// keystore password of the node, and the old key signs the returned handover
+// which is relayed to the bridge contracts.
+func (a *PrivateAPI) RotateBridgeKey(keystorePath, password string) (*signer.Handover, error) {
+	kp, err := dpos.GetBridgeAccount(keystorePath, []byte(password))
+	if err != nil {
+		return nil, err
+	}
+	return rotateBridgeSigner(a.engine, signer.NewKeypairSigner(kp))
+}
+
+// RotateExternalBridgeKey switches the bridge key to address on the external
+// signer of the node without a restart.
+func (a *PrivateAPI) RotateExternalBridgeKey(address common.Address) (*signer.Handover, error) {
+	if externalSigner == "" {
+		return nil, errors.New("no external signer")
+	}
+	s, err := signer.NewExternalSigner(externalSigner, address)
+	if err != nil {
+		return nil, err
+	}
+	return rotateBridgeSigner(a.engine, s)
 }
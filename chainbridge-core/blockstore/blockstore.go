@@ -10,6 +10,7 @@ import (
 	"math/big"
 
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
+	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -56,19 +57,39 @@ func GetLastStoredBlock(db KeyValueReader, chainID uint64) (*big.Int, error) {
 	return block, nil
 }
 
-// SetupBlockstore queries the blockstore for the latest known block. If the latest block is
-// greater than config.StartBlock, then config.StartBlock is replaced with the latest known block.
+// StoreBlockHash stores the hash of the last stored block, to detect it was
+// reorganised away when polling resumes.
+func StoreBlockHash(db KeyValueWriter, hash common.Hash, chainID uint64) error {
+	key := fmt.Sprintf("chain:%d:hash", chainID)
+	return db.SetByKey([]byte(key), hash.Bytes())
+}
+
+// GetLastStoredBlockHash returns the hash of the last stored block, the zero
+// hash if none was stored.
+func GetLastStoredBlockHash(db KeyValueReader, chainID uint64) (common.Hash, error) {
+	key := fmt.Sprintf("chain:%d:hash", chainID)
+	v, err := db.GetByKey([]byte(key))
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return common.Hash{}, nil
+		}
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(v), nil
+}
+
+// SetupBlockstore queries the blockstore for the latest known block. The latest block is
+// processed already, so if the block after it is greater than config.StartBlock, polling
+// resumes from that block instead.
 func SetupBlockstore(generalConfig *config.GeneralChainConfig, kvdb KeyValueReaderWriter, startBlock *big.Int) (*big.Int, error) {
 	latestBlock, err := GetLastStoredBlock(kvdb, generalConfig.Id)
 	if err != nil {
 		return nil, err
 	}
 
-	if !generalConfig.FreshStart {
-		if latestBlock.Cmp(startBlock) == 1 {
-			return latestBlock, nil
-		} else {
-			return startBlock, nil
+	if !generalConfig.FreshStart && latestBlock.Sign() > 0 {
+		if next := new(big.Int).Add(latestBlock, big.NewInt(1)); next.Cmp(startBlock) == 1 {
+			return next, nil
 		}
 	}
 	return startBlock, nil
@@ -17,10 +17,10 @@ import (
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/listener"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/voter"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/dpos_msg"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/lvldb"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/consensus/pbft"
 	"github.com/pgprotocol/pgp-chain/crypto"
@@ -38,6 +38,10 @@ import (
 
 const (
 	MAX_RETRYCOUNT = 60
+
+	// bridgeSignerFile persists the bridge key in use and its pending
+	// handovers, under the data directory of the node
+	bridgeSignerFile = "bridge_signer.json"
 )
 
 var (
@@ -62,6 +66,10 @@ var (
 	isNeedRecoveryArbiters    bool
 
 	escChainID uint64
+
+	// externalSigner is the endpoint of the clef-style signer of the node,
+	// the bridge signs through it if the config names accounts on it.
+	externalSigner string
 )
 
 func init() {
@@ -83,6 +91,11 @@ func APIs(engine *pbft.Pbft) []rpc.API {
 		Version:   "1.0",
 		Service:   api,
 		Public:    true,
+	}, {
+		Namespace: "bridge",
+		Version:   "1.0",
+		Service:   &PrivateAPI{engine},
+		Public:    false,
 	}}
 }
 
@@ -109,6 +122,8 @@ func Start() bool {
 	}
 	bridgelog.Info("chain bridge start")
 	isStarted = true
+	StartUpdateNode()
+	go relayHandovers(pbftEngine)
 	events.Subscribe(func(e *events.Event) {
 		switch e.Type {
 		case events.ETDirectPeersChanged:
@@ -120,7 +135,7 @@ func Start() bool {
 			isProducer := pbftEngine.IsProducer()
 			self := pbftEngine.GetProducer()
 			keypair := pbftEngine.GetBridgeArbiters()
-			selfArbiterAddr = keypair.Address()
+			selfArbiterAddr = keypair.Address().String()
 			currentArbitersOnContract = MsgReleayer.GetArbiters(escChainID)
 			isValidator := currentArbitersHasself()
 			bridgelog.Info("selfArbiterAddr ", selfArbiterAddr, "isValidator", isValidator)
@@ -228,6 +243,7 @@ func Start() bool {
 			escStateChanged(e)
 		case dpos.ETOnDutyEvent:
 			recoveryArbiter()
+			go relayHandovers(pbftEngine)
 		}
 	})
 	return true
@@ -325,9 +341,6 @@ func receivedReqArbiterSignature(engine *pbft.Pbft, e *events.Event) {
 	msg := &dpos_msg.FeedBackArbitersSignature{}
 	msg.Producer = selfProducer
 
-	kp := engine.GetBridgeArbiters().(*secp256k1.Keypair)
-	privateKey := kp.PrivateKey()
-
 	salt, err := MsgReleayer.GetHashSalt(escChainID)
 	if err != nil {
 		bridgelog.Warn("GetHashSalt failed", "error")
@@ -338,7 +351,7 @@ func receivedReqArbiterSignature(engine *pbft.Pbft, e *events.Event) {
 	if err != nil {
 		bridgelog.Error("receivedReqArbiterSignature HashArbiterList failed", "error", err)
 	}
-	sign, err := crypto.Sign(accounts.TextHash(hash.Bytes()), privateKey)
+	sign, err := engine.GetBridgeArbiters().SignText(hash.Bytes())
 	if err != nil {
 		bridgelog.Warn("sign arbiters error", "error", err)
 		return
@@ -661,14 +674,40 @@ func initRelayer(engine *pbft.Pbft, stack *node.Node, accountPath, accountPasswo
 		return err
 	}
 	escChainID = engine.GetBlockChain().Config().ChainID.Uint64()
-	count := len(cfg.Chains)
-	chains := make([]relayer.RelayedChain, count)
-	for i := 0; i < count; i++ {
+	externalSigner = stack.Config().ExternalSigner
+	// The key on the external signer replaces the keystore one as the
+	// configured key, it is not a rotation
+	var initial signer.Signer
+	if cfg.BridgeSigner != "" {
+		if externalSigner == "" {
+			return errors.New("bridgeSigner is set without an external signer")
+		}
+		initial, err = signer.NewExternalSigner(externalSigner, common.HexToAddress(cfg.BridgeSigner))
+		if err != nil {
+			return err
+		}
+	}
+	store := signer.NewStore(stack.ResolvePath(bridgeSignerFile),
+		[]byte(engine.GetBlockChain().Config().PbftKeyStorePassWord), externalSigner)
+	if err = engine.BridgeSigner().Open(store, initial); err != nil {
+		return err
+	}
+	if current := engine.BridgeSigner().Current(); current != nil {
+		selfArbiterAddr = current.Address().String()
+		bridgelog.Info("bridge key set", "address", selfArbiterAddr, "pendingHandovers", len(engine.BridgeSigner().Pending()))
+	}
+	chains := make([]relayer.RelayedChain, 0, len(cfg.Chains))
+	for i := range cfg.Chains {
 		layer, errMsg := createChain(&cfg.Chains[i], db, engine, accountPath, accountPassword)
 		if errMsg != nil {
-			return errors.New(fmt.Sprintf("evm chain is create error:%s, chainid:%d", errMsg.Error(), cfg.Chains[i].Id))
+			// The other chains are relayed without it
+			if cfg.Chains[i].Id == escChainID {
+				return errors.New(fmt.Sprintf("evm chain is create error:%s, chainid:%d", errMsg.Error(), cfg.Chains[i].Id))
+			}
+			bridgelog.Error("evm chain is create error, skip it", "error", errMsg, "chainid", cfg.Chains[i].Id)
+			continue
 		}
-		chains[i] = layer
+		chains = append(chains, layer)
 		if escChainID == layer.ChainID() {
 			engine.GetBlockChain().Config().BridgeContractAddr = layer.GetBridgeContract()
 		}
@@ -677,6 +716,51 @@ func initRelayer(engine *pbft.Pbft, stack *node.Node, accountPath, accountPasswo
 	return nil
 }
 
+// rotateBridgeSigner switches the bridge arbiter key of the engine, and so of
+// the voters of every chain, to next. It returns the handover signed by the
+// old key, nil if there was none, which is relayed to the bridge contracts.
+func rotateBridgeSigner(engine *pbft.Pbft, next signer.Signer) (*signer.Handover, error) {
+	old := engine.BridgeSigner().Address()
+	handover, err := engine.RotateBridgeSigner(next)
+	if err != nil {
+		return nil, err
+	}
+	if handover != nil {
+		bridgelog.Info("bridge key rotated", "old", old.String(), "new", next.Address().String(), "handover", handover.Hash().String())
+		go relayHandovers(engine)
+	} else {
+		bridgelog.Info("bridge key set", "address", next.Address().String())
+	}
+	selfArbiterAddr = next.Address().String()
+	return handover, nil
+}
+
+// relayHandovers relays the pending handovers of the bridge key to the bridge
+// contracts, oldest first. A handover stays pending until no contract holds
+// its old address, and the later ones wait for it. Relaying depends on the
+// handoverArbiter method of the bridge contracts, the contracts without it
+// keep the old address until the next arbiter update.
+func relayHandovers(engine *pbft.Pbft) {
+	if MsgReleayer == nil {
+		return
+	}
+	for _, h := range engine.BridgeSigner().Pending() {
+		done, err := MsgReleayer.RelayHandover(&h)
+		if err != nil {
+			bridgelog.Warn("relay bridge key handover failed", "handover", h.Hash().String(), "error", err)
+			return
+		}
+		if !done {
+			return
+		}
+		if err = engine.BridgeSigner().Relayed(h.Hash()); err != nil {
+			bridgelog.Error("persist relayed bridge key handover failed", "handover", h.Hash().String(), "error", err)
+			return
+		}
+		bridgelog.Info("bridge key handover relayed", "handover", h.Hash().String())
+	}
+}
+
 func createSelfChain(engine *pbft.Pbft, stack *node.Node) error {
 	if engine.GetBlockChain().Config().ChainID == nil {
 		return errors.New("escChainID is nil")
@@ -688,7 +772,11 @@ func createSelfChain(engine *pbft.Pbft, stack *node.Node) error {
 		Id:       escChainID,
 		Endpoint: rpc,
 	}
-	layer, errMsg := createChain(&generalConfig, nil, engine, "", "")
+	db, err := lvldb.NewLvlDB(config.BlockstoreFlagName)
+	if err != nil {
+		return err
+	}
+	layer, errMsg := createChain(&generalConfig, db, engine, "", "")
 	if errMsg != nil {
 		return errors.New(fmt.Sprintf("evm createSelfChain is error:%s, chainid:%d", errMsg.Error(), escChainID))
 	}
@@ -698,6 +786,14 @@ func createSelfChain(engine *pbft.Pbft, stack *node.Node) error {
 	return nil
 }
 
+// Close stops relaying the chains, along with the transactions they follow.
+// It is called on node shutdown.
+func Close() {
+	if MsgReleayer != nil {
+		MsgReleayer.Stop()
+	}
+}
+
 func Stop(msg string) {
 	if isRequireArbiter {
 		errChn <- fmt.Errorf(msg)
@@ -715,13 +811,17 @@ func createChain(generalConfig *config.GeneralChainConfig, db blockstore.KeyValu
 		return nil, err
 	}
 
-	var evmVoter *voter.EVMVoter
-	if engine.GetBridgeArbiters() != nil {
-		kp := engine.GetBridgeArbiters().(*secp256k1.Keypair)
-		evmVoter = voter.NewVoter(ethClient, kp)
-	} else {
-		evmVoter = voter.NewVoter(ethClient, nil)
+	if externalSigner != "" && generalConfig.From != "" {
+		s, err := signer.NewExternalSigner(externalSigner, common.HexToAddress(generalConfig.From))
+		if err != nil {
+			return nil, err
+		}
+		ethClient.SetSigner(s)
 	}
+
+	// The voter signs with the bridge signer of the engine, following its
+	// rotations
+	evmVoter := voter.NewVoter(ethClient, engine.BridgeSigner())
 	evmListener := listener.NewEVMListener(ethClient, &generalConfig.Opts)
 	chain := evm.NewEVMChain(evmListener, evmVoter, generalConfig.Id, db,
 		generalConfig, arbiterManager)
@@ -15,13 +15,14 @@ import (
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/voter"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/crypto"
 	"github.com/pgprotocol/pgp-chain/log"
 )
 
 type EventListener interface {
-	ListenToEvents(startBlock *big.Int, chainID uint64, kvrw blockstore.KeyValueWriter, errChn chan<- error) <-chan *relayer.SetArbiterListMsg
+	ListenToEvents(startBlock *big.Int, chainID uint64, kvrw blockstore.KeyValueReaderWriter, stop <-chan struct{}, errChn chan<- error) <-chan *relayer.SetArbiterListMsg
 }
 
 type ProposalVoter interface {
@@ -37,6 +38,9 @@ type ProposalVoter interface {
 	IsDeployedBridgeContract(bridgeAddress string) bool
 	SetESCState(bridgeAddress string, state uint8) error
 	SetManualArbiter(bridgeAddress string, arbiter []common.Address, totalSigner int) error
+	HandoverArbiter(bridgeAddress string, h *signer.Handover) error
+	Submissions() []relayer.Submission
+	Stop()
 }
 
 // EVMChain is struct that aggregates all data required for
@@ -84,7 +88,7 @@ func (c *EVMChain) WriteArbiters(arbiters []common.Address, signatures [][]byte,
 	if !isSame {
 		return c.writer.SetArbiterList(arbiters, totalCount, signatures, c.bridgeContractAddress)
 	}
-	return errors.New("is same arbiters on contract")
+	return relayer.ErrSameArbiters
 }
 
 func (c *EVMChain) GetArbiters() []common.Address {
@@ -143,14 +147,40 @@ func (c *EVMChain) SetManualArbiters(arbiters []common.Address, totalSigner int)
 	return nil
 }
 
+// HandoverArbiter relays the handover of the bridge key to the bridge
+// contract of the chain.
+func (c *EVMChain) HandoverArbiter(h *signer.Handover) error {
+	return c.writer.HandoverArbiter(c.bridgeContractAddress, h)
+}
+
 func (c *EVMChain) GetBridgeContract() string {
 	return c.config.Opts.Bridge
 }
 
+// Submissions returns the recent transactions sent to the chain.
+func (c *EVMChain) Submissions() []relayer.Submission {
+	return c.writer.Submissions()
+}
+
+// Stop stops following the transactions sent to the chain.
+func (c *EVMChain) Stop() {
+	c.writer.Stop()
+}
+
 func (c *EVMChain) ChainID() uint64 {
 	return c.chainID
 }
 
+// LastPolledBlock returns the last block polled for events, as stored in the
+// blockstore of the chain.
+func (c *EVMChain) LastPolledBlock() (uint64, error) {
+	block, err := blockstore.GetLastStoredBlock(c.kvdb, c.chainID)
+	if err != nil {
+		return 0, err
+	}
+	return block.Uint64(), nil
+}
+
 // PollEvents is the goroutine that polling blocks and searching Deposit Events in them. Event then sent to eventsChan
 func (c *EVMChain) PollEvents(sysErr chan<- error, stop <-chan struct{}, eventsChan chan *relayer.SetArbiterListMsg) {
 	log.Info("Polling Blocks...", "startBlock", c.config.Opts.StartBlock)
@@ -160,12 +190,17 @@ func (c *EVMChain) PollEvents(sysErr chan<- error, stop <-chan struct{}, eventsC
 		sysErr <- fmt.Errorf("error %w on getting last stored block", err)
 		return
 	}
-	ech := c.listener.ListenToEvents(block, c.chainID, c.kvdb, sysErr)
+	ech := c.listener.ListenToEvents(block, c.chainID, c.kvdb, stop, sysErr)
 	for {
 		select {
 		case newEvent := <-ech:
 			// Here we can place middlewares for custom logic?
-			eventsChan <- newEvent
+			select {
+			case eventsChan <- newEvent:
+			case <-stop:
+				bridgelog.Info("PollEvents stopped")
+				return
+			}
 			continue
 		case <-stop:
 			bridgelog.Info("PollEvents stopped")
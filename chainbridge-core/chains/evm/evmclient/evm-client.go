@@ -20,6 +20,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/engine"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/keystore"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/chainbridge_abi"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/common/hexutil"
@@ -37,6 +38,7 @@ type EVMClient struct {
 	nonceLock sync.Mutex
 	config    *config.GeneralChainConfig
 	nonce     *big.Int
+	txSigner  signer.Signer
 
 	engine            engine.ESCEngine
 	updateArbitersABI abi.ABI
@@ -108,6 +110,21 @@ func (h *headerNumber) UnmarshalJSON(input []byte) error {
 	return nil
 }
 
+// BlockHash returns the hash of the block number of the current chain
+func (c *EVMClient) BlockHash(number *big.Int) (common.Hash, error) {
+	var head *struct {
+		Hash common.Hash `json:"hash"`
+	}
+	err := c.rpClient.CallContext(context.Background(), &head, "eth_getBlockByNumber", toBlockNumArg(number), false)
+	if err == nil && head == nil {
+		err = ethereum.NotFound
+	}
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return head.Hash, nil
+}
+
 // LatestBlock returns the latest block from the current chain
 func (c *EVMClient) LatestBlock() (*big.Int, error) {
 	var head *headerNumber
@@ -139,7 +156,16 @@ func (c *EVMClient) Engine() engine.ESCEngine {
 	return c.engine
 }
 
+// SetSigner makes the client sign its transactions with s instead of the
+// keystore account.
+func (c *EVMClient) SetSigner(s signer.Signer) {
+	c.txSigner = s
+}
+
 func (c *EVMClient) GetClientAddress() common.Address {
+	if c.txSigner != nil {
+		return c.txSigner.Address()
+	}
 	return common.HexToAddress(c.config.Kp.Address())
 }
 
@@ -184,6 +210,25 @@ func (c *EVMClient) SignAndSendTransaction(ctx context.Context, tx CommonTransac
 	return tx.Hash(), nil
 }
 
+// SignTransaction signs tx with the account of the client
+func (c *EVMClient) SignTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if c.txSigner != nil {
+		id, err := c.ChainID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return c.txSigner.SignTx(tx, id)
+	}
+	if c.config.Kp == nil {
+		return nil, errors.New("account is nil")
+	}
+	id, err := c.ChainID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return types.SignTx(tx, types.NewEIP155Signer(id), c.config.Kp.PrivateKey())
+}
+
 func (c *EVMClient) LockNonce() {
 	c.nonceLock.Lock()
 }
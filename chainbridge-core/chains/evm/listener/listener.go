@@ -6,6 +6,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/blockstore"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
@@ -24,6 +25,7 @@ type SetArbitersEvent struct {
 
 type ChainClient interface {
 	LatestBlock() (*big.Int, error)
+	BlockHash(number *big.Int) (common.Hash, error)
 	FetchUpdateArbitersLogs(ctx context.Context, contractAddress common.Address, startBlock *big.Int, endBlock *big.Int) ([]*relayer.SetArbiterListMsg, error)
 	CallContract(ctx context.Context, callArgs map[string]interface{}, blockNumber *big.Int) ([]byte, error)
 }
@@ -32,68 +34,159 @@ type EVMListener struct {
 	chainReader   ChainClient
 	bridgeAddress common.Address
 	opsConfig     *config.OpsConfig
+	blockDelay    *big.Int
+	maxRange      uint64
+	rescanBlocks  *big.Int
 }
 
 func NewEVMListener(chainReader ChainClient, opsConfig *config.OpsConfig) *EVMListener {
 	listener := &EVMListener{chainReader: chainReader, opsConfig: opsConfig}
 	listener.bridgeAddress = common.HexToAddress(opsConfig.Bridge)
+	listener.blockDelay = BlockDelay
 	if opsConfig.BlockConfirmations > 0 {
-		BlockDelay = big.NewInt(opsConfig.BlockConfirmations)
+		listener.blockDelay = big.NewInt(opsConfig.BlockConfirmations)
+	}
+	listener.maxRange = opsConfig.BlockRange
+	if listener.maxRange == 0 {
+		listener.maxRange = config.DefaultBlockRange
+	}
+	listener.rescanBlocks = new(big.Int).SetUint64(opsConfig.ReorgRescanBlocks)
+	if opsConfig.ReorgRescanBlocks == 0 {
+		listener.rescanBlocks.SetUint64(config.DefaultReorgRescanBlocks)
 	}
 
 	return listener
 }
 
+// ListenToEvents fetches the SetArbiterList logs from startBlock on until
+// stop is closed. Logs are fetched in block ranges ending at least the
+// confirmation count below the head, and the range is halved while the node
+// returns too many results. The last block of a range is stored once all of
+// its logs are delivered. If that block is reorganised away, the listener
+// rescans from the configured reorg rescan blocks before it.
 func (l *EVMListener) ListenToEvents(startBlock *big.Int, chainID uint64,
-	kvrw blockstore.KeyValueWriter,
+	kvrw blockstore.KeyValueReaderWriter, stop <-chan struct{},
 	errChn chan<- error) <-chan *relayer.SetArbiterListMsg {
 	ch := make(chan *relayer.SetArbiterListMsg)
 	go func() {
+		wait := func() bool {
+			select {
+			case <-time.After(BlockRetryInterval):
+				return true
+			case <-stop:
+				return false
+			}
+		}
+		startBlock = new(big.Int).Set(startBlock)
+		blockRange := l.maxRange
+
+		// The last processed block and its hash, the stored ones are only
+		// used if polling resumes right after them.
+		var lastBlock *big.Int
+		var lastHash common.Hash
+		if stored, err := blockstore.GetLastStoredBlock(kvrw, chainID); err == nil && stored.Sign() > 0 &&
+			new(big.Int).Add(stored, big.NewInt(1)).Cmp(startBlock) == 0 {
+			if hash, err := blockstore.GetLastStoredBlockHash(kvrw, chainID); err == nil && hash != (common.Hash{}) {
+				lastBlock, lastHash = stored, hash
+			}
+		}
 		for {
 			select {
+			case <-stop:
+				return
 			default:
-				head, err := l.chainReader.LatestBlock()
+			}
+			head, err := l.chainReader.LatestBlock()
+			if err != nil {
+				if !wait() {
+					return
+				}
+				continue
+			}
+			// Sleep if the difference is less than the confirmations; (latest - current) < blockDelay
+			endBlock := new(big.Int).Sub(head, l.blockDelay)
+			if endBlock.Cmp(startBlock) == -1 {
+				if !wait() {
+					return
+				}
+				continue
+			}
+			if lastBlock != nil {
+				hash, err := l.chainReader.BlockHash(lastBlock)
 				if err != nil {
-					time.Sleep(BlockRetryInterval)
+					if !wait() {
+						return
+					}
 					continue
 				}
-				// Sleep if the difference is less than BlockDelay; (latest - current) < BlockDelay
-				if big.NewInt(0).Sub(head, startBlock).Cmp(BlockDelay) == -1 {
-					time.Sleep(BlockRetryInterval)
+				if hash != lastHash {
+					startBlock = new(big.Int).Sub(lastBlock, l.rescanBlocks)
+					if startBlock.Sign() < 0 {
+						startBlock.SetUint64(0)
+					}
+					bridgelog.Warn("Processed block reorganised, rescan", "block", lastBlock, "from", startBlock, "chainId", chainID)
+					lastBlock, lastHash = nil, common.Hash{}
 					continue
 				}
-				err = l.fetchUpdateArbitersMsg(startBlock, chainID, ch)
-				if err != nil {
-					errChn <- err
+			}
+			if limit := new(big.Int).Add(startBlock, new(big.Int).SetUint64(blockRange-1)); endBlock.Cmp(limit) == 1 {
+				endBlock = limit
+			}
+			logs, err := l.chainReader.FetchUpdateArbitersLogs(context.Background(), l.bridgeAddress, startBlock, endBlock)
+			if err != nil {
+				if isTooManyResults(err) && blockRange > 1 {
+					blockRange /= 2
+					bridgelog.Warn("Too many logs in range, shrink it", "startBlock", startBlock, "range", blockRange, "chainId", chainID)
+					continue
+				}
+				errChn <- err
+				return
+			}
+			endHash, err := l.chainReader.BlockHash(endBlock)
+			if err != nil {
+				if !wait() {
 					return
 				}
-
-				if startBlock.Int64()%20 == 0 {
-					// Logging process every 20 bocks to exclude spam
-					bridgelog.Info("Queried block for deposit events", "block", startBlock.String(), "chainId", chainID)
+				continue
+			}
+			for _, eventLog := range logs {
+				select {
+				case ch <- eventLog:
+				case <-stop:
+					return
 				}
-				//Write to block store. Not a critical operation, no need to retry
-				err = blockstore.StoreBlock(kvrw, startBlock, chainID)
-				if err != nil {
-					bridgelog.Error("Failed to write latest block to blockstore", "block", startBlock.String())
+				bridgelog.Info(fmt.Sprintf("Resolved message %+v in blocks %s-%s", eventLog, startBlock.String(), endBlock.String()))
+			}
+			bridgelog.Info("Queried blocks for arbiter events", "startBlock", startBlock, "endBlock", endBlock, "chainId", chainID)
+
+			//Write to block store. Not a critical operation, no need to retry
+			if err = blockstore.StoreBlock(kvrw, endBlock, chainID); err != nil {
+				bridgelog.Error("Failed to write latest block to blockstore", "block", endBlock.String())
+			} else if err = blockstore.StoreBlockHash(kvrw, endHash, chainID); err != nil {
+				bridgelog.Error("Failed to write latest block hash to blockstore", "block", endBlock.String())
+			}
+			lastBlock, lastHash = endBlock, endHash
+			if blockRange < l.maxRange {
+				blockRange = blockRange * 2
+				if blockRange > l.maxRange {
+					blockRange = l.maxRange
 				}
-				// Goto next block
-				startBlock.Add(startBlock, big.NewInt(1))
 			}
+			// Goto next range
+			startBlock = new(big.Int).Add(endBlock, big.NewInt(1))
 		}
 	}()
 	return ch
 }
 
-func (l *EVMListener) fetchUpdateArbitersMsg(startBlock *big.Int, chainID uint64, msg chan *relayer.SetArbiterListMsg) error {
-	bridgelog.Info("FetchUpdateArbitersLogs", "startBlock", startBlock.Uint64(), "chainID", chainID)
-	logs, err := l.chainReader.FetchUpdateArbitersLogs(context.Background(), l.bridgeAddress, startBlock, startBlock)
-	if err != nil {
-		return err
-	}
-	for _, eventLog := range logs {
-		msg <- eventLog
-		bridgelog.Info(fmt.Sprintf("Resolved message %+v in block %s", eventLog, startBlock.String()))
+// isTooManyResults reports whether a log query failed because its block
+// range holds more logs than the node returns at once.
+func isTooManyResults(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"too many", "more than", "limit exceeded", "response size", "block range"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,183 @@
+package listener
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/blockstore"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+const testChainID = 2
+
+type testClient struct {
+	mu       sync.Mutex
+	head     uint64
+	fork     byte             // Changes the hashes of the blocks, as a reorg does
+	events   map[uint64]int64 // Arbiter count set at a block
+	maxLogs  int              // Queries matching more logs fail
+	queries  [][2]uint64
+	rejected int
+}
+
+func (c *testClient) LatestBlock() (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return new(big.Int).SetUint64(c.head), nil
+}
+
+func (c *testClient) BlockHash(number *big.Int) (common.Hash, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return common.BytesToHash([]byte{c.fork, byte(number.Uint64() >> 8), byte(number.Uint64())}), nil
+}
+
+func (c *testClient) FetchUpdateArbitersLogs(ctx context.Context, contractAddress common.Address, startBlock *big.Int, endBlock *big.Int) ([]*relayer.SetArbiterListMsg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var logs []*relayer.SetArbiterListMsg
+	for n := startBlock.Uint64(); n <= endBlock.Uint64(); n++ {
+		if count, ok := c.events[n]; ok {
+			logs = append(logs, &relayer.SetArbiterListMsg{AddressCount: big.NewInt(count)})
+		}
+	}
+	if c.maxLogs > 0 && len(logs) > c.maxLogs {
+		c.rejected++
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	c.queries = append(c.queries, [2]uint64{startBlock.Uint64(), endBlock.Uint64()})
+	return logs, nil
+}
+
+func (c *testClient) CallContract(ctx context.Context, callArgs map[string]interface{}, blockNumber *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+type testStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *testStore) GetByKey(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[string(key)]
+	if !ok {
+		return nil, leveldb.ErrNotFound
+	}
+	return v, nil
+}
+
+func (s *testStore) SetByKey(key []byte, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[string(key)] = value
+	return nil
+}
+
+func TestMain(m *testing.M) {
+	BlockRetryInterval = time.Millisecond
+	os.Exit(m.Run())
+}
+
+func receive(t *testing.T, ch <-chan *relayer.SetArbiterListMsg) int64 {
+	select {
+	case m := <-ch:
+		return m.AddressCount.Int64()
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return 0
+	}
+}
+
+func storedBlock(t *testing.T, store *testStore) uint64 {
+	block, err := blockstore.GetLastStoredBlock(store, testChainID)
+	assert.NoError(t, err)
+	return block.Uint64()
+}
+
+func TestListenToEventsRanges(t *testing.T) {
+	client := &testClient{head: 1000, events: map[uint64]int64{5: 1, 200: 2, 201: 3, 400: 4}, maxLogs: 2}
+	store := &testStore{data: make(map[string][]byte)}
+	l := NewEVMListener(client, &config.OpsConfig{BlockConfirmations: 10, BlockRange: 300})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	ch := l.ListenToEvents(big.NewInt(1), testChainID, store, stop, make(chan error, 1))
+	for i := int64(1); i <= 4; i++ {
+		assert.Equal(t, i, receive(t, ch))
+	}
+	assert.Eventually(t, func() bool { return storedBlock(t, store) == 990 }, time.Second, time.Millisecond)
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	// Ranges holding too many logs are shrunk, later ones grow back
+	assert.Equal(t, 2, client.rejected)
+	assert.Equal(t, [2]uint64{1, 150}, client.queries[0])
+	assert.Equal(t, [2]uint64{151, 300}, client.queries[1])
+	assert.Equal(t, [2]uint64{301, 600}, client.queries[2])
+	assert.Equal(t, uint64(990), client.queries[len(client.queries)-1][1])
+}
+
+func TestListenToEventsReorg(t *testing.T) {
+	client := &testClient{head: 110, events: map[uint64]int64{95: 1}}
+	store := &testStore{data: make(map[string][]byte)}
+	l := NewEVMListener(client, &config.OpsConfig{BlockConfirmations: 10})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	ch := l.ListenToEvents(big.NewInt(1), testChainID, store, stop, make(chan error, 1))
+	assert.Equal(t, int64(1), receive(t, ch))
+	assert.Eventually(t, func() bool { return storedBlock(t, store) == 100 }, time.Second, time.Millisecond)
+
+	// The processed blocks are replaced by a reorg deeper than the
+	// confirmations, the listener rescans them
+	client.mu.Lock()
+	client.fork, client.head = 1, 120
+	client.events = map[uint64]int64{98: 2}
+	client.mu.Unlock()
+	assert.Equal(t, int64(2), receive(t, ch))
+	assert.Eventually(t, func() bool { return storedBlock(t, store) == 110 }, time.Second, time.Millisecond)
+	hash, err := blockstore.GetLastStoredBlockHash(store, testChainID)
+	assert.NoError(t, err)
+	expected, _ := client.BlockHash(big.NewInt(110))
+	assert.Equal(t, expected, hash)
+}
+
+func TestListenToEventsResume(t *testing.T) {
+	client := &testClient{head: 111, events: map[uint64]int64{100: 1, 101: 2}}
+	store := &testStore{data: make(map[string][]byte)}
+	hash, _ := client.BlockHash(big.NewInt(100))
+	assert.NoError(t, blockstore.StoreBlock(store, big.NewInt(100), testChainID))
+	assert.NoError(t, blockstore.StoreBlockHash(store, hash, testChainID))
+
+	// Polling resumes after the stored block, its events are not relayed again
+	start, err := blockstore.SetupBlockstore(&config.GeneralChainConfig{Id: testChainID}, store, big.NewInt(1))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(101), start.Uint64())
+	l := NewEVMListener(client, &config.OpsConfig{BlockConfirmations: 10, ReorgRescanBlocks: 5})
+	stop := make(chan struct{})
+	defer close(stop)
+	ch := l.ListenToEvents(start, testChainID, store, stop, make(chan error, 1))
+	assert.Equal(t, int64(2), receive(t, ch))
+	assert.Eventually(t, func() bool { return storedBlock(t, store) == 101 }, time.Second, time.Millisecond)
+
+	// The configured number of blocks is rescanned on a reorg
+	client.mu.Lock()
+	client.fork, client.head = 1, 115
+	client.events = map[uint64]int64{97: 3}
+	client.mu.Unlock()
+	assert.Equal(t, int64(3), receive(t, ch))
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	assert.Contains(t, client.queries, [2]uint64{96, 105})
+}
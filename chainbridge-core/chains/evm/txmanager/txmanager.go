@@ -0,0 +1,362 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package txmanager
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+)
+
+var (
+	// ReceiptPollInterval is how often the receipts of pending transactions
+	// are queried.
+	ReceiptPollInterval = time.Second * 5
+
+	// ReplaceInterval is how long a transaction stays pending before it is
+	// replaced with a higher gas price.
+	ReplaceInterval = time.Minute
+
+	// ReceiptTimeout is how long a submission waits for a receipt before it
+	// is given up.
+	ReceiptTimeout = time.Minute * 10
+
+	// GasPriceBump is the percentage of the previous gas price a replacement
+	// transaction pays.
+	GasPriceBump = big.NewInt(125)
+
+	// SendAttempts is how many times a transaction rejected as underpriced or
+	// with a wrong nonce is sent before the error is returned.
+	SendAttempts = 3
+
+	// HistoryLimit is how many finished submissions are kept.
+	HistoryLimit = 32
+)
+
+type Client interface {
+	GetClientAddress() common.Address
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	SignTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+type pendingTx struct {
+	sub      *relayer.Submission
+	to       common.Address
+	gasLimit uint64
+	data     []byte
+	lastSent time.Time
+}
+
+// TxManager sends the transactions of a chain account and follows them
+// until they are included. Pending transactions are replaced with a higher
+// gas price, and resent if the node drops them or holds them back behind a
+// nonce gap. The nonce is tracked locally and resynced from the chain on
+// errors.
+type TxManager struct {
+	client          Client
+	maxGasPrice     *big.Int
+	replaceInterval time.Duration
+
+	mu       sync.Mutex
+	nonce    uint64
+	synced   bool
+	pending  []*pendingTx
+	history  []relayer.Submission
+	tracking bool
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewTxManager(client Client, maxGasPrice *big.Int) *TxManager {
+	return &TxManager{
+		client:          client,
+		maxGasPrice:     maxGasPrice,
+		replaceInterval: ReplaceInterval,
+		quit:            make(chan struct{}),
+	}
+}
+
+// Stop stops following the pending transactions.
+func (m *TxManager) Stop() {
+	m.stopOnce.Do(func() { close(m.quit) })
+}
+
+// Send sends a transaction calling method of the contract to, and returns
+// once the node accepted it. The receipt is waited for in the background.
+func (m *TxManager) Send(method string, to common.Address, gasLimit uint64, gasPrice *big.Int, data []byte) (common.Hash, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.pending {
+		if p.to == to && string(p.data) == string(data) {
+			bridgelog.Info("transaction is pending", "method", method, "hash", p.sub.Hashes[len(p.sub.Hashes)-1])
+			return p.sub.Hashes[len(p.sub.Hashes)-1], nil
+		}
+	}
+
+	var err error
+	for i := 0; i < SendAttempts; i++ {
+		var nonce uint64
+		if nonce, err = m.nextNonce(); err != nil {
+			return common.Hash{}, err
+		}
+		var tx *types.Transaction
+		if tx, err = m.send(nonce, to, gasLimit, gasPrice, data); err == nil {
+			m.nonce = nonce + 1
+			p := &pendingTx{
+				sub: &relayer.Submission{
+					Method:   method,
+					Nonce:    nonce,
+					GasPrice: gasPrice,
+					Hashes:   []common.Hash{tx.Hash()},
+					SentAt:   time.Now(),
+					Status:   relayer.SubmissionPending,
+				},
+				to:       to,
+				gasLimit: gasLimit,
+				data:     data,
+				lastSent: time.Now(),
+			}
+			m.pending = append(m.pending, p)
+			if !m.tracking {
+				m.tracking = true
+				go m.track()
+			}
+			bridgelog.Info("transaction sent", "method", method, "hash", tx.Hash(), "nonce", nonce, "gasPrice", gasPrice)
+			return tx.Hash(), nil
+		}
+		m.synced = false
+		if isUnderpriced(err) {
+			bumped, ok := m.bumpGasPrice(gasPrice)
+			if !ok {
+				break
+			}
+			gasPrice = bumped
+		} else if !isNonceError(err) {
+			break
+		}
+		bridgelog.Warn("send transaction failed, retry", "method", method, "nonce", nonce, "gasPrice", gasPrice, "error", err)
+	}
+	return common.Hash{}, err
+}
+
+// Submissions returns the finished submissions followed by the pending ones,
+// oldest first.
+func (m *TxManager) Submissions() []relayer.Submission {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subs := make([]relayer.Submission, 0, len(m.history)+len(m.pending))
+	subs = append(subs, m.history...)
+	for _, p := range m.pending {
+		sub := *p.sub
+		sub.Hashes = append([]common.Hash{}, sub.Hashes...)
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+func (m *TxManager) nextNonce() (uint64, error) {
+	if !m.synced {
+		nonce, err := m.client.PendingNonceAt(context.Background(), m.client.GetClientAddress())
+		if err != nil {
+			return 0, err
+		}
+		m.nonce, m.synced = nonce, true
+	}
+	return m.nonce, nil
+}
+
+func (m *TxManager) send(nonce uint64, to common.Address, gasLimit uint64, gasPrice *big.Int, data []byte) (*types.Transaction, error) {
+	tx := types.NewTransaction(nonce, to, big.NewInt(0), gasLimit, gasPrice, data)
+	tx, err := m.client.SignTransaction(context.Background(), tx)
+	if err != nil {
+		return nil, err
+	}
+	return tx, m.client.SendTransaction(context.Background(), tx)
+}
+
+// bumpGasPrice returns gasPrice raised by GasPriceBump, capped by the max gas
+// price. It returns false if the gas price can't be raised.
+func (m *TxManager) bumpGasPrice(gasPrice *big.Int) (*big.Int, bool) {
+	bumped := new(big.Int).Mul(gasPrice, GasPriceBump)
+	bumped.Div(bumped, big.NewInt(100))
+	if bumped.Cmp(gasPrice) <= 0 {
+		bumped.Add(gasPrice, big.NewInt(1))
+	}
+	if bumped.Cmp(m.maxGasPrice) > 0 {
+		bumped = new(big.Int).Set(m.maxGasPrice)
+	}
+	return bumped, bumped.Cmp(gasPrice) > 0
+}
+
+func (m *TxManager) track() {
+	for {
+		select {
+		case <-time.After(ReceiptPollInterval):
+		case <-m.quit:
+			m.mu.Lock()
+			m.tracking = false
+			m.mu.Unlock()
+			return
+		}
+		m.check()
+		m.mu.Lock()
+		if len(m.pending) == 0 {
+			m.tracking = false
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+	}
+}
+
+// copy returns a copy of p the node can be queried with without the lock.
+func (p *pendingTx) copy() *pendingTx {
+	cpy, sub := *p, *p.sub
+	sub.Hashes = append([]common.Hash{}, sub.Hashes...)
+	cpy.sub = &sub
+	return &cpy
+}
+
+// check finishes the pending transactions with a receipt or waiting for one
+// too long, and replaces or resends the others. The node is queried without
+// the lock on copies of the pending transactions, which are applied once it
+// answered.
+func (m *TxManager) check() {
+	type checkedTx struct {
+		pending, checked *pendingTx
+	}
+	m.mu.Lock()
+	txs := make([]checkedTx, len(m.pending))
+	for i, p := range m.pending {
+		txs[i] = checkedTx{p, p.copy()}
+	}
+	m.mu.Unlock()
+
+	chainNonce, err := m.client.PendingNonceAt(context.Background(), m.client.GetClientAddress())
+	if err != nil {
+		bridgelog.Error("get pending nonce failed", "error", err)
+		return
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].checked.sub.Nonce < txs[j].checked.sub.Nonce
+	})
+	resync := false
+	for _, tx := range txs {
+		p := tx.checked
+		if receipt := m.receipt(p); receipt != nil {
+			p.sub.Block = receipt.BlockNumber.Uint64()
+			if receipt.Status == types.ReceiptStatusFailed {
+				p.sub.Status, p.sub.Error = relayer.SubmissionReverted, "execution reverted"
+			} else {
+				p.sub.Status = relayer.SubmissionIncluded
+			}
+			continue
+		}
+		if time.Since(p.sub.SentAt) > ReceiptTimeout {
+			p.sub.Status, p.sub.Error = relayer.SubmissionTimeout, "no receipt in time"
+			resync = true
+			continue
+		}
+		if p.sub.Nonce >= chainNonce {
+			// The node dropped it or holds it back behind a nonce gap
+			if m.resend(p, chainNonce, p.sub.GasPrice) == nil {
+				chainNonce++
+			}
+			resync = true
+		} else if time.Since(p.lastSent) > m.replaceInterval {
+			if bumped, ok := m.bumpGasPrice(p.sub.GasPrice); ok {
+				if err := m.resend(p, p.sub.Nonce, bumped); err != nil && isNonceError(err) {
+					resync = true
+				}
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if resync {
+		m.synced = false
+	}
+	finished := make(map[*pendingTx]bool)
+	for _, tx := range txs {
+		if tx.checked.sub.Status != relayer.SubmissionPending {
+			m.finish(tx.checked)
+			finished[tx.pending] = true
+			continue
+		}
+		*tx.pending.sub = *tx.checked.sub
+		tx.pending.lastSent = tx.checked.lastSent
+	}
+	// Transactions sent while the node was queried stay pending
+	pending := make([]*pendingTx, 0, len(m.pending))
+	for _, p := range m.pending {
+		if !finished[p] {
+			pending = append(pending, p)
+		}
+	}
+	m.pending = pending
+}
+
+func (m *TxManager) receipt(p *pendingTx) *types.Receipt {
+	for _, hash := range p.sub.Hashes {
+		receipt, err := m.client.TransactionReceipt(context.Background(), hash)
+		if err == nil && receipt != nil {
+			return receipt
+		}
+	}
+	return nil
+}
+
+// resend sends p again with nonce and gasPrice, it returns nil if the node
+// accepted it or already knows it.
+func (m *TxManager) resend(p *pendingTx, nonce uint64, gasPrice *big.Int) error {
+	tx, err := m.send(nonce, p.to, p.gasLimit, gasPrice, p.data)
+	if err != nil && !isKnown(err) {
+		bridgelog.Warn("resend transaction failed", "method", p.sub.Method, "nonce", nonce, "gasPrice", gasPrice, "error", err)
+		return err
+	}
+	if hash := tx.Hash(); hash != p.sub.Hashes[len(p.sub.Hashes)-1] {
+		p.sub.Hashes = append(p.sub.Hashes, hash)
+		bridgelog.Info("transaction replaced", "method", p.sub.Method, "hash", hash, "nonce", nonce, "gasPrice", gasPrice)
+	}
+	p.sub.Nonce, p.sub.GasPrice, p.lastSent = nonce, gasPrice, time.Now()
+	return nil
+}
+
+// finish moves p with its final status to the history.
+func (m *TxManager) finish(p *pendingTx) {
+	m.history = append(m.history, *p.sub)
+	if len(m.history) > HistoryLimit {
+		m.history = m.history[len(m.history)-HistoryLimit:]
+	}
+	hash := p.sub.Hashes[len(p.sub.Hashes)-1]
+	if p.sub.Status == relayer.SubmissionIncluded {
+		bridgelog.Info("transaction included", "method", p.sub.Method, "hash", hash, "block", p.sub.Block)
+	} else {
+		bridgelog.Error("transaction failed", "method", p.sub.Method, "hash", hash, "status", p.sub.Status, "error", p.sub.Error)
+	}
+}
+
+func isUnderpriced(err error) bool {
+	return strings.Contains(err.Error(), "underpriced")
+}
+
+func isNonceError(err error) bool {
+	return strings.Contains(err.Error(), "nonce too low") || strings.Contains(err.Error(), "nonce too high")
+}
+
+func isKnown(err error) bool {
+	return strings.Contains(err.Error(), "already known") || strings.Contains(err.Error(), "known transaction")
+}
@@ -0,0 +1,242 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package txmanager
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type testClient struct {
+	mu       sync.Mutex
+	nonce    uint64 // Pending nonce of the node
+	pool     map[uint64]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+	sendErrs []error // Returned by the next sends
+	slow     chan struct{}
+}
+
+func newTestClient() *testClient {
+	return &testClient{pool: make(map[uint64]*types.Transaction), receipts: make(map[common.Hash]*types.Receipt)}
+}
+
+func (c *testClient) GetClientAddress() common.Address { return common.HexToAddress("0x01") }
+
+func (c *testClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nonce, nil
+}
+
+func (c *testClient) SignTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	return tx, nil
+}
+
+func (c *testClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sendErrs) > 0 {
+		err := c.sendErrs[0]
+		c.sendErrs = c.sendErrs[1:]
+		return err
+	}
+	if tx.Nonce() != c.nonce {
+		if old := c.pool[tx.Nonce()]; old == nil || old.GasPrice().Cmp(tx.GasPrice()) >= 0 {
+			return errors.New("replacement transaction underpriced")
+		}
+	}
+	c.pool[tx.Nonce()] = tx
+	if tx.Nonce() == c.nonce {
+		c.nonce++
+	}
+	return nil
+}
+
+func (c *testClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	slow := c.slow
+	c.mu.Unlock()
+	if slow != nil {
+		<-slow
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if receipt := c.receipts[txHash]; receipt != nil {
+		return receipt, nil
+	}
+	return nil, ethereum.NotFound
+}
+
+// mine includes the pooled transaction with nonce.
+func (c *testClient) mine(nonce uint64, status uint64) common.Hash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx := c.pool[nonce]
+	c.receipts[tx.Hash()] = &types.Receipt{Status: status, BlockNumber: big.NewInt(int64(nonce) + 100)}
+	return tx.Hash()
+}
+
+// drop removes the pooled transactions from nonce on.
+func (c *testClient) drop(nonce uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for n := range c.pool {
+		if n >= nonce {
+			delete(c.pool, n)
+		}
+	}
+	c.nonce = nonce
+}
+
+func (c *testClient) failNext(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendErrs = append(c.sendErrs, err)
+}
+
+func TestMain(m *testing.M) {
+	ReceiptPollInterval = time.Millisecond
+	os.Exit(m.Run())
+}
+
+func waitSubmissions(t *testing.T, m *TxManager, done func([]relayer.Submission) bool) []relayer.Submission {
+	var subs []relayer.Submission
+	assert.Eventually(t, func() bool {
+		subs = m.Submissions()
+		return done(subs)
+	}, time.Second, time.Millisecond)
+	return subs
+}
+
+func TestTxManagerIncluded(t *testing.T) {
+	client := newTestClient()
+	m := NewTxManager(client, big.NewInt(1000))
+	defer m.Stop()
+	to := common.HexToAddress("0x02")
+
+	hash, err := m.Send("setArbiterList", to, 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+	// The same call is not sent twice while pending
+	again, err := m.Send("setArbiterList", to, 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, hash, again)
+	_, err = m.Send("setChainStatus", to, 21000, big.NewInt(100), []byte{2})
+	assert.NoError(t, err)
+
+	assert.Equal(t, hash, client.mine(0, types.ReceiptStatusSuccessful))
+	client.mine(1, types.ReceiptStatusFailed)
+	subs := waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return subs[0].Status != relayer.SubmissionPending && subs[1].Status != relayer.SubmissionPending
+	})
+	assert.Equal(t, relayer.SubmissionIncluded, subs[0].Status)
+	assert.Equal(t, uint64(100), subs[0].Block)
+	assert.Equal(t, relayer.SubmissionReverted, subs[1].Status)
+	assert.Equal(t, "execution reverted", subs[1].Error)
+}
+
+func TestTxManagerReplace(t *testing.T) {
+	client := newTestClient()
+	m := NewTxManager(client, big.NewInt(150))
+	defer m.Stop()
+	m.replaceInterval = 0
+	_, err := m.Send("setArbiterList", common.HexToAddress("0x02"), 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+
+	// The stuck transaction is replaced up to the max gas price
+	subs := waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return subs[0].GasPrice.Cmp(big.NewInt(150)) == 0
+	})
+	assert.Len(t, subs[0].Hashes, 3)
+	assert.Equal(t, subs[0].Hashes[2], client.mine(0, types.ReceiptStatusSuccessful))
+	waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return subs[0].Status == relayer.SubmissionIncluded
+	})
+}
+
+func TestTxManagerNonce(t *testing.T) {
+	client := newTestClient()
+	m := NewTxManager(client, big.NewInt(1000))
+	defer m.Stop()
+	to := common.HexToAddress("0x02")
+
+	// The nonce is resynced after a nonce error
+	client.nonce = 5
+	m.nonce, m.synced = 3, true
+	client.failNext(errors.New("nonce too low"))
+	_, err := m.Send("setArbiterList", to, 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), m.Submissions()[0].Nonce)
+
+	// A dropped transaction is resent at the nonce of the chain
+	client.drop(4)
+	waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return subs[0].Nonce == 4
+	})
+	client.mine(4, types.ReceiptStatusSuccessful)
+	waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return subs[0].Status == relayer.SubmissionIncluded
+	})
+
+	// Other errors are returned
+	client.failNext(errors.New("insufficient funds"))
+	_, err = m.Send("setArbiterList", to, 21000, big.NewInt(100), []byte{2})
+	assert.EqualError(t, err, "insufficient funds")
+}
+
+func TestTxManagerSlowNode(t *testing.T) {
+	client := newTestClient()
+	slow := make(chan struct{})
+	client.slow = slow
+	m := NewTxManager(client, big.NewInt(1000))
+	defer m.Stop()
+	to := common.HexToAddress("0x02")
+	_, err := m.Send("setArbiterList", to, 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+
+	// Sends are not held back while the receipts are queried
+	time.Sleep(10 * time.Millisecond)
+	sent := make(chan error)
+	go func() {
+		_, err := m.Send("setChainStatus", to, 21000, big.NewInt(100), []byte{2})
+		sent <- err
+	}()
+	select {
+	case err := <-sent:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("send blocked by the receipt query")
+	}
+	client.mine(0, types.ReceiptStatusSuccessful)
+	close(slow)
+	subs := waitSubmissions(t, m, func(subs []relayer.Submission) bool {
+		return len(subs) == 2 && subs[0].Status == relayer.SubmissionIncluded
+	})
+	assert.Equal(t, relayer.SubmissionPending, subs[1].Status)
+}
+
+func TestTxManagerStop(t *testing.T) {
+	client := newTestClient()
+	m := NewTxManager(client, big.NewInt(1000))
+	_, err := m.Send("setArbiterList", common.HexToAddress("0x02"), 21000, big.NewInt(100), []byte{1})
+	assert.NoError(t, err)
+
+	m.Stop()
+	assert.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return !m.tracking
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, relayer.SubmissionPending, m.Submissions()[0].Status)
+}
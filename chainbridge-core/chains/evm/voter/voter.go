@@ -10,34 +10,33 @@ import (
 	"math/big"
 
 	"github.com/pgprotocol/pgp-chain"
-	"github.com/pgprotocol/pgp-chain/accounts"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/evmclient"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/evmtransaction"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/chains/evm/txmanager"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/config"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/engine"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/relayer"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/chainbridge_abi"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/common/hexutil"
 	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
 	"github.com/pgprotocol/pgp-chain/crypto"
 	"github.com/pgprotocol/pgp-chain/internal/ethapi"
 	"github.com/pgprotocol/pgp-chain/log"
 )
 
 type ChainClient interface {
+	txmanager.Client
 	LatestBlock() (*big.Int, error)
 	CurrentBlock() (*types.Block, error)
-	SignAndSendTransaction(ctx context.Context, tx evmclient.CommonTransaction) (common.Hash, error)
 	CallContract(ctx context.Context, callArgs map[string]interface{}, blockNumber *big.Int) ([]byte, error)
-	GetNonce() (*big.Int, error)
-	LockNonce()
-	UnlockNonce()
 	GasPrice() (*big.Int, error)
+	GetConfig() *config.GeneralChainConfig
 	EstimateGasLimit(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
 	ChainID(ctx context.Context) (*big.Int, error)
 	Engine() engine.ESCEngine
-	GetClientAddress() common.Address
 	IsContractAddress(address string) bool
 	PendingTransaction() ([]ethapi.RPCTransaction, error)
 }
@@ -45,29 +44,31 @@ type ChainClient interface {
 type EVMVoter struct {
 	stop    <-chan struct{}
 	client  ChainClient
-	account *secp256k1.Keypair
+	account signer.Signer
+	txs     *txmanager.TxManager
 }
 
-func NewVoter(client ChainClient, arbiterAccount *secp256k1.Keypair) *EVMVoter {
+func NewVoter(client ChainClient, arbiterAccount signer.Signer) *EVMVoter {
+	maxGasPrice := new(big.Int).SetUint64(client.GetConfig().Opts.MaxGasPrice)
 	return &EVMVoter{
 		client:  client,
 		account: arbiterAccount,
+		txs:     txmanager.NewTxManager(client, maxGasPrice),
 	}
 }
 
 func (w *EVMVoter) GetPublicKey() ([]byte, error) {
-	if w.account == nil {
+	if w.account == nil || w.account.PublicKeyBytes() == nil {
 		return nil, errors.New("account is nil")
 	}
-	pbk, err := hexutil.Decode(w.account.PublicKey())
-	return pbk, err
+	return w.account.PublicKeyBytes(), nil
 }
 
 func (w *EVMVoter) GetSignerAddress() (common.Address, error) {
-	if w.account == nil {
+	if w.account == nil || w.account.PublicKeyBytes() == nil {
 		return common.Address{}, errors.New("account is nil")
 	}
-	return w.account.CommonAddress(), nil
+	return w.account.Address(), nil
 }
 
 func (w *EVMVoter) SetArbiterList(arbiters []common.Address, totalCount int, signature [][]byte, bridgeAddress string) error {
@@ -104,18 +105,9 @@ func (w *EVMVoter) SetArbiterList(arbiters []common.Address, totalCount int, sig
 	if gasLimit == 0 {
 		return errors.New("SetArbiterList EstimateGasLimit is 0")
 	}
-	w.client.LockNonce()
-	defer w.client.UnlockNonce()
-	n, err := w.client.GetNonce()
+	hash, err := w.txs.Send("setArbiterList", bridge, gasLimit, gasPrice, input)
 	if err != nil {
-		log.Error("SetArbiterList GetNonce", "error", err)
-		return err
-	}
-
-	tx := evmtransaction.NewTransaction(n.Uint64(), bridge, big.NewInt(0), gasLimit, gasPrice, input)
-	hash, err := w.client.SignAndSendTransaction(context.TODO(), tx)
-	if err != nil {
-		log.Error("SetArbiterList SignAndSendTransaction", "error", err)
+		log.Error("SetArbiterList Send", "error", err)
 		return err
 	}
 	log.Info("SetArbiterList", "error", err, "hash", hash.String())
@@ -156,7 +148,10 @@ func (w *EVMVoter) SetESCState(bridgeAddress string, state uint8) error {
 		return err
 	}
 	khash := crypto.Keccak256(packData)
-	signature := w.SignData(accounts.TextHash(khash))
+	signature, err := w.SignText(khash)
+	if err != nil {
+		return err
+	}
 	input, err := a.Pack("setChainStatus", state, signature)
 	if err != nil {
 		return err
@@ -171,15 +166,8 @@ func (w *EVMVoter) SetESCState(bridgeAddress string, state uint8) error {
 	if gasLimit == 0 {
 		return errors.New("SetESCState EstimateGasLimit is 0")
 	}
-	w.client.LockNonce()
-	defer w.client.UnlockNonce()
-	n, err := w.client.GetNonce()
-	if err != nil {
-		return err
-	}
 	gasLimit = gasLimit * 3
-	tx := evmtransaction.NewTransaction(n.Uint64(), bridge, big.NewInt(0), gasLimit, gasPrice, input)
-	hash, err := w.client.SignAndSendTransaction(context.TODO(), tx)
+	hash, err := w.txs.Send("setChainStatus", bridge, gasLimit, gasPrice, input)
 	if err != nil {
 		return err
 	}
@@ -205,7 +193,10 @@ func (w *EVMVoter) SetManualArbiter(bridgeAddress string, arbiters []common.Addr
 	totalBytes := common.LeftPadBytes(totalCount.Bytes(), 32)
 	data = append(data, totalBytes...)
 	khash := crypto.Keccak256(data)
-	signature := w.SignData(accounts.TextHash(khash))
+	signature, err := w.SignText(khash)
+	if err != nil {
+		return err
+	}
 	input, err := a.Pack("setManualArbiter", arbiters, &totalCount, signature)
 	if err != nil {
 		return err
@@ -220,15 +211,8 @@ func (w *EVMVoter) SetManualArbiter(bridgeAddress string, arbiters []common.Addr
 	if gasLimit == 0 {
 		return errors.New("setManualArbiter EstimateGasLimit is 0")
 	}
-	w.client.LockNonce()
-	defer w.client.UnlockNonce()
-	n, err := w.client.GetNonce()
-	if err != nil {
-		return err
-	}
 	gasLimit = gasLimit * 4
-	tx := evmtransaction.NewTransaction(n.Uint64(), bridge, big.NewInt(0), gasLimit, gasPrice, input)
-	hash, err := w.client.SignAndSendTransaction(context.TODO(), tx)
+	hash, err := w.txs.Send("setManualArbiter", bridge, gasLimit, gasPrice, input)
 	if err != nil {
 		return err
 	}
@@ -236,6 +220,57 @@ func (w *EVMVoter) SetManualArbiter(bridgeAddress string, arbiters []common.Addr
 	return err
 }
 
+// HandoverArbiter submits the handover of the bridge key to the bridge
+// contract, which replaces the old arbiter address by the new one once it
+// checks both signatures. It returns relayer.ErrHandoverUnsupported without
+// sending anything if the code of the contract does not dispatch
+// handoverArbiter.
+func (w *EVMVoter) HandoverArbiter(bridgeAddress string, h *signer.Handover) error {
+	a, err := chainbridge_abi.HandoverArbiterABI()
+	if err != nil {
+		return err
+	}
+	bridge := common.HexToAddress(bridgeAddress)
+	code, err := w.client.CodeAt(context.TODO(), bridge, nil)
+	if err != nil {
+		return err
+	}
+	if !dispatches(code, a.Methods["handoverArbiter"].ID) {
+		return relayer.ErrHandoverUnsupported
+	}
+	gasPrice, err := w.client.GasPrice()
+	if err != nil {
+		return err
+	}
+	input, err := a.Pack("handoverArbiter", h.OldAddress, h.NewAddress, []byte(h.NewPublicKey),
+		new(big.Int).SetUint64(h.Time), []byte(h.OldSignature), []byte(h.NewSignature))
+	if err != nil {
+		return err
+	}
+	from := w.client.GetClientAddress()
+	msg := ethereum.CallMsg{From: from, To: &bridge, Data: input, GasPrice: gasPrice}
+	gasLimit, err := w.client.EstimateGasLimit(context.TODO(), msg)
+	if err != nil {
+		return err
+	}
+	if gasLimit == 0 {
+		return errors.New("handoverArbiter EstimateGasLimit is 0")
+	}
+	gasLimit = gasLimit * 3
+	hash, err := w.txs.Send("handoverArbiter", bridge, gasLimit, gasPrice, input)
+	if err != nil {
+		return err
+	}
+	log.Info("handoverArbiter", "old", h.OldAddress.String(), "new", h.NewAddress.String(), "hash", hash.String(), "gasLimit", gasLimit)
+	return nil
+}
+
+// dispatches returns whether the contract code compares the calldata against
+// the method selector, as the dispatcher of solidity does with a PUSH4 of it.
+func dispatches(code []byte, selector []byte) bool {
+	return bytes.Contains(code, append([]byte{byte(vm.PUSH4)}, selector...))
+}
+
 func (w *EVMVoter) GetSignatures(bridgeAddress string) ([][crypto.SignatureLength]byte, error) {
 	a, err := chainbridge_abi.GetSignaturesABI()
 	if err != nil {
@@ -320,17 +355,27 @@ func (w *EVMVoter) IsDeployedBridgeContract(bridgeAddress string) bool {
 	return w.client.IsContractAddress(bridgeAddress)
 }
 
+// Submissions returns the recent transactions of the voter and their
+// outcome.
+func (w *EVMVoter) Submissions() []relayer.Submission {
+	return w.txs.Submissions()
+}
+
+// Stop stops following the transactions of the voter.
+func (w *EVMVoter) Stop() {
+	w.txs.Stop()
+}
+
 func (w *EVMVoter) GetClient() ChainClient {
 	return w.client
 }
 
-func (w *EVMVoter) SignData(data []byte) []byte {
-	privateKey := w.account.PrivateKey()
-	sign, err := crypto.Sign(data, privateKey)
-	if err != nil {
-		return nil
+// SignText signs text with the bridge arbiter key, as personal_sign does.
+func (w *EVMVoter) SignText(text []byte) ([]byte, error) {
+	if w.account == nil {
+		return nil, errors.New("account is nil")
 	}
-	return sign
+	return w.account.SignText(text)
 }
 
 func toCallArg(msg ethereum.CallMsg) map[string]interface{} {
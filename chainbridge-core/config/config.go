@@ -55,7 +55,8 @@ func (c *GeneralChainConfig) Validate() error {
 }
 
 type BridgeConfig struct {
-	Chains []GeneralChainConfig `json:"chains"`
+	Chains       []GeneralChainConfig `json:"chains"`
+	BridgeSigner string               `json:"bridgeSigner"` // address of the bridge arbiter key on the external signer
 }
 
 func NewConfig() *BridgeConfig {
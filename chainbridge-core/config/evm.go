@@ -11,6 +11,8 @@ const DefaultGasLimit = 7000000
 const DefaultGasPrice = 20000000000
 const DefaultGasMultiplier = 1.1
 const DefaultBlockConfirmations = 10
+const DefaultBlockRange = 1000
+const DefaultReorgRescanBlocks = 64
 
 type OpsConfig struct {
 	Bridge             string  `mapstructure:"bridge"`
@@ -19,6 +21,8 @@ type OpsConfig struct {
 	GasLimit           uint64  `mapstructure:"gasLimit"`
 	StartBlock         uint64  `mapstructure:"startBlock"`
 	BlockConfirmations int64   `mapstructure:"blockConfirmations"`
+	BlockRange         uint64  `mapstructure:"blockRange"`
+	ReorgRescanBlocks  uint64  `mapstructure:"reorgRescanBlocks"` // Blocks rescanned when the last processed one was reorganised away
 }
 
 func (c *OpsConfig) Validate() error {
@@ -61,6 +65,18 @@ func (c *OpsConfig) ParseConfig() (*OpsConfig, error) {
 		config.BlockConfirmations = DefaultBlockConfirmations
 	}
 
+	if c.BlockRange != 0 {
+		config.BlockRange = c.BlockRange
+	} else {
+		config.BlockRange = DefaultBlockRange
+	}
+
+	if c.ReorgRescanBlocks != 0 {
+		config.ReorgRescanBlocks = c.ReorgRescanBlocks
+	} else {
+		config.ReorgRescanBlocks = DefaultReorgRescanBlocks
+	}
+
 	config.StartBlock = c.StartBlock
 
 	return config, nil
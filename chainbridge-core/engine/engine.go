@@ -1,7 +1,7 @@
 package engine
 
 import (
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 	"github.com/elastos/Elastos.ELA/p2p"
@@ -14,7 +14,7 @@ type ESCEngine interface {
 	SignData(data []byte) []byte
 	DecryptArbiter(cipher []byte) (arbiter []byte, err error)
 	GetProducer() []byte
-	GetBridgeArbiters() crypto.Keypair
+	GetBridgeArbiters() signer.Signer
 	GetTotalArbitersCount() int
 	IsSyncFinished() bool
 }
@@ -0,0 +1,217 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package relayer
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
+	"github.com/pgprotocol/pgp-chain/common"
+)
+
+// ErrSameArbiters is returned by WriteArbiters if the chain already holds
+// the arbiters.
+var ErrSameArbiters = errors.New("is same arbiters on contract")
+
+var (
+	// RetryMinInterval is the first delay before a relayed chain retries a
+	// failed write or restarts its listener.
+	RetryMinInterval = time.Second * 5
+
+	// RetryMaxInterval bounds the delay between retries, the delay is
+	// doubled after every failure in a row.
+	RetryMaxInterval = time.Minute * 5
+)
+
+// ChainStatus is the health of a relayed chain.
+type ChainStatus struct {
+	ChainID         uint64
+	IsESC           bool
+	LastPolledBlock uint64
+	PollRestarts    int
+	PollError       string
+
+	ObservedArbiterCount uint64 // Arbiter count of the last SetArbiterList event seen on the chain
+	PendingArbiterCount  int    // Arbiters waiting to be written, zero if the chain caught up
+	LastArbiterCount     int    // Arbiters of the last SetArbiterList written
+	LastWriteTime        time.Time
+	WriteRetries         int
+	WriteError           string
+
+	ESCState      uint8
+	ESCStateError string
+
+	Submissions []Submission // Recent transactions sent to the chain
+}
+
+type arbiterUpdate struct {
+	arbiters   []common.Address
+	signatures [][]byte
+	totalCount int
+}
+
+// chainRunner polls and writes a relayed chain independently of the other
+// chains, so a chain whose RPC fails only delays its own arbiter updates.
+type chainRunner struct {
+	chain  RelayedChain
+	notify chan struct{}
+
+	mu      sync.Mutex
+	pending *arbiterUpdate // Latest arbiters not written yet
+	status  ChainStatus
+}
+
+func newChainRunner(chain RelayedChain, isESC bool) *chainRunner {
+	return &chainRunner{
+		chain:  chain,
+		notify: make(chan struct{}, 1),
+		status: ChainStatus{ChainID: chain.ChainID(), IsESC: isESC},
+	}
+}
+
+func nextRetryInterval(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > RetryMaxInterval {
+		return RetryMaxInterval
+	}
+	return interval
+}
+
+// schedule replaces the arbiters waiting to be written to the chain, a chain
+// behind only catches up with the latest ones.
+func (c *chainRunner) schedule(update *arbiterUpdate) {
+	c.mu.Lock()
+	c.pending = update
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+// recordWrite updates the status after arbiters were written to the chain.
+func (c *chainRunner) recordWrite(arbiterCount int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && !errors.Is(err, ErrSameArbiters) {
+		c.status.WriteRetries++
+		c.status.WriteError = err.Error()
+		return
+	}
+	c.status.LastArbiterCount = arbiterCount
+	c.status.LastWriteTime = time.Now()
+	c.status.WriteRetries = 0
+	c.status.WriteError = ""
+}
+
+// write writes the scheduled arbiters until stop is closed, retrying with
+// backoff while the chain fails. The chain is stopped once it returns.
+func (c *chainRunner) write(stop <-chan struct{}) {
+	defer c.chain.Stop()
+	retry := RetryMinInterval
+	for {
+		select {
+		case <-c.notify:
+		case <-stop:
+			return
+		}
+		for {
+			c.mu.Lock()
+			update := c.pending
+			c.mu.Unlock()
+			if update == nil {
+				break
+			}
+			err := c.chain.WriteArbiters(update.arbiters, update.signatures, update.totalCount)
+			c.recordWrite(len(update.arbiters), err)
+			if err == nil || errors.Is(err, ErrSameArbiters) {
+				c.mu.Lock()
+				if c.pending == update {
+					c.pending = nil
+				}
+				c.mu.Unlock()
+				retry = RetryMinInterval
+				continue
+			}
+			bridgelog.Error("write arbiters error, retry", "chainid", c.chain.ChainID(), "error", err, "retry", retry)
+			select {
+			case <-time.After(retry):
+			case <-c.notify:
+			case <-stop:
+				return
+			}
+			retry = nextRetryInterval(retry)
+		}
+	}
+}
+
+// poll polls the events of the chain until stop is closed, and restarts the
+// listener with backoff if it fails. The listener resumes from the last
+// block in the blockstore of the chain.
+func (c *chainRunner) poll(stop <-chan struct{}, handle func(m *SetArbiterListMsg)) {
+	retry := RetryMinInterval
+	for {
+		sysErr := make(chan error, 1)
+		pollStop := make(chan struct{})
+		events := make(chan *SetArbiterListMsg)
+		started := time.Now()
+		go c.chain.PollEvents(sysErr, pollStop, events)
+
+		var err error
+		for err == nil {
+			select {
+			case m := <-events:
+				c.mu.Lock()
+				if m.AddressCount != nil {
+					c.status.ObservedArbiterCount = m.AddressCount.Uint64()
+				}
+				c.mu.Unlock()
+				handle(m)
+			case err = <-sysErr:
+			case <-stop:
+				close(pollStop)
+				return
+			}
+		}
+		close(pollStop)
+		if time.Since(started) > RetryMaxInterval {
+			retry = RetryMinInterval
+		}
+		c.mu.Lock()
+		c.status.PollRestarts++
+		c.status.PollError = err.Error()
+		c.mu.Unlock()
+		bridgelog.Error("poll events error, restart", "chainid", c.chain.ChainID(), "error", err, "retry", retry)
+		select {
+		case <-time.After(retry):
+		case <-stop:
+			return
+		}
+		retry = nextRetryInterval(retry)
+	}
+}
+
+// Status returns the status of the chain, querying its polled block and ESC
+// state.
+func (c *chainRunner) Status() ChainStatus {
+	c.mu.Lock()
+	status := c.status
+	if c.pending != nil {
+		status.PendingArbiterCount = len(c.pending.arbiters)
+	}
+	c.mu.Unlock()
+
+	if block, err := c.chain.LastPolledBlock(); err == nil {
+		status.LastPolledBlock = block
+	}
+	status.Submissions = c.chain.Submissions()
+	state, err := c.chain.GetESCState()
+	if err != nil {
+		status.ESCStateError = err.Error()
+	}
+	status.ESCState = state
+	return status
+}
@@ -7,13 +7,26 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/bridgelog"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/crypto"
 	"github.com/pgprotocol/pgp-chain/log"
 )
 
+var (
+	errUnknownChain   = errors.New("chain is not relayed")
+	errRelayerStopped = errors.New("relayer is stopped")
+
+	// ErrHandoverUnsupported is returned by HandoverArbiter if the bridge
+	// contract of the chain does not define handoverArbiter.
+	ErrHandoverUnsupported = errors.New("bridge contract does not support handoverArbiter")
+)
+
 type RelayedChain interface {
 	ChainID() uint64
 	WriteArbiters(aribters []common.Address, signatures [][]byte, totalCount int) error
@@ -24,8 +37,12 @@ type RelayedChain interface {
 	SetESCState(state uint8) error
 	GetHashSalt() (*big.Int, error)
 	SetManualArbiters(arbiter []common.Address, totalSigner int) error
+	HandoverArbiter(h *signer.Handover) error
 	GetBridgeContract() string
+	LastPolledBlock() (uint64, error)
+	Submissions() []Submission
 	PollEvents(sysErr chan<- error, stop <-chan struct{}, eventsChan chan *SetArbiterListMsg)
+	Stop()
 }
 
 func NewRelayer(chains []RelayedChain, escChainID uint64) *Relayer {
@@ -33,7 +50,6 @@ func NewRelayer(chains []RelayedChain, escChainID uint64) *Relayer {
 	for _, c := range chains {
 		relayer.addRelayedChain(c)
 	}
-	relayer.errChn = make(chan error)
 	relayer.stopChn = make(chan struct{})
 	return relayer
 }
@@ -41,19 +57,45 @@ func NewRelayer(chains []RelayedChain, escChainID uint64) *Relayer {
 type Relayer struct {
 	relayedChains []RelayedChain
 	registry      map[uint64]RelayedChain
+	runners       map[uint64]*chainRunner
 	escChainID    uint64
-	errChn        chan error
 	stopChn       chan struct{}
+	stopOnce      sync.Once
+
+	// handedOver holds the chains each bridge key handover was submitted to
+	handedOver map[common.Hash]map[uint64]struct{}
+	handoverMu sync.Mutex
 }
 
 func (r *Relayer) addRelayedChain(c RelayedChain) {
 	if r.registry == nil {
 		r.registry = make(map[uint64]RelayedChain)
+		r.runners = make(map[uint64]*chainRunner)
 	}
 	chainID := c.ChainID()
 	r.registry[chainID] = c
+	r.runners[chainID] = newChainRunner(c, chainID == r.escChainID)
+}
+
+// ChainErrors holds, by chain id, the errors of the relayed chains an arbiter
+// update could not be scheduled on.
+type ChainErrors map[uint64]error
+
+func (e ChainErrors) Error() string {
+	ids := make([]uint64, 0, len(e))
+	for id := range e {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = fmt.Sprintf("chain %d: %v", id, e[id])
+	}
+	return strings.Join(msgs, "; ")
 }
 
+// UpdateArbiters schedules the signed arbiters on the relayed chain chainID,
+// or on every relayed chain if it is zero.
 func (r *Relayer) UpdateArbiters(arbiters [][]byte, totalCount int,
 	signatures [][]byte, chainID uint64) error {
 	address := make([]common.Address, 0)
@@ -65,37 +107,39 @@ func (r *Relayer) UpdateArbiters(arbiters [][]byte, totalCount int,
 		addr := crypto.PubkeyToAddress(*escssaPUb)
 		address = append(address, addr)
 	}
-	errorCount := 0
-	var err error
-	for _, c := range r.relayedChains {
-		if c.ChainID() != chainID && chainID != 0 {
-			continue
-		}
-		err = c.WriteArbiters(address, signatures, totalCount)
-		if err != nil {
-			errorCount++
-			log.Error("write arbiter error", "error", err, "chainID", c.ChainID())
-		}
-	}
-	if len(r.relayedChains) > 0 {
-		if errorCount >= len(r.relayedChains)-1 {
-			return err
-		}
-	}
-
-	return nil
+	return r.schedule(&arbiterUpdate{arbiters: address, signatures: signatures, totalCount: totalCount}, chainID)
 }
 
+// SetArbiterList schedules the unsigned arbiters on the relayed chain
+// chainID, or on every relayed chain if it is zero.
 func (r *Relayer) SetArbiterList(arbiters []common.Address, total int, chainID uint64) error {
+	return r.schedule(&arbiterUpdate{arbiters: arbiters, signatures: [][]byte{}, totalCount: total}, chainID)
+}
+
+// schedule hands update to the runner of the relayed chain chainID, or of
+// every relayed chain if it is zero. The runners write it with backoff and
+// report the outcome in ChainStatus, the returned ChainErrors only hold the
+// chains it could not be scheduled on.
+func (r *Relayer) schedule(update *arbiterUpdate, chainID uint64) error {
+	errs := make(ChainErrors)
+	if chainID != 0 && r.runners[chainID] == nil {
+		errs[chainID] = errUnknownChain
+	}
 	for _, c := range r.relayedChains {
 		if c.ChainID() != chainID && chainID != 0 {
 			continue
 		}
-		err := c.WriteArbiters(arbiters, [][]byte{}, total)
-		if err != nil {
-			log.Error("write arbiter error", "error", err, "chainID", c.ChainID())
-			return err
+		select {
+		case <-r.stopChn:
+			errs[c.ChainID()] = errRelayerStopped
+			continue
+		default:
 		}
+		log.Info("schedule arbiters", "chainID", c.ChainID(), "count", len(update.arbiters))
+		r.runners[c.ChainID()].schedule(update)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 	return nil
 }
@@ -176,26 +220,87 @@ func (r *Relayer) SetManualArbiters(arbiters []common.Address, totalCount int) e
 	return errors.New(fmt.Sprintf("not found esc chain, chainID:%d", r.escChainID))
 }
 
+// RelayHandover submits the handover of the bridge key to the bridge contract
+// of every chain whose arbiter list still holds its old address, once per
+// chain. It returns true once no chain holds the old address anymore, so the
+// handover needs no more relaying. Chains whose bridge contract does not
+// define handoverArbiter are skipped, the old address only leaves them with
+// the next arbiter update.
+func (r *Relayer) RelayHandover(h *signer.Handover) (bool, error) {
+	r.handoverMu.Lock()
+	defer r.handoverMu.Unlock()
+	if r.handedOver == nil {
+		r.handedOver = make(map[common.Hash]map[uint64]struct{})
+	}
+	hash := h.Hash()
+	sent := r.handedOver[hash]
+	if sent == nil {
+		sent = make(map[uint64]struct{})
+		r.handedOver[hash] = sent
+	}
+	done := true
+	for _, c := range r.relayedChains {
+		if !containsAddress(c.GetArbiters(), h.OldAddress) {
+			continue
+		}
+		done = false
+		if _, ok := sent[c.ChainID()]; ok {
+			continue
+		}
+		if err := c.HandoverArbiter(h); errors.Is(err, ErrHandoverUnsupported) {
+			log.Debug("bridge contract does not support handovers", "chainID", c.ChainID())
+			continue
+		} else if err != nil {
+			return false, fmt.Errorf("relay handover to chain %d: %w", c.ChainID(), err)
+		}
+		sent[c.ChainID()] = struct{}{}
+	}
+	return done, nil
+}
+
+func containsAddress(list []common.Address, address common.Address) bool {
+	for _, a := range list {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Start polls and writes every relayed chain independently until Stop is
+// called. The arbiter lists set on the ESC chain are routed to the others.
 func (r *Relayer) Start() {
 	bridgelog.Info("Starting update relayer")
-	messagesChannel := make(chan *SetArbiterListMsg)
 	for _, c := range r.relayedChains {
 		bridgelog.Info("Starting chain", "chainid", c.ChainID())
+		runner := r.runners[c.ChainID()]
+		handle := func(m *SetArbiterListMsg) {
+			bridgelog.Info("arbiter list set", "chainid", runner.chain.ChainID(), "addressCount", m.AddressCount)
+		}
 		if c.ChainID() == r.escChainID {
-			go c.PollEvents(r.errChn, r.stopChn, messagesChannel)
+			handle = func(m *SetArbiterListMsg) { go r.route(m) }
 		}
+		go runner.poll(r.stopChn, handle)
+		go runner.write(r.stopChn)
 	}
-	for {
-		select {
-		case m := <-messagesChannel:
-			go r.route(m)
-			continue
-		case err := <-r.errChn:
-			bridgelog.Error("poll events error", "message", err.Error())
-			close(r.stopChn)
-			return
-		}
+	<-r.stopChn
+}
+
+// Stop stops polling and writing the relayed chains.
+func (r *Relayer) Stop() {
+	r.stopOnce.Do(func() { close(r.stopChn) })
+}
+
+// ChainStatus returns the status of the relayed chains, ordered by chain id.
+func (r *Relayer) ChainStatus() []ChainStatus {
+	statuses := make([]ChainStatus, 0, len(r.runners))
+	for _, runner := range r.runners {
+		statuses = append(statuses, runner.Status())
 	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ChainID < statuses[j].ChainID
+	})
+	return statuses
 }
 
 func (r *Relayer) route(m *SetArbiterListMsg) {
@@ -221,13 +326,11 @@ func (r *Relayer) route(m *SetArbiterListMsg) {
 		sigs[i] = make([]byte, crypto.SignatureLength)
 		copy(sigs[i], sig[:])
 	}
+	update := &arbiterUpdate{arbiters: list, signatures: sigs, totalCount: int(totalCount)}
 	for _, c := range r.relayedChains {
 		if c.ChainID() != r.escChainID {
 			bridgelog.Info("WriteArbiters chain", "chainid", c.ChainID(), "sigs", sigs)
-			err = c.WriteArbiters(list, sigs, int(totalCount))
-			if err != nil {
-				bridgelog.Error("write Aribters error", "msg", err)
-			}
+			r.runners[c.ChainID()].schedule(update)
 		}
 	}
 }
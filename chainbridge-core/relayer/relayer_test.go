@@ -0,0 +1,288 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package relayer
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChain struct {
+	id       uint64
+	arbiters []common.Address
+
+	mu       sync.Mutex
+	writeErr error
+	pollErr  error
+	written  []common.Address
+	events   chan *SetArbiterListMsg
+	stopped  bool
+
+	handoverErr error
+	handovers   []*signer.Handover
+}
+
+func newTestChain(id uint64) *testChain {
+	return &testChain{id: id, events: make(chan *SetArbiterListMsg)}
+}
+
+func (c *testChain) ChainID() uint64 { return c.id }
+
+func (c *testChain) WriteArbiters(arbiters []common.Address, signatures [][]byte, totalCount int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writeErr != nil {
+		return c.writeErr
+	}
+	c.written = arbiters
+	return nil
+}
+
+func (c *testChain) GetArbiters() []common.Address {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]common.Address{}, c.arbiters...)
+}
+
+func (c *testChain) HandoverArbiter(h *signer.Handover) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.handoverErr != nil {
+		return c.handoverErr
+	}
+	c.handovers = append(c.handovers, h)
+	return nil
+}
+
+// applyHandovers replaces the handed over arbiters as the contract does once
+// the handovers are mined.
+func (c *testChain) applyHandovers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, h := range c.handovers {
+		for i, a := range c.arbiters {
+			if a == h.OldAddress {
+				c.arbiters[i] = h.NewAddress
+			}
+		}
+	}
+}
+
+func (c *testChain) GetSignatures() ([][crypto.SignatureLength]byte, error) {
+	return make([][crypto.SignatureLength]byte, len(c.arbiters)), nil
+}
+
+func (c *testChain) GetTotalCount() (uint64, error)                { return uint64(len(c.arbiters)), nil }
+func (c *testChain) GetESCState() (uint8, error)                   { return 0, nil }
+func (c *testChain) SetESCState(state uint8) error                 { return nil }
+func (c *testChain) GetHashSalt() (*big.Int, error)                { return big.NewInt(0), nil }
+func (c *testChain) GetBridgeContract() string                     { return "" }
+func (c *testChain) LastPolledBlock() (uint64, error)              { return 0, nil }
+func (c *testChain) Submissions() []Submission                     { return nil }
+func (c *testChain) SetManualArbiters([]common.Address, int) error { return nil }
+
+func (c *testChain) PollEvents(sysErr chan<- error, stop <-chan struct{}, eventsChan chan *SetArbiterListMsg) {
+	c.mu.Lock()
+	err := c.pollErr
+	c.mu.Unlock()
+	if err != nil {
+		sysErr <- err
+		return
+	}
+	for {
+		select {
+		case m := <-c.events:
+			select {
+			case eventsChan <- m:
+			case <-stop:
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (c *testChain) Stop() {
+	c.mu.Lock()
+	c.stopped = true
+	c.mu.Unlock()
+}
+
+func (c *testChain) isStopped() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopped
+}
+
+func (c *testChain) setWriteErr(err error) {
+	c.mu.Lock()
+	c.writeErr = err
+	c.mu.Unlock()
+}
+
+func (c *testChain) writtenArbiters() []common.Address {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.written
+}
+
+func TestMain(m *testing.M) {
+	RetryMinInterval, RetryMaxInterval = 10*time.Millisecond, 40*time.Millisecond
+	os.Exit(m.Run())
+}
+
+func TestRelayerRoutesIndependently(t *testing.T) {
+	esc, healthy, failing := newTestChain(1), newTestChain(2), newTestChain(3)
+	esc.arbiters = []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	failing.setWriteErr(errors.New("rpc down"))
+
+	r := NewRelayer([]RelayedChain{esc, healthy, failing}, esc.id)
+	go r.Start()
+	defer r.Stop()
+
+	esc.events <- &SetArbiterListMsg{AddressCount: big.NewInt(2)}
+
+	// The failing chain does not hold back the healthy one
+	assert.Eventually(t, func() bool { return len(healthy.writtenArbiters()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return r.ChainStatus()[2].WriteRetries > 0 }, time.Second, 5*time.Millisecond)
+	status := r.ChainStatus()
+	assert.True(t, status[0].IsESC)
+	assert.Equal(t, uint64(2), status[0].ObservedArbiterCount)
+	assert.Equal(t, 2, status[1].LastArbiterCount)
+	assert.Equal(t, 2, status[2].PendingArbiterCount)
+	assert.Equal(t, "rpc down", status[2].WriteError)
+
+	// Once recovered it catches up with the pending arbiters
+	failing.setWriteErr(nil)
+	assert.Eventually(t, func() bool { return r.ChainStatus()[2].PendingArbiterCount == 0 }, time.Second, 5*time.Millisecond)
+	assert.Len(t, failing.writtenArbiters(), 2)
+	status = r.ChainStatus()
+	assert.Equal(t, 0, status[2].WriteRetries)
+	assert.Equal(t, "", status[2].WriteError)
+}
+
+func TestRelayerStopsChains(t *testing.T) {
+	esc, other := newTestChain(1), newTestChain(2)
+	r := NewRelayer([]RelayedChain{esc, other}, esc.id)
+	done := make(chan struct{})
+	go func() {
+		r.Start()
+		close(done)
+	}()
+	r.Stop()
+	<-done
+	assert.Eventually(t, func() bool { return esc.isStopped() && other.isStopped() }, time.Second, 5*time.Millisecond)
+}
+
+func TestRelayerRestartsPolling(t *testing.T) {
+	chain := newTestChain(2)
+	chain.pollErr = errors.New("subscription lost")
+	r := NewRelayer([]RelayedChain{chain}, 1)
+	go r.Start()
+	defer r.Stop()
+
+	assert.Eventually(t, func() bool { return r.ChainStatus()[0].PollRestarts >= 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "subscription lost", r.ChainStatus()[0].PollError)
+}
+
+func TestRelayHandover(t *testing.T) {
+	old, next := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	esc, other, unrelated := newTestChain(1), newTestChain(2), newTestChain(3)
+	esc.arbiters = []common.Address{old, common.HexToAddress("0x03")}
+	other.arbiters = []common.Address{old}
+	unrelated.arbiters = []common.Address{common.HexToAddress("0x03")}
+	r := NewRelayer([]RelayedChain{esc, other, unrelated}, 1)
+	h := &signer.Handover{OldAddress: old, NewAddress: next}
+
+	other.handoverErr = errors.New("node down")
+	done, err := r.RelayHandover(h)
+	assert.Error(t, err)
+	assert.False(t, done)
+	assert.Len(t, esc.handovers, 1)
+
+	// The chain it was submitted to is not submitted again
+	other.handoverErr = nil
+	done, err = r.RelayHandover(h)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	assert.Len(t, esc.handovers, 1)
+	assert.Len(t, other.handovers, 1)
+	assert.Empty(t, unrelated.handovers)
+
+	esc.applyHandovers()
+	other.applyHandovers()
+	done, err = r.RelayHandover(h)
+	assert.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []common.Address{next}, other.GetArbiters())
+}
+
+func TestRelayerSchedulesArbiters(t *testing.T) {
+	esc, healthy, failing := newTestChain(1), newTestChain(2), newTestChain(3)
+	failing.setWriteErr(errors.New("rpc down"))
+	r := NewRelayer([]RelayedChain{esc, healthy, failing}, esc.id)
+	arbiters := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+
+	// The writes are scheduled on every chain and retried on the failing one
+	assert.NoError(t, r.SetArbiterList(arbiters, 2, 0))
+	go r.Start()
+	assert.Eventually(t, func() bool {
+		return len(esc.writtenArbiters()) == 2 && len(healthy.writtenArbiters()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return r.ChainStatus()[2].WriteRetries > 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, 2, r.ChainStatus()[2].PendingArbiterCount)
+
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	assert.NoError(t, r.UpdateArbiters([][]byte{crypto.CompressPubkey(&key.PublicKey)}, 1, nil, healthy.id))
+	assert.Eventually(t, func() bool {
+		written := healthy.writtenArbiters()
+		return len(written) == 1 && written[0] == crypto.PubkeyToAddress(key.PublicKey)
+	}, time.Second, 5*time.Millisecond)
+	assert.Len(t, esc.writtenArbiters(), 2)
+
+	// Chains it cannot be scheduled on are reported each
+	err = r.SetArbiterList(arbiters, 2, 4)
+	assert.Equal(t, ChainErrors{4: errUnknownChain}, err)
+	r.Stop()
+	err = r.SetArbiterList(arbiters, 2, 0)
+	assert.Equal(t, ChainErrors{1: errRelayerStopped, 2: errRelayerStopped, 3: errRelayerStopped}, err)
+}
+
+func TestRelayHandoverUnsupported(t *testing.T) {
+	old, next := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	esc, other := newTestChain(1), newTestChain(2)
+	esc.arbiters, other.arbiters = []common.Address{old}, []common.Address{old}
+	esc.handoverErr = ErrHandoverUnsupported
+	r := NewRelayer([]RelayedChain{esc, other}, 1)
+	h := &signer.Handover{OldAddress: old, NewAddress: next}
+
+	// The chain without the method is skipped, the handover stays pending
+	// until the old address leaves it
+	done, err := r.RelayHandover(h)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	assert.Len(t, other.handovers, 1)
+
+	other.applyHandovers()
+	done, err = r.RelayHandover(h)
+	assert.NoError(t, err)
+	assert.False(t, done)
+	esc.mu.Lock()
+	esc.arbiters = []common.Address{next}
+	esc.mu.Unlock()
+	done, err = r.RelayHandover(h)
+	assert.NoError(t, err)
+	assert.True(t, done)
+}
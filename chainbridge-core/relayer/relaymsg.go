@@ -2,8 +2,31 @@ package relayer
 
 import (
 	"math/big"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/common"
 )
 
 type SetArbiterListMsg struct {
 	AddressCount *big.Int
 }
+
+// Statuses of a Submission.
+const (
+	SubmissionPending  = "pending"
+	SubmissionIncluded = "included"
+	SubmissionReverted = "reverted"
+	SubmissionTimeout  = "timeout"
+)
+
+// Submission is a transaction sent to a relayed chain and its outcome.
+type Submission struct {
+	Method   string
+	Nonce    uint64
+	GasPrice *big.Int      // Gas price of the last transaction sent
+	Hashes   []common.Hash // Transactions sent, each replacing the previous one
+	SentAt   time.Time
+	Status   string
+	Block    uint64 // Block including the transaction
+	Error    string
+}
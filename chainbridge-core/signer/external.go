@@ -0,0 +1,80 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/pgprotocol/pgp-chain/accounts"
+	"github.com/pgprotocol/pgp-chain/accounts/external"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/crypto"
+)
+
+// publicKeyProbe is the text the external signer signs once to recover the
+// public key of its account.
+var publicKeyProbe = []byte("bridge signer public key")
+
+type externalSigner struct {
+	api     *external.ExternalSigner
+	account accounts.Account
+	public  []byte
+}
+
+// NewExternalSigner returns a Signer for address through the clef-style
+// external signer at endpoint, so the key is not held in process. The
+// signer is asked once to sign a probe, to recover the public key of the
+// account.
+func NewExternalSigner(endpoint string, address common.Address) (Signer, error) {
+	api, err := external.NewExternalSigner(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	s := &externalSigner{api: api, account: accounts.Account{Address: address}}
+	if !api.Contains(s.account) {
+		return nil, fmt.Errorf("account %s not found on external signer", address.String())
+	}
+	sig, err := s.SignText(publicKeyProbe)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := crypto.SigToPub(accounts.TextHash(publicKeyProbe), sig)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(*pub) != address {
+		return nil, fmt.Errorf("external signer signed for %s instead of %s", crypto.PubkeyToAddress(*pub).String(), address.String())
+	}
+	s.public = crypto.CompressPubkey(pub)
+	return s, nil
+}
+
+func (s *externalSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *externalSigner) PublicKeyBytes() []byte {
+	return s.public
+}
+
+func (s *externalSigner) SignText(text []byte) ([]byte, error) {
+	sig, err := s.api.SignText(s.account, text)
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != crypto.SignatureLength {
+		return nil, fmt.Errorf("invalid signature length %d from external signer", len(sig))
+	}
+	// Transform V from 27/28 to 0/1 as the keys in process sign
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	return sig, nil
+}
+
+func (s *externalSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.api.SignTx(s.account, tx, chainID)
+}
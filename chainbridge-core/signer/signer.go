@@ -0,0 +1,275 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+/*
+The signer package signs for the accounts of the bridge, with a key held in
+process or through a clef-style external signer.
+
+The bridge arbiter key is held by a Rotatable signer, which is shared by the
+engine and the voters of every chain. Rotating it switches all of them to the
+new key without a restart, the old key signing a handover to the new one.
+Once opened on a Store, the key in use and the handovers not yet relayed to
+the bridge contracts are persisted, so the rotation survives a restart.
+*/
+package signer
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/accounts"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/crypto"
+)
+
+var (
+	// ErrNoSigner is returned if a Rotatable is used before it holds a key.
+	ErrNoSigner = errors.New("no bridge signer")
+
+	// ErrSameSigner is returned if a Rotatable is rotated to its own key.
+	ErrSameSigner = errors.New("rotate to the same bridge signer")
+
+	// ErrInvalidHandover is returned if a handover is not signed by its keys.
+	ErrInvalidHandover = errors.New("invalid bridge key handover")
+)
+
+// Signer signs for an account of the bridge.
+type Signer interface {
+	// Address returns the address of the account
+	Address() common.Address
+	// PublicKeyBytes returns the compressed public key of the account
+	PublicKeyBytes() []byte
+	// SignText signs the hash of text as personal_sign does, in the
+	// [R || S || V] format where V is 0 or 1
+	SignText(text []byte) ([]byte, error)
+	// SignTx signs tx with the EIP155 signer of chainID
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+type keypairSigner struct {
+	kp *secp256k1.Keypair
+}
+
+// NewKeypairSigner returns a Signer holding the key of kp in process.
+func NewKeypairSigner(kp *secp256k1.Keypair) Signer {
+	return &keypairSigner{kp: kp}
+}
+
+func (s *keypairSigner) Address() common.Address {
+	return s.kp.CommonAddress()
+}
+
+func (s *keypairSigner) PublicKeyBytes() []byte {
+	return s.kp.PublicKeyBytes()
+}
+
+func (s *keypairSigner) SignText(text []byte) ([]byte, error) {
+	return crypto.Sign(accounts.TextHash(text), s.kp.PrivateKey())
+}
+
+func (s *keypairSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.NewEIP155Signer(chainID), s.kp.PrivateKey())
+}
+
+// Handover hands the bridge arbiter account over from an old key to a new
+// one. The old key signs it to endorse the new key, and the new key signs it
+// to prove it is held.
+type Handover struct {
+	OldAddress   common.Address
+	NewAddress   common.Address
+	NewPublicKey hexutil.Bytes
+	Time         uint64
+	OldSignature hexutil.Bytes
+	NewSignature hexutil.Bytes
+}
+
+// NewHandover returns the handover from old to next signed by both.
+func NewHandover(old, next Signer) (*Handover, error) {
+	h := &Handover{
+		OldAddress:   old.Address(),
+		NewAddress:   next.Address(),
+		NewPublicKey: next.PublicKeyBytes(),
+		Time:         uint64(time.Now().Unix()),
+	}
+	var err error
+	if h.OldSignature, err = old.SignText(h.Hash().Bytes()); err != nil {
+		return nil, err
+	}
+	if h.NewSignature, err = next.SignText(h.Hash().Bytes()); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Hash returns the hash both keys sign.
+func (h *Handover) Hash() common.Hash {
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], h.Time)
+	return crypto.Keccak256Hash([]byte("bridge key handover"), h.OldAddress.Bytes(), h.NewPublicKey, t[:])
+}
+
+// Verify checks the handover is signed by the old and new keys.
+func (h *Handover) Verify() error {
+	pub, err := crypto.DecompressPubkey(h.NewPublicKey)
+	if err != nil || crypto.PubkeyToAddress(*pub) != h.NewAddress {
+		return ErrInvalidHandover
+	}
+	hash := accounts.TextHash(h.Hash().Bytes())
+	for _, s := range []struct {
+		address   common.Address
+		signature []byte
+	}{{h.OldAddress, h.OldSignature}, {h.NewAddress, h.NewSignature}} {
+		pub, err := crypto.SigToPub(hash, s.signature)
+		if err != nil || crypto.PubkeyToAddress(*pub) != s.address {
+			return ErrInvalidHandover
+		}
+	}
+	return nil
+}
+
+// Rotatable is a Signer whose key can be rotated while it is in use.
+type Rotatable struct {
+	mu        sync.RWMutex
+	current   Signer
+	handovers []Handover
+	pending   []Handover
+	store     *Store
+}
+
+// NewRotatable returns a Rotatable signing with s, which may be nil.
+func NewRotatable(s Signer) *Rotatable {
+	return &Rotatable{current: s}
+}
+
+// Open persists the rotations of r to store. The key persisted by an earlier
+// rotation is restored along with its pending handovers. If there is none, r
+// switches to initial when it is not nil. This is not a rotation and no
+// handover is signed.
+func (r *Rotatable) Open(store *Store, initial Signer) error {
+	restored, pending, err := store.load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if restored != nil {
+		r.current = restored
+	} else if initial != nil {
+		r.current = initial
+	}
+	r.handovers = append(r.handovers, pending...)
+	r.pending = pending
+	r.store = store
+	return nil
+}
+
+// Current returns the signer in use, nil if there is none.
+func (r *Rotatable) Current() Signer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// Rotate switches to next. If a key was in use, it signs a handover to next
+// which is returned and kept pending until it is relayed. If r is open on a
+// store, the rotation only takes effect once it is persisted.
+func (r *Rotatable) Rotate(next Signer) (*Handover, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current != nil && r.current.Address() == next.Address() {
+		return nil, ErrSameSigner
+	}
+	var h *Handover
+	pending := r.pending
+	if r.current != nil {
+		var err error
+		if h, err = NewHandover(r.current, next); err != nil {
+			return nil, err
+		}
+		pending = append(append([]Handover{}, r.pending...), *h)
+	}
+	if r.store != nil {
+		if err := r.store.save(next, pending); err != nil {
+			return nil, err
+		}
+	}
+	r.current = next
+	r.pending = pending
+	if h != nil {
+		r.handovers = append(r.handovers, *h)
+	}
+	return h, nil
+}
+
+// Handovers returns the handovers signed since r was created, along with the
+// pending ones restored by Open, oldest first.
+func (r *Rotatable) Handovers() []Handover {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Handover{}, r.handovers...)
+}
+
+// Pending returns the handovers not yet relayed to the bridge contracts,
+// oldest first.
+func (r *Rotatable) Pending() []Handover {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]Handover{}, r.pending...)
+}
+
+// Relayed marks the handover of hash as relayed to the bridge contracts, so
+// it is no longer pending.
+func (r *Rotatable) Relayed(hash common.Hash) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := make([]Handover, 0, len(r.pending))
+	for _, h := range r.pending {
+		if h.Hash() != hash {
+			pending = append(pending, h)
+		}
+	}
+	if len(pending) == len(r.pending) {
+		return nil
+	}
+	if r.store != nil {
+		if err := r.store.save(r.current, pending); err != nil {
+			return err
+		}
+	}
+	r.pending = pending
+	return nil
+}
+
+func (r *Rotatable) Address() common.Address {
+	if s := r.Current(); s != nil {
+		return s.Address()
+	}
+	return common.Address{}
+}
+
+func (r *Rotatable) PublicKeyBytes() []byte {
+	if s := r.Current(); s != nil {
+		return s.PublicKeyBytes()
+	}
+	return nil
+}
+
+func (r *Rotatable) SignText(text []byte) ([]byte, error) {
+	if s := r.Current(); s != nil {
+		return s.SignText(text)
+	}
+	return nil, ErrNoSigner
+}
+
+func (r *Rotatable) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	if s := r.Current(); s != nil {
+		return s.SignTx(tx, chainID)
+	}
+	return nil, ErrNoSigner
+}
@@ -0,0 +1,134 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"math/big"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/accounts"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSigner(t *testing.T) Signer {
+	kp, err := secp256k1.GenerateKeypair()
+	assert.NoError(t, err)
+	return NewKeypairSigner(kp)
+}
+
+func TestKeypairSigner(t *testing.T) {
+	s := newTestSigner(t)
+	text := []byte("arbiters")
+	sig, err := s.SignText(text)
+	assert.NoError(t, err)
+	pub, err := crypto.SigToPub(accounts.TextHash(text), sig)
+	assert.NoError(t, err)
+	assert.Equal(t, s.Address(), crypto.PubkeyToAddress(*pub))
+	assert.Equal(t, s.PublicKeyBytes(), crypto.CompressPubkey(pub))
+
+	tx := types.NewTransaction(0, common.HexToAddress("0x01"), big.NewInt(0), 21000, big.NewInt(1), nil)
+	tx, err = s.SignTx(tx, big.NewInt(20))
+	assert.NoError(t, err)
+	from, err := types.Sender(types.NewEIP155Signer(big.NewInt(20)), tx)
+	assert.NoError(t, err)
+	assert.Equal(t, s.Address(), from)
+}
+
+func TestRotatable(t *testing.T) {
+	r := NewRotatable(nil)
+	_, err := r.SignText([]byte("arbiters"))
+	assert.Equal(t, ErrNoSigner, err)
+
+	first, second := newTestSigner(t), newTestSigner(t)
+	h, err := r.Rotate(first)
+	assert.NoError(t, err)
+	assert.Nil(t, h)
+	assert.Equal(t, first.Address(), r.Address())
+
+	_, err = r.Rotate(first)
+	assert.Equal(t, ErrSameSigner, err)
+
+	// The old key hands over to the new one
+	h, err = r.Rotate(second)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Address(), h.OldAddress)
+	assert.Equal(t, second.Address(), h.NewAddress)
+	assert.NoError(t, h.Verify())
+	assert.Equal(t, []Handover{*h}, r.Handovers())
+	assert.Equal(t, second.Address(), r.Address())
+
+	tampered := *h
+	tampered.NewAddress = common.HexToAddress("0x01")
+	assert.Equal(t, ErrInvalidHandover, tampered.Verify())
+	tampered = *h
+	tampered.Time++
+	assert.Equal(t, ErrInvalidHandover, tampered.Verify())
+}
+
+func TestRotatableConcurrent(t *testing.T) {
+	r := NewRotatable(newTestSigner(t))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 16; j++ {
+				_, err := r.SignText([]byte("arbiters"))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		_, err := r.Rotate(newTestSigner(t))
+		assert.NoError(t, err)
+	}
+	wg.Wait()
+	assert.Len(t, r.Handovers(), 4)
+}
+
+func TestRotatableOpen(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "signer.json"), []byte("password"), "")
+	configured, initial := newTestSigner(t), newTestSigner(t)
+
+	// Nothing persisted, the initial key replaces the configured one without
+	// a handover
+	r := NewRotatable(configured)
+	assert.NoError(t, r.Open(store, initial))
+	assert.Equal(t, initial.Address(), r.Address())
+	assert.Empty(t, r.Handovers())
+	assert.Empty(t, r.Pending())
+
+	next := newTestSigner(t)
+	h, err := r.Rotate(next)
+	assert.NoError(t, err)
+	assert.Equal(t, []Handover{*h}, r.Pending())
+
+	// After a restart the rotated key and its pending handover are restored
+	restarted := NewRotatable(configured)
+	assert.NoError(t, restarted.Open(store, initial))
+	assert.Equal(t, next.Address(), restarted.Address())
+	assert.Equal(t, []Handover{*h}, restarted.Pending())
+	sig, err := restarted.SignText([]byte("arbiters"))
+	assert.NoError(t, err)
+	pub, err := crypto.SigToPub(accounts.TextHash([]byte("arbiters")), sig)
+	assert.NoError(t, err)
+	assert.Equal(t, next.Address(), crypto.PubkeyToAddress(*pub))
+
+	assert.NoError(t, restarted.Relayed(h.Hash()))
+	assert.Empty(t, restarted.Pending())
+	restarted = NewRotatable(configured)
+	assert.NoError(t, restarted.Open(store, nil))
+	assert.Equal(t, next.Address(), restarted.Address())
+	assert.Empty(t, restarted.Pending())
+
+	// The persisted key is encrypted with the password of the store
+	wrong := NewStore(store.path, []byte("wrong"), "")
+	assert.Error(t, NewRotatable(nil).Open(wrong, nil))
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 ChainSafe Systems
+// SPDX-License-Identifier: LGPL-3.0-only
+
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	bridgecrypto "github.com/pgprotocol/pgp-chain/chainbridge-core/crypto"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/keystore"
+	"github.com/pgprotocol/pgp-chain/common"
+)
+
+// storedKey is the persisted form of a key. A key held in process is kept
+// encrypted with the password of the store, a key on the external signer by
+// its address only.
+type storedKey struct {
+	Keystore *keystore.EncryptedKeystore `json:"keystore,omitempty"`
+	External *common.Address             `json:"external,omitempty"`
+}
+
+type storedState struct {
+	Key     storedKey  `json:"key"`
+	Pending []Handover `json:"pending"`
+}
+
+// Store persists the key in use by a Rotatable along with the handovers not
+// yet relayed to the bridge contracts, so a restart resumes with the rotated
+// key.
+type Store struct {
+	path     string
+	password []byte
+	endpoint string
+}
+
+// NewStore returns a Store writing to the file at path. Keys held in process
+// are encrypted with password, keys on the external signer are restored
+// through endpoint.
+func NewStore(path string, password []byte, endpoint string) *Store {
+	return &Store{path: path, password: password, endpoint: endpoint}
+}
+
+// load returns the persisted key and pending handovers, a nil key if nothing
+// was persisted yet.
+func (s *Store) load() (Signer, []Handover, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var state storedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case state.Key.Keystore != nil:
+		ks := state.Key.Keystore
+		kp, err := keystore.DecryptKeypair(ks.PublicKey, ks.Ciphertext, s.password, ks.Type)
+		if err != nil {
+			return nil, nil, err
+		}
+		return NewKeypairSigner(kp.(*secp256k1.Keypair)), state.Pending, nil
+	case state.Key.External != nil:
+		if s.endpoint == "" {
+			return nil, nil, fmt.Errorf("bridge key %s is on the external signer, but none is set", state.Key.External.String())
+		}
+		signer, err := NewExternalSigner(s.endpoint, *state.Key.External)
+		if err != nil {
+			return nil, nil, err
+		}
+		return signer, state.Pending, nil
+	}
+	return nil, nil, errors.New("no bridge key in " + s.path)
+}
+
+// save persists current and pending, replacing the file in one rename.
+func (s *Store) save(current Signer, pending []Handover) error {
+	state := storedState{Pending: pending}
+	switch c := current.(type) {
+	case *keypairSigner:
+		ciphertext, err := keystore.EncryptKeypair(c.kp, s.password)
+		if err != nil {
+			return err
+		}
+		state.Key.Keystore = &keystore.EncryptedKeystore{
+			Type:       bridgecrypto.Secp256k1Type,
+			PublicKey:  c.kp.PublicKey(),
+			Address:    c.kp.Address(),
+			Ciphertext: ciphertext,
+		}
+	case *externalSigner:
+		address := c.Address()
+		state.Key.External = &address
+	default:
+		return fmt.Errorf("can not persist bridge signer %T", current)
+	}
+	data, err := json.MarshalIndent(&state, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
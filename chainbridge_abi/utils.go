@@ -54,6 +54,16 @@ func SetManualArbiterABI() (abi.ABI, error) {
 	return a, err
 }
 
+// HandoverArbiterABI is the ABI of the handoverArbiter method of the bridge
+// contract, which replaces an arbiter address by the new one signed by both of
+// their keys. The bridge contracts deployed so far do not define it, the
+// handovers are only submitted to the ones that do.
+func HandoverArbiterABI() (abi.ABI, error) {
+	definition := "[{\"inputs\": [{\"internalType\": \"address\",\"name\": \"_oldAddress\",\"type\": \"address\"},{\"internalType\": \"address\",\"name\": \"_newAddress\",\"type\": \"address\"},{\"internalType\": \"bytes\",\"name\": \"_newPublicKey\",\"type\": \"bytes\"},{\"internalType\": \"uint256\",\"name\": \"_time\",\"type\": \"uint256\"},{\"internalType\": \"bytes\",\"name\": \"_oldSig\",\"type\": \"bytes\"},{\"internalType\": \"bytes\",\"name\": \"_newSig\",\"type\": \"bytes\"}],\"name\": \"handoverArbiter\",\"outputs\": [],\"stateMutability\": \"nonpayable\",\"type\": \"function\"}]"
+	a, err := abi.JSON(strings.NewReader(definition))
+	return a, err
+}
+
 func GetHashSaltABI() (abi.ABI, error) {
 	definition := "[{\"inputs\": [],\"name\": \"GetHashSalt\",\"outputs\": [{\"internalType\": \"uint256\",\"name\": \"\",\"type\": \"uint256\"}],\"stateMutability\": \"view\",\"type\": \"function\"}]"
 	a, err := abi.JSON(strings.NewReader(definition))
@@ -38,6 +38,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/console"
 	"github.com/pgprotocol/pgp-chain/core"
 	"github.com/pgprotocol/pgp-chain/core/events"
+	"github.com/pgprotocol/pgp-chain/crosschain/tracker"
 	"github.com/pgprotocol/pgp-chain/eth"
 	"github.com/pgprotocol/pgp-chain/eth/downloader"
 	"github.com/pgprotocol/pgp-chain/ethclient"
@@ -171,6 +172,8 @@ var (
 		utils.EVMInterpreterFlag,
 		configFileFlag,
 		utils.SpvMonitoringAddrFlag,
+		utils.SpvConfirmationsFlag,
+		utils.WithdrawIndexFlag,
 		utils.PassBalance,
 		utils.BlackContractAddr,
 		utils.PreConnectOffset,
@@ -416,7 +419,8 @@ func startSpv(ctx *cli.Context, stack *node.Node) {
 	}
 
 	var spvCfg = &spv.Config{
-		DataDir: SpvDataDir,
+		DataDir:           SpvDataDir,
+		ConfirmationDepth: uint32(ctx.GlobalUint64(utils.SpvConfirmationsFlag.Name)),
 	}
 	// prepare the SPV service config parameters
 	switch {
@@ -501,6 +505,7 @@ func startSpv(ctx *cli.Context, stack *node.Node) {
 		smallCroTxSub := stack.EventMux().Subscribe(events.CmallCrossTx{})
 		go spv.MinedBroadcastLoop(MinedBlockSub, OnDutySub, smallCroTxSub)
 		spvService.Start()
+		tracker.Start()
 		stack.EventMux().Post(events.InitCurrentProducers{})
 		spv.InitNextTurnDposInfo()
 	}
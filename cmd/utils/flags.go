@@ -763,6 +763,16 @@ var (
 		Usage: "configue SPV module monitoring ela chain address",
 		Value: "",
 	}
+	SpvConfirmationsFlag = cli.Uint64Flag{
+		Name:  "spv.confirmations",
+		Usage: "main chain blocks a deposit must be confirmed by before it is recharged",
+		Value: 6,
+	}
+
+	WithdrawIndexFlag = cli.BoolFlag{
+		Name:  "withdrawindex",
+		Usage: "Index the withdraw, refund and pledge bill burn events for the crosschain range queries",
+	}
 
 	BlackContractAddr = cli.StringFlag{
 		Name:  "black.contract.address",
@@ -1604,6 +1614,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
 	}
+	if ctx.GlobalIsSet(WithdrawIndexFlag.Name) {
+		cfg.WithdrawIndex = ctx.GlobalBool(WithdrawIndexFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(EWASMInterpreterFlag.Name) {
 		cfg.EWASMInterpreter = ctx.GlobalString(EWASMInterpreterFlag.Name)
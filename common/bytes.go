@@ -17,7 +17,10 @@
 // Package common contains various helper functions.
 package common
 
-import "encoding/hex"
+import (
+	"encoding/hex"
+	"strings"
+)
 
 // ToHex returns the hex representation of b, prefixed with '0x'.
 // For empty slices, the return value is "0x0".
@@ -52,6 +55,15 @@ func FromHex(s string) []byte {
 	return Hex2Bytes(s)
 }
 
+// TrimHexPrefix returns the hexadecimal string s without its "0x" prefix and
+// in lower case, the form main chain hashes are stored and reported in.
+func TrimHexPrefix(s string) string {
+	if has0xPrefix(s) {
+		s = s[2:]
+	}
+	return strings.ToLower(s)
+}
+
 // CopyBytes returns an exact copy of the provided bytes.
 func CopyBytes(b []byte) (copiedBytes []byte) {
 	if b == nil {
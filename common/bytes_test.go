@@ -65,6 +65,23 @@ func TestFromHex(t *testing.T) {
 	}
 }
 
+func TestTrimHexPrefix(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"", ""},
+		{"0x", ""},
+		{"0xA9e67e", "a9e67e"},
+		{"0XA9E67E", "a9e67e"},
+		{"a9e67e", "a9e67e"},
+	}
+	for _, test := range tests {
+		if got := TrimHexPrefix(test.input); got != test.want {
+			t.Errorf("TrimHexPrefix(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
 func TestIsHex(t *testing.T) {
 	tests := []struct {
 		input string
@@ -0,0 +1,200 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"sync"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	dmsg "github.com/pgprotocol/pgp-chain/dpos/msg"
+	"github.com/pgprotocol/pgp-chain/log"
+)
+
+var (
+	// errAggregateConfirmNotActive is returned if a block carries an
+	// aggregate confirm before the aggregate confirm fork.
+	errAggregateConfirmNotActive = errors.New("aggregate confirm before fork")
+)
+
+// aggregateVotes collects the BLS signatures of the producers accepting the
+// proposals this node sponsors, until the block of the proposal is sealed.
+type aggregateVotes struct {
+	mu        sync.Mutex
+	proposals map[ecom.Uint256]*aggregateVoteSet
+}
+
+type aggregateVoteSet struct {
+	height     uint64
+	signatures map[string][]byte
+}
+
+func newAggregateVotes() *aggregateVotes {
+	return &aggregateVotes{proposals: make(map[ecom.Uint256]*aggregateVoteSet)}
+}
+
+func (v *aggregateVotes) add(proposal ecom.Uint256, height uint64, signer []byte, signature []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	set, ok := v.proposals[proposal]
+	if !ok {
+		set = &aggregateVoteSet{height: height, signatures: make(map[string][]byte)}
+		v.proposals[proposal] = set
+	}
+	set.signatures[common.Bytes2Hex(signer)] = signature
+}
+
+// signatures returns the signatures of proposal keyed by the hex encoded
+// public key of their signer.
+func (v *aggregateVotes) signatures(proposal ecom.Uint256) map[string][]byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	signatures := make(map[string][]byte)
+	if set, ok := v.proposals[proposal]; ok {
+		for signer, signature := range set.signatures {
+			signatures[signer] = signature
+		}
+	}
+	return signatures
+}
+
+// prune drops the signatures of proposals up to height.
+func (v *aggregateVotes) prune(height uint64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for proposal, set := range v.proposals {
+		if set.height <= height {
+			delete(v.proposals, proposal)
+		}
+	}
+}
+
+// verifiedBlsProofs caches the proofs of possession already verified, keyed
+// by the BLS public key and proof, as headers are verified against the keys
+// of the config one by one.
+var verifiedBlsProofs sync.Map
+
+// parseBlsPublicKeys returns the BLS public keys of the config with a valid
+// proof of possession, keyed by the hex encoded public key of their producer.
+func parseBlsPublicKeys(keys, proofs map[string]string) map[string][]byte {
+	publicKeys := make(map[string][]byte, len(keys))
+	for producer, key := range keys {
+		publicKey, proof := common.Hex2Bytes(key), common.Hex2Bytes(proofs[producer])
+		cacheKey := string(publicKey) + string(proof)
+		if _, ok := verifiedBlsProofs.Load(cacheKey); !ok {
+			if err := dpos.VerifyBlsProof(publicKey, proof); err != nil {
+				log.Error("invalid bls public key", "producer", producer, "err", err)
+				continue
+			}
+			verifiedBlsProofs.Store(cacheKey, struct{}{})
+		}
+		publicKeys[common.Bytes2Hex(common.Hex2Bytes(producer))] = publicKey
+	}
+	return publicKeys
+}
+
+// isAggregateConfirm returns whether the block at height is sealed with an
+// aggregate confirm when enough producers sign one.
+func (p *Pbft) isAggregateConfirm(height uint64) bool {
+	return p.chainConfig.IsAggregateConfirm(new(big.Int).SetUint64(height))
+}
+
+// blsPublicKeysOf returns the registered BLS public keys of producers, nil
+// for the producers without one.
+func blsPublicKeysOf(registered map[string][]byte, producers [][]byte) [][]byte {
+	publicKeys := make([][]byte, len(producers))
+	for i, producer := range producers {
+		publicKeys[i] = registered[common.Bytes2Hex(producer)]
+	}
+	return publicKeys
+}
+
+// sendAggregateVote sends the BLS signature of the accepted proposal to its
+// sponsor. It is sent ahead of the vote, so the sponsor has it once the vote
+// completes the confirm.
+func (p *Pbft) sendAggregateVote(proposal *payload.DPOSProposal) {
+	height := p.proposalHeight(proposal)
+	if p.blsKey == nil || !p.isAggregateConfirm(height) {
+		return
+	}
+	hash := proposal.Hash()
+	signature := p.blsKey.Sign(hash[:])
+	if bytes.Equal(proposal.Sponsor, p.account.PublicKeyBytes()) {
+		p.aggregateVotes.add(hash, height, proposal.Sponsor, signature)
+		return
+	}
+	var sponsor peer.PID
+	copy(sponsor[:], proposal.Sponsor)
+	vote := dmsg.NewAggregateVote(hash, p.account.PublicKeyBytes(), signature)
+	if err := p.network.SendMessageToPeer(sponsor, vote); err != nil {
+		log.Warn("send aggregate vote error", "sponsor", sponsor.String(), "err", err)
+	}
+}
+
+func (p *Pbft) OnAggregateVoteReceived(id peer.PID, v *dmsg.AggregateVote) {
+	proposal := p.dispatcher.GetProcessingProposal()
+	if proposal == nil || p.account == nil || !bytes.Equal(proposal.Sponsor, p.account.PublicKeyBytes()) ||
+		!proposal.Hash().IsEqual(v.ProposalHash) {
+		return
+	}
+	if !bytes.Equal(id[:], v.Signer) || !p.IsProducerByAccount(v.Signer) {
+		log.Warn("[OnAggregateVoteReceived] not a producer", "signer", common.Bytes2Hex(v.Signer))
+		return
+	}
+	publicKey, ok := p.blsPublicKeys[common.Bytes2Hex(v.Signer)]
+	if !ok {
+		return
+	}
+	if err := dpos.VerifyBlsSignature(publicKey, v.ProposalHash[:], v.Signature); err != nil {
+		log.Warn("[OnAggregateVoteReceived] invalid signature", "signer", common.Bytes2Hex(v.Signer), "err", err)
+		return
+	}
+	p.aggregateVotes.add(v.ProposalHash, p.proposalHeight(proposal), v.Signer, v.Signature)
+}
+
+// aggregateConfirm returns the aggregate confirm to seal header with instead
+// of confirm, or nil if the fork is not active at header or too few
+// producers sent their BLS signatures.
+func (p *Pbft) aggregateConfirm(header *types.Header, confirm *payload.Confirm) *dpos.AggregateConfirm {
+	if !p.isAggregateConfirm(header.Number.Uint64()) {
+		return nil
+	}
+	signatures := p.aggregateVotes.signatures(confirm.Proposal.Hash())
+	producers := p.dispatcher.GetConsensusView().GetProducers()
+	indexes, signed := make([]int, 0, len(signatures)), make([][]byte, 0, len(signatures))
+	for i, producer := range producers {
+		if signature, ok := signatures[common.Bytes2Hex(producer)]; ok {
+			indexes = append(indexes, i)
+			signed = append(signed, signature)
+		}
+	}
+	minSignCount, err := p.minSignCount(len(indexes), header.Nonce.Uint64(), int64(header.Time))
+	if err != nil || len(indexes) == 0 || len(indexes) < minSignCount {
+		log.Info("not enough bls signatures, seal full confirm", "signers", len(indexes), "need", minSignCount)
+		return nil
+	}
+	aggregate, err := dpos.NewAggregateConfirm(confirm.Proposal, len(producers), indexes, signed)
+	if err != nil {
+		log.Error("aggregate confirm error", "err", err)
+		return nil
+	}
+	return aggregate
+}
+
+// verifyAggregateConfirm checks confirm is signed by the majority of set, its
+// signers indexing the producers of set.
+func (p *Pbft) verifyAggregateConfirm(confirm *dpos.AggregateConfirm, set *ProducerSet, timeStamp int64) error {
+	minSignCount := p.majorityCount(confirm.SignerCount(), set.TotalCount, timeStamp)
+	return dpos.CheckAggregateConfirm(confirm, blsPublicKeysOf(p.blsPublicKeys, set.Producers), minSignCount)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/consensus"
 	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/params"
 )
 
 // API is a user facing RPC API to allow controlling the signer and voting
@@ -59,6 +60,42 @@ func (a *API) GetActivePeers() []peerInfo {
 	return result
 }
 
+// GetIllegalEvidence returns the verified evidences of producers that signed
+// conflicting proposals or votes, so arbiters can forward them to the main chain.
+func (a *API) GetIllegalEvidence() []IllegalEvidence {
+	return a.pbft.GetIllegalEvidence()
+}
+
+// GetBlsPublicKey returns the BLS public key this producer signs aggregate
+// confirms with, to be registered in the blspublickeys of the pbft config.
+func (a *API) GetBlsPublicKey() string {
+	if a.pbft.blsKey == nil {
+		return ""
+	}
+	return common.Bytes2Hex(a.pbft.blsKey.PublicKey())
+}
+
+// GetProducerTransitions returns the producer set changes from height on with
+// the headers proving them, so clients holding the genesis producers can
+// follow the producer set and verify later confirms.
+func (a *API) GetProducerTransitions(height uint64) []*ProducerTransition {
+	return a.pbft.GetProducerTransitions(height)
+}
+
+// GetProducerCheckpoints returns the producers journaled for the main chain
+// heights of the blocks before the producer transition fork, at each height
+// the set changes, to be pinned in the producercheckpoints of the pbft config.
+func (a *API) GetProducerCheckpoints() []params.ProducerCheckpoint {
+	return a.pbft.producerSnapshots.checkpoints()
+}
+
+// GetProducerStats returns the proposals made and missed, the votes and the
+// view changes of every current producer over the last finalized blocks, and
+// when each producer was last seen signing.
+func (a *API) GetProducerStats() *dpos.ProducerStatsReport {
+	return a.pbft.GetProducerStats()
+}
+
 func (a *API) Dispatcher() *dpos.Dispatcher {
 	return a.pbft.dispatcher
 }
@@ -67,6 +104,6 @@ func (a *API) Dispatcher() *dpos.Dispatcher {
 //	return a.pbft.account
 //}
 
-func (a *API) Network() *dpos.Network {
+func (a *API) Network() dpos.DPOSNetwork {
 	return a.pbft.network
 }
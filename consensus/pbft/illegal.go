@@ -0,0 +1,392 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+)
+
+const (
+	illegalProposalType uint8 = iota
+	illegalVoteType
+)
+
+var (
+	errIllegalHeightMismatch = errors.New("evidence height and block height should match")
+	errIllegalBlockMismatch  = errors.New("proposal hash and block should match")
+	errIllegalVoteMismatch   = errors.New("vote and proposal should match")
+	errIllegalDiffHeight     = errors.New("should be in same height")
+	errIllegalSameEvidence   = errors.New("evidences can not be same")
+	errIllegalEvidenceOrder  = errors.New("evidence order error")
+	errIllegalDiffSponsor    = errors.New("should be same sponsor")
+	errIllegalDiffSigner     = errors.New("should be same signer")
+	errIllegalDiffView       = errors.New("should in same view")
+	errIllegalNotProducer    = errors.New("offender is not a producer")
+	errUnknownIllegalType    = errors.New("unknown illegal evidence type")
+	errIllegalBlockNotFound  = errors.New("block of proposal not found")
+)
+
+// IllegalEvidence is a verified proof that a producer signed two conflicting
+// proposals or votes in the same view. Data is the hex encoded evidence
+// payload, ready to be forwarded to the main chain.
+type IllegalEvidence struct {
+	Hash       string `json:"hash"`
+	Type       string `json:"type"`
+	Offender   string `json:"offender"`
+	Height     uint32 `json:"height"`
+	ViewOffset uint32 `json:"viewoffset"`
+	Data       string `json:"data"`
+}
+
+// illegalEvidences keeps the verified evidences in arrival order and
+// journals every new one to disk.
+type illegalEvidences struct {
+	mu      sync.RWMutex
+	known   map[ecom.Uint256]struct{}
+	list    []*IllegalEvidence
+	journal *illegalJournal
+}
+
+// newIllegalEvidences creates the evidence set and reloads the evidences
+// journaled in dataDir, an empty dataDir keeps them in memory only.
+func newIllegalEvidences(dataDir string) *illegalEvidences {
+	evidences := &illegalEvidences{
+		known: make(map[ecom.Uint256]struct{}),
+	}
+	if dataDir == "" {
+		return evidences
+	}
+	evidences.journal = newIllegalJournal(dataDir)
+	if err := evidences.journal.load(evidences.restore); err != nil {
+		log.Warn("Failed to load illegal evidence journal", "err", err)
+	}
+	return evidences
+}
+
+// restore adds a journaled record without writing it to the journal again.
+func (e *illegalEvidences) restore(record *illegalRecord) error {
+	evidence, hash, err := decodeIllegalRecord(record)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.known[hash]; ok {
+		return nil
+	}
+	e.known[hash] = struct{}{}
+	e.list = append(e.list, evidence)
+	return nil
+}
+
+// add records the evidence, it reports false if the evidence is already known.
+func (e *illegalEvidences) add(record *illegalRecord) (bool, error) {
+	evidence, hash, err := decodeIllegalRecord(record)
+	if err != nil {
+		return false, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.known[hash]; ok {
+		return false, nil
+	}
+	if e.journal != nil {
+		if err := e.journal.insert(record); err != nil {
+			log.Error("Failed to journal illegal evidence", "hash", hash.String(), "err", err)
+		}
+	}
+	e.known[hash] = struct{}{}
+	e.list = append(e.list, evidence)
+	return true, nil
+}
+
+func (e *illegalEvidences) has(hash ecom.Uint256) bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	_, ok := e.known[hash]
+	return ok
+}
+
+func (e *illegalEvidences) all() []IllegalEvidence {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	list := make([]IllegalEvidence, len(e.list))
+	for i, evidence := range e.list {
+		list[i] = *evidence
+	}
+	return list
+}
+
+func (e *illegalEvidences) close() error {
+	if e.journal == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.journal.close()
+}
+
+func decodeIllegalRecord(record *illegalRecord) (*IllegalEvidence, ecom.Uint256, error) {
+	switch record.Type {
+	case illegalProposalType:
+		proposals := new(payload.DPOSIllegalProposals)
+		if err := proposals.Deserialize(bytes.NewReader(record.Data), payload.IllegalProposalVersion); err != nil {
+			return nil, ecom.EmptyHash, err
+		}
+		hash := proposals.Hash()
+		return &IllegalEvidence{
+			Hash:       hash.String(),
+			Type:       "proposal",
+			Offender:   common.Bytes2Hex(proposals.Evidence.Proposal.Sponsor),
+			Height:     proposals.GetBlockHeight(),
+			ViewOffset: proposals.Evidence.Proposal.ViewOffset,
+			Data:       common.Bytes2Hex(record.Data),
+		}, hash, nil
+	case illegalVoteType:
+		votes := new(payload.DPOSIllegalVotes)
+		if err := votes.Deserialize(bytes.NewReader(record.Data), payload.IllegalVoteVersion); err != nil {
+			return nil, ecom.EmptyHash, err
+		}
+		hash := votes.Hash()
+		return &IllegalEvidence{
+			Hash:       hash.String(),
+			Type:       "vote",
+			Offender:   common.Bytes2Hex(votes.Evidence.Vote.Signer),
+			Height:     votes.GetBlockHeight(),
+			ViewOffset: votes.Evidence.Proposal.ViewOffset,
+			Data:       common.Bytes2Hex(record.Data),
+		}, hash, nil
+	}
+	return nil, ecom.EmptyHash, errUnknownIllegalType
+}
+
+// checkProposalEvidence verifies the proposal signature and that the proposal
+// is for the block header carried by the evidence.
+func checkProposalEvidence(evidence *payload.ProposalEvidence) error {
+	if err := dpos.CheckProposal(&evidence.Proposal); err != nil {
+		return err
+	}
+	header := new(types.Header)
+	if err := rlp.DecodeBytes(evidence.BlockHeader, header); err != nil {
+		return err
+	}
+	if header.Number == nil || header.Number.Uint64() != uint64(evidence.BlockHeight) {
+		return errIllegalHeightMismatch
+	}
+	sealHash := SealHash(header)
+	if !bytes.Equal(sealHash.Bytes(), evidence.Proposal.BlockHash.Bytes()) {
+		return errIllegalBlockMismatch
+	}
+	return nil
+}
+
+func checkVoteEvidence(evidence *payload.VoteEvidence) error {
+	if err := checkProposalEvidence(&evidence.ProposalEvidence); err != nil {
+		return err
+	}
+	if err := dpos.CheckVote(&evidence.Vote); err != nil {
+		return err
+	}
+	if !evidence.Proposal.Hash().IsEqual(evidence.Vote.ProposalHash) {
+		return errIllegalVoteMismatch
+	}
+	return nil
+}
+
+// CheckIllegalProposals verifies that both proposals are validly signed by the
+// same sponsor for different blocks at the same height and view.
+func CheckIllegalProposals(d *payload.DPOSIllegalProposals) error {
+	if err := checkProposalEvidence(&d.Evidence); err != nil {
+		return err
+	}
+	if err := checkProposalEvidence(&d.CompareEvidence); err != nil {
+		return err
+	}
+	if d.Evidence.BlockHeight != d.CompareEvidence.BlockHeight {
+		return errIllegalDiffHeight
+	}
+	hash, compareHash := d.Evidence.Proposal.Hash(), d.CompareEvidence.Proposal.Hash()
+	if hash.IsEqual(compareHash) {
+		return errIllegalSameEvidence
+	}
+	if hash.Compare(compareHash) > 0 {
+		return errIllegalEvidenceOrder
+	}
+	if !bytes.Equal(d.Evidence.Proposal.Sponsor, d.CompareEvidence.Proposal.Sponsor) {
+		return errIllegalDiffSponsor
+	}
+	if d.Evidence.Proposal.ViewOffset != d.CompareEvidence.Proposal.ViewOffset {
+		return errIllegalDiffView
+	}
+	return nil
+}
+
+// CheckIllegalVotes verifies that both votes are validly signed by the same
+// signer for different proposals of one sponsor at the same height and view.
+func CheckIllegalVotes(d *payload.DPOSIllegalVotes) error {
+	if err := checkVoteEvidence(&d.Evidence); err != nil {
+		return err
+	}
+	if err := checkVoteEvidence(&d.CompareEvidence); err != nil {
+		return err
+	}
+	if d.Evidence.BlockHeight != d.CompareEvidence.BlockHeight {
+		return errIllegalDiffHeight
+	}
+	hash, compareHash := d.Evidence.Vote.Hash(), d.CompareEvidence.Vote.Hash()
+	if hash.IsEqual(compareHash) {
+		return errIllegalSameEvidence
+	}
+	if hash.Compare(compareHash) > 0 {
+		return errIllegalEvidenceOrder
+	}
+	if !bytes.Equal(d.Evidence.Vote.Signer, d.CompareEvidence.Vote.Signer) {
+		return errIllegalDiffSigner
+	}
+	if !bytes.Equal(d.Evidence.Proposal.Sponsor, d.CompareEvidence.Proposal.Sponsor) {
+		return errIllegalDiffSponsor
+	}
+	if d.Evidence.Proposal.ViewOffset != d.CompareEvidence.Proposal.ViewOffset {
+		return errIllegalDiffView
+	}
+	return nil
+}
+
+// addIllegalProposals verifies and records the evidence, it reports false if
+// the evidence is already known.
+func (p *Pbft) addIllegalProposals(proposals *payload.DPOSIllegalProposals) (bool, error) {
+	if p.illegalEvidences.has(proposals.Hash()) {
+		return false, nil
+	}
+	if err := CheckIllegalProposals(proposals); err != nil {
+		return false, err
+	}
+	if !p.dispatcher.GetConsensusView().IsProducers(proposals.Evidence.Proposal.Sponsor) {
+		return false, errIllegalNotProducer
+	}
+	record := &illegalRecord{
+		Type: illegalProposalType,
+		Data: proposals.Data(payload.IllegalProposalVersion),
+	}
+	return p.illegalEvidences.add(record)
+}
+
+// addIllegalVotes verifies and records the evidence, it reports false if the
+// evidence is already known.
+func (p *Pbft) addIllegalVotes(votes *payload.DPOSIllegalVotes) (bool, error) {
+	if p.illegalEvidences.has(votes.Hash()) {
+		return false, nil
+	}
+	if err := CheckIllegalVotes(votes); err != nil {
+		return false, err
+	}
+	if !p.dispatcher.GetConsensusView().IsProducers(votes.Evidence.Vote.Signer) {
+		return false, errIllegalNotProducer
+	}
+	record := &illegalRecord{
+		Type: illegalVoteType,
+		Data: votes.Data(payload.IllegalVoteVersion),
+	}
+	return p.illegalEvidences.add(record)
+}
+
+// proposalEvidence builds the evidence of proposal from its block in the pool.
+func (p *Pbft) proposalEvidence(proposal *payload.DPOSProposal) (*payload.ProposalEvidence, error) {
+	dblock, ok := p.blockPool.GetBlock(proposal.BlockHash)
+	if !ok {
+		return nil, errIllegalBlockNotFound
+	}
+	block, ok := dblock.(*types.Block)
+	if !ok {
+		return nil, errIllegalBlockNotFound
+	}
+	header, err := rlp.EncodeToBytes(block.Header())
+	if err != nil {
+		return nil, err
+	}
+	return &payload.ProposalEvidence{
+		Proposal:    *proposal,
+		BlockHeader: header,
+		BlockHeight: uint32(block.NumberU64()),
+	}, nil
+}
+
+// onIllegalProposalsDetected turns the conflicting proposals found by the
+// dispatcher into evidence, records it and broadcasts it to the producers.
+func (p *Pbft) onIllegalProposalsDetected(evt *dpos.IllegalProposalsEvent) {
+	evidence, err := p.proposalEvidence(evt.Proposal)
+	if err != nil {
+		log.Error("[onIllegalProposalsDetected] build evidence failed", "proposal", evt.Proposal.Hash().String(), "err", err)
+		return
+	}
+	compareEvidence, err := p.proposalEvidence(evt.CompareProposal)
+	if err != nil {
+		log.Error("[onIllegalProposalsDetected] build evidence failed", "proposal", evt.CompareProposal.Hash().String(), "err", err)
+		return
+	}
+	proposals := &payload.DPOSIllegalProposals{Evidence: *evidence, CompareEvidence: *compareEvidence}
+	if evt.Proposal.Hash().Compare(evt.CompareProposal.Hash()) > 0 {
+		proposals.Evidence, proposals.CompareEvidence = proposals.CompareEvidence, proposals.Evidence
+	}
+	added, err := p.addIllegalProposals(proposals)
+	if err != nil {
+		log.Error("[onIllegalProposalsDetected] invalid evidence", "err", err)
+		return
+	}
+	if added {
+		p.BroadMessage(&msg.IllegalProposals{Proposals: *proposals})
+	}
+}
+
+// onIllegalVotesDetected turns the conflicting votes found by the dispatcher
+// into evidence, records it and broadcasts it to the producers.
+func (p *Pbft) onIllegalVotesDetected(evt *dpos.IllegalVotesEvent) {
+	evidence, err := p.proposalEvidence(evt.Proposal)
+	if err != nil {
+		log.Error("[onIllegalVotesDetected] build evidence failed", "proposal", evt.Proposal.Hash().String(), "err", err)
+		return
+	}
+	compareEvidence, err := p.proposalEvidence(evt.CompareProposal)
+	if err != nil {
+		log.Error("[onIllegalVotesDetected] build evidence failed", "proposal", evt.CompareProposal.Hash().String(), "err", err)
+		return
+	}
+	votes := &payload.DPOSIllegalVotes{
+		Evidence:        payload.VoteEvidence{ProposalEvidence: *evidence, Vote: *evt.Vote},
+		CompareEvidence: payload.VoteEvidence{ProposalEvidence: *compareEvidence, Vote: *evt.CompareVote},
+	}
+	if evt.Vote.Hash().Compare(evt.CompareVote.Hash()) > 0 {
+		votes.Evidence, votes.CompareEvidence = votes.CompareEvidence, votes.Evidence
+	}
+	added, err := p.addIllegalVotes(votes)
+	if err != nil {
+		log.Error("[onIllegalVotesDetected] invalid evidence", "err", err)
+		return
+	}
+	if added {
+		p.BroadMessage(&msg.IllegalVotes{Votes: *votes})
+	}
+}
+
+// GetIllegalEvidence returns the verified illegal evidences in arrival order.
+func (p *Pbft) GetIllegalEvidence() []IllegalEvidence {
+	if p.illegalEvidences == nil {
+		return []IllegalEvidence{}
+	}
+	return p.illegalEvidences.all()
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+const illegalJournalFileName = "illegalevidence.rlp"
+
+// illegalRecord is one verified illegal evidence as it is stored on disk,
+// Data holds the evidence payload in its main chain serialization.
+type illegalRecord struct {
+	Type uint8
+	Data []byte
+}
+
+// illegalJournal is an append only log of verified illegal evidences, so the
+// evidences survive node restarts until they are forwarded to the main chain.
+type illegalJournal struct {
+	path   string         // Filesystem path to store the evidences at
+	writer io.WriteCloser // Output stream to write new evidences into
+}
+
+// newIllegalJournal creates an illegal evidence journal inside dir.
+func newIllegalJournal(dir string) *illegalJournal {
+	return &illegalJournal{
+		path: filepath.Join(dir, illegalJournalFileName),
+	}
+}
+
+// load parses the journal from disk and hands every record to add.
+func (journal *illegalJournal) load(add func(record *illegalRecord) error) error {
+	// Skip the parsing if the journal file doesn't exist at all
+	if _, err := os.Stat(journal.path); os.IsNotExist(err) {
+		return nil
+	}
+	input, err := os.Open(journal.path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(input, 0)
+	total, dropped := 0, 0
+	for {
+		record := new(illegalRecord)
+		if err := stream.Decode(record); err != nil {
+			if err != io.EOF {
+				log.Warn("Illegal evidence journal is truncated", "path", journal.path, "err", err)
+			}
+			break
+		}
+		total++
+		if err := add(record); err != nil {
+			log.Debug("Failed to add journaled illegal evidence", "err", err)
+			dropped++
+		}
+	}
+	log.Info("Loaded illegal evidence journal", "evidences", total, "dropped", dropped)
+	return nil
+}
+
+// insert appends the record to the journal, opening it on first use.
+func (journal *illegalJournal) insert(record *illegalRecord) error {
+	if journal.writer == nil {
+		sink, err := os.OpenFile(journal.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		journal.writer = sink
+	}
+	return rlp.Encode(journal.writer, record)
+}
+
+// close closes the journal file.
+func (journal *illegalJournal) close() error {
+	var err error
+	if journal.writer != nil {
+		err = journal.writer.Close()
+		journal.writer = nil
+	}
+	return err
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elacrypto "github.com/elastos/Elastos.ELA/crypto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+func init() {
+	dpos.InitLog(0, 0, 0, "")
+}
+
+// illegalTestSigner signs proposals and votes the way a dpos account does.
+type illegalTestSigner struct {
+	key       *ecdsa.PrivateKey
+	publicKey []byte
+}
+
+func newIllegalTestSigner(t *testing.T) *illegalTestSigner {
+	key, err := ecdsa.GenerateKey(elacrypto.DefaultCurve, rand.Reader)
+	assert.NoError(t, err)
+	publicKey, err := (&elacrypto.PublicKey{X: key.X, Y: key.Y}).EncodePoint(true)
+	assert.NoError(t, err)
+	return &illegalTestSigner{key: key, publicKey: publicKey}
+}
+
+func (s *illegalTestSigner) sign(t *testing.T, data []byte) []byte {
+	signature, err := s.signData(data)
+	assert.NoError(t, err)
+	return signature
+}
+
+func (s *illegalTestSigner) signData(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	r, v, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, elacrypto.SignatureLength)
+	rBytes, vBytes := r.Bytes(), v.Bytes()
+	copy(signature[elacrypto.SignerLength-len(rBytes):], rBytes)
+	copy(signature[elacrypto.SignatureLength-len(vBytes):], vBytes)
+	return signature, nil
+}
+
+func newProposalEvidence(t *testing.T, sponsor *illegalTestSigner, height uint64, time uint64, viewOffset uint32) payload.ProposalEvidence {
+	header := &types.Header{Number: new(big.Int).SetUint64(height), Difficulty: big.NewInt(1), Time: time}
+	data, err := rlp.EncodeToBytes(header)
+	assert.NoError(t, err)
+	sealHash, err := ecom.Uint256FromBytes(SealHash(header).Bytes())
+	assert.NoError(t, err)
+	proposal := payload.DPOSProposal{
+		Sponsor:    sponsor.publicKey,
+		BlockHash:  *sealHash,
+		ViewOffset: viewOffset,
+	}
+	proposal.Sign = sponsor.sign(t, proposal.Data())
+	return payload.ProposalEvidence{
+		Proposal:    proposal,
+		BlockHeader: data,
+		BlockHeight: uint32(height),
+	}
+}
+
+func orderIllegalProposals(a, b payload.ProposalEvidence) *payload.DPOSIllegalProposals {
+	if a.Proposal.Hash().Compare(b.Proposal.Hash()) > 0 {
+		a, b = b, a
+	}
+	return &payload.DPOSIllegalProposals{Evidence: a, CompareEvidence: b}
+}
+
+func TestCheckIllegalProposals(t *testing.T) {
+	sponsor, other := newIllegalTestSigner(t), newIllegalTestSigner(t)
+
+	first := newProposalEvidence(t, sponsor, 10, 1, 0)
+	second := newProposalEvidence(t, sponsor, 10, 2, 0)
+	assert.NoError(t, CheckIllegalProposals(orderIllegalProposals(first, second)))
+
+	illegal := orderIllegalProposals(first, second)
+	illegal.Evidence, illegal.CompareEvidence = illegal.CompareEvidence, illegal.Evidence
+	assert.Equal(t, errIllegalEvidenceOrder, CheckIllegalProposals(illegal))
+
+	assert.Equal(t, errIllegalSameEvidence, CheckIllegalProposals(&payload.DPOSIllegalProposals{Evidence: first, CompareEvidence: first}))
+	assert.Equal(t, errIllegalDiffSponsor, CheckIllegalProposals(orderIllegalProposals(first, newProposalEvidence(t, other, 10, 2, 0))))
+	assert.Equal(t, errIllegalDiffView, CheckIllegalProposals(orderIllegalProposals(first, newProposalEvidence(t, sponsor, 10, 2, 1))))
+	assert.Equal(t, errIllegalDiffHeight, CheckIllegalProposals(orderIllegalProposals(first, newProposalEvidence(t, sponsor, 11, 2, 0))))
+
+	forged := second
+	forged.BlockHeight = 11
+	assert.Equal(t, errIllegalHeightMismatch, CheckIllegalProposals(orderIllegalProposals(first, forged)))
+
+	forged = second
+	forged.BlockHeader = first.BlockHeader
+	assert.Equal(t, errIllegalBlockMismatch, CheckIllegalProposals(orderIllegalProposals(first, forged)))
+
+	forged = second
+	forged.Proposal.Sign = first.Proposal.Sign
+	assert.Error(t, CheckIllegalProposals(orderIllegalProposals(first, forged)))
+}
+
+func TestCheckIllegalVotes(t *testing.T) {
+	sponsor, signer := newIllegalTestSigner(t), newIllegalTestSigner(t)
+
+	newVoteEvidence := func(evidence payload.ProposalEvidence, accept bool) payload.VoteEvidence {
+		vote := payload.DPOSProposalVote{
+			ProposalHash: evidence.Proposal.Hash(),
+			Signer:       signer.publicKey,
+			Accept:       accept,
+		}
+		vote.Sign = signer.sign(t, vote.Data())
+		return payload.VoteEvidence{ProposalEvidence: evidence, Vote: vote}
+	}
+	order := func(a, b payload.VoteEvidence) *payload.DPOSIllegalVotes {
+		if a.Vote.Hash().Compare(b.Vote.Hash()) > 0 {
+			a, b = b, a
+		}
+		return &payload.DPOSIllegalVotes{Evidence: a, CompareEvidence: b}
+	}
+
+	first := newVoteEvidence(newProposalEvidence(t, sponsor, 10, 1, 0), true)
+	second := newVoteEvidence(newProposalEvidence(t, sponsor, 10, 2, 0), true)
+	assert.NoError(t, CheckIllegalVotes(order(first, second)))
+
+	// accepting and rejecting the same proposal is a conflict too
+	assert.NoError(t, CheckIllegalVotes(order(first, newVoteEvidence(first.ProposalEvidence, false))))
+
+	mismatch := second
+	mismatch.ProposalEvidence = newProposalEvidence(t, sponsor, 10, 3, 0)
+	assert.Equal(t, errIllegalVoteMismatch, CheckIllegalVotes(order(first, mismatch)))
+
+	other := newVoteEvidence(newProposalEvidence(t, newIllegalTestSigner(t), 10, 2, 0), true)
+	assert.Equal(t, errIllegalDiffSponsor, CheckIllegalVotes(order(first, other)))
+}
+
+func TestIllegalEvidencesJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "illegal-evidence")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sponsor := newIllegalTestSigner(t)
+	proposals := orderIllegalProposals(newProposalEvidence(t, sponsor, 10, 1, 0),
+		newProposalEvidence(t, sponsor, 10, 2, 0))
+	record := &illegalRecord{
+		Type: illegalProposalType,
+		Data: proposals.Data(payload.IllegalProposalVersion),
+	}
+
+	evidences := newIllegalEvidences(dir)
+	added, err := evidences.add(record)
+	assert.NoError(t, err)
+	assert.True(t, added)
+	added, err = evidences.add(record)
+	assert.NoError(t, err)
+	assert.False(t, added)
+	assert.NoError(t, evidences.close())
+
+	_, err = evidences.add(&illegalRecord{Type: 7})
+	assert.Equal(t, errUnknownIllegalType, err)
+
+	reloaded := newIllegalEvidences(dir)
+	defer reloaded.close()
+	assert.True(t, reloaded.has(proposals.Hash()))
+	list := reloaded.all()
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "proposal", list[0].Type)
+		assert.Equal(t, proposals.Hash().String(), list[0].Hash)
+		assert.Equal(t, uint32(10), list[0].Height)
+	}
+}
@@ -1,8 +1,8 @@
 package pbft
 
 import (
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/dpos_msg"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 
 	dpeer "github.com/elastos/Elastos.ELA/dpos/p2p/peer"
 	"github.com/elastos/Elastos.ELA/events"
@@ -49,8 +49,31 @@ func (p *Pbft) HasProducerMajorityCount(count int) bool {
 	return p.dispatcher.GetConsensusView().HasProducerMajorityCount(count)
 }
 
-func (p *Pbft) GetBridgeArbiters() crypto.Keypair {
-	return p.bridgeAccount
+// GetBridgeArbiters returns the signer of the bridge arbiter key, nil if the
+// node has none. It follows the rotations of the key.
+func (p *Pbft) GetBridgeArbiters() signer.Signer {
+	if p.bridgeSigner.Current() == nil {
+		return nil
+	}
+	return p.bridgeSigner
+}
+
+// BridgeSigner returns the rotatable signer of the bridge arbiter key, which
+// holds no key if the node has none yet.
+func (p *Pbft) BridgeSigner() *signer.Rotatable {
+	return p.bridgeSigner
+}
+
+// RotateBridgeSigner switches the bridge arbiter key to next without a
+// restart, and returns the handover signed by the previous key if any.
+func (p *Pbft) RotateBridgeSigner(next signer.Signer) (*signer.Handover, error) {
+	return p.bridgeSigner.Rotate(next)
+}
+
+// BridgeHandovers returns the handovers signed by the rotations of the
+// bridge arbiter key.
+func (p *Pbft) BridgeHandovers() []signer.Handover {
+	return p.bridgeSigner.Handovers()
 }
 
 func (p *Pbft) GetTotalProducerCount() int {
@@ -0,0 +1,100 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"errors"
+
+	"github.com/pgprotocol/pgp-chain/consensus"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+var (
+	// errMainChainSnapshotNotActive is returned if a block carries a main
+	// chain snapshot before the main chain oracle fork.
+	errMainChainSnapshotNotActive = errors.New("main chain snapshot before fork")
+
+	// errMissingMainChainSnapshot is returned if a block after the main chain
+	// oracle fork does not carry a main chain snapshot.
+	errMissingMainChainSnapshot = errors.New("missing main chain snapshot")
+
+	// errMainChainSnapshotHeight is returned if the main chain height of a
+	// snapshot is below the one of its parent.
+	errMainChainSnapshotHeight = errors.New("main chain snapshot height below parent")
+
+	// errMainChainSnapshotAhead is returned if the main chain height of a
+	// snapshot is not known to the local spv yet.
+	errMainChainSnapshotAhead = errors.New("main chain snapshot ahead of spv")
+)
+
+// mainChainSnapshot returns the main chain snapshot header is mined against,
+// at the best height of spv. The answers to the main chain precompiles are
+// recorded into it as the block is executed.
+func (p *Pbft) mainChainSnapshot(header, parent *types.Header) (*types.MainChainSnapshot, error) {
+	snapshot := &types.MainChainSnapshot{Height: spv.GetSpvHeight()}
+	previous, _, err := types.SplitMainChainSnapshot(parent.Extra)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil && previous.Height > snapshot.Height {
+		return nil, errMainChainSnapshotAhead
+	}
+	return snapshot, nil
+}
+
+// verifyMainChainSnapshot checks header carries a main chain snapshot once
+// they are active, whose height does not go below the one of its parent. It
+// does not depend on the local spv, the block is executed against the
+// answers committed to.
+func verifyMainChainSnapshot(chain consensus.ChainReader, header, parent *types.Header) error {
+	snapshot, _, err := types.SplitMainChainSnapshot(header.Extra)
+	if err != nil {
+		return err
+	}
+	if !chain.Config().IsMainChainOracle(header.Number) {
+		if snapshot != nil {
+			return errMainChainSnapshotNotActive
+		}
+		return nil
+	}
+	if snapshot == nil {
+		return errMissingMainChainSnapshot
+	}
+	previous, _, err := types.SplitMainChainSnapshot(parent.Extra)
+	if err != nil {
+		return err
+	}
+	if previous != nil && snapshot.Height < previous.Height {
+		return errMainChainSnapshotHeight
+	}
+	return nil
+}
+
+// verifyMainChainAnswers checks the main chain snapshot of a proposed block
+// against the local spv: its height is known and the answers it commits to
+// are the ones of spv. Producers vote for what their spv confirms, blocks
+// imported later are executed against the committed answers only.
+func verifyMainChainAnswers(header *types.Header) error {
+	snapshot, _, err := types.SplitMainChainSnapshot(header.Extra)
+	if err != nil || snapshot == nil {
+		return err
+	}
+	if snapshot.Height > spv.GetSpvHeight() {
+		return errMainChainSnapshotAhead
+	}
+	return vm.VerifyMainChainAnswers(header)
+}
+
+// sealedConfirm returns the confirm in the extra data of a header, after the
+// main chain snapshot and producer commitment if there are.
+func sealedConfirm(extra []byte) []byte {
+	if _, _, confirm, err := types.SplitSealedExtra(extra); err == nil {
+		return confirm
+	}
+	return extra
+}
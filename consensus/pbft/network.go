@@ -43,10 +43,23 @@ func (p *Pbft) StartProposal(block *types.Block) (*payload.DPOSProposal, error)
 	if err != nil {
 		return nil, err
 	}
-	proposal, err := dpos.StartProposal(p.account, *hash, p.dispatcher.GetConsensusView().GetViewOffset())
-	if err != nil {
-		log.Error("Start proposal error", "err", err)
-		return nil, err
+	height, viewOffset := block.NumberU64(), p.dispatcher.GetConsensusView().GetViewOffset()
+	proposal := p.wal.proposal(height, viewOffset)
+	if proposal != nil && !proposal.BlockHash.IsEqual(*hash) {
+		log.Warn("[StartProposal] already proposed in this view", "height", height, "viewOffset", viewOffset,
+			"proposal", proposal.Hash().String())
+		return nil, errWALConflict
+	}
+	if proposal == nil {
+		proposal, err = dpos.StartProposal(p.account, *hash, viewOffset)
+		if err != nil {
+			log.Error("Start proposal error", "err", err)
+			return nil, err
+		}
+		if err := p.wal.writeProposal(height, proposal); err != nil {
+			log.Error("write consensus WAL error", "err", err)
+			return nil, err
+		}
 	}
 
 	var id peer.PID
@@ -233,7 +246,7 @@ func (p *Pbft) OnBlock(id peer.PID, block *dmsg.BlockMsg) {
 	if err != nil {
 		panic("OnBlock Decode Block Msg error:" + err.Error())
 	}
-	if len(b.Extra()) > extraVanity {
+	if len(sealedConfirm(b.Extra())) > extraVanity {
 		p.OnBlockReceived(id, block, true)
 		return
 	}
@@ -281,6 +294,20 @@ func (p *Pbft) OnInsertBlock(block *types.Block) bool {
 	if p.dispatcher == nil {
 		return false
 	}
+	if err := p.wal.prune(block.NumberU64()); err != nil {
+		log.Error("prune consensus WAL error", "err", err)
+	}
+	p.aggregateVotes.prune(block.NumberU64())
+	p.pipeline.inserted(block, p.chain.GetCanonicalHash)
+	if block.MixDigest() != (common.Hash{}) {
+		if err := p.producerTransitions.record(block.Header()); err != nil {
+			log.Error("record producer transition error", "height", block.NumberU64(), "err", err)
+		}
+	}
+	p.storeProducerSnapshot(p.chain, block.Header())
+	if block.NumberU64()%producerStatsSaveInterval == 0 {
+		p.saveProducerStats()
+	}
 
 	log.Info("[OnInsertBlock]",
 		" block.Nonce ", block.Nonce(),
@@ -436,11 +463,31 @@ func (p *Pbft) OnRequestProposal(id peer.PID, hash elacom.Uint256) {
 }
 
 func (p *Pbft) OnIllegalProposalReceived(id peer.PID, proposals *payload.DPOSIllegalProposals) {
-	fmt.Println("OnIllegalProposalReceived")
+	added, err := p.addIllegalProposals(proposals)
+	if err != nil {
+		log.Warn("[OnIllegalProposalReceived] invalid evidence", "from", id.String(), "err", err)
+		return
+	}
+	if !added {
+		return
+	}
+	log.Warn("[OnIllegalProposalReceived] illegal proposals", "hash", proposals.Hash().String(),
+		"sponsor", common.Bytes2Hex(proposals.Evidence.Proposal.Sponsor), "height", proposals.GetBlockHeight())
+	p.BroadMessageExcept(&msg.IllegalProposals{Proposals: *proposals}, id)
 }
 
 func (p *Pbft) OnIllegalVotesReceived(id peer.PID, votes *payload.DPOSIllegalVotes) {
-	fmt.Println("OnIllegalVotesReceived")
+	added, err := p.addIllegalVotes(votes)
+	if err != nil {
+		log.Warn("[OnIllegalVotesReceived] invalid evidence", "from", id.String(), "err", err)
+		return
+	}
+	if !added {
+		return
+	}
+	log.Warn("[OnIllegalVotesReceived] illegal votes", "hash", votes.Hash().String(),
+		"signer", common.Bytes2Hex(votes.Evidence.Vote.Signer), "height", votes.GetBlockHeight())
+	p.BroadMessageExcept(&msg.IllegalVotes{Votes: *votes}, id)
 }
 
 func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
@@ -481,7 +528,7 @@ func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
 	if err != nil {
 		log.Error("Process Proposal error", "err", err)
 		if isSendReject {
-			voteMsg = p.dispatcher.RejectProposal(proposal, p.account)
+			voteMsg = p.signVote(proposal, false)
 		} else if !handled {
 			pubKey := common.Bytes2Hex(id[:])
 			p.notHandledProposal[pubKey] = struct{}{}
@@ -500,9 +547,9 @@ func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
 
 	} else if isBadProposal {
 		log.Info("bad proposal reject")
-		voteMsg = p.dispatcher.RejectProposal(proposal, p.account)
+		voteMsg = p.signVote(proposal, false)
 	} else {
-		voteMsg = p.dispatcher.AcceptProposal(proposal, p.account)
+		voteMsg = p.signVote(proposal, true)
 	}
 
 	if handled {
@@ -510,15 +557,62 @@ func (p *Pbft) OnProposalReceived(id peer.PID, proposal *payload.DPOSProposal) {
 		p.notHandledProposal = make(map[string]struct{})
 	}
 	if voteMsg != nil && !p.dispatcher.GetProposalProcessFinished() {
+		if voteMsg.Vote.Accept {
+			p.sendAggregateVote(proposal)
+		}
 		p.BroadMessage(voteMsg)
+		p.dispatcher.RecordVote(&voteMsg.Vote)
 		p.dispatcher.SetProposalProcessFinished()
 	}
 }
 
+// proposalHeight returns the height of the block proposal is for.
+func (p *Pbft) proposalHeight(proposal *payload.DPOSProposal) uint64 {
+	if block, ok := p.blockPool.GetBlock(proposal.BlockHash); ok {
+		return block.GetHeight()
+	}
+	return p.chain.CurrentHeader().Number.Uint64() + 1
+}
+
+// signVote returns the vote of this producer for proposal. The vote is
+// written to the consensus WAL before it is returned. If
+// the WAL already holds a vote for the same height and view, that vote is
+// returned again when it matches and no vote is signed when it conflicts.
+func (p *Pbft) signVote(proposal *payload.DPOSProposal, accept bool) *msg.Vote {
+	height := p.proposalHeight(proposal)
+	if voted := p.wal.vote(height, proposal.ViewOffset); voted != nil {
+		if !voted.ProposalHash.IsEqual(proposal.Hash()) || voted.Accept != accept {
+			log.Warn("[signVote] already voted in this view", "height", height, "viewOffset", proposal.ViewOffset,
+				"vote", voted.Hash().String(), "proposal", proposal.Hash().String())
+			return nil
+		}
+		command := msg.CmdAcceptVote
+		if !accept {
+			command = msg.CmdRejectVote
+		}
+		return &msg.Vote{Command: command, Vote: *voted}
+	}
+	var voteMsg *msg.Vote
+	if accept {
+		voteMsg = p.dispatcher.AcceptProposal(proposal, p.account)
+	} else {
+		voteMsg = p.dispatcher.RejectProposal(proposal, p.account)
+	}
+	if voteMsg == nil {
+		return nil
+	}
+	if err := p.wal.writeVote(height, proposal.ViewOffset, &voteMsg.Vote); err != nil {
+		log.Error("write consensus WAL error", "err", err)
+		return nil
+	}
+	return voteMsg
+}
+
 func (p *Pbft) OnVoteAccepted(id peer.PID, vote *payload.DPOSProposalVote) {
 	if !p.IsProducer() {
 		return
 	}
+	p.dispatcher.RecordVote(vote)
 	if !p.dispatcher.GetConsensusView().IsRunning() {
 		return
 	}
@@ -628,6 +722,7 @@ func (p *Pbft) recoverAbnormalState() bool {
 		p.recoverStarted = true
 		p.RequestAbnormalRecovering()
 		startTime := time.Now()
+		timeout := time.Duration(p.cfg.TimingAt(p.chain.CurrentBlock().NumberU64()+1).RecoverTimeout) * time.Second
 		go func() {
 			for {
 				var count int
@@ -638,7 +733,7 @@ func (p *Pbft) recoverAbnormalState() bool {
 					p.OnRecoverTimeout()
 					break
 				}
-				if time.Now().Sub(startTime) > time.Second*3 {
+				if time.Now().Sub(startTime) > timeout {
 					p.OnRecoverTimeout()
 					break
 				}
@@ -768,6 +863,7 @@ func (p *Pbft) OnBlockReceived(id peer.PID, b *dmsg.BlockMsg, confirmed bool) {
 		log.Error("insert chain is known block", "hash", block.Hash().String(), "number", block.NumberU64())
 		return
 	}
+	p.invalidatePipelined(block)
 	if _, err := p.chain.InsertChain(blocks); err != nil {
 		if p.OnInsertChainError != nil {
 			p.OnInsertChainError(id, block, err)
@@ -17,7 +17,8 @@ import (
 	"time"
 
 	"github.com/elastos/Elastos.ELA/core/types/payload"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
+	"github.com/pgprotocol/pgp-chain/chainbridge-core/signer"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/common/math"
 	"github.com/pgprotocol/pgp-chain/consensus"
@@ -55,6 +56,11 @@ const (
 	// maxRequestedBlocks is the maximum number of requested block
 	// hashes to store in memory.
 	maxRequestedBlocks = msg.MaxInvPerMsg
+
+	// producerStatsFileName is the file the producer statistics are saved to
+	// every producerStatsSaveInterval blocks and on close.
+	producerStatsFileName     = "producerstats.rlp"
+	producerStatsSaveInterval = 64
 )
 
 var (
@@ -92,21 +98,27 @@ var (
 	errChainForkBlock = errors.New("chain fork block")
 
 	errDoubleSignBlock = errors.New("double sign block")
+
+	// errWALConflict is returned if the consensus WAL shows this producer
+	// already signed a different proposal at the same height and view.
+	errWALConflict = errors.New("already signed a different proposal in this view")
 )
 
 // Pbft is a consensus engine based on Byzantine fault-tolerant algorithm
 type Pbft struct {
-	datadir       string
-	cfg           params.PbftConfig
-	dispatcher    *dpos.Dispatcher
-	confirmCh     chan *payload.Confirm
-	unConfirmCh   chan *payload.Confirm
-	account       daccount.Account
-	bridgeAccount crypto.Keypair
-	network       *dpos.Network
-	blockPool     *dpos.BlockPool
-	chain         *core.BlockChain
-	timeSource    dtime.MedianTimeSource
+	datadir      string
+	cfg          params.PbftConfig
+	chainConfig  *params.ChainConfig
+	dispatcher   *dpos.Dispatcher
+	confirmCh    chan *payload.Confirm
+	unConfirmCh  chan *payload.Confirm
+	account      daccount.Account
+	bridgeSigner *signer.Rotatable
+	blsKey       *dpos.BlsKey
+	network      dpos.DPOSNetwork
+	blockPool    *dpos.BlockPool
+	chain        *core.BlockChain
+	timeSource   dtime.MedianTimeSource
 
 	// IsCurrent returns whether BlockChain synced to best height.
 	IsCurrent          func() bool
@@ -114,15 +126,22 @@ type Pbft struct {
 	OnDuty             func()
 	OnInsertChainError func(id peer.PID, block *types.Block, err error)
 
-	requestedBlocks    map[common.Hash]struct{}
-	requestedProposals map[ecom.Uint256]struct{}
-	statusMap          map[uint32]map[string]*dmsg.ConsensusStatus
-	notHandledProposal map[string]struct{}
+	requestedBlocks     map[common.Hash]struct{}
+	requestedProposals  map[ecom.Uint256]struct{}
+	statusMap           map[uint32]map[string]*dmsg.ConsensusStatus
+	notHandledProposal  map[string]struct{}
+	illegalEvidences    *illegalEvidences
+	wal                 *consensusWAL
+	blsPublicKeys       map[string][]byte
+	aggregateVotes      *aggregateVotes
+	producerTransitions *producerTransitions
+	producerSnapshots   *producerSnapshots
+	producerStatsPath   string
+	pipeline            *pipeline
 
 	enableViewLoop              bool
 	recoverStarted              bool
 	isRecoved                   bool
-	period                      uint64
 	isSealOver                  bool
 	isRecovering                bool
 	isSealing                   int32
@@ -144,12 +163,8 @@ func New(chainConfig *params.ChainConfig, dataDir string) *Pbft {
 	pbftKeystore := chainConfig.PbftKeyStore
 	password := []byte(chainConfig.PbftKeyStorePassWord)
 	dpos.InitLog(cfg.PrintLevel, cfg.MaxPerLogSize, cfg.MaxLogsSize, logpath)
-	producers := make([][]byte, len(cfg.Producers))
-	for i, v := range cfg.Producers {
-		producers[i] = common.Hex2Bytes(v)
-	}
 	account, err := dpos.GetDposAccount(pbftKeystore, password)
-	var bridgeAccount crypto.Keypair
+	var bridgeAccount *secp256k1.Keypair
 	if err != nil {
 		if string(password) == "" {
 			fmt.Println("create dpos account error:", err.Error(), "pbftKeystore:", pbftKeystore, "password")
@@ -168,27 +183,15 @@ func New(chainConfig *params.ChainConfig, dataDir string) *Pbft {
 		}
 	}
 	medianTimeSouce := dtime.NewMedianTime()
-	blockPeriod := 3
-	pbft := &Pbft{
-		datadir:            dataDir,
-		cfg:                *cfg,
-		confirmCh:          make(chan *payload.Confirm),
-		unConfirmCh:        make(chan *payload.Confirm),
-		account:            account,
-		bridgeAccount:      bridgeAccount,
-		requestedBlocks:    make(map[common.Hash]struct{}),
-		requestedProposals: make(map[ecom.Uint256]struct{}),
-		statusMap:          make(map[uint32]map[string]*dmsg.ConsensusStatus),
-		notHandledProposal: make(map[string]struct{}),
-		period:             uint64(blockPeriod),
-		timeSource:         medianTimeSouce,
-	}
-	blockPool := dpos.NewBlockPool(pbft.verifyConfirm, pbft.verifyBlock, DBlockSealHash)
-	pbft.blockPool = blockPool
-	var accpubkey []byte
-
+	pbft := newPbft(chainConfig, dataDir, account, bridgeAccount, medianTimeSouce)
 	if account != nil {
-		accpubkey = account.PublicKeyBytes()
+		if pbft.blsKey, err = dpos.GetBlsKey(pbftKeystore, password); err != nil {
+			fmt.Println("create GetBlsKey error:", err.Error())
+		} else if _, ok := pbft.blsPublicKeys[common.Bytes2Hex(account.PublicKeyBytes())]; !ok {
+			log.Warn("bls public key is not registered, can not sign aggregate confirms",
+				"blsPublicKey", common.Bytes2Hex(pbft.blsKey.PublicKey()),
+				"blsProof", common.Bytes2Hex(pbft.blsKey.ProofOfPossession()))
+		}
 		network, err := dpos.NewNetwork(&dpos.NetworkConfig{
 			IPAddress:         cfg.IPAddress,
 			Magic:             cfg.Magic,
@@ -198,7 +201,7 @@ func New(chainConfig *params.ChainConfig, dataDir string) *Pbft {
 			MaxNodePerHost:    cfg.MaxNodePerHost,
 			Listener:          pbft,
 			DataPath:          dposPath,
-			PublicKey:         accpubkey,
+			PublicKey:         account.PublicKeyBytes(),
 			GetCurrentHeight:  pbft.GetMainChainHeight,
 			DPoSV2StartHeight: cfg.DPoSV2StartHeight,
 			NodeVersion:       cfg.NodeVersion,
@@ -213,12 +216,70 @@ func New(chainConfig *params.ChainConfig, dataDir string) *Pbft {
 		pbft.network = network
 		pbft.subscribeEvent()
 	}
-	tolerance := time.Duration(blockPeriod) * 2 * time.Second
+	return pbft
+}
+
+// newPbft creates the engine signing with account and keeping the consensus
+// view on timeSource, the direct network is attached by the caller.
+func newPbft(chainConfig *params.ChainConfig, dataDir string, account daccount.Account,
+	bridgeAccount *secp256k1.Keypair, timeSource dtime.MedianTimeSource) *Pbft {
+	cfg := chainConfig.Pbft
+	producers := make([][]byte, len(cfg.Producers))
+	for i, v := range cfg.Producers {
+		producers[i] = common.Hex2Bytes(v)
+	}
+	pbft := &Pbft{
+		datadir:             dataDir,
+		cfg:                 *cfg,
+		chainConfig:         chainConfig,
+		confirmCh:           make(chan *payload.Confirm),
+		unConfirmCh:         make(chan *payload.Confirm),
+		account:             account,
+		bridgeSigner:        signer.NewRotatable(nil),
+		requestedBlocks:     make(map[common.Hash]struct{}),
+		requestedProposals:  make(map[ecom.Uint256]struct{}),
+		statusMap:           make(map[uint32]map[string]*dmsg.ConsensusStatus),
+		notHandledProposal:  make(map[string]struct{}),
+		illegalEvidences:    newIllegalEvidences(dataDir),
+		wal:                 newConsensusWAL(dataDir),
+		blsPublicKeys:       parseBlsPublicKeys(cfg.BlsPublicKeys, cfg.BlsProofs),
+		aggregateVotes:      newAggregateVotes(),
+		producerTransitions: newProducerTransitions(dataDir),
+		producerSnapshots:   newProducerSnapshots(dataDir),
+		pipeline:            newPipeline(cfg.Pipelined),
+		timeSource:          timeSource,
+	}
+	if bridgeAccount != nil {
+		pbft.bridgeSigner = signer.NewRotatable(signer.NewKeypairSigner(bridgeAccount))
+	}
+	pbft.blockPool = dpos.NewBlockPool(pbft.verifyConfirm, pbft.verifyBlock, DBlockSealHash)
+	var accpubkey []byte
+	if account != nil {
+		accpubkey = account.PublicKeyBytes()
+	}
 	pbft.dispatcher = dpos.NewDispatcher(producers, pbft.onConfirm, pbft.onUnConfirm, pbft.checkBPosFullVoteFork,
-		tolerance, accpubkey, medianTimeSouce, pbft, chainConfig.GetPbftBlock())
+		pbft.viewInterval, accpubkey, timeSource, pbft, chainConfig.GetPbftBlock())
+	if dataDir != "" {
+		pbft.producerStatsPath = filepath.Join(dataDir, "pbft", producerStatsFileName)
+		if err := pbft.dispatcher.GetProducerStats().Load(pbft.producerStatsPath); err != nil {
+			log.Warn("Failed to load producer stats", "err", err)
+		}
+	}
 	return pbft
 }
 
+// blockPeriod returns the minimum time in seconds between the block at number
+// and its parent.
+func (p *Pbft) blockPeriod(number uint64) uint64 {
+	return p.cfg.TimingAt(number).BlockPeriod
+}
+
+// viewInterval returns the interval of the views in the consensus on the
+// block at height.
+func (p *Pbft) viewInterval(height uint64) time.Duration {
+	return time.Duration(p.cfg.TimingAt(height).ViewInterval) * time.Second
+}
+
 func (p *Pbft) checkBPosFullVoteFork(count int) bool {
 	dpos.Info("checkBPosFullVoteFork", "p.cfg.BPosFullVoteTime  ", p.cfg.BPosFullVoteTime)
 	if p.timeSource.AdjustedTime().Unix() > p.cfg.BPosFullVoteTime {
@@ -295,6 +356,14 @@ func (p *Pbft) subscribeEvent() {
 				msg := dmsg.NewFailedWithdrawTx(failEvt.Signature, failEvt.Txid)
 				p.BroadMessage(msg)
 			}
+		case dpos.ETIllegalProposals:
+			if evt, ok := e.Data.(*dpos.IllegalProposalsEvent); ok {
+				go p.onIllegalProposalsDetected(evt)
+			}
+		case dpos.ETIllegalVotes:
+			if evt, ok := e.Data.(*dpos.IllegalVotesEvent); ok {
+				go p.onIllegalVotesDetected(evt)
+			}
 		}
 	})
 }
@@ -388,9 +457,10 @@ func (p *Pbft) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	if header.GasUsed > header.GasLimit {
 		return fmt.Errorf("invalid gasUsed: have %d, gasLimit %d", header.GasUsed, header.GasLimit)
 	}
-	// Ensure that the mix digest is zero as we don't have fork protection currently
-	if header.MixDigest != (common.Hash{}) {
-		return errInvalidMixDigest
+	// Ensure that the mix digest is zero as we don't have fork protection currently,
+	// after the producer transition fork it commits to the producers it carries
+	if err := verifyProducersCommitment(chain.Config(), header); err != nil {
+		return err
 	}
 	// Ensure that the block doesn't contain any uncles which are meaningless in Pbft
 	if header.UncleHash != types.CalcUncleHash(nil) {
@@ -407,13 +477,16 @@ func (p *Pbft) verifyHeader(chain consensus.ChainReader, header *types.Header, p
 	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
 		return consensus.ErrUnknownAncestor
 	}
+	if err := verifyMainChainSnapshot(chain, header, parent); err != nil {
+		return err
+	}
 	log.Info("verify header HasConfirmed", "seal:", seal, "height", header.Number)
 	if !seal && p.dispatcher.GetFinishedHeight() == number {
 		log.Warn("verify header already confirm block")
 		return ErrAlreadyConfirmedBlock
 	}
 
-	if parent.Time+p.period > header.Time {
+	if parent.Time+p.blockPeriod(number) > header.Time {
 		return ErrInvalidTimestamp
 	}
 
@@ -449,30 +522,63 @@ func (p *Pbft) verifySeal(chain consensus.ChainReader, header *types.Header, par
 	if number == 0 {
 		return errUnknownBlock
 	}
-
-	// Retrieve the confirm from the header extra-data
-	var confirm payload.Confirm
-	err := confirm.Deserialize(bytes.NewReader(header.Extra))
-	if err != nil {
+	// Blocks in the pool are not verified by verifyHeader, check the producer
+	// commitment the seal covers through the mix digest
+	if err := verifyProducersCommitment(chain.Config(), header); err != nil {
 		return err
 	}
-	err = p.verifyConfirm(&confirm, header.Nonce.Uint64(), int64(header.Time))
+
+	// Retrieve the confirm from the header extra-data, blocks after the
+	// aggregate confirm fork may carry either format
+	set, err := p.sealProducers(chain, header, parents)
 	if err != nil {
 		return err
 	}
+	extra := sealedConfirm(header.Extra)
+	var proposal *payload.DPOSProposal
+	if dpos.IsAggregateConfirm(extra) {
+		if !chain.Config().IsAggregateConfirm(header.Number) {
+			return errAggregateConfirmNotActive
+		}
+		var confirm dpos.AggregateConfirm
+		err := confirm.Deserialize(bytes.NewReader(extra))
+		if err != nil {
+			return err
+		}
+		err = p.verifyAggregateConfirm(&confirm, set, int64(header.Time))
+		if err != nil {
+			return err
+		}
+		proposal = &confirm.Proposal
+	} else {
+		var confirm payload.Confirm
+		err := confirm.Deserialize(bytes.NewReader(extra))
+		if err != nil {
+			return err
+		}
+		minSignCount := p.majorityCount(len(confirm.Votes), set.TotalCount, int64(header.Time))
+		if chainProducers(chain.Config(), number) {
+			err = dpos.CheckProducersConfirm(&confirm, set.Producers, minSignCount)
+		} else {
+			err = dpos.CheckConfirm(&confirm, minSignCount)
+		}
+		if err != nil {
+			return err
+		}
+		proposal = &confirm.Proposal
+	}
 
 	if oldHeader := chain.GetHeaderByNumber(number); oldHeader != nil {
-		var oldConfirm payload.Confirm
-		err := oldConfirm.Deserialize(bytes.NewReader(oldHeader.Extra))
+		oldProposal, err := dpos.SealedProposal(oldHeader.Extra)
 		if err != nil {
 			return nil
 		}
 
-		if confirm.Proposal.ViewOffset < oldConfirm.Proposal.ViewOffset && number < chain.CurrentHeader().Number.Uint64() {
-			log.Warn("verify seal chain fork", "oldViewOffset", oldConfirm.Proposal.ViewOffset, "newViewOffset", confirm.Proposal.ViewOffset, "height", number)
+		if proposal.ViewOffset < oldProposal.ViewOffset && number < chain.CurrentHeader().Number.Uint64() {
+			log.Warn("verify seal chain fork", "oldViewOffset", oldProposal.ViewOffset, "newViewOffset", proposal.ViewOffset, "height", number)
 			//return errChainForkBlock
 		}
-		if confirm.Proposal.ViewOffset == oldConfirm.Proposal.ViewOffset && oldHeader.Hash() != header.Hash() {
+		if proposal.ViewOffset == oldProposal.ViewOffset && oldHeader.Hash() != header.Hash() {
 			log.Error("double sign block", "oldHeader.Hash()", oldHeader.Hash().String(), "header.Hash()", header.Hash().String(), "old.miner", oldHeader.Coinbase.String(), "newHeader.Miner", header.Coinbase.String(), "current best height", p.CurrentBlock().NumberU64())
 			if oldHeader.Nonce.Uint64() != math.MaxUint64 && header.Nonce.Uint64() != math.MaxUint64 {
 				return errDoubleSignBlock
@@ -498,6 +604,15 @@ func (p *Pbft) Prepare(chain consensus.ChainReader, header *types.Header) error
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
 	}
+	if chain.Config().IsMainChainOracle(header.Number) {
+		snapshot, err := p.mainChainSnapshot(header, parent)
+		if err != nil {
+			return err
+		}
+		if header.Extra, err = snapshot.EncodeExtra(nil); err != nil {
+			return err
+		}
+	}
 	if !p.isRecoved {
 		return ErrWaitRecoverStatus
 	}
@@ -511,7 +626,7 @@ func (p *Pbft) Prepare(chain consensus.ChainReader, header *types.Header) error
 		return errUnauthorizedSigner
 	}
 	p.Start(parent.Time)
-	header.Time = parent.Time + p.period
+	header.Time = parent.Time + p.blockPeriod(header.Number.Uint64())
 	if header.Time < nowTime {
 		header.Time = nowTime
 		p.dispatcher.ResetView(nowTime)
@@ -531,9 +646,7 @@ func (p *Pbft) Finalize(chain consensus.ChainReader, header *types.Header, state
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
 	p.CleanFinalConfirmedBlock(header.Number.Uint64())
-	dutyIndex := p.dispatcher.GetConsensusView().GetDutyIndex()
-
-	if dutyIndex == 0 && spv.SpvIsWorkingHeight() {
+	if p.isLastTurnBlock() {
 		p.needChangeNextTurnProducers = true
 	}
 }
@@ -544,6 +657,9 @@ func (p *Pbft) FinalizeAndAssemble(chain consensus.ChainReader, header *types.He
 	// No block rewards in DPoS, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
+	if err := p.commitProducers(chain.Config(), header); err != nil {
+		return nil, err
+	}
 
 	// Assemble and return the final block for sealing
 	return types.NewBlock(header, txs, nil, receipts), nil
@@ -579,11 +695,14 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 	if err != nil {
 		return err
 	}
+	proposedAt := time.Now()
+	p.pipeline.proposed(block.NumberU64())
 	p.isSealOver = false
 	atomic.StoreInt32(&p.isSealing, 1)
 	// Broadcast vote
-	voteMsg := p.dispatcher.AcceptProposal(proposal, p.account)
+	voteMsg := p.signVote(proposal, true)
 	if voteMsg != nil {
+		p.sendAggregateVote(proposal)
 		var id peer.PID
 		copy(id[:], p.account.PublicKeyBytes()[:])
 		go p.OnVoteAccepted(id, &voteMsg.Vote)
@@ -602,7 +721,13 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 	select {
 	case confirm := <-p.confirmCh:
 		atomic.StoreInt32(&p.isSealing, 0)
+		proposalConfirmTimer.UpdateSince(proposedAt)
 		log.Info("Received confirmCh", "proposal", confirm.Proposal.Hash().String(), "block:", block.NumberU64())
+		if p.leftToSealer(header, confirm.Proposal.ViewOffset) {
+			log.Info("block is sealed by the next producer", "block:", block.NumberU64())
+			p.isSealOver = true
+			return nil
+		}
 		p.addConfirmToBlock(header, confirm)
 		p.isSealOver = true
 		break
@@ -637,19 +762,37 @@ func (p *Pbft) Seal(chain consensus.ChainReader, block *types.Block, results cha
 }
 
 func (p *Pbft) addConfirmToBlock(header *types.Header, confirm *payload.Confirm) error {
-	sealBuf := new(bytes.Buffer)
-	if err := confirm.Serialize(sealBuf); err != nil {
-		log.Error("confirm serialize error", "error", err)
+	if err := p.sealConfirm(header, confirm); err != nil {
 		return err
 	}
-	header.Extra = make([]byte, sealBuf.Len())
-	copy(header.Extra[:], sealBuf.Bytes()[:])
 	sealHash := SealHash(header)
 	hash, _ := ecom.Uint256FromBytes(sealHash.Bytes())
 	p.dispatcher.FinishedProposal(header.Number.Uint64(), *hash, header.Time)
 	return nil
 }
 
+// sealConfirm puts confirm into the extra data of header, as an aggregate
+// confirm once they are active, after the main chain snapshot and producer
+// commitment of header.
+func (p *Pbft) sealConfirm(header *types.Header, confirm *payload.Confirm) error {
+	snapshot, commitment, _, err := types.SplitSealedExtra(header.Extra)
+	if err != nil {
+		return err
+	}
+	sealBuf := new(bytes.Buffer)
+	if aggregate := p.aggregateConfirm(header, confirm); aggregate != nil {
+		err = aggregate.Serialize(sealBuf)
+	} else {
+		err = confirm.Serialize(sealBuf)
+	}
+	if err != nil {
+		log.Error("confirm serialize error", "error", err)
+		return err
+	}
+	header.Extra, err = types.EncodeSealedExtra(snapshot, commitment, sealBuf.Bytes())
+	return err
+}
+
 func (p *Pbft) onConfirm(confirm *payload.Confirm) error {
 	log.Info("--------[onConfirm]------", "proposal:", confirm.Proposal.Hash())
 	err := p.blockPool.AppendConfirm(confirm)
@@ -657,6 +800,9 @@ func (p *Pbft) onConfirm(confirm *payload.Confirm) error {
 		log.Error("Received confirm", "proposal", confirm.Proposal.Hash().String(), "err:", err)
 		return err
 	}
+	if block, ok := p.blockPool.GetBlock(confirm.Proposal.BlockHash); ok {
+		p.pipeline.confirmed(block.GetHeight())
+	}
 	duty := p.IsOnDuty()
 	if p.isSealOver && duty {
 		return errors.New("seal block is over, can't confirm")
@@ -672,6 +818,9 @@ func (p *Pbft) onConfirm(confirm *payload.Confirm) error {
 		} else {
 			dpos.Info("on duty, now is not sealing")
 		}
+	} else if block, ok := p.pipelinedBlock(confirm); ok {
+		log.Info("next on duty, seal confirm block")
+		go p.sealPipelined(block, confirm)
 	} else {
 		log.Info("not on duty, not broad confirm block")
 	}
@@ -716,9 +865,36 @@ func (p *Pbft) APIs(chain consensus.ChainReader) []rpc.API {
 func (p *Pbft) Close() error {
 	dpos.Info("Pbft Close")
 	p.enableViewLoop = false
+	p.saveProducerStats()
+	if p.wal != nil {
+		if err := p.wal.close(); err != nil {
+			log.Error("close consensus WAL error", "err", err)
+		}
+	}
+	if p.illegalEvidences != nil {
+		return p.illegalEvidences.close()
+	}
 	return nil
 }
 
+// saveProducerStats saves the producer statistics so they survive restarts.
+func (p *Pbft) saveProducerStats() {
+	if p.dispatcher == nil || p.producerStatsPath == "" {
+		return
+	}
+	if err := p.dispatcher.GetProducerStats().Save(p.producerStatsPath); err != nil {
+		log.Error("save producer stats error", "err", err)
+	}
+}
+
+// GetProducerStats returns the liveness statistics of the current producers.
+func (p *Pbft) GetProducerStats() *dpos.ProducerStatsReport {
+	if p.dispatcher == nil {
+		return &dpos.ProducerStatsReport{Producers: []*dpos.ProducerStat{}}
+	}
+	return p.dispatcher.GetProducerStats().Report(p.GetCurrentProducers())
+}
+
 func (p *Pbft) SignersCount() int {
 	dpos.Info("Pbft SignersCount")
 	count := p.dispatcher.GetConsensusView().GetTotalProducersCount()
@@ -745,7 +921,7 @@ func DBlockSealHash(block dpos.DBlock) (hash ecom.Uint256, err error) {
 }
 
 func encodeSigHeader(w io.Writer, header *types.Header) {
-	err := rlp.Encode(w, []interface{}{
+	fields := []interface{}{
 		header.ParentHash,
 		header.UncleHash,
 		header.Coinbase,
@@ -761,7 +937,13 @@ func encodeSigHeader(w io.Writer, header *types.Header) {
 		//header.Extra[:len(header.Extra)-crypto.SignatureLength], // Yes, this will panic if extra is too short
 		header.MixDigest,
 		header.Nonce,
-	})
+	}
+	// The seal covers the main chain snapshot, but not the confirm after it.
+	// The mix digest covers the producer commitment.
+	if snapshot, _, _ := types.SplitMainChainSnapshot(header.Extra); snapshot != nil {
+		fields = append(fields, snapshot.Hash())
+	}
+	err := rlp.Encode(w, fields)
 	if err != nil {
 		panic("can't encode: " + err.Error())
 	}
@@ -808,8 +990,8 @@ func (p *Pbft) Start(headerTime uint64) {
 	}
 	if !p.enableViewLoop {
 		p.enableViewLoop = true
-		p.dispatcher.GetConsensusView().SetChangViewTime(headerTime)
 		p.dispatcher.GetConsensusView().UpdateDutyIndex(p.chain.CurrentBlock().NumberU64())
+		p.dispatcher.GetConsensusView().SetChangViewTime(headerTime)
 		go p.changeViewLoop()
 	} else {
 		p.dispatcher.ResetView(headerTime)
@@ -868,8 +1050,16 @@ func (p *Pbft) IsProducer() bool {
 	return p.dispatcher.IsProducer(p.account.PublicKeyBytes())
 }
 
+// SetBlockChain sets the chain of the engine and replays the consensus WAL
+// on top of it, before this producer signs anything.
 func (p *Pbft) SetBlockChain(chain *core.BlockChain) {
 	p.chain = chain
+	if p.account == nil {
+		return
+	}
+	if err := p.wal.replay(chain.CurrentBlock().NumberU64()); err != nil {
+		log.Error("replay consensus WAL error", "err", err)
+	}
 }
 
 func (p *Pbft) GetBlockChain() *core.BlockChain {
@@ -882,23 +1072,44 @@ func (p *Pbft) broadConfirmMsg(confirm *payload.Confirm, height uint64) {
 }
 
 func (p *Pbft) verifyConfirm(confirm *payload.Confirm, elaHeight uint64, timeStamp int64) error {
-	minSignCount := 0
-	if elaHeight == 0 {
-		minSignCount = p.dispatcher.GetConsensusView().GetCRMajorityCount()
-	} else {
+	minSignCount, err := p.minSignCount(len(confirm.Votes), elaHeight, timeStamp)
+	if err != nil {
+		return err
+	}
+	err = dpos.CheckConfirm(confirm, minSignCount)
+	return err
+}
+
+// minSignCount returns how many producers must sign the confirm of a block
+// with signers signatures, sealed at timeStamp on top of main chain elaHeight.
+func (p *Pbft) minSignCount(signers int, elaHeight uint64, timeStamp int64) (int, error) {
+	totalCount := 0
+	if elaHeight != 0 {
 		_, count, err := spv.GetProducers(elaHeight)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		minSignCount = p.dispatcher.GetConsensusView().GetMajorityCountByTotalSigners(count)
+		totalCount = count
+	}
+	return p.majorityCount(signers, totalCount, timeStamp), nil
+}
+
+// majorityCount returns how many of totalCount producers must sign the
+// confirm of a block with signers signatures sealed at timeStamp, the CR
+// producers sign it if totalCount is zero.
+func (p *Pbft) majorityCount(signers int, totalCount int, timeStamp int64) int {
+	minSignCount := 0
+	if totalCount == 0 {
+		minSignCount = p.dispatcher.GetConsensusView().GetCRMajorityCount()
+	} else {
+		minSignCount = p.dispatcher.GetConsensusView().GetMajorityCountByTotalSigners(totalCount)
 	}
-	if len(confirm.Votes) < minSignCount {
+	if signers < minSignCount {
 		if timeStamp <= p.cfg.BPosFullVoteTime-5 {
 			minSignCount = p.dispatcher.GetConsensusView().GetMinAcceptVoteCount()
 		}
 	}
-	err := dpos.CheckConfirm(confirm, minSignCount)
-	return err
+	return minSignCount
 }
 
 func (p *Pbft) verifyBlock(block dpos.DBlock) error {
@@ -910,6 +1121,14 @@ func (p *Pbft) verifyBlock(block dpos.DBlock) error {
 		if err != nil {
 			return err
 		}
+		err = p.verifyNextProducers(b.Header())
+		if err != nil {
+			return err
+		}
+		err = verifyMainChainAnswers(b.Header())
+		if err != nil {
+			return err
+		}
 		err = p.chain.Validator().ValidateBody(b)
 		if err != nil {
 			log.Error("validateBody error", "height:", b.GetHeight())
@@ -220,3 +220,18 @@ func TestChangeEngine(t *testing.T) {
 	assert.Equal(t, chain.CurrentHeader().Difficulty, diffInTurn)
 	assert.Equal(t, chain.CurrentHeader().Number.Uint64(), uint64(len(blocks2)))
 }
+
+func TestParseBlsPublicKeys(t *testing.T) {
+	registered, unproven, stolen := dpos.NewBlsKey([]byte{1}), dpos.NewBlsKey([]byte{2}), dpos.NewBlsKey([]byte{3})
+	keys := map[string]string{
+		"01": common.Bytes2Hex(registered.PublicKey()),
+		"02": common.Bytes2Hex(unproven.PublicKey()),
+		"03": common.Bytes2Hex(stolen.PublicKey()),
+	}
+	proofs := map[string]string{
+		"01": common.Bytes2Hex(registered.ProofOfPossession()),
+		"03": common.Bytes2Hex(registered.ProofOfPossession()),
+	}
+	publicKeys := parseBlsPublicKeys(keys, proofs)
+	assert.Equal(t, map[string][]byte{"01": registered.PublicKey()}, publicKeys)
+}
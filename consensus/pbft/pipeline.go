@@ -0,0 +1,226 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/metrics"
+)
+
+var (
+	proposalConfirmTimer = metrics.NewRegisteredTimer("pbft/proposal/confirm", nil) // From the proposal to its confirm, at the sponsor
+	proposalDelayTimer   = metrics.NewRegisteredTimer("pbft/proposal/delay", nil)   // From the confirm of the parent to the proposal, at the sponsor
+	blockIntervalTimer   = metrics.NewRegisteredTimer("pbft/block/interval", nil)   // Between two consecutive chain heads
+	pipelineHandoffMeter = metrics.NewRegisteredMeter("pbft/pipeline/handoff", nil) // Blocks sealed for their sponsor
+	pipelineInvalidMeter = metrics.NewRegisteredMeter("pbft/pipeline/invalid", nil) // Blocks sealed for their sponsor and replaced
+)
+
+// pipeline keeps the state of pipelined proposals, where the producer on duty
+// at the next height seals a block as soon as it has a majority of votes for
+// it and proposes the next block on top of it, while the other producers have
+// not imported the block yet. The sponsor leaves the block to it and never
+// seals it, so exactly one producer seals each block. The block sealed for
+// the sponsor stays unconfirmed until a block on top of it is inserted, and
+// is dropped with the proposal on top of it if a block confirmed at a later
+// view replaces it. The pipeline also keeps the latency metrics, which are
+// updated whether pipelining is enabled or not so both can be compared.
+type pipeline struct {
+	enabled bool
+
+	mu              sync.Mutex
+	confirmedHeight uint64
+	confirmedAt     time.Time
+	insertedAt      time.Time
+	unconfirmed     *types.Header // Block sealed for its sponsor, until a block on top of it is inserted
+}
+
+func newPipeline(enabled bool) *pipeline {
+	return &pipeline{enabled: enabled}
+}
+
+// proposed records this producer started the proposal of the block at height.
+func (p *pipeline) proposed(height uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.confirmedHeight+1 == height {
+		proposalDelayTimer.UpdateSince(p.confirmedAt)
+	}
+}
+
+// confirmed records the block at height got a majority of votes.
+func (p *pipeline) confirmed(height uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if height > p.confirmedHeight {
+		p.confirmedHeight, p.confirmedAt = height, time.Now()
+	}
+}
+
+// handOff records header was sealed for its sponsor and is not confirmed yet.
+func (p *pipeline) handOff(header *types.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unconfirmed = header
+	pipelineHandoffMeter.Mark(1)
+}
+
+// pending returns the block sealed for its sponsor that is not confirmed yet,
+// nil if there is none.
+func (p *pipeline) pending() *types.Header {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.unconfirmed
+}
+
+// drop records the unconfirmed block header was replaced.
+func (p *pipeline) drop(header *types.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.unconfirmed == header {
+		p.unconfirmed = nil
+		pipelineInvalidMeter.Mark(1)
+	}
+}
+
+// inserted records block became the chain head, canonical returns the hash
+// of the canonical block at a height.
+func (p *pipeline) inserted(block *types.Block, canonical func(number uint64) common.Hash) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.insertedAt.IsZero() {
+		blockIntervalTimer.UpdateSince(p.insertedAt)
+	}
+	p.insertedAt = time.Now()
+	if p.unconfirmed != nil && block.NumberU64() > p.unconfirmed.Number.Uint64() {
+		if canonical(p.unconfirmed.Number.Uint64()) != p.unconfirmed.Hash() {
+			pipelineInvalidMeter.Mark(1)
+			log.Warn("pipelined block was replaced", "height", p.unconfirmed.Number, "hash", p.unconfirmed.Hash())
+		}
+		p.unconfirmed = nil
+	}
+}
+
+// pipelinedSealer returns the producer sealing the block of header proposed
+// at viewOffset in pipelined mode, which is the producer on duty at the first
+// view of the next height. It returns nil if the sponsor seals the block
+// itself: after a view change, so a next producer that is offline costs a
+// view and not the height, and when the producers may change after the block.
+func (p *Pbft) pipelinedSealer(header *types.Header, viewOffset uint32) []byte {
+	if !p.pipeline.enabled || p.dispatcher == nil || viewOffset != 0 || header.MixDigest != (common.Hash{}) {
+		return nil
+	}
+	view := p.dispatcher.GetConsensusView()
+	producers := view.GetProducers()
+	if len(producers) == 0 {
+		return nil
+	}
+	next := (view.GetDutyIndex() + 1) % uint32(len(producers))
+	if next == 0 {
+		return nil
+	}
+	return producers[next]
+}
+
+// leftToSealer returns whether the block of header, confirmed at viewOffset,
+// is sealed by another producer than this one in pipelined mode.
+func (p *Pbft) leftToSealer(header *types.Header, viewOffset uint32) bool {
+	sealer := p.pipelinedSealer(header, viewOffset)
+	return len(sealer) > 0 && !bytes.Equal(sealer, p.account.PublicKeyBytes())
+}
+
+// pipelinedBlock returns the block of confirm if this producer seals it for
+// its sponsor.
+func (p *Pbft) pipelinedBlock(confirm *payload.Confirm) (*types.Block, bool) {
+	if !p.pipeline.enabled || p.account == nil {
+		return nil, false
+	}
+	dblock, ok := p.blockPool.GetBlock(confirm.Proposal.BlockHash)
+	if !ok {
+		return nil, false
+	}
+	block, ok := dblock.(*types.Block)
+	if !ok {
+		return nil, false
+	}
+	sealer := p.pipelinedSealer(block.Header(), confirm.Proposal.ViewOffset)
+	if len(sealer) == 0 || !bytes.Equal(sealer, p.account.PublicKeyBytes()) {
+		return nil, false
+	}
+	return block, true
+}
+
+// sealPipelined seals block with confirm for its sponsor and inserts it, so
+// this producer proposes the next block on top of it right away. If the block
+// can not be inserted it is left unsealed, and the view change moves the
+// height on like for any block its sponsor did not seal.
+func (p *Pbft) sealPipelined(block *types.Block, confirm *payload.Confirm) {
+	delay := time.Unix(int64(block.Time()), 0).Sub(p.dispatcher.GetNowTime())
+	log.Info("[sealPipelined] wait seal time", "delay", delay)
+	time.Sleep(delay)
+
+	if p.chain.CurrentHeader().Hash() != block.ParentHash() {
+		log.Info("[sealPipelined] block is not on the chain head", "height", block.NumberU64())
+		return
+	}
+	header := block.Header()
+	if err := p.sealConfirm(header, confirm); err != nil {
+		return
+	}
+	sealed := block.WithSeal(header)
+	if _, err := p.chain.InsertChain(types.Blocks{sealed}); err != nil {
+		log.Warn("[sealPipelined] insert block error", "height", sealed.NumberU64(), "err", err)
+		pipelineInvalidMeter.Mark(1)
+		return
+	}
+	p.pipeline.handOff(sealed.Header())
+	p.BroadBlockMsg(sealed)
+}
+
+// invalidatePipelined rewinds the chain below the unconfirmed block this
+// producer sealed for its sponsor if block, confirmed at a later view of the
+// same height, replaces it. The other producers moved on without the block
+// then, so the proposal on top of it can not be confirmed either and is
+// dropped with the chain head. It returns whether the chain was rewound.
+func (p *Pbft) invalidatePipelined(block *types.Block) bool {
+	unconfirmed := p.pipeline.pending()
+	if unconfirmed == nil || unconfirmed.Number.Uint64() != block.NumberU64() || unconfirmed.Hash() == block.Hash() {
+		return false
+	}
+	if p.chain.CurrentHeader().Hash() != unconfirmed.Hash() {
+		return false
+	}
+	sealed, err := dpos.SealedProposal(unconfirmed.Extra)
+	if err != nil {
+		return false
+	}
+	replacing, err := dpos.SealedProposal(block.Extra())
+	if err != nil || replacing.ViewOffset <= sealed.ViewOffset {
+		return false
+	}
+	if err := p.VerifyHeader(p.chain, block.Header(), true); err != nil {
+		log.Warn("[invalidatePipelined] replacing block is invalid", "height", block.NumberU64(), "err", err)
+		return false
+	}
+	log.Warn("pipelined block was replaced, drop it", "height", block.NumberU64(), "hash", unconfirmed.Hash(),
+		"viewOffset", sealed.ViewOffset, "replacedBy", block.Hash(), "replacingViewOffset", replacing.ViewOffset)
+	p.pipeline.drop(unconfirmed)
+	height := block.NumberU64() - 1
+	if err := p.chain.SetHead(height); err != nil {
+		log.Error("[invalidatePipelined] rewind chain error", "height", height, "err", err)
+		return false
+	}
+	p.dispatcher.RewindFinishedHeight(height)
+	return true
+}
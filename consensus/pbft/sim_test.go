@@ -0,0 +1,919 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	elap2p "github.com/elastos/Elastos.ELA/p2p"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core"
+	"github.com/pgprotocol/pgp-chain/core/rawdb"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/params"
+)
+
+const (
+	// simSettleTime is how long the harness waits for messages sent from
+	// goroutines of the engines before it considers the network idle.
+	simSettleTime = 20 * time.Millisecond
+
+	// simMaxDuration bounds the fake time a scenario may take to reach a height.
+	simMaxDuration = 2 * time.Minute
+)
+
+// simNode is one producer of the simulation, running its own engine and
+// chain on top of an in-memory database.
+type simNode struct {
+	index   int
+	pid     peer.PID
+	account *simAccount
+	db      ethdb.Database
+	engine  *Pbft
+	chain   *core.BlockChain
+
+	head        common.Hash
+	mineRequest int32
+	stop        chan struct{}
+	sealDone    chan struct{}
+	results     chan *types.Block
+	sealed      int // Blocks sealed by the node itself
+}
+
+// simulation runs producers as the miner and the eth sync of a node would
+// drive them: every chain head starts new work on all nodes, the on-duty
+// node seals it and its sealed block is written to its chain. Time only
+// passes when the scenario advances the fake clock, and every second of it
+// the view of each node is tried to change like the view loop does.
+type simulation struct {
+	t       *testing.T
+	clock   *simClock
+	network *simNetwork
+	config  *params.ChainConfig
+	genesis *core.Genesis
+	nodes   []*simNode
+}
+
+// newSimulation creates producers nodes wired through a simulated network,
+// followed by standby nodes that are not in the initial producer set.
+func newSimulation(t *testing.T, producers, standby int) *simulation {
+	clock := &simClock{now: time.Now().Add(-time.Hour).Truncate(time.Second)}
+	s := &simulation{
+		t:       t,
+		clock:   clock,
+		network: newSimNetwork(clock),
+	}
+	accounts := make([]*simAccount, producers+standby)
+	for i := range accounts {
+		accounts[i] = &simAccount{newIllegalTestSigner(t)}
+	}
+	// order the nodes like the producers are ordered on duty
+	sort.Slice(accounts[:producers], func(i, j int) bool {
+		return bytes.Compare(accounts[i].publicKey, accounts[j].publicKey) < 0
+	})
+	cfg := &params.PbftConfig{BlsPublicKeys: make(map[string]string), BlsProofs: make(map[string]string)}
+	for _, account := range accounts[:producers] {
+		cfg.Producers = append(cfg.Producers, common.Bytes2Hex(account.publicKey))
+	}
+	for _, account := range accounts {
+		cfg.BlsPublicKeys[common.Bytes2Hex(account.publicKey)] = common.Bytes2Hex(simBlsKey(account).PublicKey())
+		cfg.BlsProofs[common.Bytes2Hex(account.publicKey)] = common.Bytes2Hex(simBlsKey(account).ProofOfPossession())
+	}
+	s.config = &params.ChainConfig{OldChainID: big.NewInt(1), ChainID: big.NewInt(20), HomesteadBlock: big.NewInt(0),
+		EIP150Block: big.NewInt(0), EIP155Block: big.NewInt(0), EIP158Block: big.NewInt(0),
+		ByzantiumBlock: big.NewInt(0), ConstantinopleBlock: big.NewInt(0), PetersburgBlock: big.NewInt(0),
+		PBFTBlock: big.NewInt(0), Pbft: cfg, PreConnectOffset: 1}
+	s.genesis = &core.Genesis{
+		Config:     s.config,
+		Timestamp:  uint64(clock.now.Unix()),
+		ExtraData:  make([]byte, extraVanity+extraSeal),
+		GasLimit:   params.GenesisGasLimit,
+		Difficulty: big.NewInt(1),
+	}
+	for i, account := range accounts {
+		node := &simNode{
+			index:   i,
+			account: account,
+			db:      rawdb.NewMemoryDatabase(),
+		}
+		copy(node.pid[:], account.publicKey)
+		s.genesis.MustCommit(node.db)
+		s.boot(node)
+		s.nodes = append(s.nodes, node)
+	}
+	t.Cleanup(s.close)
+	return s
+}
+
+// boot creates the engine and the chain of node on top of its database and
+// attaches it to the network.
+func (s *simulation) boot(node *simNode) {
+	engine := newPbft(s.config, "", node.account, nil, s.clock)
+	engine.blsKey = simBlsKey(node.account)
+	engine.IsCurrent = func() bool { return true }
+	engine.StartMine = func() { atomic.StoreInt32(&node.mineRequest, 1) }
+	chain, err := core.NewBlockChain(node.db, nil, s.config, engine, engine, vm.Config{}, nil)
+	if err != nil {
+		s.t.Fatalf("node %d: failed to create chain: %v", node.index, err)
+	}
+	engine.SetBlockChain(chain)
+	engine.network = s.network.join(node.pid, engine)
+	node.engine, node.chain = engine, chain
+	node.head = chain.CurrentBlock().Hash()
+	node.results = make(chan *types.Block, 1)
+}
+
+// simBlsKey returns the BLS key of account, derived from its private key
+// like producers derive it from their keystore.
+func simBlsKey(account *simAccount) *dpos.BlsKey {
+	return dpos.NewBlsKey(account.key.D.Bytes())
+}
+
+// start starts the direct network servers of the online nodes and waits
+// until the producers recovered their consensus state from each other.
+func (s *simulation) start(nodes ...*simNode) {
+	for _, node := range nodes {
+		node.engine.StartServer()
+	}
+	s.runUntil("recovered", func() bool {
+		for _, node := range nodes {
+			if node.engine.IsProducer() && !node.engine.isRecoved {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// restart simulates a crash of node followed by a restart on its database.
+func (s *simulation) restart(node *simNode) {
+	s.abortSeal(node)
+	node.engine.Close()
+	node.chain.Stop()
+	s.boot(node)
+	s.start(node)
+}
+
+func (s *simulation) close() {
+	for _, node := range s.nodes {
+		s.abortSeal(node)
+		node.engine.Close()
+		node.chain.Stop()
+	}
+}
+
+// onDuty returns the node on duty in the view of observer.
+func (s *simulation) onDuty(observer *simNode) *simNode {
+	view := observer.engine.dispatcher.GetConsensusView()
+	for _, node := range s.nodes {
+		if view.ProducerIsOnDuty(node.account.publicKey) {
+			return node
+		}
+	}
+	return nil
+}
+
+// setOnline takes node off the network or brings it back.
+func (s *simulation) setOnline(node *simNode, online bool) {
+	if !online {
+		s.abortSeal(node)
+	}
+	s.network.setOnline(node.pid, online)
+}
+
+func (s *simulation) online(node *simNode) bool {
+	s.network.mu.Lock()
+	defer s.network.mu.Unlock()
+	return s.network.endpoints[node.pid].online
+}
+
+// advance moves the fake clock forward second by second, running the nodes
+// after every step.
+func (s *simulation) advance(d time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+		s.clock.advance(time.Second)
+		for _, node := range s.nodes {
+			if s.online(node) && node.engine.enableViewLoop {
+				node.engine.OnChangeView()
+			}
+		}
+		s.run()
+	}
+}
+
+// runUntil advances the fake clock until done returns true.
+func (s *simulation) runUntil(what string, done func() bool) {
+	s.run()
+	for start := s.clock.AdjustedTime(); !done(); {
+		if s.clock.AdjustedTime().Sub(start) > simMaxDuration {
+			s.t.Fatalf("simulation did not reach %s in %v", what, simMaxDuration)
+		}
+		s.advance(time.Second)
+	}
+}
+
+// waitHeight runs the simulation until all nodes reached height.
+func (s *simulation) waitHeight(height uint64, nodes ...*simNode) {
+	s.runUntil("height", func() bool {
+		for _, node := range nodes {
+			if node.chain.CurrentBlock().NumberU64() < height {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// run delivers due messages and drives the miners until the simulation is
+// idle at the current time of the fake clock.
+func (s *simulation) run() {
+	for {
+		progressed := false
+		for s.deliver() {
+			progressed = true
+		}
+		for _, node := range s.nodes {
+			if s.collectSeal(node) {
+				progressed = true
+			}
+			if s.checkHead(node) {
+				progressed = true
+			}
+		}
+		for _, node := range s.nodes {
+			if s.mine(node) {
+				progressed = true
+			}
+		}
+		if progressed {
+			continue
+		}
+		// engines send some messages from goroutines, give them a moment
+		time.Sleep(simSettleTime)
+		if s.network.pending() == 0 && !s.sealPending() {
+			return
+		}
+		if !s.deliver() && !s.sealPending() {
+			return
+		}
+	}
+}
+
+// deliver hands the next due recover task or message to its node.
+func (s *simulation) deliver() bool {
+	if listener := s.network.nextRecover(); listener != nil {
+		listener.OnRecover()
+		return true
+	}
+	envelope, listener := s.network.next()
+	if envelope == nil {
+		return false
+	}
+	dpos.DispatchMessage(listener, envelope.from, envelope.msg)
+	return true
+}
+
+func (s *simulation) sealPending() bool {
+	for _, node := range s.nodes {
+		if len(node.results) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSeal writes the block sealed by node to its chain, like the miner.
+func (s *simulation) collectSeal(node *simNode) bool {
+	select {
+	case block := <-node.results:
+		node.sealed++
+		if _, err := node.chain.InsertChain(types.Blocks{block}); err != nil {
+			s.t.Logf("node %d: failed to write sealed block %d: %v", node.index, block.NumberU64(), err)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// checkHead notifies the engine of a new chain head and starts new work on
+// top of it, like the eth backend and the miner do.
+func (s *simulation) checkHead(node *simNode) bool {
+	head := node.chain.CurrentBlock()
+	if head.Hash() == node.head {
+		return false
+	}
+	node.head = head.Hash()
+	node.engine.OnInsertBlock(head)
+	atomic.StoreInt32(&node.mineRequest, 1)
+	return true
+}
+
+// mine prepares new work for node once the block period passed and seals it
+// if node is on duty.
+func (s *simulation) mine(node *simNode) bool {
+	if !s.online(node) || atomic.LoadInt32(&node.mineRequest) == 0 {
+		return false
+	}
+	parent := node.chain.CurrentBlock()
+	if uint64(s.clock.AdjustedTime().Unix()) < parent.Time()+node.engine.blockPeriod(parent.NumberU64()+1) {
+		return false
+	}
+	atomic.StoreInt32(&node.mineRequest, 0)
+	s.abortSeal(node)
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+	}
+	if err := node.engine.Prepare(node.chain, header); err != nil {
+		return true
+	}
+	state, err := node.chain.StateAt(parent.Root())
+	if err != nil {
+		s.t.Fatalf("node %d: failed to open state: %v", node.index, err)
+	}
+	block, err := node.engine.FinalizeAndAssemble(node.chain, header, state, nil, nil, nil)
+	if err != nil {
+		s.t.Fatalf("node %d: failed to assemble block: %v", node.index, err)
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	node.stop, node.sealDone = stop, done
+	go func() {
+		defer close(done)
+		if err := node.engine.Seal(node.chain, block, node.results, stop); err != nil {
+			s.t.Logf("node %d: failed to seal block %d: %v", node.index, block.NumberU64(), err)
+		}
+	}()
+	// wait until the proposal is out and the sealer waits for the votes
+	for atomic.LoadInt32(&node.engine.isSealing) == 0 {
+		select {
+		case <-done:
+			return true
+		case <-time.After(time.Millisecond):
+		}
+	}
+	return true
+}
+
+// abortSeal stops the sealing of node, like the miner does on new work.
+func (s *simulation) abortSeal(node *simNode) {
+	if node.stop == nil {
+		return
+	}
+	close(node.stop)
+	<-node.sealDone
+	node.stop, node.sealDone = nil, nil
+}
+
+// confirmOf returns the confirm sealed into the block of node at height.
+func (s *simulation) confirmOf(node *simNode, height uint64) *payload.Confirm {
+	block := node.chain.GetBlockByNumber(height)
+	if block == nil {
+		s.t.Fatalf("node %d: missing block %d", node.index, height)
+	}
+	confirm := new(payload.Confirm)
+	if err := confirm.Deserialize(bytes.NewReader(sealedConfirm(block.Extra()))); err != nil {
+		s.t.Fatalf("node %d: invalid confirm in block %d: %v", node.index, height, err)
+	}
+	return confirm
+}
+
+// sync inserts the blocks node is missing from the best chain of the nodes
+// it can reach, like the eth downloader.
+func (s *simulation) sync(node *simNode) {
+	var best *simNode
+	for _, other := range s.nodes {
+		if !s.network.connected(node.pid, other.pid) {
+			continue
+		}
+		if best == nil || other.chain.CurrentBlock().NumberU64() > best.chain.CurrentBlock().NumberU64() {
+			best = other
+		}
+	}
+	if best == nil {
+		return
+	}
+	var blocks types.Blocks
+	for number := node.chain.CurrentBlock().NumberU64() + 1; number <= best.chain.CurrentBlock().NumberU64(); number++ {
+		blocks = append(blocks, best.chain.GetBlockByNumber(number))
+	}
+	if len(blocks) == 0 {
+		return
+	}
+	if _, err := node.chain.InsertChain(blocks); err != nil {
+		s.t.Fatalf("node %d: failed to sync from node %d: %v", node.index, best.index, err)
+	}
+	s.run()
+}
+
+// assertConsistent checks the nodes agree on every block up to height.
+func (s *simulation) assertConsistent(height uint64, nodes ...*simNode) {
+	for number := uint64(1); number <= height; number++ {
+		want := nodes[0].chain.GetBlockByNumber(number)
+		if !assert.NotNil(s.t, want, "node %d: missing block %d", nodes[0].index, number) {
+			return
+		}
+		for _, node := range nodes[1:] {
+			block := node.chain.GetBlockByNumber(number)
+			if assert.NotNil(s.t, block, "node %d: missing block %d", node.index, number) {
+				assert.Equal(s.t, want.Hash(), block.Hash(), "node %d: block %d differs", node.index, number)
+			}
+		}
+	}
+}
+
+// sponsorOf returns the node that proposed the confirmed block.
+func (s *simulation) sponsorOf(confirm *payload.Confirm) *simNode {
+	for _, node := range s.nodes {
+		if bytes.Equal(node.account.publicKey, confirm.Proposal.Sponsor) {
+			return node
+		}
+	}
+	return nil
+}
+
+// votersOf returns the indexes of the nodes that signed the confirm.
+func (s *simulation) votersOf(confirm *payload.Confirm) []int {
+	voters := make([]int, 0, len(confirm.Votes))
+	for _, vote := range confirm.Votes {
+		for _, node := range s.nodes {
+			if bytes.Equal(node.account.publicKey, vote.Signer) {
+				voters = append(voters, node.index)
+			}
+		}
+	}
+	sort.Ints(voters)
+	return voters
+}
+
+func TestSimulationProducesBlocks(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(3, s.nodes...)
+	s.assertConsistent(3, s.nodes...)
+}
+
+// pidsOf returns the direct network ids of nodes.
+func pidsOf(nodes []*simNode) []peer.PID {
+	pids := make([]peer.PID, len(nodes))
+	for i, node := range nodes {
+		pids[i] = node.pid
+	}
+	return pids
+}
+
+// except returns the nodes of the simulation other than the given ones.
+func (s *simulation) except(excluded ...*simNode) []*simNode {
+	nodes := make([]*simNode, 0, len(s.nodes))
+	for _, node := range s.nodes {
+		skip := false
+		for _, other := range excluded {
+			skip = skip || node == other
+		}
+		if !skip {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+func TestSimulationOnDutyOffline(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	offline := s.onDuty(s.nodes[0])
+	if offline == nil {
+		t.Fatal("no producer on duty")
+	}
+	s.setOnline(offline, false)
+	others := s.except(offline)
+	s.waitHeight(3, others...)
+	s.assertConsistent(3, others...)
+
+	confirm := s.confirmOf(others[0], 3)
+	assert.NotEqual(t, offline, s.sponsorOf(confirm))
+	assert.NotZero(t, confirm.Proposal.ViewOffset)
+	assert.NotContains(t, s.votersOf(confirm), offline.index)
+	assert.Equal(t, uint64(2), offline.chain.CurrentBlock().NumberU64())
+}
+
+func TestSimulationViewChange(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	// the on-duty producer stays online and votes, but its proposals get lost
+	silenced := s.onDuty(s.nodes[0])
+	if silenced == nil {
+		t.Fatal("no producer on duty")
+	}
+	s.network.setDrop(func(from, to peer.PID, m elap2p.Message) bool {
+		return from == silenced.pid && m.CMD() == msg.CmdReceivedProposal
+	})
+	s.waitHeight(3, s.nodes...)
+	s.assertConsistent(3, s.nodes...)
+
+	confirm := s.confirmOf(s.nodes[0], 3)
+	assert.NotZero(t, confirm.Proposal.ViewOffset)
+	assert.NotEqual(t, silenced, s.sponsorOf(confirm))
+
+	s.network.setDrop(nil)
+	s.waitHeight(5, s.nodes...)
+	s.assertConsistent(5, s.nodes...)
+}
+
+func TestSimulationPartitionHeals(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	majority, minority := s.nodes[:3], s.nodes[3:]
+	s.network.partition(pidsOf(majority), pidsOf(minority))
+	s.waitHeight(4, majority...)
+	s.assertConsistent(4, majority...)
+	for _, node := range minority {
+		assert.Less(t, node.chain.CurrentBlock().NumberU64(), uint64(4), "node %d", node.index)
+	}
+
+	s.network.heal()
+	for _, node := range minority {
+		s.sync(node)
+	}
+	height := s.nodes[0].chain.CurrentBlock().NumberU64() + 2
+	s.waitHeight(height, s.nodes...)
+	s.assertConsistent(height, s.nodes...)
+}
+
+func TestSimulationRecoverConsensus(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	// stall the consensus so the views keep changing
+	s.network.setDrop(func(from, to peer.PID, m elap2p.Message) bool {
+		return m.CMD() == msg.CmdReceivedProposal
+	})
+	s.advance(10 * time.Second)
+	crashed := s.nodes[2]
+	s.restart(crashed)
+
+	offset := s.nodes[0].engine.dispatcher.GetConsensusView().GetViewOffset()
+	assert.NotZero(t, offset)
+	assert.Equal(t, offset, crashed.engine.dispatcher.GetConsensusView().GetViewOffset())
+
+	s.network.setDrop(nil)
+	s.waitHeight(4, s.nodes...)
+	s.assertConsistent(4, s.nodes...)
+}
+
+func TestSimulationProducersRotation(t *testing.T) {
+	s := newSimulation(t, 5, 1)
+	s.start(s.nodes...)
+	leaving, joining := s.nodes[0], s.nodes[5]
+	s.waitHeight(2, s.nodes[:5]...)
+	s.sync(joining)
+
+	next := pidsOf(s.nodes[1:])
+	for _, node := range s.nodes {
+		node.engine.dispatcher.GetConsensusView().UpdateNextProducers(next, len(next))
+		node.engine.needChangeNextTurnProducers = true
+	}
+	s.waitHeight(3, s.nodes[:5]...)
+	s.sync(joining)
+	assert.False(t, leaving.engine.IsProducer())
+	assert.True(t, joining.engine.IsProducer())
+
+	producers := s.nodes[1:]
+	s.waitHeight(10, producers...)
+	s.assertConsistent(10, producers...)
+	sponsored := false
+	for height := uint64(4); height <= 10; height++ {
+		confirm := s.confirmOf(joining, height)
+		assert.NotEqual(t, leaving, s.sponsorOf(confirm))
+		assert.NotContains(t, s.votersOf(confirm), leaving.index)
+		sponsored = sponsored || s.sponsorOf(confirm) == joining
+	}
+	assert.True(t, sponsored, "joining producer never sponsored a block")
+}
+
+func TestSimulationAggregateConfirm(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	// the engines and chains share the config, activate the fork before start
+	s.config.AggregateConfirmBlock = big.NewInt(3)
+	s.start(s.nodes...)
+	s.waitHeight(6, s.nodes...)
+	s.assertConsistent(6, s.nodes...)
+
+	for height := uint64(1); height <= 6; height++ {
+		extra := s.nodes[0].chain.GetBlockByNumber(height).Extra()
+		assert.Equal(t, height >= 3, dpos.IsAggregateConfirm(extra), "block %d", height)
+	}
+	// a classic confirm stays valid after the fork
+	for _, node := range s.nodes {
+		node.engine.blsKey = nil
+	}
+	s.waitHeight(7, s.nodes...)
+	s.assertConsistent(7, s.nodes...)
+	assert.False(t, dpos.IsAggregateConfirm(s.nodes[0].chain.GetBlockByNumber(7).Extra()))
+}
+
+func TestSimulationProducerTransitions(t *testing.T) {
+	s := newSimulation(t, 5, 1)
+	s.config.ProducerTransitionBlock = big.NewInt(0)
+	s.start(s.nodes...)
+	joining := s.nodes[5]
+	s.waitHeight(2, s.nodes[:5]...)
+	s.sync(joining)
+
+	next := pidsOf(s.nodes[1:])
+	for _, node := range s.nodes {
+		node.engine.dispatcher.GetConsensusView().UpdateNextProducers(next, len(next))
+		node.engine.needChangeNextTurnProducers = true
+	}
+	s.waitHeight(3, s.nodes[:5]...)
+	s.sync(joining)
+	s.waitHeight(6, s.nodes[1:]...)
+	s.assertConsistent(6, s.nodes[1:]...)
+
+	// a client holding the genesis producers follows the rotation over rpc
+	data, err := json.Marshal(s.nodes[1].engine.GetProducerTransitions(0))
+	assert.NoError(t, err)
+	var transitions []*ProducerTransition
+	assert.NoError(t, json.Unmarshal(data, &transitions))
+	if assert.Len(t, transitions, 1) {
+		assert.Equal(t, uint64(4), transitions[0].ChangeHeight)
+	}
+	set, err := VerifyProducerTransitions(s.config.Pbft, transitions)
+	assert.NoError(t, err)
+	assert.Len(t, set.Producers, len(next))
+	for _, producer := range next {
+		assert.Contains(t, set.Producers, producer[:])
+	}
+	header := s.nodes[1].chain.GetHeaderByNumber(6)
+	assert.NoError(t, VerifyConfirmedHeader(s.config.Pbft, set, header))
+	for height := uint64(1); height <= 6; height++ {
+		mixDigest := s.nodes[1].chain.GetHeaderByNumber(height).MixDigest
+		assert.Equal(t, height == 3, mixDigest != common.Hash{}, "block %d", height)
+	}
+
+	// the committed producers are carried by the block and checked on import
+	node := s.nodes[1]
+	header = types.CopyHeader(node.chain.GetHeaderByNumber(3))
+	_, commitment, _, err := types.SplitSealedExtra(header.Extra)
+	if assert.NoError(t, err) && assert.NotNil(t, commitment) {
+		assert.Equal(t, header.MixDigest, commitment.Hash())
+	}
+	header.Extra = sealedConfirm(header.Extra)
+	assert.Equal(t, errMissingProducersCommitment, node.engine.verifyHeader(node.chain, header, nil, false))
+	assert.Equal(t, errMissingProducersCommitment, node.engine.verifySeal(node.chain, header, nil))
+}
+
+func TestSimulationMainChainOracle(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.config.MainChainOracleBlock = big.NewInt(3)
+	s.start(s.nodes...)
+	s.waitHeight(5, s.nodes...)
+	s.assertConsistent(5, s.nodes...)
+
+	node := s.nodes[0]
+	for height := uint64(1); height <= 5; height++ {
+		extra := node.chain.GetBlockByNumber(height).Extra()
+		snapshot, _, err := types.SplitMainChainSnapshot(extra)
+		assert.NoError(t, err)
+		assert.Equal(t, height >= 3, snapshot != nil, "block %d", height)
+		_, err = dpos.SealedProposal(extra)
+		assert.NoError(t, err, "block %d", height)
+	}
+
+	// the seal covers the snapshot, which is checked against the local spv
+	// when voting for the block only
+	header := types.CopyHeader(node.chain.GetHeaderByNumber(4))
+	snapshot, confirm, err := types.SplitMainChainSnapshot(header.Extra)
+	assert.NoError(t, err)
+	sealHash := SealHash(header)
+	snapshot.Height = 1000
+	header.Extra, err = snapshot.EncodeExtra(confirm)
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealHash, SealHash(header))
+	assert.NoError(t, verifyMainChainSnapshot(node.chain, header, node.chain.GetHeaderByNumber(3)))
+	assert.Equal(t, errMainChainSnapshotAhead, verifyMainChainAnswers(header))
+
+	header.Extra = confirm
+	assert.Equal(t, errMissingMainChainSnapshot, node.engine.verifyHeader(node.chain, header, nil, false))
+}
+
+func TestSimulationProducerStats(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	offline := s.onDuty(s.nodes[0])
+	if offline == nil {
+		t.Fatal("no producer on duty")
+	}
+	others := s.except(offline)
+	baseline := others[0].engine.GetProducerStats()
+	before := make(map[string]*dpos.ProducerStat)
+	for _, stat := range baseline.Producers {
+		before[stat.Producer] = stat
+	}
+	s.setOnline(offline, false)
+	s.waitHeight(5, others...)
+
+	report := others[0].engine.GetProducerStats()
+	assert.Equal(t, uint64(5), report.ToHeight)
+	assert.Len(t, report.Producers, len(s.nodes))
+	heights := report.ToHeight - baseline.ToHeight
+	for _, stat := range report.Producers {
+		last := before[stat.Producer]
+		if stat.Producer == common.Bytes2Hex(offline.account.publicKey) {
+			assert.Equal(t, last.ProposalsMissed+1, stat.ProposalsMissed)
+			assert.Equal(t, last.ViewChangesCaused+1, stat.ViewChangesCaused)
+			assert.Equal(t, last.VotesAbsent+heights, stat.VotesAbsent)
+		} else {
+			assert.Equal(t, last.ProposalsMissed, stat.ProposalsMissed, stat.Producer)
+			assert.Equal(t, last.VotesAccepted+heights, stat.VotesAccepted, stat.Producer)
+			assert.Zero(t, stat.VotesAbsent, stat.Producer)
+			assert.True(t, stat.LastSeen >= last.LastSeen)
+		}
+	}
+}
+
+func TestSimulationPipelinedProposals(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	for _, node := range s.nodes {
+		node.engine.pipeline = newPipeline(true)
+	}
+	s.start(s.nodes...)
+	s.waitHeight(8, s.nodes...)
+	s.assertConsistent(8, s.nodes...)
+
+	// the next producers sealed the blocks, sponsors did not have to
+	sealed := 0
+	for _, node := range s.nodes {
+		sealed += node.sealed
+	}
+	assert.True(t, sealed <= 2, "sponsors sealed %d of 8 blocks", sealed)
+}
+
+// pipelinedSealer runs the simulation until observer is in consensus on a
+// block its sponsor leaves to another node to seal when it is confirmed at the
+// first view, and returns the sponsor, that node and the height of the block.
+func (s *simulation) pipelinedSealer(observer *simNode) (*simNode, *simNode, uint64) {
+	for {
+		height := observer.chain.CurrentBlock().NumberU64() + 1
+		sponsor := s.onDuty(observer)
+		sealer := observer.engine.pipelinedSealer(&types.Header{Number: new(big.Int).SetUint64(height)}, 0)
+		for _, node := range s.nodes {
+			if sponsor != nil && sponsor != node && bytes.Equal(node.account.publicKey, sealer) {
+				return sponsor, node, height
+			}
+		}
+		s.waitHeight(height, s.nodes...)
+	}
+}
+
+func TestSimulationPipelinedNextOffline(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	for _, node := range s.nodes {
+		node.engine.pipeline = newPipeline(true)
+	}
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	sponsor, next, height := s.pipelinedSealer(s.nodes[0])
+	sealed := sponsor.sealed
+	s.setOnline(next, false)
+	others := s.except(next)
+	s.waitHeight(height, others...)
+	s.assertConsistent(height, others...)
+
+	// nobody sealed the block left to the offline producer, the block of a
+	// later view replaced it and was sealed by its own sponsor
+	confirm := s.confirmOf(others[0], height)
+	assert.NotZero(t, confirm.Proposal.ViewOffset)
+	assert.NotEqual(t, sponsor, s.sponsorOf(confirm))
+	assert.Equal(t, sealed, sponsor.sealed)
+}
+
+func TestSimulationPipelinedInvalidation(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	for _, node := range s.nodes {
+		node.engine.pipeline = newPipeline(true)
+	}
+	s.start(s.nodes...)
+	s.waitHeight(2, s.nodes...)
+
+	// the other producers never get the block sealed by the next producer
+	_, next, height := s.pipelinedSealer(s.nodes[0])
+	others := s.except(next)
+	s.network.setDrop(func(from, to peer.PID, m elap2p.Message) bool {
+		return from == next.pid && m.CMD() == elap2p.CmdBlock
+	})
+	s.runUntil("proposal on the unconfirmed block", func() bool {
+		return next.engine.wal.proposal(height+1, 0) != nil
+	})
+	unconfirmed := next.chain.CurrentBlock()
+	assert.Equal(t, height, unconfirmed.NumberU64())
+	for _, node := range others {
+		assert.Equal(t, height-1, node.chain.CurrentBlock().NumberU64(), "node %d", node.index)
+	}
+
+	// a later view confirms another block, which replaces the unconfirmed one
+	// and the proposal on top of it as soon as it arrives
+	s.waitHeight(height, others...)
+	s.runUntil("replaced", func() bool {
+		return next.chain.GetBlockByNumber(height).Hash() != unconfirmed.Hash()
+	})
+	assert.Equal(t, height, next.chain.CurrentBlock().NumberU64())
+	for _, node := range others {
+		assert.Equal(t, height, node.chain.CurrentBlock().NumberU64(), "node %d", node.index)
+	}
+	s.network.setDrop(nil)
+	s.waitHeight(height+2, s.nodes...)
+	s.assertConsistent(height+2, s.nodes...)
+	assert.NotZero(t, s.confirmOf(next, height).Proposal.ViewOffset)
+	assert.NotEqual(t, unconfirmed.Hash(), s.nodes[0].chain.GetBlockByNumber(height).Hash())
+}
+
+func TestSimulationTimingFork(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	for _, node := range s.nodes {
+		node.engine.cfg.TimingForks = []params.PbftTimingFork{
+			{Block: big.NewInt(4), PbftTiming: params.PbftTiming{BlockPeriod: 5, ViewInterval: 10}},
+		}
+	}
+	s.start(s.nodes...)
+	s.waitHeight(5, s.nodes...)
+	s.assertConsistent(5, s.nodes...)
+
+	chain := s.nodes[0].chain
+	for height := uint64(2); height <= 5; height++ {
+		period := chain.GetHeaderByNumber(height).Time - chain.GetHeaderByNumber(height-1).Time
+		if height < 4 {
+			assert.True(t, period >= 3 && period < 5, "block %d period %d", height, period)
+		} else {
+			assert.True(t, period >= 5, "block %d period %d", height, period)
+		}
+	}
+
+	// views of the blocks after the fork last the new interval
+	offline := s.onDuty(s.nodes[0])
+	if offline == nil {
+		t.Fatal("no producer on duty")
+	}
+	s.setOnline(offline, false)
+	others := s.except(offline)
+	s.waitHeight(6, others...)
+	s.assertConsistent(6, others...)
+
+	confirm := s.confirmOf(others[0], 6)
+	assert.NotZero(t, confirm.Proposal.ViewOffset)
+	period := others[0].chain.GetHeaderByNumber(6).Time - others[0].chain.GetHeaderByNumber(5).Time
+	assert.True(t, period >= 10, "view changed after %d seconds", period)
+}
+
+func TestSimulationProducerSnapshots(t *testing.T) {
+	s := newSimulation(t, 5, 0)
+	s.config.ProducerTransitionBlock = big.NewInt(2)
+	s.start(s.nodes...)
+	s.waitHeight(4, s.nodes...)
+
+	// the fork block commits to the producers, the blocks after it are
+	// verified against them without spv, even by a node not importing them
+	node := s.nodes[0]
+	assert.NotEqual(t, common.Hash{}, node.chain.GetHeaderByNumber(2).MixDigest)
+	header := types.CopyHeader(node.chain.GetHeaderByNumber(4))
+	header.Nonce = types.EncodeNonce(math.MaxUint64)
+	assert.NoError(t, node.engine.VerifySeal(node.chain, header))
+	fresh := newPbft(s.config, "", node.account, nil, s.clock)
+	fresh.SetBlockChain(node.chain)
+	assert.NoError(t, fresh.VerifySeal(node.chain, header))
+
+	// the default producers height of the main chain can not be resolved
+	// without spv, and the producers are not guessed for the blocks before
+	header = types.CopyHeader(node.chain.GetHeaderByNumber(1))
+	header.Nonce = types.EncodeNonce(math.MaxUint64)
+	assert.Error(t, node.engine.VerifySeal(node.chain, header))
+}
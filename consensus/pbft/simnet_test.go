@@ -0,0 +1,355 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elacrypto "github.com/elastos/Elastos.ELA/crypto"
+	daccount "github.com/elastos/Elastos.ELA/dpos/account"
+	"github.com/elastos/Elastos.ELA/dpos/dtime"
+	"github.com/elastos/Elastos.ELA/dpos/p2p"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	elap2p "github.com/elastos/Elastos.ELA/p2p"
+
+	"github.com/pgprotocol/pgp-chain/dpos"
+)
+
+// simAccount is a dpos account backed by an in-memory key.
+type simAccount struct {
+	*illegalTestSigner
+}
+
+var _ daccount.Account = (*simAccount)(nil)
+
+func (a *simAccount) PublicKey() *elacrypto.PublicKey {
+	return &elacrypto.PublicKey{X: a.key.X, Y: a.key.Y}
+}
+
+func (a *simAccount) PublicKeyBytes() []byte {
+	return a.publicKey
+}
+
+func (a *simAccount) SignProposal(proposal *payload.DPOSProposal) ([]byte, error) {
+	return a.signData(proposal.Data())
+}
+
+func (a *simAccount) SignVote(vote *payload.DPOSProposalVote) ([]byte, error) {
+	return a.signData(vote.Data())
+}
+
+func (a *simAccount) Sign(data []byte) []byte {
+	signature, _ := a.signData(data)
+	return signature
+}
+
+func (a *simAccount) SignTx(tx interfaces.Transaction) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := tx.SerializeUnsigned(buf); err != nil {
+		return nil, err
+	}
+	return a.signData(buf.Bytes())
+}
+
+func (a *simAccount) DecryptAddr(cipher []byte) (string, error) {
+	return "", errors.New("not supported by simulated account")
+}
+
+// simClock is the fake clock the consensus views of all simulated nodes read,
+// it only moves when the harness advances it.
+type simClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ dtime.MedianTimeSource = (*simClock)(nil)
+
+func (c *simClock) AdjustedTime() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *simClock) AddTimeSample(id string, timeVal time.Time) {}
+
+func (c *simClock) Offset() time.Duration {
+	return 0
+}
+
+func (c *simClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+type simLink struct {
+	from, to peer.PID
+}
+
+// simEnvelope is a message in flight between two simulated nodes.
+type simEnvelope struct {
+	from, to  peer.PID
+	msg       elap2p.Message
+	deliverAt time.Time
+	seq       uint64
+}
+
+// simNetwork is an in-memory direct network connecting the simulated nodes.
+// Sent messages are copied through their wire encoding and queued until the
+// fake clock reaches their delivery time, the harness then hands them to the
+// receivers one by one in delivery order. Links can be delayed, messages
+// dropped and nodes partitioned or taken offline.
+type simNetwork struct {
+	mu        sync.Mutex
+	clock     *simClock
+	endpoints map[peer.PID]*simEndpoint
+	latency   time.Duration
+	latencies map[simLink]time.Duration
+	groups    map[peer.PID]int
+	drop      func(from, to peer.PID, m elap2p.Message) bool
+	queue     []*simEnvelope
+	recovers  []peer.PID
+	seq       uint64
+}
+
+func newSimNetwork(clock *simClock) *simNetwork {
+	return &simNetwork{
+		clock:     clock,
+		endpoints: make(map[peer.PID]*simEndpoint),
+		latencies: make(map[simLink]time.Duration),
+		groups:    make(map[peer.PID]int),
+	}
+}
+
+// join attaches listener to the network as pid, a restarted node takes over
+// its previous endpoint.
+func (n *simNetwork) join(pid peer.PID, listener dpos.NetworkEventListener) *simEndpoint {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	endpoint, ok := n.endpoints[pid]
+	if !ok {
+		endpoint = &simEndpoint{network: n, pid: pid}
+		n.endpoints[pid] = endpoint
+	}
+	endpoint.listener = listener
+	endpoint.online = true
+	return endpoint
+}
+
+// setLatency delays the messages sent from one node to another.
+func (n *simNetwork) setLatency(from, to peer.PID, latency time.Duration) {
+	n.mu.Lock()
+	n.latencies[simLink{from, to}] = latency
+	n.mu.Unlock()
+}
+
+// setDrop installs a filter discarding the messages it returns true for, nil
+// removes it.
+func (n *simNetwork) setDrop(drop func(from, to peer.PID, m elap2p.Message) bool) {
+	n.mu.Lock()
+	n.drop = drop
+	n.mu.Unlock()
+}
+
+// setOnline takes the node off the network or brings it back. Messages in
+// flight to or from an offline node are lost.
+func (n *simNetwork) setOnline(pid peer.PID, online bool) {
+	n.mu.Lock()
+	n.endpoints[pid].online = online
+	n.mu.Unlock()
+}
+
+// partition splits the network so that only nodes in the same group can
+// reach each other, nodes not listed stay in group zero.
+func (n *simNetwork) partition(groups ...[]peer.PID) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.groups = make(map[peer.PID]int)
+	for i, group := range groups {
+		for _, pid := range group {
+			n.groups[pid] = i + 1
+		}
+	}
+}
+
+// heal removes all partitions.
+func (n *simNetwork) heal() {
+	n.partition()
+}
+
+func (n *simNetwork) connected(a, b peer.PID) bool {
+	ea, eb := n.endpoints[a], n.endpoints[b]
+	return a != b && ea != nil && eb != nil && ea.online && eb.online &&
+		n.groups[a] == n.groups[b]
+}
+
+func (n *simNetwork) send(from, to peer.PID, m elap2p.Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.connected(from, to) {
+		return errors.New("peer not connected")
+	}
+	if n.drop != nil && n.drop(from, to, m) {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if err := m.Serialize(buf); err != nil {
+		return err
+	}
+	copied, err := dpos.NewMessage(m.CMD())
+	if err != nil {
+		return err
+	}
+	if err := copied.Deserialize(buf); err != nil {
+		return err
+	}
+	latency, ok := n.latencies[simLink{from, to}]
+	if !ok {
+		latency = n.latency
+	}
+	n.seq++
+	n.queue = append(n.queue, &simEnvelope{
+		from:      from,
+		to:        to,
+		msg:       copied,
+		deliverAt: n.clock.AdjustedTime().Add(latency),
+		seq:       n.seq,
+	})
+	return nil
+}
+
+// next removes and returns the earliest message due at the current time of
+// the fake clock, messages whose link went down in flight are discarded.
+func (n *simNetwork) next() (*simEnvelope, dpos.NetworkEventListener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	sort.SliceStable(n.queue, func(i, j int) bool {
+		if !n.queue[i].deliverAt.Equal(n.queue[j].deliverAt) {
+			return n.queue[i].deliverAt.Before(n.queue[j].deliverAt)
+		}
+		return n.queue[i].seq < n.queue[j].seq
+	})
+	now := n.clock.AdjustedTime()
+	for len(n.queue) > 0 && !n.queue[0].deliverAt.After(now) {
+		envelope := n.queue[0]
+		n.queue = n.queue[1:]
+		if n.connected(envelope.from, envelope.to) {
+			return envelope, n.endpoints[envelope.to].listener
+		}
+	}
+	return nil, nil
+}
+
+// nextRecover returns the listener of the earliest pending recover task.
+func (n *simNetwork) nextRecover() dpos.NetworkEventListener {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for len(n.recovers) > 0 {
+		pid := n.recovers[0]
+		n.recovers = n.recovers[1:]
+		if endpoint := n.endpoints[pid]; endpoint.online {
+			return endpoint.listener
+		}
+	}
+	return nil
+}
+
+// pending returns the number of queued messages and tasks.
+func (n *simNetwork) pending() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.queue) + len(n.recovers)
+}
+
+// simEndpoint is the view of the simulated network of one node.
+type simEndpoint struct {
+	network  *simNetwork
+	pid      peer.PID
+	listener dpos.NetworkEventListener
+	online   bool
+}
+
+var _ dpos.DPOSNetwork = (*simEndpoint)(nil)
+
+// simPeer is a connected peer as returned by GetActivePeers.
+type simPeer peer.PID
+
+func (p simPeer) PID() peer.PID {
+	return peer.PID(p)
+}
+
+func (p simPeer) ToPeer() *peer.Peer {
+	return nil
+}
+
+func (e *simEndpoint) Start() {
+	e.network.setOnline(e.pid, true)
+}
+
+func (e *simEndpoint) Stop() error {
+	e.network.setOnline(e.pid, false)
+	return nil
+}
+
+func (e *simEndpoint) SendMessageToPeer(id peer.PID, msg elap2p.Message) error {
+	return e.network.send(e.pid, id, msg)
+}
+
+func (e *simEndpoint) BroadcastMessage(msg elap2p.Message) {
+	for _, info := range e.DumpPeersInfo() {
+		e.network.send(e.pid, info.PID, msg)
+	}
+}
+
+func (e *simEndpoint) UpdatePeers(currentPeers []peer.PID, nextPeers []peer.PID) {}
+
+func (e *simEndpoint) GetActivePeers() []p2p.Peer {
+	peers := make([]p2p.Peer, 0)
+	for _, info := range e.DumpPeersInfo() {
+		if info.State == p2p.CS2WayConnection {
+			peers = append(peers, simPeer(info.PID))
+		}
+	}
+	return peers
+}
+
+func (e *simEndpoint) DumpPeersInfo() []*p2p.PeerInfo {
+	e.network.mu.Lock()
+	defer e.network.mu.Unlock()
+	peers := make([]*p2p.PeerInfo, 0, len(e.network.endpoints))
+	for pid := range e.network.endpoints {
+		if pid == e.pid {
+			continue
+		}
+		state := p2p.CSNoneConnection
+		if e.network.connected(e.pid, pid) {
+			state = p2p.CS2WayConnection
+		}
+		peers = append(peers, &p2p.PeerInfo{PID: pid, State: state})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return bytes.Compare(peers[i].PID[:], peers[j].PID[:]) < 0
+	})
+	return peers
+}
+
+func (e *simEndpoint) AddDirectLinkAddr(pid peer.PID, addr string) {}
+
+// PostChangeViewTask is a no-op, the harness tries to change the view of
+// every node whenever it advances the fake clock.
+func (e *simEndpoint) PostChangeViewTask() {}
+
+func (e *simEndpoint) PostRecoverTask() {
+	e.network.mu.Lock()
+	e.network.recovers = append(e.network.recovers, e.pid)
+	e.network.mu.Unlock()
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/consensus"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/rlp"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+const (
+	producerSnapshotsFileName = "producersnapshots.rlp"
+
+	// producerSnapshotInterval is the number of blocks after which the
+	// producers are written again even if they did not change, so resolving
+	// the producers of a block walks back at most that many headers.
+	producerSnapshotInterval = 1024
+
+	// inmemoryProducerSnapshots is the number of resolved producer sets of
+	// recent blocks kept in memory.
+	inmemoryProducerSnapshots = 128
+)
+
+// producerSnapshot is the producer set in force for the children of the
+// imported block Hash at Height, the block carrying the main chain height
+// ElaHeight. The snapshots of the blocks before the producer transition fork
+// have no Hash: their producers follow from the main chain height alone and
+// they are the set in force at ElaHeight, first imported at Height.
+type producerSnapshot struct {
+	Height     uint64
+	Hash       common.Hash
+	ElaHeight  uint64
+	TotalCount uint64
+	Producers  [][]byte
+}
+
+// elaHeightKey keys the sets resolved for a main chain height among the
+// recent ones.
+type elaHeightKey uint64
+
+// producerSnapshots journals the producer sets in force after imported
+// blocks, so resolving the producers of a block from the chain does not walk
+// back to the producer transition fork. The sets are only derived from the
+// producer commitments of the chain. Before the fork, it journals the sets
+// of the main chain heights the imported blocks carry instead, so verifying
+// them again does not need the spv.
+type producerSnapshots struct {
+	mu         sync.Mutex
+	path       string
+	snapshots  map[common.Hash]*producerSnapshot
+	elaHeights map[uint64]*producerSnapshot
+	recents    *lru.ARCCache // Sets resolved for recent blocks, imported or not, and main chain heights
+}
+
+// newProducerSnapshots creates the snapshot journal inside dataDir, or keeps
+// the snapshots in memory only if dataDir is empty.
+func newProducerSnapshots(dataDir string) *producerSnapshots {
+	recents, _ := lru.NewARC(inmemoryProducerSnapshots)
+	snapshots := &producerSnapshots{
+		snapshots:  make(map[common.Hash]*producerSnapshot),
+		elaHeights: make(map[uint64]*producerSnapshot),
+		recents:    recents,
+	}
+	if dataDir == "" {
+		return snapshots
+	}
+	snapshots.path = filepath.Join(dataDir, "pbft", producerSnapshotsFileName)
+	if err := snapshots.load(); err != nil {
+		log.Warn("Failed to load producer snapshots", "err", err)
+	}
+	return snapshots
+}
+
+func (s *producerSnapshots) load() error {
+	input, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(input, 0)
+	for {
+		snapshot := new(producerSnapshot)
+		if err := stream.Decode(snapshot); err != nil {
+			if err != io.EOF {
+				log.Warn("Producer snapshots journal is truncated", "path", s.path, "err", err)
+			}
+			break
+		}
+		if snapshot.Hash == (common.Hash{}) {
+			s.elaHeights[snapshot.ElaHeight] = snapshot
+		} else {
+			s.snapshots[snapshot.Hash] = snapshot
+		}
+	}
+	log.Info("Loaded producer snapshots", "snapshots", len(s.snapshots), "elaheights", len(s.elaHeights))
+	return nil
+}
+
+// get returns the producers in force after the block hash, or nil if they
+// were not resolved yet.
+func (s *producerSnapshots) get(hash common.Hash) *ProducerSet {
+	if set, ok := s.recents.Get(hash); ok {
+		return set.(*ProducerSet)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snapshot := s.snapshots[hash]; snapshot != nil {
+		return snapshot.producerSet()
+	}
+	return nil
+}
+
+// getAtElaHeight returns the producers before the producer transition fork
+// at the main chain height elaHeight, or nil if they were not resolved yet.
+func (s *producerSnapshots) getAtElaHeight(elaHeight uint64) *ProducerSet {
+	if set, ok := s.recents.Get(elaHeightKey(elaHeight)); ok {
+		return set.(*ProducerSet)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snapshot := s.elaHeights[elaHeight]; snapshot != nil {
+		return snapshot.producerSet()
+	}
+	return nil
+}
+
+// remember keeps in memory the producers resolved for the block hash.
+func (s *producerSnapshots) remember(hash common.Hash, set *ProducerSet) {
+	s.recents.Add(hash, set)
+}
+
+// rememberAtElaHeight keeps in memory the producers resolved for the main
+// chain height elaHeight.
+func (s *producerSnapshots) rememberAtElaHeight(elaHeight uint64, set *ProducerSet) {
+	s.recents.Add(elaHeightKey(elaHeight), set)
+}
+
+// store journals the producers in force after the imported header.
+func (s *producerSnapshots) store(header *types.Header, set *ProducerSet) error {
+	snapshot := &producerSnapshot{
+		Height:     header.Number.Uint64(),
+		Hash:       header.Hash(),
+		ElaHeight:  header.Nonce.Uint64(),
+		TotalCount: uint64(set.TotalCount),
+		Producers:  set.Producers,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.snapshots[snapshot.Hash]; ok {
+		return nil
+	}
+	s.snapshots[snapshot.Hash] = snapshot
+	return s.write(snapshot)
+}
+
+// storeAtElaHeight journals the producers before the producer transition fork
+// at the main chain height of the imported header.
+func (s *producerSnapshots) storeAtElaHeight(header *types.Header, set *ProducerSet) error {
+	snapshot := &producerSnapshot{
+		Height:     header.Number.Uint64(),
+		ElaHeight:  header.Nonce.Uint64(),
+		TotalCount: uint64(set.TotalCount),
+		Producers:  set.Producers,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.elaHeights[snapshot.ElaHeight]; ok {
+		return nil
+	}
+	s.elaHeights[snapshot.ElaHeight] = snapshot
+	return s.write(snapshot)
+}
+
+// checkpoints returns the journaled producers before the producer transition
+// fork as the checkpoints of the pbft config, one at each main chain height
+// the set changes at.
+func (s *producerSnapshots) checkpoints() []params.ProducerCheckpoint {
+	s.mu.Lock()
+	snapshots := make([]*producerSnapshot, 0, len(s.elaHeights))
+	for _, snapshot := range s.elaHeights {
+		snapshots = append(snapshots, snapshot)
+	}
+	s.mu.Unlock()
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ElaHeight < snapshots[j].ElaHeight
+	})
+
+	var (
+		checkpoints []params.ProducerCheckpoint
+		last        common.Hash
+	)
+	for _, snapshot := range snapshots {
+		set := snapshot.producerSet()
+		if hash := set.Hash(); len(checkpoints) == 0 || hash != last {
+			checkpoint := params.ProducerCheckpoint{
+				ElaHeight:  snapshot.ElaHeight,
+				TotalCount: set.TotalCount,
+			}
+			for _, producer := range set.Producers {
+				checkpoint.Producers = append(checkpoint.Producers, common.Bytes2Hex(producer))
+			}
+			checkpoints = append(checkpoints, checkpoint)
+			last = hash
+		}
+	}
+	return checkpoints
+}
+
+// write appends snapshot to the journal, the lock must be held.
+func (s *producerSnapshots) write(snapshot *producerSnapshot) error {
+	if s.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	output, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	return rlp.Encode(output, snapshot)
+}
+
+func (s *producerSnapshot) producerSet() *ProducerSet {
+	return &ProducerSet{Producers: s.Producers, TotalCount: int(s.TotalCount)}
+}
+
+// checkpointProducerSet returns the producers of the checkpoint.
+func checkpointProducerSet(checkpoint *params.ProducerCheckpoint) *ProducerSet {
+	producers := make([][]byte, len(checkpoint.Producers))
+	for i, v := range checkpoint.Producers {
+		producers[i] = common.Hex2Bytes(v)
+	}
+	sort.Slice(producers, func(i, j int) bool {
+		return bytes.Compare(producers[i], producers[j]) < 0
+	})
+	return &ProducerSet{Producers: producers, TotalCount: checkpoint.TotalCount}
+}
+
+// chainProducers returns whether the producers sealing the block at number
+// follow from the chain: the blocks after the producer transition fork block,
+// which commits to the producers in force after it, and all blocks if the
+// fork is at the genesis, whose producers are the ones of the config.
+func chainProducers(config *params.ChainConfig, number uint64) bool {
+	return config.ProducerTransitionBlock != nil && config.ProducerTransitionBlock.Uint64() < number
+}
+
+// producersAfter returns the producers in force for the children of the block
+// hash at number: the producers the nearest committing block up to it commits
+// to. parents are the headers not imported yet the block may be among, in
+// ascending order.
+func (p *Pbft) producersAfter(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*ProducerSet, error) {
+	var walked []common.Hash
+	for {
+		set := p.producerSnapshots.get(hash)
+		if set == nil && number == 0 {
+			set = GenesisProducerSet(&p.cfg)
+		}
+		if set != nil {
+			p.rememberProducers(walked, set)
+			return set, nil
+		}
+		var header *types.Header
+		if len(parents) > 0 {
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Number.Uint64() != number {
+				return nil, consensus.ErrUnknownAncestor
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			header = chain.GetHeader(hash, number)
+			if header == nil {
+				return nil, consensus.ErrUnknownAncestor
+			}
+		}
+		walked = append(walked, hash)
+		set, err := committedProducerSet(header)
+		if err != nil {
+			return nil, err
+		}
+		if set != nil {
+			p.rememberProducers(walked, set)
+			return set, nil
+		}
+		if !chainProducers(chain.Config(), number) {
+			// The producer transition fork block commits to the producers
+			return nil, errMissingProducersCommitment
+		}
+		number, hash = number-1, header.ParentHash
+	}
+}
+
+// rememberProducers keeps in memory that set is in force after the blocks
+// hashes.
+func (p *Pbft) rememberProducers(hashes []common.Hash, set *ProducerSet) {
+	for _, hash := range hashes {
+		p.producerSnapshots.remember(hash, set)
+	}
+}
+
+// producersAtElaHeight returns the producers sealing the blocks before the
+// producer transition fork at the main chain height elaHeight. They are the
+// ones of the last checkpoint of the config up to it, else the ones
+// journaled when a block at that height was imported, else the main chain
+// arbiters in the spv. The CR producers before the main chain height is known
+// are the ones of the config, with a total count of zero.
+func (p *Pbft) producersAtElaHeight(elaHeight uint64) (*ProducerSet, error) {
+	if checkpoint := p.cfg.CheckpointAt(elaHeight); checkpoint != nil {
+		return checkpointProducerSet(checkpoint), nil
+	}
+	if set := p.producerSnapshots.getAtElaHeight(elaHeight); set != nil {
+		return set, nil
+	}
+	if elaHeight == 0 {
+		set := GenesisProducerSet(&p.cfg)
+		set.TotalCount = 0
+		return set, nil
+	}
+	producers, count, err := spv.GetProducers(elaHeight)
+	if err != nil {
+		return nil, err
+	}
+	set := &ProducerSet{Producers: producers, TotalCount: count}
+	p.producerSnapshots.rememberAtElaHeight(elaHeight, set)
+	return set, nil
+}
+
+// storeProducerSnapshot journals the producers in force after the imported
+// block if it commits to them or if none were journaled for the last
+// producerSnapshotInterval blocks. Before the producer transition fork, it
+// journals the producers at the main chain height of the block unless a
+// checkpoint of the config covers it.
+func (p *Pbft) storeProducerSnapshot(chain consensus.ChainReader, header *types.Header) {
+	number := header.Number.Uint64()
+	if !chainProducers(chain.Config(), number) {
+		p.storeElaHeightSnapshot(header)
+	}
+	if !chainProducers(chain.Config(), number+1) {
+		return
+	}
+	if header.MixDigest == (common.Hash{}) && number%producerSnapshotInterval != 0 {
+		return
+	}
+	set, err := p.producersAfter(chain, number, header.Hash(), nil)
+	if err == nil {
+		err = p.producerSnapshots.store(header, set)
+	}
+	if err != nil {
+		log.Warn("store producer snapshot error", "height", number, "err", err)
+	}
+}
+
+func (p *Pbft) storeElaHeightSnapshot(header *types.Header) {
+	elaHeight := header.Nonce.Uint64()
+	if elaHeight == 0 || p.cfg.CheckpointAt(elaHeight) != nil {
+		return
+	}
+	set, err := p.producersAtElaHeight(elaHeight)
+	if err == nil {
+		err = p.producerSnapshots.storeAtElaHeight(header, set)
+	}
+	if err != nil {
+		log.Warn("store producer snapshot error", "height", header.Number, "elaHeight", elaHeight, "err", err)
+	}
+}
+
+// sealProducers returns the producers the seal of header is verified against.
+// After the producer transition fork they are the producers in force after
+// its parent. Before, they are the producers at the ELA height of header.
+func (p *Pbft) sealProducers(chain consensus.ChainReader, header *types.Header, parents []*types.Header) (*ProducerSet, error) {
+	if number := header.Number.Uint64(); chainProducers(chain.Config(), number) {
+		return p.producersAfter(chain, number-1, header.ParentHash, parents)
+	}
+	return p.producersAtElaHeight(header.Nonce.Uint64())
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/consensus"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/params"
+)
+
+// snapshotTestChain is a chain of headers, committing to the producers set at
+// their heights.
+type snapshotTestChain struct {
+	consensus.ChainReader
+	config  *params.ChainConfig
+	headers []*types.Header
+}
+
+func newSnapshotTestChain(t *testing.T, config *params.ChainConfig, length int, commitments map[int]*ProducerSet) *snapshotTestChain {
+	chain := &snapshotTestChain{config: config}
+	parent := &types.Header{Number: big.NewInt(0)}
+	chain.headers = append(chain.headers, parent)
+	for i := 1; i < length; i++ {
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent.Hash()}
+		if set := commitments[i]; set != nil {
+			var err error
+			header.Extra, err = types.EncodeSealedExtra(nil, set.commitment(), nil)
+			assert.NoError(t, err)
+			header.MixDigest = set.Hash()
+		}
+		chain.headers = append(chain.headers, header)
+		parent = header
+	}
+	return chain
+}
+
+func (c *snapshotTestChain) Config() *params.ChainConfig { return c.config }
+
+func (c *snapshotTestChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	if number < uint64(len(c.headers)) && c.headers[number] != nil && c.headers[number].Hash() == hash {
+		return c.headers[number]
+	}
+	return nil
+}
+
+func TestProducersAfter(t *testing.T) {
+	signers := newTransitionTestSigners(t, 5)
+	first, second := producerSetOf(signers[:4]), producerSetOf(signers[1:])
+	cfg := params.PbftConfig{}
+	for _, signer := range signers[:4] {
+		cfg.Producers = append(cfg.Producers, common.Bytes2Hex(signer.publicKey))
+	}
+
+	// from the genesis producers when the fork is at the genesis
+	config := &params.ChainConfig{ProducerTransitionBlock: big.NewInt(0)}
+	chain := newSnapshotTestChain(t, config, 8, map[int]*ProducerSet{4: second})
+	p := &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	for height := 1; height < 8; height++ {
+		set, err := p.sealProducers(chain, chain.headers[height], nil)
+		assert.NoError(t, err)
+		want := first
+		if height > 4 {
+			want = second
+		}
+		assert.Equal(t, want.Hash(), set.Hash(), "block %d", height)
+	}
+
+	// from the fork block which must commit otherwise
+	config = &params.ChainConfig{ProducerTransitionBlock: big.NewInt(3)}
+	chain = newSnapshotTestChain(t, config, 8, map[int]*ProducerSet{3: second})
+	p = &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	set, err := p.sealProducers(chain, chain.headers[7], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.Hash(), set.Hash())
+
+	chain = newSnapshotTestChain(t, config, 8, nil)
+	p = &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	_, err = p.sealProducers(chain, chain.headers[7], nil)
+	assert.Equal(t, errMissingProducersCommitment, err)
+
+	// headers not imported yet are resolved from the parents
+	chain = newSnapshotTestChain(t, config, 8, map[int]*ProducerSet{3: first, 5: second})
+	imported := &snapshotTestChain{config: config, headers: chain.headers[:4]}
+	p = &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	set, err = p.sealProducers(imported, chain.headers[7], chain.headers[4:7])
+	assert.NoError(t, err)
+	assert.Equal(t, second.Hash(), set.Hash())
+	set, err = p.sealProducers(imported, chain.headers[5], chain.headers[4:5])
+	assert.NoError(t, err)
+	assert.Equal(t, first.Hash(), set.Hash())
+	p = &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	_, err = p.sealProducers(imported, chain.headers[7], nil)
+	assert.Equal(t, consensus.ErrUnknownAncestor, err)
+}
+
+func TestProducerSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "producer-snapshots")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signers := newTransitionTestSigners(t, 5)
+	first, second := producerSetOf(signers[:4]), producerSetOf(signers[1:])
+	config := &params.ChainConfig{ProducerTransitionBlock: big.NewInt(1)}
+	length := producerSnapshotInterval + 2
+	chain := newSnapshotTestChain(t, config, length, map[int]*ProducerSet{1: first, 3: second})
+
+	// only committing blocks and every producerSnapshotInterval blocks are
+	// journaled, after their import
+	p := &Pbft{producerSnapshots: newProducerSnapshots(dir)}
+	for _, header := range chain.headers[1:] {
+		p.storeProducerSnapshot(chain, header)
+	}
+	assert.Len(t, p.producerSnapshots.snapshots, 3)
+
+	// the journal resolves the producers without the headers before it
+	p = &Pbft{producerSnapshots: newProducerSnapshots(dir)}
+	assert.Len(t, p.producerSnapshots.snapshots, 3)
+	assert.Equal(t, first.Hash(), p.producerSnapshots.get(chain.headers[1].Hash()).Hash())
+	pruned := &snapshotTestChain{config: config, headers: make([]*types.Header, length)}
+	copy(pruned.headers[producerSnapshotInterval:], chain.headers[producerSnapshotInterval:])
+	set, err := p.sealProducers(pruned, chain.headers[length-1], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.Hash(), set.Hash())
+}
+
+func TestProducersAtElaHeight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "producer-snapshots")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signers := newTransitionTestSigners(t, 5)
+	first, second := producerSetOf(signers[:4]), producerSetOf(signers[1:])
+	first.TotalCount, second.TotalCount = 6, 7
+	checkpoint := func(elaHeight uint64, set *ProducerSet) params.ProducerCheckpoint {
+		checkpoint := params.ProducerCheckpoint{ElaHeight: elaHeight, TotalCount: set.TotalCount}
+		for i := len(set.Producers) - 1; i >= 0; i-- {
+			checkpoint.Producers = append(checkpoint.Producers, common.Bytes2Hex(set.Producers[i]))
+		}
+		return checkpoint
+	}
+	header := func(number, elaHeight uint64) *types.Header {
+		return &types.Header{Number: new(big.Int).SetUint64(number), Nonce: types.EncodeNonce(elaHeight)}
+	}
+	chain := &snapshotTestChain{config: &params.ChainConfig{ProducerTransitionBlock: big.NewInt(100)}}
+	cfg := params.PbftConfig{Checkpoints: []params.ProducerCheckpoint{checkpoint(100, first), checkpoint(200, second)}}
+	for _, signer := range signers[1:] {
+		cfg.Producers = append(cfg.Producers, common.Bytes2Hex(signer.publicKey))
+	}
+
+	// from the last checkpoint up to the main chain height
+	p := &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots("")}
+	for elaHeight, want := range map[uint64]*ProducerSet{100: first, 199: first, 200: second, 1000: second} {
+		set, err := p.sealProducers(chain, header(10, elaHeight), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, want.Hash(), set.Hash(), "ela height %d", elaHeight)
+		assert.Equal(t, want.TotalCount, set.TotalCount, "ela height %d", elaHeight)
+	}
+	// the CR producers before the main chain height is known
+	set, err := p.sealProducers(chain, header(10, 0), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, second.Producers, set.Producers)
+	assert.Equal(t, 0, set.TotalCount)
+	// the spv is needed below the first checkpoint
+	_, err = p.sealProducers(chain, header(10, 50), nil)
+	assert.Error(t, err)
+
+	// the journal keyed by main chain height resolves them without the spv,
+	// blocks covered by a checkpoint are not journaled
+	p = &Pbft{cfg: cfg, producerSnapshots: newProducerSnapshots(dir)}
+	for elaHeight, set := range map[uint64]*ProducerSet{40: second, 50: first, 60: first} {
+		p.producerSnapshots.rememberAtElaHeight(elaHeight, set)
+	}
+	for i, elaHeight := range []uint64{40, 50, 50, 60, 150} {
+		p.storeProducerSnapshot(chain, header(uint64(i+1), elaHeight))
+	}
+	assert.Len(t, p.producerSnapshots.elaHeights, 3)
+	p = &Pbft{producerSnapshots: newProducerSnapshots(dir)}
+	assert.Len(t, p.producerSnapshots.elaHeights, 3)
+	set, err = p.sealProducers(chain, header(2, 50), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Hash(), set.Hash())
+	assert.Equal(t, first.TotalCount, set.TotalCount)
+
+	// and exports the checkpoints at each change of the set
+	hexes := func(set *ProducerSet) (producers []string) {
+		for _, producer := range set.Producers {
+			producers = append(producers, common.Bytes2Hex(producer))
+		}
+		return producers
+	}
+	assert.Equal(t, []params.ProducerCheckpoint{
+		{ElaHeight: 40, TotalCount: second.TotalCount, Producers: hexes(second)},
+		{ElaHeight: 50, TotalCount: first.TotalCount, Producers: hexes(first)},
+	}, p.producerSnapshots.checkpoints())
+}
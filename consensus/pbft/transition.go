@@ -0,0 +1,458 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/rlp"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+const producerTransitionsFileName = "producertransitions.rlp"
+
+var (
+	// errInvalidProducersCommitment is returned if a block commits to another
+	// producer set than the next turn producers, or if its mix digest does not
+	// commit to the producers it carries.
+	errInvalidProducersCommitment = errors.New("invalid next producers commitment")
+
+	// errMissingProducersCommitment is returned if a block that must commit to
+	// the producers in force after it does not.
+	errMissingProducersCommitment = errors.New("missing next producers commitment")
+
+	// errProducersCommitmentNotActive is returned if a block carries a
+	// producer commitment before the producer transition fork.
+	errProducersCommitmentNotActive = errors.New("producers commitment before fork")
+
+	// errInvalidProducerTransition is returned if a transition does not
+	// follow the previous one.
+	errInvalidProducerTransition = errors.New("invalid producer transition")
+
+	// errConfirmMismatch is returned if the confirm sealed into a header
+	// confirms another block.
+	errConfirmMismatch = errors.New("confirm does not match header")
+)
+
+// ProducerSet is the producer list in force for a range of blocks, ordered
+// like the consensus view indexes the signers of aggregate confirms. Empty
+// entries are the seats of the main chain arbiters left vacant.
+type ProducerSet struct {
+	Producers  [][]byte
+	TotalCount int
+}
+
+// GenesisProducerSet returns the producers of the pbft config, which are in
+// force until the first transition.
+func GenesisProducerSet(cfg *params.PbftConfig) *ProducerSet {
+	producers := make([][]byte, len(cfg.Producers))
+	for i, v := range cfg.Producers {
+		producers[i] = common.Hex2Bytes(v)
+	}
+	sort.Slice(producers, func(i, j int) bool {
+		return bytes.Compare(producers[i], producers[j]) < 0
+	})
+	return &ProducerSet{Producers: producers, TotalCount: len(producers)}
+}
+
+// Hash returns the commitment to the set that the last block of the outgoing
+// producers carries in its mix digest.
+func (s *ProducerSet) Hash() common.Hash {
+	return s.commitment().Hash()
+}
+
+// commitment returns the set as the last block of the outgoing producers
+// carries it in its extra data.
+func (s *ProducerSet) commitment() *types.ProducerCommitment {
+	return &types.ProducerCommitment{TotalCount: uint64(s.TotalCount), Producers: s.Producers}
+}
+
+// committedProducerSet returns the set header commits to, nil if it commits
+// to none.
+func committedProducerSet(header *types.Header) (*ProducerSet, error) {
+	_, commitment, _, err := types.SplitSealedExtra(header.Extra)
+	if err != nil || commitment == nil {
+		return nil, err
+	}
+	return &ProducerSet{Producers: commitment.Producers, TotalCount: int(commitment.TotalCount)}, nil
+}
+
+// minSignCount returns how many producers of the set must sign a confirm,
+// the majority of the total producers like the consensus view counts it.
+func (s *ProducerSet) minSignCount() int {
+	count := int(float64(s.TotalCount) * 2 / 3)
+	if count < 1 {
+		return 1
+	}
+	return count
+}
+
+// ProducerTransition proves the producers in force from ChangeHeight on.
+// Header is the last block of the outgoing producers, its confirm signs the
+// commitment to the incoming producers in the mix digest.
+type ProducerTransition struct {
+	ChangeHeight uint64          `json:"changeHeight"`
+	Producers    []hexutil.Bytes `json:"producers"`
+	TotalCount   int             `json:"totalCount"`
+	Header       *types.Header   `json:"header"`
+}
+
+// ProducerSet returns the incoming producers of the transition.
+func (t *ProducerTransition) ProducerSet() *ProducerSet {
+	producers := make([][]byte, len(t.Producers))
+	for i, producer := range t.Producers {
+		producers[i] = producer
+	}
+	return &ProducerSet{Producers: producers, TotalCount: t.TotalCount}
+}
+
+// VerifyConfirmedHeader checks the confirm sealed into header confirms the
+// header and is signed by the majority of set. Aggregate confirms are checked
+// against the BLS public keys registered in cfg.
+func VerifyConfirmedHeader(cfg *params.PbftConfig, set *ProducerSet, header *types.Header) error {
+	sealHash, err := ecom.Uint256FromBytes(SealHash(header).Bytes())
+	if err != nil {
+		return err
+	}
+	extra := sealedConfirm(header.Extra)
+	if dpos.IsAggregateConfirm(extra) {
+		var confirm dpos.AggregateConfirm
+		if err := confirm.Deserialize(bytes.NewReader(extra)); err != nil {
+			return err
+		}
+		if !confirm.Proposal.BlockHash.IsEqual(*sealHash) {
+			return errConfirmMismatch
+		}
+		publicKeys := blsPublicKeysOf(parseBlsPublicKeys(cfg.BlsPublicKeys, cfg.BlsProofs), set.Producers)
+		return dpos.CheckAggregateConfirm(&confirm, publicKeys, set.minSignCount())
+	}
+	var confirm payload.Confirm
+	if err := confirm.Deserialize(bytes.NewReader(extra)); err != nil {
+		return err
+	}
+	if !confirm.Proposal.BlockHash.IsEqual(*sealHash) {
+		return errConfirmMismatch
+	}
+	return dpos.CheckProducersConfirm(&confirm, set.Producers, set.minSignCount())
+}
+
+// VerifyProducerTransitions follows transitions from the genesis producers of
+// cfg and returns the producers in force after the last one. Confirms of
+// later blocks are checked against it with VerifyConfirmedHeader.
+func VerifyProducerTransitions(cfg *params.PbftConfig, transitions []*ProducerTransition) (*ProducerSet, error) {
+	set, height := GenesisProducerSet(cfg), uint64(0)
+	for i, transition := range transitions {
+		header := transition.Header
+		if header == nil || header.Number == nil || header.Number.Uint64()+1 != transition.ChangeHeight ||
+			transition.ChangeHeight <= height {
+			return nil, fmt.Errorf("transition %d: %v", i, errInvalidProducerTransition)
+		}
+		if err := VerifyConfirmedHeader(cfg, set, header); err != nil {
+			return nil, fmt.Errorf("transition %d: %v", i, err)
+		}
+		next := transition.ProducerSet()
+		if header.MixDigest != next.Hash() {
+			return nil, fmt.Errorf("transition %d: %v", i, errInvalidProducersCommitment)
+		}
+		set, height = next, transition.ChangeHeight
+	}
+	return set, nil
+}
+
+// producerTransitionRecord is a transition as it is stored on disk, the
+// header is read back from the chain by BlockHash.
+type producerTransitionRecord struct {
+	ChangeHeight uint64
+	BlockHash    common.Hash
+	TotalCount   uint64
+	Producers    [][]byte
+}
+
+// producerTransitions journals the producer changes the chain committed to,
+// so the node can prove them to clients that do not run the consensus.
+type producerTransitions struct {
+	mu      sync.Mutex
+	path    string
+	records []*producerTransitionRecord
+}
+
+// newProducerTransitions creates the transition journal inside dataDir, or
+// keeps the transitions in memory only if dataDir is empty.
+func newProducerTransitions(dataDir string) *producerTransitions {
+	transitions := new(producerTransitions)
+	if dataDir == "" {
+		return transitions
+	}
+	transitions.path = filepath.Join(dataDir, "pbft", producerTransitionsFileName)
+	if err := transitions.load(); err != nil {
+		log.Warn("Failed to load producer transitions", "err", err)
+	}
+	return transitions
+}
+
+func (t *producerTransitions) load() error {
+	input, err := os.Open(t.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	stream := rlp.NewStream(input, 0)
+	for {
+		record := new(producerTransitionRecord)
+		if err := stream.Decode(record); err != nil {
+			if err != io.EOF {
+				log.Warn("Producer transitions journal is truncated", "path", t.path, "err", err)
+			}
+			break
+		}
+		t.add(record)
+	}
+	log.Info("Loaded producer transitions", "transitions", len(t.records))
+	return nil
+}
+
+// add keeps the records ordered by change height, a record replaces the one
+// of the same height which a reorganisation dropped.
+func (t *producerTransitions) add(record *producerTransitionRecord) {
+	index := sort.Search(len(t.records), func(i int) bool {
+		return t.records[i].ChangeHeight >= record.ChangeHeight
+	})
+	if index < len(t.records) && t.records[index].ChangeHeight == record.ChangeHeight {
+		t.records[index] = record
+		return
+	}
+	t.records = append(t.records, nil)
+	copy(t.records[index+1:], t.records[index:])
+	t.records[index] = record
+}
+
+// record journals the change to the producers the imported header commits
+// to. It returns errMissingProducersCommitment if the header carries none.
+func (t *producerTransitions) record(header *types.Header) error {
+	set, err := committedProducerSet(header)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		return errMissingProducersCommitment
+	}
+	record := &producerTransitionRecord{
+		ChangeHeight: header.Number.Uint64() + 1,
+		BlockHash:    header.Hash(),
+		TotalCount:   uint64(set.TotalCount),
+		Producers:    set.Producers,
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.add(record)
+	if t.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return err
+	}
+	output, err := os.OpenFile(t.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+	return rlp.Encode(output, record)
+}
+
+// list returns the records of the changes from height on.
+func (t *producerTransitions) list(height uint64) []*producerTransitionRecord {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	index := sort.Search(len(t.records), func(i int) bool {
+		return t.records[i].ChangeHeight >= height
+	})
+	records := make([]*producerTransitionRecord, len(t.records)-index)
+	copy(records, t.records[index:])
+	return records
+}
+
+// nextProducerSet returns the next turn producers, or nil if they are unknown
+// or the same as the current producers.
+func (p *Pbft) nextProducerSet() *ProducerSet {
+	view := p.dispatcher.GetConsensusView()
+	producers, totalCount := view.GetNextProducers()
+	if len(producers) == 0 || view.IsSameProducers(view.GetProducers()) {
+		return nil
+	}
+	return &ProducerSet{Producers: producers, TotalCount: totalCount}
+}
+
+// isLastTurnBlock returns whether the producers change after the block being
+// finalized, once the main chain reached the working height of the next turn.
+func (p *Pbft) isLastTurnBlock() bool {
+	return p.dispatcher.GetConsensusView().GetDutyIndex() == 0 && spv.SpvIsWorkingHeight()
+}
+
+// isProducerTransitionAnchor returns whether number is the producer
+// transition fork block. Unless it is the genesis, whose producers are the
+// ones of the config, it commits to the producers in force after it, so the
+// producer sets of the later blocks are known from the chain.
+func isProducerTransitionAnchor(config *params.ChainConfig, number *big.Int) bool {
+	return config.ProducerTransitionBlock != nil && config.ProducerTransitionBlock.Sign() > 0 &&
+		config.ProducerTransitionBlock.Cmp(number) == 0
+}
+
+// committedProducers returns the producers the block sealed on header
+// commits to: the next turn producers if they take over after the block, and
+// the current producers at the producer transition fork block otherwise. It
+// returns nil if the block commits to none.
+func (p *Pbft) committedProducers(config *params.ChainConfig, header *types.Header) *ProducerSet {
+	if !config.IsProducerTransition(header.Number) || p.dispatcher == nil {
+		return nil
+	}
+	if p.needChangeNextTurnProducers || p.isLastTurnBlock() {
+		if set := p.nextProducerSet(); set != nil {
+			return set
+		}
+	}
+	if isProducerTransitionAnchor(config, header.Number) {
+		view := p.dispatcher.GetConsensusView()
+		return &ProducerSet{Producers: view.GetProducers(), TotalCount: view.GetTotalProducersCount()}
+	}
+	return nil
+}
+
+// commitProducers makes header commit to the producers in force after it if
+// it must, with its mix digest and the producers in its extra data.
+func (p *Pbft) commitProducers(config *params.ChainConfig, header *types.Header) error {
+	set := p.committedProducers(config, header)
+	if set == nil {
+		header.MixDigest = common.Hash{}
+		return nil
+	}
+	snapshot, _, confirm, err := types.SplitSealedExtra(header.Extra)
+	if err != nil {
+		return err
+	}
+	if header.Extra, err = types.EncodeSealedExtra(snapshot, set.commitment(), confirm); err != nil {
+		return err
+	}
+	header.MixDigest = set.Hash()
+	log.Info("commit to next turn producers", "height", header.Number, "producers", len(set.Producers))
+	return nil
+}
+
+// verifyProducersCommitment checks the producer commitment of header. Before
+// the producer transition fork a block commits to none. After it a non-zero
+// mix digest is the hash of the producers the block carries, and the fork
+// block must commit.
+func verifyProducersCommitment(config *params.ChainConfig, header *types.Header) error {
+	_, commitment, _, err := types.SplitSealedExtra(header.Extra)
+	if err != nil {
+		return err
+	}
+	if !config.IsProducerTransition(header.Number) {
+		if header.MixDigest != (common.Hash{}) {
+			return errInvalidMixDigest
+		}
+		if commitment != nil {
+			return errProducersCommitmentNotActive
+		}
+		return nil
+	}
+	if header.MixDigest == (common.Hash{}) {
+		if commitment != nil {
+			return errInvalidProducersCommitment
+		}
+		if isProducerTransitionAnchor(config, header.Number) {
+			return errMissingProducersCommitment
+		}
+		return nil
+	}
+	if commitment == nil {
+		return errMissingProducersCommitment
+	}
+	if commitment.Hash() != header.MixDigest {
+		return errInvalidProducersCommitment
+	}
+	return checkCommittedProducers(commitment)
+}
+
+// checkCommittedProducers checks the committed producers are distinct public
+// keys or vacant seats, at least one being filled.
+func checkCommittedProducers(commitment *types.ProducerCommitment) error {
+	seen := make(map[string]struct{}, len(commitment.Producers))
+	for _, producer := range commitment.Producers {
+		if len(producer) == 0 {
+			continue
+		}
+		if len(producer) != 33 {
+			return errInvalidProducersCommitment
+		}
+		if _, ok := seen[string(producer)]; ok {
+			return errInvalidProducersCommitment
+		}
+		seen[string(producer)] = struct{}{}
+	}
+	if len(seen) == 0 {
+		return errInvalidProducersCommitment
+	}
+	return nil
+}
+
+// verifyNextProducers checks a proposed block commits to the producers this
+// producer expects in force after it, and to none if it expects no change.
+func (p *Pbft) verifyNextProducers(header *types.Header) error {
+	expected := common.Hash{}
+	if set := p.committedProducers(p.chainConfig, header); set != nil {
+		expected = set.Hash()
+	}
+	if header.MixDigest != expected {
+		return errInvalidProducersCommitment
+	}
+	return nil
+}
+
+// GetProducerTransitions returns the proofs of the producer changes from
+// height on that are still on the canonical chain.
+func (p *Pbft) GetProducerTransitions(height uint64) []*ProducerTransition {
+	transitions := make([]*ProducerTransition, 0)
+	if p.chain == nil {
+		return transitions
+	}
+	for _, record := range p.producerTransitions.list(height) {
+		header := p.chain.GetHeaderByNumber(record.ChangeHeight - 1)
+		if header == nil || header.Hash() != record.BlockHash {
+			continue
+		}
+		producers := make([]hexutil.Bytes, len(record.Producers))
+		for i, producer := range record.Producers {
+			producers[i] = producer
+		}
+		transitions = append(transitions, &ProducerTransition{
+			ChangeHeight: record.ChangeHeight,
+			Producers:    producers,
+			TotalCount:   int(record.TotalCount),
+			Header:       header,
+		})
+	}
+	return transitions
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"testing"
+
+	ecom "github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/params"
+)
+
+// newTransitionTestSigners returns count signers ordered like the producers
+// of a consensus view.
+func newTransitionTestSigners(t *testing.T, count int) []*illegalTestSigner {
+	signers := make([]*illegalTestSigner, count)
+	for i := range signers {
+		signers[i] = newIllegalTestSigner(t)
+	}
+	sort.Slice(signers, func(i, j int) bool {
+		return bytes.Compare(signers[i].publicKey, signers[j].publicKey) < 0
+	})
+	return signers
+}
+
+func producerSetOf(signers []*illegalTestSigner) *ProducerSet {
+	set := &ProducerSet{TotalCount: len(signers)}
+	for _, signer := range signers {
+		set.Producers = append(set.Producers, signer.publicKey)
+	}
+	return set
+}
+
+// newConfirmedHeader returns the header at height committing to mixDigest,
+// sealed with a confirm of the voters.
+func newConfirmedHeader(t *testing.T, height uint64, mixDigest common.Hash, voters []*illegalTestSigner) *types.Header {
+	header := &types.Header{
+		Number:     new(big.Int).SetUint64(height),
+		Difficulty: big.NewInt(1),
+		MixDigest:  mixDigest,
+	}
+	sealHash, err := ecom.Uint256FromBytes(SealHash(header).Bytes())
+	assert.NoError(t, err)
+	confirm := &payload.Confirm{Proposal: payload.DPOSProposal{
+		Sponsor:   voters[0].publicKey,
+		BlockHash: *sealHash,
+	}}
+	confirm.Proposal.Sign = voters[0].sign(t, confirm.Proposal.Data())
+	for _, voter := range voters {
+		confirm.Votes = append(confirm.Votes, *newTestVote(t, voter, &confirm.Proposal, true))
+	}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, confirm.Serialize(buf))
+	header.Extra = buf.Bytes()
+	return header
+}
+
+func newProducerTransition(header *types.Header, set *ProducerSet) *ProducerTransition {
+	transition := &ProducerTransition{
+		ChangeHeight: header.Number.Uint64() + 1,
+		TotalCount:   set.TotalCount,
+		Header:       header,
+	}
+	for _, producer := range set.Producers {
+		transition.Producers = append(transition.Producers, hexutil.Bytes(producer))
+	}
+	return transition
+}
+
+func TestVerifyProducerTransitions(t *testing.T) {
+	signers := newTransitionTestSigners(t, 7)
+	genesis, outsiders := signers[:4], signers[4:]
+	cfg := &params.PbftConfig{}
+	for _, signer := range genesis {
+		cfg.Producers = append(cfg.Producers, common.Bytes2Hex(signer.publicKey))
+	}
+	assert.Equal(t, producerSetOf(genesis).Hash(), GenesisProducerSet(cfg).Hash())
+
+	next := producerSetOf(signers[3:])
+	header := newConfirmedHeader(t, 10, next.Hash(), genesis[:3])
+	set, err := VerifyProducerTransitions(cfg, []*ProducerTransition{newProducerTransition(header, next)})
+	assert.NoError(t, err)
+	assert.Equal(t, next.Hash(), set.Hash())
+
+	// later confirms are checked against the incoming producers
+	assert.NoError(t, VerifyConfirmedHeader(cfg, set, newConfirmedHeader(t, 12, common.Hash{}, outsiders)))
+	assert.Error(t, VerifyConfirmedHeader(cfg, set, newConfirmedHeader(t, 12, common.Hash{}, genesis[:3])))
+
+	// votes of other signers do not count for the outgoing producers
+	forged := newConfirmedHeader(t, 10, next.Hash(), append([]*illegalTestSigner{genesis[0]}, outsiders...))
+	_, err = VerifyProducerTransitions(cfg, []*ProducerTransition{newProducerTransition(forged, next)})
+	assert.Error(t, err)
+
+	// the confirm must sign the commitment to the incoming producers
+	other := producerSetOf(outsiders)
+	_, err = VerifyProducerTransitions(cfg, []*ProducerTransition{newProducerTransition(header, other)})
+	assert.Error(t, err)
+	tampered := types.CopyHeader(header)
+	tampered.MixDigest = other.Hash()
+	_, err = VerifyProducerTransitions(cfg, []*ProducerTransition{newProducerTransition(tampered, other)})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), errConfirmMismatch.Error())
+	}
+
+	transition := newProducerTransition(header, next)
+	transition.ChangeHeight++
+	_, err = VerifyProducerTransitions(cfg, []*ProducerTransition{transition})
+	assert.Error(t, err)
+}
+
+func TestProducerTransitionsJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "producer-transitions")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signers := newTransitionTestSigners(t, 4)
+	next := producerSetOf(signers[1:])
+	header := &types.Header{Number: big.NewInt(10), MixDigest: next.Hash()}
+
+	transitions := newProducerTransitions(dir)
+	assert.Equal(t, errMissingProducersCommitment, transitions.record(header))
+	assert.Empty(t, transitions.list(0))
+	header.Extra, err = types.EncodeSealedExtra(nil, next.commitment(), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, transitions.record(header))
+
+	reloaded := newProducerTransitions(dir)
+	records := reloaded.list(0)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, uint64(11), records[0].ChangeHeight)
+		assert.Equal(t, header.Hash(), records[0].BlockHash)
+		assert.Equal(t, next.Producers, records[0].Producers)
+	}
+	assert.Empty(t, reloaded.list(12))
+}
+
+func TestVerifyProducersCommitment(t *testing.T) {
+	config := &params.ChainConfig{ProducerTransitionBlock: big.NewInt(5)}
+	signers := newTransitionTestSigners(t, 4)
+	set := producerSetOf(signers)
+	duplicated := producerSetOf(append(signers[:3:3], signers[0]))
+
+	newHeader := func(height int64, mixDigest common.Hash, commitment *ProducerSet) *types.Header {
+		header := &types.Header{Number: big.NewInt(height), MixDigest: mixDigest}
+		if commitment != nil {
+			var err error
+			header.Extra, err = types.EncodeSealedExtra(nil, commitment.commitment(), nil)
+			assert.NoError(t, err)
+		}
+		return header
+	}
+	tests := []struct {
+		header *types.Header
+		err    error
+	}{
+		{newHeader(4, common.Hash{}, nil), nil},
+		{newHeader(4, set.Hash(), nil), errInvalidMixDigest},
+		{newHeader(4, common.Hash{}, set), errProducersCommitmentNotActive},
+		// the fork block anchors the producers to the chain
+		{newHeader(5, common.Hash{}, nil), errMissingProducersCommitment},
+		{newHeader(5, set.Hash(), set), nil},
+		{newHeader(6, common.Hash{}, nil), nil},
+		{newHeader(6, set.Hash(), nil), errMissingProducersCommitment},
+		{newHeader(6, common.Hash{}, set), errInvalidProducersCommitment},
+		{newHeader(6, common.HexToHash("0x01"), set), errInvalidProducersCommitment},
+		{newHeader(6, duplicated.Hash(), duplicated), errInvalidProducersCommitment},
+	}
+	for i, test := range tests {
+		if err := verifyProducersCommitment(config, test.header); err != test.err {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.err)
+		}
+	}
+}
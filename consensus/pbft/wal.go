@@ -0,0 +1,238 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+const walFileName = "consensus.wal"
+
+// The kinds of the WAL records.
+const (
+	walProposal uint8 = 1 // proposal signed by this producer
+	walVote     uint8 = 2 // vote signed by this producer
+)
+
+// walRecord is one entry of the consensus WAL, Data holds the proposal or
+// vote in its main chain serialization.
+type walRecord struct {
+	Kind       uint8
+	Height     uint64
+	ViewOffset uint32
+	Data       []byte
+}
+
+type walKey struct {
+	kind       uint8
+	height     uint64
+	viewOffset uint32
+}
+
+// consensusWAL is a write-ahead log of what this producer signed in the
+// heights that are not inserted yet. Records are synced to disk before the
+// signed message is broadcast and are replayed on start, so a restarted
+// producer never signs a conflicting proposal or vote for a height and view
+// it already signed in.
+type consensusWAL struct {
+	mu       sync.Mutex
+	path     string // empty keeps the log in memory only
+	file     *os.File
+	records  map[walKey]*walRecord
+	replayed bool
+}
+
+// newConsensusWAL creates the WAL inside dataDir, an empty dataDir keeps
+// the log in memory only.
+func newConsensusWAL(dataDir string) *consensusWAL {
+	wal := &consensusWAL{
+		records: make(map[walKey]*walRecord),
+	}
+	if dataDir != "" {
+		wal.path = filepath.Join(dataDir, "pbft", walFileName)
+	}
+	return wal
+}
+
+// replay loads the records on disk once, dropping those of heights up to the
+// inserted height, and opens the log for appending.
+func (w *consensusWAL) replay(height uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.replayed {
+		return nil
+	}
+	w.replayed = true
+	if w.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(w.path), 0700); err != nil {
+		return err
+	}
+	if input, err := os.Open(w.path); err == nil {
+		stream := rlp.NewStream(input, 0)
+		for {
+			record := new(walRecord)
+			if err := stream.Decode(record); err != nil {
+				if err != io.EOF {
+					// a crash may leave the last record half written
+					log.Warn("Consensus WAL is truncated", "path", w.path, "err", err)
+				}
+				break
+			}
+			if record.Height > height {
+				w.records[walKey{record.Kind, record.Height, record.ViewOffset}] = record
+			}
+		}
+		input.Close()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	log.Info("Replayed consensus WAL", "records", len(w.records), "height", height)
+	return w.rewrite()
+}
+
+// rewrite replaces the file with the records in memory and keeps it open
+// for appending.
+func (w *consensusWAL) rewrite() error {
+	if w.file != nil {
+		w.file.Close()
+		w.file = nil
+	}
+	replacement, err := os.OpenFile(w.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	for _, record := range w.records {
+		if err := rlp.Encode(replacement, record); err != nil {
+			replacement.Close()
+			return err
+		}
+	}
+	if err := replacement.Sync(); err != nil {
+		replacement.Close()
+		return err
+	}
+	replacement.Close()
+	if err := os.Rename(w.path+".new", w.path); err != nil {
+		return err
+	}
+	w.file, err = os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// write adds the record and syncs it to disk.
+func (w *consensusWAL) write(record *walRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		if err := rlp.Encode(w.file, record); err != nil {
+			return err
+		}
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+	}
+	w.records[walKey{record.Kind, record.Height, record.ViewOffset}] = record
+	return nil
+}
+
+func (w *consensusWAL) get(kind uint8, height uint64, viewOffset uint32) *walRecord {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.records[walKey{kind, height, viewOffset}]
+}
+
+// proposal returns the proposal this producer signed at height and view.
+func (w *consensusWAL) proposal(height uint64, viewOffset uint32) *payload.DPOSProposal {
+	record := w.get(walProposal, height, viewOffset)
+	if record == nil {
+		return nil
+	}
+	proposal := new(payload.DPOSProposal)
+	if err := proposal.Deserialize(bytes.NewReader(record.Data)); err != nil {
+		log.Error("Decode consensus WAL proposal failed", "height", height, "err", err)
+		return nil
+	}
+	return proposal
+}
+
+// vote returns the vote this producer signed at height and view.
+func (w *consensusWAL) vote(height uint64, viewOffset uint32) *payload.DPOSProposalVote {
+	record := w.get(walVote, height, viewOffset)
+	if record == nil {
+		return nil
+	}
+	vote := new(payload.DPOSProposalVote)
+	if err := vote.Deserialize(bytes.NewReader(record.Data)); err != nil {
+		log.Error("Decode consensus WAL vote failed", "height", height, "err", err)
+		return nil
+	}
+	return vote
+}
+
+func (w *consensusWAL) writeProposal(height uint64, proposal *payload.DPOSProposal) error {
+	buf := new(bytes.Buffer)
+	if err := proposal.Serialize(buf); err != nil {
+		return err
+	}
+	return w.write(&walRecord{
+		Kind:       walProposal,
+		Height:     height,
+		ViewOffset: proposal.ViewOffset,
+		Data:       buf.Bytes(),
+	})
+}
+
+func (w *consensusWAL) writeVote(height uint64, viewOffset uint32, vote *payload.DPOSProposalVote) error {
+	buf := new(bytes.Buffer)
+	if err := vote.Serialize(buf); err != nil {
+		return err
+	}
+	return w.write(&walRecord{
+		Kind:       walVote,
+		Height:     height,
+		ViewOffset: viewOffset,
+		Data:       buf.Bytes(),
+	})
+}
+
+// prune drops the records of heights up to the inserted height.
+func (w *consensusWAL) prune(height uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pruned := 0
+	for key := range w.records {
+		if key.height <= height {
+			delete(w.records, key)
+			pruned++
+		}
+	}
+	if pruned == 0 || w.file == nil {
+		return nil
+	}
+	return w.rewrite()
+}
+
+func (w *consensusWAL) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package pbft
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/dpos"
+)
+
+func newTestVote(t *testing.T, signer *illegalTestSigner, proposal *payload.DPOSProposal, accept bool) *payload.DPOSProposalVote {
+	vote := &payload.DPOSProposalVote{
+		ProposalHash: proposal.Hash(),
+		Signer:       signer.publicKey,
+		Accept:       accept,
+	}
+	vote.Sign = signer.sign(t, vote.Data())
+	return vote
+}
+
+func TestConsensusWALReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pbft-wal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	signer := newIllegalTestSigner(t)
+	proposal := newProposalEvidence(t, signer, 10, 1, 2).Proposal
+	vote := newTestVote(t, signer, &proposal, true)
+
+	wal := newConsensusWAL(dir)
+	assert.NoError(t, wal.replay(9))
+	assert.NoError(t, wal.writeProposal(10, &proposal))
+	assert.NoError(t, wal.writeVote(10, proposal.ViewOffset, vote))
+	assert.NoError(t, wal.close())
+
+	// a half written record left by a crash is skipped
+	file, err := os.OpenFile(filepath.Join(dir, "pbft", walFileName), os.O_WRONLY|os.O_APPEND, 0600)
+	assert.NoError(t, err)
+	file.Write([]byte{0xf8, 0x40, 0x01})
+	file.Close()
+
+	wal = newConsensusWAL(dir)
+	assert.NoError(t, wal.replay(9))
+	if replayed := wal.proposal(10, 2); assert.NotNil(t, replayed) {
+		assert.Equal(t, proposal.Hash(), replayed.Hash())
+		assert.Equal(t, proposal.Sign, replayed.Sign)
+	}
+	if replayed := wal.vote(10, 2); assert.NotNil(t, replayed) {
+		assert.Equal(t, vote.Hash(), replayed.Hash())
+	}
+	assert.Nil(t, wal.vote(10, 3))
+	assert.Nil(t, wal.vote(11, 2))
+
+	// records of inserted heights are dropped from disk as well
+	assert.NoError(t, wal.prune(10))
+	assert.Nil(t, wal.vote(10, 2))
+	assert.NoError(t, wal.close())
+
+	wal = newConsensusWAL(dir)
+	assert.NoError(t, wal.replay(0))
+	assert.Nil(t, wal.proposal(10, 2))
+	assert.NoError(t, wal.close())
+}
+
+func TestSignVoteFromWAL(t *testing.T) {
+	p := &Pbft{
+		wal: newConsensusWAL(""),
+		blockPool: dpos.NewBlockPool(nil, func(block dpos.DBlock) error {
+			return nil
+		}, DBlockSealHash),
+	}
+	assert.NoError(t, p.wal.replay(0))
+	for _, time := range []uint64{0, 1} {
+		header := &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(1), Time: time}
+		assert.NoError(t, p.blockPool.AppendDposBlock(types.NewBlockWithHeader(header)))
+	}
+
+	signer := newIllegalTestSigner(t)
+	voted := newProposalEvidence(t, signer, 10, 0, 0).Proposal
+	other := newProposalEvidence(t, signer, 10, 1, 0).Proposal
+	assert.Equal(t, uint64(10), p.proposalHeight(&voted))
+	assert.Equal(t, uint64(10), p.proposalHeight(&other))
+
+	vote := newTestVote(t, signer, &voted, true)
+	assert.NoError(t, p.wal.writeVote(10, voted.ViewOffset, vote))
+
+	voteMsg := p.signVote(&voted, true)
+	if assert.NotNil(t, voteMsg) {
+		assert.Equal(t, msg.CmdAcceptVote, voteMsg.Command)
+		assert.Equal(t, vote.Hash(), voteMsg.Vote.Hash())
+		assert.Equal(t, vote.Sign, voteMsg.Vote.Sign)
+	}
+	assert.Nil(t, p.signVote(&voted, false))
+	assert.Nil(t, p.signVote(&other, true))
+}
+
+func TestConsensusWALReplayedWithChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pbft-wal")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s := newSimulation(t, 4, 0)
+	node := s.nodes[0]
+	proposal := newProposalEvidence(t, node.account.illegalTestSigner, 1, 0, 0).Proposal
+	vote := newTestVote(t, node.account.illegalTestSigner, &proposal, true)
+	wal := newConsensusWAL(dir)
+	assert.NoError(t, wal.replay(0))
+	assert.NoError(t, wal.writeVote(1, 0, vote))
+	assert.NoError(t, wal.close())
+
+	// a restarted producer knows its votes as soon as it has a chain, before
+	// it starts the consensus
+	engine := newPbft(s.config, dir, node.account, nil, s.clock)
+	defer engine.Close()
+	engine.SetBlockChain(node.chain)
+	if replayed := engine.wal.vote(1, 0); assert.NotNil(t, replayed) {
+		assert.Equal(t, vote.Hash(), replayed.Hash())
+	}
+}
@@ -40,6 +40,9 @@ var (
 	ErrMainTxHashPresence  = errors.New("main txhash presence")
 	ErrMainTxHashCompleted = errors.New("ELAMinter: already completed")
 
+	// ErrRechargeBatchNotActive is returned if a batched recharge tx is sent before the fork
+	ErrRechargeBatchNotActive = errors.New("batched recharge is not active")
+
 	// ErrElaToEthAddress   is returned if Ethereum address is incorrect
 	ErrElaToEthAddress = errors.New("Ethereum address is incorrect ")
 
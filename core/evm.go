@@ -67,6 +67,9 @@ func NewEVMContext(msg Message, header *types.Header, chain ChainContext, author
 		GasPrice:    new(big.Int).Set(msg.GasPrice()),
 		BaseFee:     baseFee,
 		Random:      random,
+		// Whether the answers are recorded or served from the local spv
+		// depends on the vm.Config of the EVM
+		MainChainOracle: vm.NewMainChainOracle(header),
 	}
 }
 
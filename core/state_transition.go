@@ -251,6 +251,11 @@ func (st *StateTransition) TransitionDb() (result *ExecutionResult, err error) {
 	contractCreation := msg.To() == nil
 	isRefundWithdrawTx := spv.IsRefundWithdrawTx(msg.Data(), msg.To())
 	isRechargeTx := spv.IsRechargeTx(msg.Data(), msg.To())
+	isRechargeBatch := spv.IsRechargeBatchTx(msg.Data(), msg.To())
+	if isRechargeBatch && !evm.ChainConfig().IsBatchRecharge(evm.Context.BlockNumber) {
+		// before the fork a batch is an ordinary call of the ELAMinter contract
+		isRechargeTx, isRechargeBatch = false, false
+	}
 	elaHash := ""
 	//recharge tx and widthdraw refund
 	if isRechargeTx || isRefundWithdrawTx {
@@ -308,7 +313,11 @@ func (st *StateTransition) TransitionDb() (result *ExecutionResult, err error) {
 	} else {
 		// Increment the nonce for the next transaction
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
-		ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value, nil)
+		if isRechargeBatch {
+			ret, vmerr = st.applyRechargeBatch(sender)
+		} else {
+			ret, st.gas, vmerr = evm.Call(sender, st.to(), st.data, st.gas, st.value, nil)
+		}
 	}
 	if vmerr != nil {
 		if vmerr != vm.ErrCodeStoreOutOfGas {
@@ -353,6 +362,32 @@ func (st *StateTransition) TransitionDb() (result *ExecutionResult, err error) {
 	return &ExecutionResult{st.gasUsed(), vmerr, ret}, err
 }
 
+// applyRechargeBatch calls the ELAMinter contract once for every main chain tx
+// of a batched recharge. A recharge that fails, mostly because it is completed
+// already, is reverted on its own, the batch fails only if none succeeds.
+func (st *StateTransition) applyRechargeBatch(sender vm.AccountRef) (ret []byte, vmerr error) {
+	inputs, err := spv.SplitRechargeBatch(st.data)
+	if err != nil {
+		return nil, err
+	}
+	recharged := 0
+	for _, input := range inputs {
+		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), input, st.gas, new(big.Int), nil)
+		if vmerr == nil {
+			recharged++
+		} else {
+			log.Info("batched recharge returned with error", "err", vmerr, "ret", string(ret))
+		}
+		if st.gas == 0 {
+			break
+		}
+	}
+	if recharged == 0 {
+		return ret, vmerr
+	}
+	return nil, nil
+}
+
 func (st *StateTransition) dealSmallCrossTx() (isSmallCrossTx, verifyed bool, txHash string, err error) {
 	msg := st.msg
 	err = nil
@@ -224,7 +224,8 @@ type TxPool struct {
 	signer      types.Signer
 	mu          sync.RWMutex
 
-	istanbul bool // Fork indicator whether we are in the istanbul stage.
+	istanbul      bool // Fork indicator whether we are in the istanbul stage.
+	batchRecharge bool // Fork indicator whether batched recharge txs are accepted.
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
@@ -591,6 +592,9 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	//		}
 	//	}
 	//}
+	if !pool.batchRecharge && spv.IsRechargeBatchTx(tx.Data(), tx.To()) {
+		return ErrRechargeBatchNotActive
+	}
 	if crosschain.IsSystemTx(tx) {
 		if ok, _ := spv.IsCompletedByTxInput(tx.Data()); ok {
 			return spv.ErrMainTxHashCompleted
@@ -990,11 +994,14 @@ func RemoveLocalTx(pool *TxPool, hash common.Hash, outofbound bool, removetx boo
 
 func UptxhashIndex(pool *TxPool, tx *types.Transaction) bool {
 	if spv.IsRechargeTx(tx.Data(), tx.To()) {
-		completed, elaHash := spv.IsCompletedByTxInput(tx.Data())
-		if completed {
+		// a batch may be completed in part, queue the rest again
+		elaHashes := spv.GetUncompletedByTxInput(tx.Data())
+		if len(elaHashes) == 0 {
 			return false
 		}
-		spv.UpTransactionIndex(elaHash)
+		for _, elaHash := range elaHashes {
+			spv.UpTransactionIndex(elaHash)
+		}
 		return true
 	}
 	return false
@@ -1299,6 +1306,7 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+	pool.batchRecharge = pool.chainconfig.IsBatchRecharge(next)
 }
 
 // promoteExecutables moves transactions that have become processable from the
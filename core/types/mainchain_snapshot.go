@@ -0,0 +1,86 @@
+// Copyright 2014 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+// MainChainSnapshotPrefix starts the main chain snapshot at the head of the
+// extra data of a header. Neither a classic nor an aggregate confirm sealed
+// after it starts with this byte.
+const MainChainSnapshotPrefix byte = 0xfe
+
+var errInvalidMainChainSnapshot = errors.New("invalid main chain snapshot")
+
+// MainChainAnswer is the answer a main chain precompile served to the
+// question Key: a main chain transaction hash, header hash or height.
+type MainChainAnswer struct {
+	Key     common.Hash
+	Payload []byte
+}
+
+// MainChainSnapshot holds the main chain facts the producer of a block used
+// to execute it: the latest main chain height and the answers served to the
+// main chain precompiles. Validators execute the block against it instead of
+// the progress of their own spv.
+type MainChainSnapshot struct {
+	Height      uint64
+	Arbiters    []MainChainAnswer
+	Blocks      []MainChainAnswer
+	Recharges   []MainChainAnswer
+	PledgeBills []MainChainAnswer
+	Headers     []MainChainAnswer
+}
+
+// Hash returns the hash the seal of a header commits to the snapshot with.
+func (s *MainChainSnapshot) Hash() common.Hash {
+	return rlpHash(s)
+}
+
+// EncodeExtra returns the extra data of a header holding the snapshot,
+// followed by rest.
+func (s *MainChainSnapshot) EncodeExtra(rest []byte) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(s)
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]byte, 0, 1+len(enc)+len(rest))
+	extra = append(extra, MainChainSnapshotPrefix)
+	extra = append(extra, enc...)
+	return append(extra, rest...), nil
+}
+
+// SplitMainChainSnapshot splits the extra data of a header into its main
+// chain snapshot, nil if it has none, and the data following it.
+func SplitMainChainSnapshot(extra []byte) (*MainChainSnapshot, []byte, error) {
+	if len(extra) == 0 || extra[0] != MainChainSnapshotPrefix {
+		return nil, extra, nil
+	}
+	_, rest, err := rlp.SplitList(extra[1:])
+	if err != nil {
+		return nil, nil, errInvalidMainChainSnapshot
+	}
+	snapshot := new(MainChainSnapshot)
+	if err := rlp.DecodeBytes(extra[1:len(extra)-len(rest)], snapshot); err != nil {
+		return nil, nil, errInvalidMainChainSnapshot
+	}
+	return snapshot, rest, nil
+}
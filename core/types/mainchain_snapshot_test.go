@@ -0,0 +1,64 @@
+// Copyright 2014 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/common"
+)
+
+func TestMainChainSnapshotExtra(t *testing.T) {
+	snapshot := &MainChainSnapshot{
+		Height:      1024,
+		Arbiters:    []MainChainAnswer{{Key: common.BigToHash(big.NewInt(1000)), Payload: []byte{0x02, 0xaa}}},
+		Blocks:      []MainChainAnswer{{Key: common.BigToHash(big.NewInt(1023)), Payload: []byte{0x03}}},
+		Recharges:   []MainChainAnswer{{Key: common.HexToHash("0x01"), Payload: []byte{0x04}}},
+		PledgeBills: []MainChainAnswer{{Key: common.HexToHash("0x03"), Payload: []byte{0x05}}},
+		Headers:     []MainChainAnswer{{Key: common.HexToHash("0x04"), Payload: []byte{}}},
+	}
+	confirm := []byte{0x21, 0x02, 0x03}
+	extra, err := snapshot.EncodeExtra(confirm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, rest, err := SplitMainChainSnapshot(extra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, snapshot) {
+		t.Errorf("snapshot mismatch: got %+v, want %+v", decoded, snapshot)
+	}
+	if !bytes.Equal(rest, confirm) {
+		t.Errorf("rest mismatch: got %x, want %x", rest, confirm)
+	}
+	if decoded.Hash() != snapshot.Hash() {
+		t.Error("hash of the decoded snapshot differs")
+	}
+
+	// Extra data without a snapshot is returned as is
+	decoded, rest, err = SplitMainChainSnapshot(confirm)
+	if err != nil || decoded != nil || !bytes.Equal(rest, confirm) {
+		t.Errorf("split without snapshot: got %v %x %v", decoded, rest, err)
+	}
+	if _, _, err := SplitMainChainSnapshot(extra[:len(extra)-len(confirm)-1]); err == nil {
+		t.Error("truncated snapshot accepted")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2014 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"errors"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+// ProducerCommitmentPrefix starts the producer commitment in the extra data
+// of a header, after the main chain snapshot if there is one. Neither a
+// classic nor an aggregate confirm sealed after it starts with this byte.
+const ProducerCommitmentPrefix byte = 0xfd
+
+var errInvalidProducerCommitment = errors.New("invalid producer commitment")
+
+// ProducerCommitment is the producer set in force after the header carrying
+// it, which commits to it with its mix digest. Empty producers are the seats
+// of the main chain arbiters left vacant.
+type ProducerCommitment struct {
+	TotalCount uint64
+	Producers  [][]byte
+}
+
+// Hash returns the hash the mix digest of a header commits to the producers
+// with.
+func (c *ProducerCommitment) Hash() common.Hash {
+	return rlpHash(c)
+}
+
+// EncodeExtra returns the extra data of a header holding the commitment,
+// followed by rest.
+func (c *ProducerCommitment) EncodeExtra(rest []byte) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes(c)
+	if err != nil {
+		return nil, err
+	}
+	extra := make([]byte, 0, 1+len(enc)+len(rest))
+	extra = append(extra, ProducerCommitmentPrefix)
+	extra = append(extra, enc...)
+	return append(extra, rest...), nil
+}
+
+// SplitProducerCommitment splits the extra data following the main chain
+// snapshot of a header into its producer commitment, nil if it has none, and
+// the data following it.
+func SplitProducerCommitment(extra []byte) (*ProducerCommitment, []byte, error) {
+	if len(extra) == 0 || extra[0] != ProducerCommitmentPrefix {
+		return nil, extra, nil
+	}
+	_, rest, err := rlp.SplitList(extra[1:])
+	if err != nil {
+		return nil, nil, errInvalidProducerCommitment
+	}
+	commitment := new(ProducerCommitment)
+	if err := rlp.DecodeBytes(extra[1:len(extra)-len(rest)], commitment); err != nil {
+		return nil, nil, errInvalidProducerCommitment
+	}
+	return commitment, rest, nil
+}
+
+// SplitSealedExtra splits the extra data of a header into its main chain
+// snapshot and producer commitment, nil for the ones it has not, and the
+// confirm sealed after them.
+func SplitSealedExtra(extra []byte) (*MainChainSnapshot, *ProducerCommitment, []byte, error) {
+	snapshot, rest, err := SplitMainChainSnapshot(extra)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	commitment, rest, err := SplitProducerCommitment(rest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return snapshot, commitment, rest, nil
+}
+
+// EncodeSealedExtra returns the extra data of a header holding snapshot and
+// commitment when they are not nil, followed by confirm.
+func EncodeSealedExtra(snapshot *MainChainSnapshot, commitment *ProducerCommitment, confirm []byte) ([]byte, error) {
+	extra := append([]byte{}, confirm...)
+	var err error
+	if commitment != nil {
+		if extra, err = commitment.EncodeExtra(extra); err != nil {
+			return nil, err
+		}
+	}
+	if snapshot != nil {
+		return snapshot.EncodeExtra(extra)
+	}
+	return extra, nil
+}
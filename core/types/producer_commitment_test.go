@@ -0,0 +1,58 @@
+// Copyright 2014 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSealedExtra(t *testing.T) {
+	snapshot := &MainChainSnapshot{Height: 1024, Recharges: []MainChainAnswer{{Payload: []byte{0x02, 0xaa}}}}
+	commitment := &ProducerCommitment{TotalCount: 3, Producers: [][]byte{{0x02, 0xaa}, {}, {0x03, 0xbb}}}
+	confirm := []byte{0x21, 0x02, 0x03}
+
+	for _, test := range []struct {
+		snapshot   *MainChainSnapshot
+		commitment *ProducerCommitment
+	}{
+		{nil, nil}, {snapshot, nil}, {nil, commitment}, {snapshot, commitment},
+	} {
+		extra, err := EncodeSealedExtra(test.snapshot, test.commitment, confirm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, c, rest, err := SplitSealedExtra(extra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (s == nil) != (test.snapshot == nil) || s != nil && s.Hash() != test.snapshot.Hash() {
+			t.Errorf("snapshot mismatch: got %+v, want %+v", s, test.snapshot)
+		}
+		if !reflect.DeepEqual(c, test.commitment) {
+			t.Errorf("commitment mismatch: got %+v, want %+v", c, test.commitment)
+		}
+		if !bytes.Equal(rest, confirm) {
+			t.Errorf("rest mismatch: got %x, want %x", rest, confirm)
+		}
+	}
+	extra, _ := commitment.EncodeExtra(confirm)
+	if _, _, err := SplitProducerCommitment(extra[:len(extra)-len(confirm)-1]); err == nil {
+		t.Error("truncated commitment accepted")
+	}
+}
@@ -38,6 +38,8 @@ import (
 	"github.com/pgprotocol/pgp-chain/spv"
 	"github.com/pgprotocol/pgp-chain/withdrawfailedtx"
 
+	"github.com/elastos/Elastos.ELA.SPV/util"
+	elacommon "github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/contract"
 	"github.com/elastos/Elastos.ELA/core/contract/program"
 	elatx "github.com/elastos/Elastos.ELA/core/transaction"
@@ -170,12 +172,40 @@ var PrecompiledContractsShangHai = map[common.Address]PrecompiledContract{
 	common.BytesToAddress(params.VerifySmallCrossTx.Bytes()):        &verifySmallCrossTx{},
 }
 
+// PrecompiledContractsMainChainProof contains the set of pre-compiled contracts
+// of Shanghai and the main chain proof fork, it is only active once both are.
+var PrecompiledContractsMainChainProof = map[common.Address]PrecompiledContract{
+	common.BytesToAddress([]byte{1}):                                &ecrecover{},
+	common.BytesToAddress([]byte{2}):                                &sha256hash{},
+	common.BytesToAddress([]byte{3}):                                &ripemd160hash{},
+	common.BytesToAddress([]byte{4}):                                &dataCopy{},
+	common.BytesToAddress([]byte{5}):                                &bigModExp{eip2565: true},
+	common.BytesToAddress([]byte{6}):                                &bn256AddIstanbul{},
+	common.BytesToAddress([]byte{7}):                                &bn256ScalarMulIstanbul{},
+	common.BytesToAddress([]byte{8}):                                &bn256PairingIstanbul{},
+	common.BytesToAddress([]byte{9}):                                &blake2F{},
+	common.BytesToAddress(params.ArbiterAddress.Bytes()):            &arbiters{},
+	common.BytesToAddress(params.P256VerifyAddress.Bytes()):         &p256Verify{},
+	common.BytesToAddress(params.SignatureVerifyByPbk.Bytes()):      &pbkVerifySignature{},
+	common.BytesToAddress(params.PledgeBillVerify.Bytes()):          &pledgeBillVerify{},
+	common.BytesToAddress(params.PledgeBillTokenID.Bytes()):         &pledgeBillTokenID{},
+	common.BytesToAddress(params.PledgeBillTokenDetail.Bytes()):     &pledgeBillTokenDetail{},
+	common.BytesToAddress(params.PledgeBillTokenVersion.Bytes()):    &pledgeBillPayloadVersion{},
+	common.BytesToAddress(params.GetMainChainBlockByHeight.Bytes()): &getMainChainBlockByHeight{},
+	common.BytesToAddress(params.GetMainChainLatestHeight.Bytes()):  &getMainChainLatestHeight{},
+	common.BytesToAddress(params.GetMainChainRechargeData.Bytes()):  &getMainChainRechargeData{},
+	common.BytesToAddress(params.GetWithdrawData.Bytes()):           &getWithdrawData{},
+	common.BytesToAddress(params.VerifySmallCrossTx.Bytes()):        &verifySmallCrossTx{},
+	common.BytesToAddress(params.VerifyMainChainTxProof.Bytes()):    &verifyMainChainTxProof{},
+}
+
 var (
-	PrecompiledAddressesShangHai  []common.Address
-	PrecompiledAddressesBerlin    []common.Address
-	PrecompiledAddressesIstanbul  []common.Address
-	PrecompiledAddressesByzantium []common.Address
-	PrecompiledAddressesHomestead []common.Address
+	PrecompiledAddressesMainChainProof []common.Address
+	PrecompiledAddressesShangHai       []common.Address
+	PrecompiledAddressesBerlin         []common.Address
+	PrecompiledAddressesIstanbul       []common.Address
+	PrecompiledAddressesByzantium      []common.Address
+	PrecompiledAddressesHomestead      []common.Address
 )
 
 func init() {
@@ -194,11 +224,16 @@ func init() {
 	for k := range PrecompiledContractsShangHai {
 		PrecompiledAddressesShangHai = append(PrecompiledAddressesShangHai, k)
 	}
+	for k := range PrecompiledContractsMainChainProof {
+		PrecompiledAddressesMainChainProof = append(PrecompiledAddressesMainChainProof, k)
+	}
 }
 
 // ActivePrecompiles returns the precompiles enabled with the current configuration.
 func ActivePrecompiles(rules params.Rules) []common.Address {
 	switch {
+	case rules.IsShanghai && rules.IsMainChainProof:
+		return PrecompiledAddressesMainChainProof
 	case rules.IsShanghai:
 		return PrecompiledAddressesShangHai
 	case rules.IsBerlin:
@@ -701,13 +736,20 @@ func (c *arbiters) Run(input []byte) ([]byte, error) {
 	if err != nil {
 		return nil, errGettingArbitersFailed
 	}
+	return packArbiters(arbiters), nil
+}
+
+func (c *arbiters) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	return oracle.Arbiters()
+}
+
+func packArbiters(arbiters []string) []byte {
 	ret := make([]byte, 0)
 	for _, pubkey := range arbiters {
 		hash := crypto.Keccak256(common.Hex2Bytes(pubkey))
 		ret = append(ret, common.LeftPadBytes(hash[:], 32)...)
 	}
-
-	return ret, nil
+	return ret
 }
 
 const (
@@ -912,6 +954,10 @@ func (p *pledgeBillTokenDetail) RequiredGas(input []byte) uint64 {
 	return params.GetPledgeBillTokenDetail
 }
 
+func (p *pledgeBillTokenDetail) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	return oracle.PledgeBillDetail(common.BytesToHash(getData(input, 32, 32)))
+}
+
 func (p *pledgeBillTokenDetail) Run(input []byte) ([]byte, error) {
 	//length := getData(input, 0, 32)
 	elaHash := getData(input, 32, 32)
@@ -1418,35 +1464,25 @@ func (c *getMainChainBlockByHeight) RequiredGas(input []byte) uint64 {
 func (c *getMainChainBlockByHeight) Run(input []byte) ([]byte, error) {
 	data := getData(input, 32, 32)
 	height := big.NewInt(0).SetBytes(data)
+	return mainChainBlockByHeight(height)
+}
+
+func (c *getMainChainBlockByHeight) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	data := getData(input, 32, 32)
+	height := big.NewInt(0).SetBytes(data)
+	if !height.IsUint64() {
+		return []byte{}, errMainChainHeightNotCommitted
+	}
+	return oracle.MainChainBlock(height.Uint64())
+}
 
+func mainChainBlockByHeight(height *big.Int) ([]byte, error) {
 	header, err := spv.SpvService.GetELAHeader(uint32(height.Uint64()))
 	if err != nil {
 		log.Error("getMainChainBlockByHeight failed", "error", err, " height", height)
 		return []byte{}, err
 	}
-
-	arguments := make([]abi.Argument, 0)
-	Bytes32, _ := abi.NewType("bytes32", "bytes32", nil)
-	UInt32, _ := abi.NewType("uint32", "uint32", nil)
-	// Bytes, _ := abi.NewType("bytes", "bytes", nil)
-
-	Previous := abi.Argument{Name: "Previous", Type: Bytes32}
-	arguments = append(arguments, Previous)
-
-	Bits := abi.Argument{Name: "Bits", Type: UInt32}
-	arguments = append(arguments, Bits)
-
-	MerkleRoot := abi.Argument{Name: "MerkleRoot", Type: Bytes32}
-	arguments = append(arguments, MerkleRoot)
-
-	Hash := abi.Argument{Name: "Hash", Type: Bytes32}
-	arguments = append(arguments, Hash)
-
-	Height := abi.Argument{Name: "Height", Type: UInt32}
-	arguments = append(arguments, Height)
-
-	m := abi.Method{Inputs: arguments}
-	ret, err := m.Inputs.Pack(header.Previous(), header.Bits(), header.MerkleRoot(), header.Hash(), header.Height)
+	ret, err := packMainChainHeader(header)
 	if err != nil {
 		log.Error("getMainChainBlockByHeight failed ", "error ", err)
 		return ret, err
@@ -1454,6 +1490,20 @@ func (c *getMainChainBlockByHeight) Run(input []byte) ([]byte, error) {
 	return ret, nil
 }
 
+// mainChainHeaderOutput is abi.encode(bytes32 previous, uint32 bits,
+// bytes32 merkleRoot, bytes32 hash, uint32 height)
+var mainChainHeaderOutput = abi.Arguments{
+	{Name: "Previous", Type: mustNewType("bytes32")},
+	{Name: "Bits", Type: mustNewType("uint32")},
+	{Name: "MerkleRoot", Type: mustNewType("bytes32")},
+	{Name: "Hash", Type: mustNewType("bytes32")},
+	{Name: "Height", Type: mustNewType("uint32")},
+}
+
+func packMainChainHeader(header *util.Header) ([]byte, error) {
+	return mainChainHeaderOutput.Pack(header.Previous(), header.Bits(), header.MerkleRoot(), header.Hash(), header.Height)
+}
+
 type getMainChainLatestHeight struct{}
 
 func (h *getMainChainLatestHeight) RequiredGas(input []byte) uint64 {
@@ -1461,11 +1511,23 @@ func (h *getMainChainLatestHeight) RequiredGas(input []byte) uint64 {
 }
 
 func (h *getMainChainLatestHeight) Run(input []byte) ([]byte, error) {
-	head, err := spv.SpvService.HeaderStore().GetBest()
+	head, err := spv.SpvService.BestHeader()
 	if err != nil {
 		log.Error("getMainChainLatestHeight failed", "error", err)
 		return []byte{}, err
 	}
+	return packMainChainHeight(head.Height)
+}
+
+func (h *getMainChainLatestHeight) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	height, err := oracle.LatestHeight()
+	if err != nil {
+		return []byte{}, err
+	}
+	return packMainChainHeight(uint32(height))
+}
+
+func packMainChainHeight(height uint32) ([]byte, error) {
 	UInt32, _ := abi.NewType("uint32", "uint32", nil)
 	arguments := make([]abi.Argument, 0)
 
@@ -1473,7 +1535,7 @@ func (h *getMainChainLatestHeight) Run(input []byte) ([]byte, error) {
 	arguments = append(arguments, Height)
 
 	m := abi.Method{Inputs: arguments}
-	ret, err := m.Inputs.Pack(head.Height)
+	ret, err := m.Inputs.Pack(height)
 	if err != nil {
 		log.Error("getMainChainLatestHeight pack failed ", "error ", err)
 		return ret, err
@@ -1487,6 +1549,10 @@ func (c *getMainChainRechargeData) RequiredGas(input []byte) uint64 {
 	return 0
 }
 
+func (c *getMainChainRechargeData) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	return oracle.RechargeData(common.BytesToHash(getData(input, 0, 32)))
+}
+
 func (c *getMainChainRechargeData) Run(input []byte) ([]byte, error) {
 	elaHash := getData(input, 0, 32)
 	hash := common.BytesToHash(elaHash).String()
@@ -1662,3 +1728,96 @@ func (c *verifySmallCrossTx) Run(input []byte) ([]byte, error) {
 	spv.NotifySmallCrossTx(txn)
 	return true32Byte, nil
 }
+
+var (
+	// verifyMainChainTxProofInput is abi.encode(bytes tx, bytes proof, bytes32 headerHash)
+	verifyMainChainTxProofInput = abi.Arguments{
+		{Name: "tx", Type: mustNewType("bytes")},
+		{Name: "proof", Type: mustNewType("bytes")},
+		{Name: "headerHash", Type: mustNewType("bytes32")},
+	}
+	// verifyMainChainTxProofOutput is abi.encode(bool valid, bytes32 txHash, uint32 height)
+	verifyMainChainTxProofOutput = abi.Arguments{
+		{Name: "valid", Type: mustNewType("bool")},
+		{Name: "txHash", Type: mustNewType("bytes32")},
+		{Name: "height", Type: mustNewType("uint32")},
+	}
+)
+
+// verifyMainChainTxProof verifies that a serialized main chain transaction is
+// packed in a main chain block by its serialized merkle proof. The header hash
+// is in the byte order returned by getMainChainBlockByHeight and must be of a
+// header accepted by the spv service, the returned tx hash is in the byte
+// order taken by getMainChainRechargeData. A proof which does not verify
+// returns valid false, undecodable input fails the call. After the main chain
+// oracle fork the header is the one committed to by the block, and must not
+// be above its main chain height.
+type verifyMainChainTxProof struct{}
+
+// RequiredGas charges a base cost plus the hashing of the transaction and of
+// every node of the proof. Undecodable input is charged as a proof, its call
+// fails anyway.
+func (c *verifyMainChainTxProof) RequiredGas(input []byte) uint64 {
+	values, err := verifyMainChainTxProofInput.Unpack(input)
+	if err != nil {
+		return params.VerifyMainChainTxProofGas + toWordSize(uint64(len(input)))*params.VerifyMainChainTxProofNodeGas
+	}
+	txWords := toWordSize(uint64(len(values[0].([]byte))))
+	proofWords := toWordSize(uint64(len(values[1].([]byte))))
+	return params.VerifyMainChainTxProofGas + txWords*params.VerifyMainChainTxProofWordGas +
+		proofWords*params.VerifyMainChainTxProofNodeGas
+}
+
+func (c *verifyMainChainTxProof) Run(input []byte) ([]byte, error) {
+	values, err := verifyMainChainTxProofInput.Unpack(input)
+	if err != nil {
+		return nil, err
+	}
+	headerHash := elacommon.Uint256(values[2].([32]byte))
+	tx, proof, err := spv.DecodeMainChainProof(values[0].([]byte), values[1].([]byte))
+	if err != nil {
+		log.Warn("verifyMainChainTxProof decode failed", "error", err)
+		return nil, err
+	}
+	if spv.SpvService == nil {
+		return nil, spv.ErrSpvNotStarted
+	}
+	txHash := tx.Hash()
+	valid := true
+	if err := spv.SpvService.VerifyMainChainTx(headerHash, proof, tx); err != nil {
+		log.Warn("verifyMainChainTxProof failed", "tx", txHash.String(), "error", err)
+		valid = false
+	}
+	return verifyMainChainTxProofOutput.Pack(valid, common.HexToHash(txHash.String()), proof.Height)
+}
+
+func (c *verifyMainChainTxProof) RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error) {
+	values, err := verifyMainChainTxProofInput.Unpack(input)
+	if err != nil {
+		return nil, err
+	}
+	headerHash := elacommon.Uint256(values[2].([32]byte))
+	tx, proof, err := spv.DecodeMainChainProof(values[0].([]byte), values[1].([]byte))
+	if err != nil {
+		log.Warn("verifyMainChainTxProof decode failed", "error", err)
+		return nil, err
+	}
+	txHash := tx.Hash()
+	valid := proof.BlockHash == headerHash
+	if valid {
+		header, err := oracle.MainChainHeader(common.Hash(headerHash))
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			err = errMainChainHeaderUnknown
+		} else {
+			err = spv.VerifyMainChainTxInBlock(header.MerkleRoot, proof, tx)
+		}
+		if err != nil {
+			log.Warn("verifyMainChainTxProof failed", "tx", txHash.String(), "error", err)
+			valid = false
+		}
+	}
+	return verifyMainChainTxProofOutput.Pack(valid, common.HexToHash(txHash.String()), proof.Height)
+}
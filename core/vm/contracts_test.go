@@ -20,13 +20,23 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	elatx "github.com/elastos/Elastos.ELA/core/transaction"
+	elacom "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
 	elaCrypto "github.com/elastos/Elastos.ELA/crypto"
 	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
 	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/spv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -466,3 +476,167 @@ func merge(first, second []byte) []byte {
 	}
 	return merged
 }
+
+func newMainChainTx(nonce uint32) interfaces.Transaction {
+	return elatx.CreateTransaction(
+		elacom.TxVersion09,
+		elacom.TransferCrossChainAsset,
+		0,
+		&payload.TransferCrossChainAsset{},
+		[]*elacom.Attribute{},
+		[]*elacom.Input{},
+		[]*elacom.Output{},
+		nonce,
+		[]*program.Program{},
+	)
+}
+
+func TestVerifyMainChainTxProof(t *testing.T) {
+	source := spv.NewMockSource()
+	defer func(service *spv.Service) { spv.SpvService = service }(spv.SpvService)
+	spv.SpvService = &spv.Service{MainChainSource: source}
+
+	deposit, other := newMainChainTx(1), newMainChainTx(2)
+	header, err := source.AddBlock(other, deposit)
+	assert.NoError(t, err)
+	proof, err := source.Proof(deposit.Hash())
+	assert.NoError(t, err)
+
+	rawTx, rawProof := new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(t, deposit.Serialize(rawTx))
+	assert.NoError(t, proof.Serialize(rawProof))
+	run := func(tx, proof []byte, headerHash [32]byte) (bool, error) {
+		input, err := verifyMainChainTxProofInput.Pack(tx, proof, headerHash)
+		assert.NoError(t, err)
+		ret, err := new(verifyMainChainTxProof).Run(input)
+		if err != nil {
+			return false, err
+		}
+		values, err := verifyMainChainTxProofOutput.Unpack(ret)
+		assert.NoError(t, err)
+		if values[0].(bool) {
+			assert.Equal(t, common.HexToHash(deposit.Hash().String()), common.Hash(values[1].([32]byte)))
+			assert.Equal(t, header.Height, values[2].(uint32))
+		}
+		return values[0].(bool), nil
+	}
+
+	valid, err := run(rawTx.Bytes(), rawProof.Bytes(), header.Hash())
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// the proof is not of the header
+	genesis, err := source.HeaderByHeight(0)
+	assert.NoError(t, err)
+	valid, err = run(rawTx.Bytes(), rawProof.Bytes(), genesis.Hash())
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// the tx is not packed in the block
+	rawOther := new(bytes.Buffer)
+	assert.NoError(t, newMainChainTx(3).Serialize(rawOther))
+	valid, err = run(rawOther.Bytes(), rawProof.Bytes(), header.Hash())
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	// the header is unknown to the spv service once rolled back
+	source.Rollback(0)
+	valid, err = run(rawTx.Bytes(), rawProof.Bytes(), header.Hash())
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, err = run([]byte{0x01}, rawProof.Bytes(), header.Hash())
+	assert.Error(t, err)
+}
+
+func TestVerifyMainChainTxProofOracle(t *testing.T) {
+	source := spv.NewMockSource()
+	defer func(service *spv.Service) { spv.SpvService = service }(spv.SpvService)
+	spv.SpvService = &spv.Service{MainChainSource: source}
+
+	deposit := newMainChainTx(1)
+	header, err := source.AddBlock(newMainChainTx(2), deposit)
+	assert.NoError(t, err)
+	proof, err := source.Proof(deposit.Hash())
+	assert.NoError(t, err)
+	rawTx, rawProof := new(bytes.Buffer), new(bytes.Buffer)
+	assert.NoError(t, deposit.Serialize(rawTx))
+	assert.NoError(t, proof.Serialize(rawProof))
+	input, err := verifyMainChainTxProofInput.Pack(rawTx.Bytes(), rawProof.Bytes(), [32]byte(header.Hash()))
+	assert.NoError(t, err)
+
+	config := *params.TestChainConfig
+	config.MainChainOracleBlock = big.NewInt(1)
+	run := func(block *types.Header, mode MainChainOracleMode) (bool, error) {
+		ctx := Context{BlockNumber: big.NewInt(1), MainChainOracle: NewMainChainOracle(block)}
+		evm := NewEVM(ctx, nil, &config, Config{MainChainOracle: mode})
+		ret, _, err := evm.runPrecompiledContract(new(verifyMainChainTxProof), input, math.MaxUint64)
+		if err != nil {
+			return false, err
+		}
+		values, err := verifyMainChainTxProofOutput.Unpack(ret)
+		assert.NoError(t, err)
+		return values[0].(bool), nil
+	}
+
+	// the producer commits the header, validators verify without the spv
+	block := newOracleTestHeader(t, uint64(header.Height), nil)
+	valid, err := run(block, MainChainRecord)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NoError(t, VerifyMainChainAnswers(block))
+	spv.SpvService = nil
+	valid, err = run(block, MainChainVerify)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	_, err = run(newOracleTestHeader(t, uint64(header.Height), nil), MainChainVerify)
+	assert.Equal(t, errMainChainPayloadNotCommitted, err)
+	spv.SpvService = &spv.Service{MainChainSource: source}
+
+	// the header must not be above the committed height
+	_, err = run(newOracleTestHeader(t, uint64(header.Height)-1, nil), MainChainRecord)
+	assert.Equal(t, errMainChainHeightNotCommitted, err)
+
+	// a header the spv did not accept is committed as such
+	source.Rollback(0)
+	assert.Equal(t, errMainChainPayloadMismatch, VerifyMainChainAnswers(block))
+	unknown := newOracleTestHeader(t, uint64(header.Height), nil)
+	valid, err = run(unknown, MainChainRecord)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+	assert.NoError(t, VerifyMainChainAnswers(unknown))
+	valid, err = run(unknown, MainChainVerify)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestVerifyMainChainTxProofGas(t *testing.T) {
+	gas := func(tx, proof []byte) uint64 {
+		input, err := verifyMainChainTxProofInput.Pack(tx, proof, [32]byte{})
+		assert.NoError(t, err)
+		return new(verifyMainChainTxProof).RequiredGas(input)
+	}
+	assert.Equal(t, params.VerifyMainChainTxProofGas, gas(nil, nil))
+	assert.Equal(t, params.VerifyMainChainTxProofGas+2*params.VerifyMainChainTxProofWordGas+
+		3*params.VerifyMainChainTxProofNodeGas, gas(make([]byte, 40), make([]byte, 96)))
+	// a larger proof costs more
+	assert.Less(t, gas(nil, make([]byte, 32)), gas(nil, make([]byte, 32*20)))
+}
+
+func TestActivePrecompilesMainChainProof(t *testing.T) {
+	proof := common.BytesToAddress(params.VerifyMainChainTxProof.Bytes())
+	active := func(rules params.Rules) bool {
+		for _, addr := range ActivePrecompiles(rules) {
+			if addr == proof {
+				return true
+			}
+		}
+		return false
+	}
+	// the main chain proof fork extends Shanghai and waits for it
+	assert.False(t, active(params.Rules{IsBerlin: true, IsMainChainProof: true}))
+	assert.Equal(t, len(PrecompiledAddressesBerlin), len(ActivePrecompiles(params.Rules{IsBerlin: true, IsMainChainProof: true})))
+	assert.False(t, active(params.Rules{IsBerlin: true, IsShanghai: true}))
+	assert.True(t, active(params.Rules{IsBerlin: true, IsShanghai: true, IsMainChainProof: true}))
+	assert.Equal(t, len(PrecompiledAddressesShangHai)+1, len(PrecompiledAddressesMainChainProof))
+}
@@ -0,0 +1,298 @@
+// Copyright 2014 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/spv"
+
+	elacommon "github.com/elastos/Elastos.ELA/common"
+)
+
+var (
+	errMainChainSnapshotMissing     = errors.New("main chain snapshot missing")
+	errMainChainHeightNotCommitted  = errors.New("main chain height is above the committed snapshot")
+	errMainChainPayloadNotCommitted = errors.New("main chain payload is not committed")
+	errMainChainPayloadMismatch     = errors.New("main chain payload differs from the committed snapshot")
+	errMainChainHeaderUnknown       = errors.New("main chain header is not accepted by the spv of the producer")
+)
+
+// MainChainOracleMode selects what the main chain precompiles answer from
+// after the main chain oracle fork.
+type MainChainOracleMode int
+
+const (
+	// MainChainVerify serves the answers committed to by the block only, so
+	// executing a block does not depend on the local spv.
+	MainChainVerify MainChainOracleMode = iota
+
+	// MainChainRecord serves the local spv and commits its answers into the
+	// block, for the producer of the block.
+	MainChainRecord
+
+	// MainChainLocal serves the local spv without committing, for the calls
+	// outside of blocks like eth_call and gas estimation.
+	MainChainLocal
+)
+
+// mainChainOracleContract is implemented by the precompiles answering from
+// the main chain. After the main chain oracle fork they are run with the
+// oracle of the block instead of the local spv state.
+type mainChainOracleContract interface {
+	RunOracle(oracle *MainChainOracle, input []byte) ([]byte, error)
+}
+
+// mainChainQuestion is a kind of question the main chain precompiles ask,
+// with the answers of a snapshot to it and the way to ask the local spv.
+type mainChainQuestion struct {
+	answers func(*types.MainChainSnapshot) *[]types.MainChainAnswer
+	ask     func(key common.Hash) ([]byte, error)
+}
+
+var (
+	arbitersQuestion = &mainChainQuestion{
+		answers: func(s *types.MainChainSnapshot) *[]types.MainChainAnswer { return &s.Arbiters },
+		ask: func(key common.Hash) ([]byte, error) {
+			arbiters, _, err := spv.GetArbitersAt(key.Big().Uint64())
+			if err != nil {
+				return nil, errGettingArbitersFailed
+			}
+			return packArbiters(arbiters), nil
+		},
+	}
+	mainChainBlockQuestion = &mainChainQuestion{
+		answers: func(s *types.MainChainSnapshot) *[]types.MainChainAnswer { return &s.Blocks },
+		ask: func(key common.Hash) ([]byte, error) {
+			return mainChainBlockByHeight(key.Big())
+		},
+	}
+	rechargeQuestion = &mainChainQuestion{
+		answers: func(s *types.MainChainSnapshot) *[]types.MainChainAnswer { return &s.Recharges },
+		ask: func(key common.Hash) ([]byte, error) {
+			return (&getMainChainRechargeData{}).Run(key[:])
+		},
+	}
+	pledgeBillQuestion = &mainChainQuestion{
+		answers: func(s *types.MainChainSnapshot) *[]types.MainChainAnswer { return &s.PledgeBills },
+		ask: func(key common.Hash) ([]byte, error) {
+			return (&pledgeBillTokenDetail{}).Run(append(make([]byte, common.HashLength), key[:]...))
+		},
+	}
+
+	// mainChainHeaderQuestion asks for the main chain header of a hash, the
+	// headers the spv did not accept are answered with an empty payload so
+	// validators can check that too.
+	mainChainHeaderQuestion = &mainChainQuestion{
+		answers: func(s *types.MainChainSnapshot) *[]types.MainChainAnswer { return &s.Headers },
+		ask: func(key common.Hash) ([]byte, error) {
+			if spv.SpvService == nil {
+				return nil, spv.ErrSpvNotStarted
+			}
+			hash := elacommon.Uint256(key)
+			header, err := spv.SpvService.HeaderByHash(&hash)
+			if err != nil {
+				return []byte{}, nil
+			}
+			return packMainChainHeader(header)
+		},
+	}
+
+	mainChainQuestions = []*mainChainQuestion{arbitersQuestion, mainChainBlockQuestion, rechargeQuestion, pledgeBillQuestion, mainChainHeaderQuestion}
+)
+
+// MainChainOracle serves the main chain data of a block from the main chain
+// snapshot in its extra data. The producer of the block records the answers
+// it serves into the snapshot, validators only serve answers committed to by
+// it.
+type MainChainOracle struct {
+	header *types.Header
+	record bool
+}
+
+// NewMainChainOracle creates the oracle of header.
+func NewMainChainOracle(header *types.Header) *MainChainOracle {
+	return &MainChainOracle{header: header}
+}
+
+// recording returns the oracle of the same block, recording the answers of
+// the local spv it serves if record is set.
+func (o *MainChainOracle) recording(record bool) *MainChainOracle {
+	if o == nil {
+		return nil
+	}
+	return &MainChainOracle{header: o.header, record: record}
+}
+
+func (o *MainChainOracle) snapshot() (*types.MainChainSnapshot, []byte, error) {
+	if o == nil {
+		return nil, nil, errMainChainSnapshotMissing
+	}
+	snapshot, rest, err := types.SplitMainChainSnapshot(o.header.Extra)
+	if err != nil {
+		return nil, nil, err
+	}
+	if snapshot == nil {
+		return nil, nil, errMainChainSnapshotMissing
+	}
+	return snapshot, rest, nil
+}
+
+// LatestHeight returns the latest main chain height committed to.
+func (o *MainChainOracle) LatestHeight() (uint64, error) {
+	snapshot, _, err := o.snapshot()
+	if err != nil {
+		return 0, err
+	}
+	return snapshot.Height, nil
+}
+
+// CheckHeight returns an error if the main chain height is above the
+// committed one.
+func (o *MainChainOracle) CheckHeight(height uint64) error {
+	latest, err := o.LatestHeight()
+	if err != nil {
+		return err
+	}
+	if height > latest {
+		return errMainChainHeightNotCommitted
+	}
+	return nil
+}
+
+// Arbiters returns the packed arbiters at the main chain height of the block.
+func (o *MainChainOracle) Arbiters() ([]byte, error) {
+	return o.answer(arbitersQuestion, common.BigToHash(new(big.Int).SetUint64(o.header.Nonce.Uint64())))
+}
+
+// MainChainBlock returns the packed main chain header at height, which must
+// not be above the committed height.
+func (o *MainChainOracle) MainChainBlock(height uint64) ([]byte, error) {
+	if err := o.CheckHeight(height); err != nil {
+		return nil, err
+	}
+	return o.answer(mainChainBlockQuestion, common.BigToHash(new(big.Int).SetUint64(height)))
+}
+
+// RechargeData returns the packed recharge data of the main chain
+// transaction txHash.
+func (o *MainChainOracle) RechargeData(txHash common.Hash) ([]byte, error) {
+	return o.answer(rechargeQuestion, txHash)
+}
+
+// PledgeBillDetail returns the packed pledge bill detail of the main chain
+// transaction txHash.
+func (o *MainChainOracle) PledgeBillDetail(txHash common.Hash) ([]byte, error) {
+	return o.answer(pledgeBillQuestion, txHash)
+}
+
+// MainChainHeader is a main chain header served by the oracle.
+type MainChainHeader struct {
+	Height     uint32
+	MerkleRoot elacommon.Uint256
+}
+
+// MainChainHeader returns the main chain header hash, nil if the spv of the
+// producer did not accept it. Its height must not be above the committed one.
+func (o *MainChainOracle) MainChainHeader(hash common.Hash) (*MainChainHeader, error) {
+	payload, err := o.answer(mainChainHeaderQuestion, hash)
+	if err != nil || len(payload) == 0 {
+		return nil, err
+	}
+	values, err := mainChainHeaderOutput.Unpack(payload)
+	if err != nil {
+		return nil, err
+	}
+	header := &MainChainHeader{Height: values[4].(uint32), MerkleRoot: values[2].([32]byte)}
+	if err := o.CheckHeight(uint64(header.Height)); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// answer returns the committed answer to the question key, or when recording
+// the answer of the local spv once committed into the block. Answers the
+// local spv fails to give are not committed, validators fail them as well.
+func (o *MainChainOracle) answer(question *mainChainQuestion, key common.Hash) ([]byte, error) {
+	snapshot, rest, err := o.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	answers := question.answers(snapshot)
+	for _, answer := range *answers {
+		if answer.Key == key {
+			return answer.Payload, nil
+		}
+	}
+	if !o.record {
+		return nil, errMainChainPayloadNotCommitted
+	}
+	payload, err := question.ask(key)
+	if err != nil {
+		return nil, err
+	}
+	*answers = append(*answers, types.MainChainAnswer{Key: key, Payload: payload})
+	extra, err := snapshot.EncodeExtra(rest)
+	if err != nil {
+		return nil, err
+	}
+	o.header.Extra = extra
+	return payload, nil
+}
+
+// VerifyMainChainAnswers checks the answers committed to by header are the
+// ones of the local spv, as a producer does before voting for the block.
+func VerifyMainChainAnswers(header *types.Header) error {
+	snapshot, _, err := types.SplitMainChainSnapshot(header.Extra)
+	if err != nil || snapshot == nil {
+		return err
+	}
+	for _, question := range mainChainQuestions {
+		for _, answer := range *question.answers(snapshot) {
+			payload, err := question.ask(answer.Key)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(payload, answer.Payload) {
+				return errMainChainPayloadMismatch
+			}
+		}
+	}
+	return nil
+}
+
+// runPrecompiledContract runs p, with the main chain oracle of the block if
+// it answers from the main chain and the oracle fork is active, unless the
+// EVM serves the local spv.
+func (evm *EVM) runPrecompiledContract(p PrecompiledContract, input []byte, suppliedGas uint64) (ret []byte, remainingGas uint64, err error) {
+	contract, ok := p.(mainChainOracleContract)
+	mode := evm.Config.MainChainOracle
+	if !ok || !evm.chainRules.IsMainChainOracle || mode == MainChainLocal {
+		return RunPrecompiledContract(p, input, suppliedGas)
+	}
+	gasCost := p.RequiredGas(input)
+	if suppliedGas < gasCost {
+		return nil, 0, ErrOutOfGas
+	}
+	suppliedGas -= gasCost
+	output, err := contract.RunOracle(evm.MainChainOracle.recording(mode == MainChainRecord), input)
+	return output, suppliedGas, err
+}
@@ -0,0 +1,126 @@
+// Copyright 2017 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/stretchr/testify/assert"
+)
+
+func newOracleTestHeader(t *testing.T, height uint64, rest []byte) *types.Header {
+	extra, err := (&types.MainChainSnapshot{Height: height}).EncodeExtra(rest)
+	assert.NoError(t, err)
+	return &types.Header{Number: big.NewInt(1), Extra: extra}
+}
+
+func TestMainChainOracleHeight(t *testing.T) {
+	_, err := NewMainChainOracle(&types.Header{Number: big.NewInt(1)}).LatestHeight()
+	assert.Equal(t, errMainChainSnapshotMissing, err)
+
+	oracle := NewMainChainOracle(newOracleTestHeader(t, 10, nil))
+	latest, err := oracle.LatestHeight()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), latest)
+	assert.NoError(t, oracle.CheckHeight(10))
+	assert.Equal(t, errMainChainHeightNotCommitted, oracle.CheckHeight(11))
+}
+
+func TestMainChainOracleAnswers(t *testing.T) {
+	header := newOracleTestHeader(t, 10, []byte{0x01})
+	txHash, failing := common.HexToHash("0x0a"), common.HexToHash("0x0b")
+	asked := 0
+	question := &mainChainQuestion{
+		answers: rechargeQuestion.answers,
+		ask: func(key common.Hash) ([]byte, error) {
+			asked++
+			if key == failing {
+				return nil, errMainChainPayloadNotCommitted
+			}
+			return []byte("recharge"), nil
+		},
+	}
+
+	// validators only serve committed answers
+	_, err := NewMainChainOracle(header).answer(question, txHash)
+	assert.Equal(t, errMainChainPayloadNotCommitted, err)
+	assert.Equal(t, 0, asked)
+
+	// the producer commits the answers it serves
+	recording := NewMainChainOracle(header).recording(true)
+	answer, err := recording.answer(question, txHash)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("recharge"), answer)
+	_, err = recording.answer(question, failing)
+	assert.Error(t, err)
+	snapshot, rest, err := types.SplitMainChainSnapshot(header.Extra)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x01}, rest)
+	assert.Len(t, snapshot.Recharges, 1)
+	assert.Len(t, snapshot.PledgeBills, 0)
+
+	oracle := NewMainChainOracle(header)
+	answer, err = oracle.answer(question, txHash)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("recharge"), answer)
+	assert.Equal(t, 2, asked)
+	_, err = oracle.PledgeBillDetail(txHash)
+	assert.Equal(t, errMainChainPayloadNotCommitted, err)
+
+	// a producer checks the committed answers against its spv before voting
+	defer func(questions []*mainChainQuestion) { mainChainQuestions = questions }(mainChainQuestions)
+	mainChainQuestions = []*mainChainQuestion{question}
+	assert.NoError(t, VerifyMainChainAnswers(header))
+	snapshot.Recharges[0].Payload = []byte("forged")
+	header.Extra, err = snapshot.EncodeExtra(rest)
+	assert.NoError(t, err)
+	assert.Equal(t, errMainChainPayloadMismatch, VerifyMainChainAnswers(header))
+}
+
+func TestMainChainOraclePrecompile(t *testing.T) {
+	config := *params.TestChainConfig
+	config.MainChainOracleBlock = big.NewInt(1)
+	txHash := common.HexToHash("0x0a")
+	extra, err := (&types.MainChainSnapshot{
+		Height:    10,
+		Recharges: []types.MainChainAnswer{{Key: txHash, Payload: []byte("recharge")}},
+	}).EncodeExtra(nil)
+	assert.NoError(t, err)
+	ctx := Context{
+		BlockNumber:     big.NewInt(1),
+		MainChainOracle: NewMainChainOracle(&types.Header{Number: big.NewInt(1), Extra: extra}),
+	}
+	evm := NewEVM(ctx, nil, &config, Config{})
+
+	contract := &getMainChainLatestHeight{}
+	ret, gas, err := evm.runPrecompiledContract(contract, nil, params.GetMainChainBlockLatestHeight+1)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), gas)
+	assert.Equal(t, common.LeftPadBytes([]byte{10}, 32), ret)
+
+	_, _, err = evm.runPrecompiledContract(&getMainChainBlockByHeight{}, common.LeftPadBytes([]byte{11}, 64), params.GetMainChainBlock)
+	assert.Equal(t, errMainChainHeightNotCommitted, err)
+
+	// committed answers are served without the local spv
+	ret, _, err = evm.runPrecompiledContract(&getMainChainRechargeData{}, txHash[:], 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("recharge"), ret)
+}
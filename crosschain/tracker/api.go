@@ -0,0 +1,66 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pgprotocol/pgp-chain/rpc"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+// PublicCrossChainAPI offers the lifecycle of cross chain transfers.
+type PublicCrossChainAPI struct{}
+
+func APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "crosschain",
+		Version:   "1.0",
+		Service:   &PublicCrossChainAPI{},
+		Public:    true,
+	}}
+}
+
+// GetTransferStatus returns the lifecycle state of a deposit, queried by the
+// main chain tx hash or by the hash of its sidechain recharge tx.
+func (api *PublicCrossChainAPI) GetTransferStatus(ctx context.Context, hash string) (*spv.RechargeStatus, error) {
+	return GetTransferStatus(ctx, hash)
+}
+
+// GetRetryStatus returns the retry attempts and the failure classification of
+// a recharge that failed to send.
+func (api *PublicCrossChainAPI) GetRetryStatus(ctx context.Context, hash string) (*spv.RechargeRetry, error) {
+	client := spv.GetClient()
+	if client == nil {
+		return nil, errors.New("ipc client is nil")
+	}
+	return spv.GetRechargeRetry(resolveElaHash(ctx, client, hash))
+}
+
+// Transitions streams the state transitions of all deposits.
+func (api *PublicCrossChainAPI) Transitions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		statuses := make(chan spv.RechargeStatus, 128)
+		sub := spv.SubscribeRechargeStatus(statuses)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case status := <-statuses:
+				notifier.Notify(rpcSub.ID, status)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
@@ -0,0 +1,224 @@
+// Package tracker follows the lifecycle of cross chain deposits through the
+// rpc client of the node and serves it in the crosschain RPC namespace. It is
+// only wired by the node, the tx predicates core needs stay in crosschain.
+package tracker
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	ethereum "github.com/pgprotocol/pgp-chain"
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/ethclient"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/spv"
+
+	elacom "github.com/elastos/Elastos.ELA/common"
+)
+
+var (
+	trackerMu sync.Mutex
+	tracker   *Tracker
+
+	ErrUnknownTransfer = errors.New("unknown cross chain transfer")
+)
+
+// Tracker follows the recharge txs packed into sidechain blocks, so the
+// lifecycle of a main chain deposit is recorded up to its packing height.
+type Tracker struct {
+	client *ethclient.Client
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// Start starts following sidechain heads through the spv ipc client.
+func Start() {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+	if tracker != nil {
+		return
+	}
+	client := spv.GetClient()
+	if client == nil {
+		log.Error("cross chain tracker is not started, ipc client is nil")
+		return
+	}
+	tracker = &Tracker{
+		client: client,
+		quit:   make(chan struct{}),
+	}
+	tracker.wg.Add(1)
+	go tracker.loop()
+}
+
+// Stop stops the running tracker.
+func Stop() {
+	trackerMu.Lock()
+	defer trackerMu.Unlock()
+	if tracker == nil {
+		return
+	}
+	close(tracker.quit)
+	tracker.wg.Wait()
+	tracker = nil
+}
+
+func (t *Tracker) loop() {
+	defer t.wg.Done()
+
+	heads := make(chan *types.Header, 16)
+	sub, err := t.client.SubscribeNewHead(context.Background(), heads)
+	if err != nil {
+		log.Error("cross chain tracker subscribe new head error", "error", err)
+		return
+	}
+	defer sub.Unsubscribe()
+	for {
+		select {
+		case head := <-heads:
+			t.processBlock(head.Number)
+		case err := <-sub.Err():
+			log.Error("cross chain tracker subscription error", "error", err)
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+func (t *Tracker) processBlock(number *big.Int) {
+	block, err := t.client.BlockByNumber(context.Background(), number)
+	if err != nil {
+		log.Error("cross chain tracker get block error", "number", number, "error", err)
+		return
+	}
+	for _, tx := range block.Transactions() {
+		if !spv.IsRechargeTx(tx.Data(), tx.To()) {
+			continue
+		}
+		receipt, err := t.client.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			log.Error("cross chain tracker get receipt error", "txHash", tx.Hash().String(), "error", err)
+			continue
+		}
+		// a reverted recharge is a duplicate or is reported by the spv module
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		// a batch may be completed in part, only the recharged deposits are packed
+		for _, elaHash := range rechargedElaHashes(receipt.Logs) {
+			spv.SetRechargePacked(elaHash, tx.Hash(), block.NumberU64())
+		}
+	}
+}
+
+// rechargedElaHashes returns the main chain tx hashes of the Recharged events in logs.
+func rechargedElaHashes(logs []*types.Log) []string {
+	eventID := spv.ELAMinterABI.Events["Recharged"].ID
+	seen := make(map[common.Hash]struct{})
+	elaHashes := make([]string, 0)
+	for _, l := range logs {
+		if l.Address != spv.ELAMinterAddress || len(l.Topics) < 2 || l.Topics[0] != eventID {
+			continue
+		}
+		if _, ok := seen[l.Topics[1]]; ok {
+			continue
+		}
+		seen[l.Topics[1]] = struct{}{}
+		elaHashes = append(elaHashes, l.Topics[1].String())
+	}
+	return elaHashes
+}
+
+// GetTransferStatus resolves hash, either a main chain deposit tx hash or the
+// hash of a sidechain recharge tx, to the lifecycle state of the deposit.
+func GetTransferStatus(ctx context.Context, hash string) (*spv.RechargeStatus, error) {
+	client := spv.GetClient()
+	if client == nil {
+		return nil, errors.New("ipc client is nil")
+	}
+	elaHash := resolveElaHash(ctx, client, hash)
+	status, err := spv.GetRechargeStatus(elaHash)
+	if err != nil {
+		status = &spv.RechargeStatus{ElaHash: elaHash}
+	}
+
+	switch status.State {
+	case spv.RechargePacked:
+		if status.BlockNumber > 0 {
+			return status, nil
+		}
+	case spv.RechargeRefunded:
+		return status, nil
+	}
+	if spv.IsCompleted(elaHash, client) {
+		if err := findRecharged(ctx, client, elaHash); err != nil {
+			log.Warn("find recharged log error", "elaHash", elaHash, "error", err)
+			spv.SetRechargePacked(elaHash, status.SideTxHash, status.BlockNumber)
+		}
+		return spv.GetRechargeStatus(elaHash)
+	}
+	if isRefunded(elaHash) {
+		spv.SetRechargeRefunded(elaHash)
+		return spv.GetRechargeStatus(elaHash)
+	}
+	if status.State == spv.RechargeUnknown {
+		return nil, ErrUnknownTransfer
+	}
+	return status, nil
+}
+
+// resolveElaHash maps a sidechain recharge tx hash to its main chain tx hash,
+// or to the first one if the recharge tx is a batch. Any other hash is taken as
+// a main chain tx hash.
+func resolveElaHash(ctx context.Context, client *ethclient.Client, hash string) string {
+	txHash := common.HexToHash(hash)
+	if elaHash, err := spv.GetRechargeElaHash(txHash); err == nil {
+		return elaHash
+	}
+	tx, _, err := client.TransactionByHash(ctx, txHash)
+	if err == nil && spv.IsRechargeTx(tx.Data(), tx.To()) {
+		if elaHashes, err := spv.GetElaHashesByTxInput(tx.Data()); err == nil {
+			return elaHashes[0]
+		}
+	}
+	return hash
+}
+
+// findRecharged records the packing height of elaHash from the Recharged
+// event of the ELAMinter contract.
+func findRecharged(ctx context.Context, client *ethclient.Client, elaHash string) error {
+	query := ethereum.FilterQuery{
+		Addresses: []common.Address{spv.ELAMinterAddress},
+		Topics: [][]common.Hash{
+			{spv.ELAMinterABI.Events["Recharged"].ID},
+			{common.HexToHash(elaHash)},
+		},
+	}
+	logs, err := client.FilterLogs(ctx, query)
+	if err != nil {
+		return err
+	}
+	for _, l := range logs {
+		if l.Removed {
+			continue
+		}
+		spv.SetRechargePacked(elaHash, l.TxHash, l.BlockNumber)
+		return nil
+	}
+	return errors.New("recharged log is not found")
+}
+
+func isRefunded(elaHash string) bool {
+	if spv.SpvService == nil {
+		return false
+	}
+	hash, err := elacom.Uint256FromHexString(common.TrimHexPrefix(elaHash))
+	if err != nil {
+		return false
+	}
+	return spv.SpvService.HaveRetSideChainDepositCoinTx(*hash)
+}
@@ -8,7 +8,6 @@ package dpos
 import (
 	"github.com/elastos/Elastos.ELA/account"
 	daccount "github.com/elastos/Elastos.ELA/dpos/account"
-	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto"
 	"github.com/pgprotocol/pgp-chain/chainbridge-core/crypto/secp256k1"
 )
 
@@ -20,10 +19,20 @@ func GetDposAccount(keystorePath string, password []byte) (daccount.Account, err
 	return daccount.New(client.GetMainAccount()), nil
 }
 
-func GetBridgeAccount(keystorePath string, password []byte) (crypto.Keypair, error) {
+func GetBridgeAccount(keystorePath string, password []byte) (*secp256k1.Keypair, error) {
 	client, err := account.Open(keystorePath, password)
 	if err != nil {
 		return nil, err
 	}
 	return secp256k1.NewKeypairFromPrivateKey(client.GetMainAccount().PrivateKey)
 }
+
+// GetBlsKey returns the BLS key the producer of the keystore signs aggregate
+// confirms with, derived from its private key.
+func GetBlsKey(keystorePath string, password []byte) (*BlsKey, error) {
+	client, err := account.Open(keystorePath, password)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlsKey(client.GetMainAccount().PrivateKey), nil
+}
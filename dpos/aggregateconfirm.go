@@ -0,0 +1,143 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/pgprotocol/pgp-chain/core/types"
+)
+
+// AggregateConfirmPrefix starts the serialized AggregateConfirm. A classic
+// confirm starts with the length of the sponsor public key instead, which is
+// never encoded as this prefix.
+const AggregateConfirmPrefix byte = 0xff
+
+// maxAggregateSigners bounds the producer list an aggregate confirm indexes.
+const maxAggregateSigners = 1024
+
+// AggregateConfirm is the compact confirm sealed into blocks after the
+// aggregate confirm fork. Instead of one signed vote per producer it carries
+// a bitmap of the accepting producers, indexed like the producer list of the
+// consensus view, and the aggregate of their BLS signatures of the proposal
+// hash.
+type AggregateConfirm struct {
+	Proposal  payload.DPOSProposal
+	Signers   []byte
+	Signature []byte
+}
+
+// NewAggregateConfirm creates the confirm of proposal signed by the
+// producers at the given indexes of a producer list of total producers.
+func NewAggregateConfirm(proposal payload.DPOSProposal, total int, indexes []int,
+	signatures [][]byte) (*AggregateConfirm, error) {
+	if len(indexes) != len(signatures) {
+		return nil, errors.New("signers and signatures mismatch")
+	}
+	signature, err := AggregateBlsSignatures(signatures)
+	if err != nil {
+		return nil, err
+	}
+	if total > maxAggregateSigners {
+		return nil, errors.New("too many producers")
+	}
+	signers := make([]byte, (total+7)/8)
+	for _, index := range indexes {
+		if index < 0 || index >= total {
+			return nil, errors.New("signer out of range")
+		}
+		signers[index/8] |= 1 << uint(index%8)
+	}
+	return &AggregateConfirm{Proposal: proposal, Signers: signers, Signature: signature}, nil
+}
+
+// IsSigner returns whether the producer at index signed the confirm.
+func (c *AggregateConfirm) IsSigner(index int) bool {
+	if index < 0 || index/8 >= len(c.Signers) {
+		return false
+	}
+	return c.Signers[index/8]&(1<<uint(index%8)) != 0
+}
+
+// SignerCount returns the number of producers that signed the confirm.
+func (c *AggregateConfirm) SignerCount() int {
+	count := 0
+	for i := 0; i < len(c.Signers)*8; i++ {
+		if c.IsSigner(i) {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *AggregateConfirm) Serialize(w io.Writer) error {
+	if _, err := w.Write([]byte{AggregateConfirmPrefix}); err != nil {
+		return err
+	}
+	if err := c.Proposal.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, c.Signers); err != nil {
+		return err
+	}
+	return common.WriteVarBytes(w, c.Signature)
+}
+
+func (c *AggregateConfirm) Deserialize(r io.Reader) error {
+	var prefix [1]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return err
+	}
+	if prefix[0] != AggregateConfirmPrefix {
+		return errors.New("not an aggregate confirm")
+	}
+	if err := c.Proposal.Deserialize(r); err != nil {
+		return err
+	}
+	signers, err := common.ReadVarBytes(r, maxAggregateSigners/8, "signers")
+	if err != nil {
+		return err
+	}
+	signature, err := common.ReadVarBytes(r, BlsSignatureLength, "signature")
+	if err != nil {
+		return err
+	}
+	c.Signers, c.Signature = signers, signature
+	return nil
+}
+
+// IsAggregateConfirm returns whether the extra data of a header holds an
+// aggregate confirm rather than a classic payload.Confirm.
+func IsAggregateConfirm(extra []byte) bool {
+	return len(extra) > 0 && extra[0] == AggregateConfirmPrefix
+}
+
+// SealedProposal returns the proposal of the confirm in the extra data of a
+// header, whichever format the confirm has and after the main chain snapshot
+// and producer commitment if there are.
+func SealedProposal(extra []byte) (*payload.DPOSProposal, error) {
+	_, _, extra, err := types.SplitSealedExtra(extra)
+	if err != nil {
+		return nil, err
+	}
+	if IsAggregateConfirm(extra) {
+		var confirm AggregateConfirm
+		if err := confirm.Deserialize(bytes.NewReader(extra)); err != nil {
+			return nil, err
+		}
+		return &confirm.Proposal, nil
+	}
+	var confirm payload.Confirm
+	if err := confirm.Deserialize(bytes.NewReader(extra)); err != nil {
+		return nil, err
+	}
+	return &confirm.Proposal, nil
+}
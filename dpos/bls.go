@@ -0,0 +1,246 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"math/big"
+
+	"github.com/pgprotocol/pgp-chain/crypto/bls12381"
+)
+
+const (
+	// BlsPublicKeyLength is the length of an uncompressed G1 public key.
+	BlsPublicKeyLength = 96
+
+	// BlsSignatureLength is the length of an uncompressed G2 signature.
+	BlsSignatureLength = 192
+)
+
+var (
+	blsSecretKeyDomain = []byte("PGP_PBFT_BLS12381_SECRET_KEY")
+
+	// blsSignatureDST and blsProofDST separate the hashes of confirms from
+	// the ones of proofs of possession, as the proof of possession scheme of
+	// the IETF BLS signature draft does.
+	blsSignatureDST = []byte("PGP_PBFT_BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+	blsProofDST     = []byte("PGP_PBFT_BLS_POP_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+
+	// blsFieldModulus is the modulus of the base field of BLS12-381.
+	blsFieldModulus, _ = new(big.Int).SetString("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab", 16)
+
+	ErrInvalidBlsPublicKey = errors.New("invalid bls public key")
+	ErrInvalidBlsSignature = errors.New("invalid bls signature")
+	ErrInvalidBlsProof     = errors.New("invalid bls proof of possession")
+)
+
+// BlsKey is the key a producer signs aggregate confirms with. Public keys
+// live in G1 and signatures in G2, so the signatures of a confirm aggregate
+// into a single G2 point.
+type BlsKey struct {
+	secret *big.Int
+	public []byte
+}
+
+// NewBlsKey derives a BLS key from seed, producers derive it from the private
+// key of their dpos account.
+func NewBlsKey(seed []byte) *BlsKey {
+	g1 := bls12381.NewG1()
+	digest := sha512.Sum512(append(append([]byte{}, blsSecretKeyDomain...), seed...))
+	order := new(big.Int).Sub(g1.Q(), big.NewInt(1))
+	secret := new(big.Int).SetBytes(digest[:])
+	secret.Mod(secret, order).Add(secret, big.NewInt(1))
+
+	public := g1.New()
+	g1.MulScalar(public, g1.One(), secret)
+	return &BlsKey{secret: secret, public: g1.ToBytes(public)}
+}
+
+// PublicKey returns the uncompressed G1 public key.
+func (k *BlsKey) PublicKey() []byte {
+	return k.public
+}
+
+// Sign signs data, which the producers of a confirm all sign the same of.
+func (k *BlsKey) Sign(data []byte) []byte {
+	return k.sign(data, blsSignatureDST)
+}
+
+// ProofOfPossession signs the public key of k, to register it along with the
+// key. It proves the key is not derived from the keys of other producers.
+func (k *BlsKey) ProofOfPossession() []byte {
+	return k.sign(k.public, blsProofDST)
+}
+
+func (k *BlsKey) sign(data, dst []byte) []byte {
+	g2 := bls12381.NewG2()
+	point, err := hashToG2(g2, data, dst)
+	if err != nil {
+		// field elements are reduced before mapping, this can not fail
+		panic(err)
+	}
+	g2.MulScalar(point, point, k.secret)
+	return g2.ToBytes(point)
+}
+
+// CheckBlsPublicKey checks publicKey is a valid G1 point of the prime order
+// subgroup other than the identity.
+func CheckBlsPublicKey(publicKey []byte) error {
+	if len(publicKey) != BlsPublicKeyLength {
+		return ErrInvalidBlsPublicKey
+	}
+	g1 := bls12381.NewG1()
+	point, err := g1.FromBytes(publicKey)
+	if err != nil || g1.IsZero(point) || !g1.InCorrectSubgroup(point) {
+		return ErrInvalidBlsPublicKey
+	}
+	return nil
+}
+
+// VerifyBlsProof checks publicKey is valid and proof is its proof of
+// possession. Only keys passing it may be aggregated.
+func VerifyBlsProof(publicKey, proof []byte) error {
+	if err := CheckBlsPublicKey(publicKey); err != nil {
+		return err
+	}
+	if err := verifyAggregateBlsSignature([][]byte{publicKey}, publicKey, proof, blsProofDST); err != nil {
+		return ErrInvalidBlsProof
+	}
+	return nil
+}
+
+// VerifyBlsSignature checks signature is the signature of publicKey for data.
+func VerifyBlsSignature(publicKey, data, signature []byte) error {
+	return VerifyAggregateBlsSignature([][]byte{publicKey}, data, signature)
+}
+
+// AggregateBlsSignatures adds up signatures of the same data.
+func AggregateBlsSignatures(signatures [][]byte) ([]byte, error) {
+	if len(signatures) == 0 {
+		return nil, ErrInvalidBlsSignature
+	}
+	g2 := bls12381.NewG2()
+	aggregate := g2.Zero()
+	for _, signature := range signatures {
+		point, err := decodeBlsSignature(g2, signature)
+		if err != nil {
+			return nil, err
+		}
+		g2.Add(aggregate, aggregate, point)
+	}
+	return g2.ToBytes(aggregate), nil
+}
+
+// VerifyAggregateBlsSignature checks signature is the aggregate of the
+// signatures of publicKeys for data. Every public key must have passed
+// VerifyBlsProof, as a key chosen from the others without its secret could
+// forge an aggregate.
+func VerifyAggregateBlsSignature(publicKeys [][]byte, data, signature []byte) error {
+	return verifyAggregateBlsSignature(publicKeys, data, signature, blsSignatureDST)
+}
+
+func verifyAggregateBlsSignature(publicKeys [][]byte, data, signature, dst []byte) error {
+	if len(publicKeys) == 0 {
+		return ErrInvalidBlsPublicKey
+	}
+	g1, g2 := bls12381.NewG1(), bls12381.NewG2()
+	aggregate := g1.Zero()
+	for _, publicKey := range publicKeys {
+		if len(publicKey) != BlsPublicKeyLength {
+			return ErrInvalidBlsPublicKey
+		}
+		point, err := g1.FromBytes(publicKey)
+		if err != nil {
+			return ErrInvalidBlsPublicKey
+		}
+		g1.Add(aggregate, aggregate, point)
+	}
+	point, err := decodeBlsSignature(g2, signature)
+	if err != nil {
+		return err
+	}
+	message, err := hashToG2(g2, data, dst)
+	if err != nil {
+		return err
+	}
+	// e(pk, H(m)) == e(g1, sig)
+	engine := bls12381.NewPairingEngine()
+	engine.AddPair(aggregate, message)
+	engine.AddPairInv(g1.One(), point)
+	if !engine.Check() {
+		return ErrInvalidBlsSignature
+	}
+	return nil
+}
+
+func decodeBlsSignature(g2 *bls12381.G2, signature []byte) (*bls12381.PointG2, error) {
+	if len(signature) != BlsSignatureLength {
+		return nil, ErrInvalidBlsSignature
+	}
+	point, err := g2.FromBytes(signature)
+	if err != nil || g2.IsZero(point) || !g2.InCorrectSubgroup(point) {
+		return nil, ErrInvalidBlsSignature
+	}
+	return point, nil
+}
+
+// hashToG2 hashes data to G2 with the hash_to_curve of the
+// BLS12381G2_XMD:SHA-256_SSWU_RO_ suite of RFC 9380, under the domain
+// separation tag dst.
+func hashToG2(g2 *bls12381.G2, data, dst []byte) (*bls12381.PointG2, error) {
+	// two elements of Fp2, of two 64 bytes halves each
+	uniform, err := expandMessageXMD(data, dst, 256)
+	if err != nil {
+		return nil, err
+	}
+	element := make([]byte, 96)
+	var points [2]*bls12381.PointG2
+	for i := range points {
+		// the map takes c1 first
+		for j := 0; j < 2; j++ {
+			offset := 128*i + 64*j
+			value := new(big.Int).SetBytes(uniform[offset : offset+64])
+			value.Mod(value, blsFieldModulus).FillBytes(element[48*(1-j) : 48*(2-j)])
+		}
+		// the map clears the cofactor, which commutes with the addition
+		if points[i], err = g2.MapToCurve(element); err != nil {
+			return nil, err
+		}
+	}
+	return g2.Affine(g2.Add(points[0], points[0], points[1])), nil
+}
+
+// expandMessageXMD is the expand_message_xmd of RFC 9380 with SHA-256.
+func expandMessageXMD(message, dst []byte, length int) ([]byte, error) {
+	ell := (length + sha256.Size - 1) / sha256.Size
+	if ell > 255 || len(dst) > 255 || length > 65535 {
+		return nil, errors.New("invalid expand message length")
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+	h := sha256.New()
+	h.Write(make([]byte, sha256.BlockSize))
+	h.Write(message)
+	h.Write([]byte{byte(length >> 8), byte(length), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	out := make([]byte, 0, ell*sha256.Size)
+	bi := make([]byte, sha256.Size)
+	for i := 1; i <= ell; i++ {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(nil)
+		out = append(out, bi...)
+	}
+	return out[:length], nil
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/crypto/bls12381"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlsSignature(t *testing.T) {
+	key := NewBlsKey([]byte("producer"))
+	assert.Len(t, key.PublicKey(), BlsPublicKeyLength)
+	assert.NoError(t, CheckBlsPublicKey(key.PublicKey()))
+	assert.Equal(t, key.PublicKey(), NewBlsKey([]byte("producer")).PublicKey())
+
+	signature := key.Sign([]byte("proposal"))
+	assert.Len(t, signature, BlsSignatureLength)
+	assert.NoError(t, VerifyBlsSignature(key.PublicKey(), []byte("proposal"), signature))
+	assert.Equal(t, ErrInvalidBlsSignature, VerifyBlsSignature(key.PublicKey(), []byte("other"), signature))
+
+	other := NewBlsKey([]byte("other producer"))
+	assert.Equal(t, ErrInvalidBlsSignature, VerifyBlsSignature(other.PublicKey(), []byte("proposal"), signature))
+	assert.Equal(t, ErrInvalidBlsSignature, VerifyBlsSignature(key.PublicKey(), []byte("proposal"), signature[1:]))
+	assert.Equal(t, ErrInvalidBlsPublicKey, CheckBlsPublicKey(make([]byte, BlsPublicKeyLength)))
+}
+
+func TestAggregateBlsSignature(t *testing.T) {
+	data := []byte("proposal")
+	keys := []*BlsKey{NewBlsKey([]byte{0}), NewBlsKey([]byte{1}), NewBlsKey([]byte{2})}
+	publicKeys := make([][]byte, len(keys))
+	signatures := make([][]byte, len(keys))
+	for i, key := range keys {
+		publicKeys[i] = key.PublicKey()
+		signatures[i] = key.Sign(data)
+	}
+	aggregate, err := AggregateBlsSignatures(signatures)
+	assert.NoError(t, err)
+	assert.NoError(t, VerifyAggregateBlsSignature(publicKeys, data, aggregate))
+
+	// every signer must be accounted for
+	assert.Equal(t, ErrInvalidBlsSignature, VerifyAggregateBlsSignature(publicKeys[:2], data, aggregate))
+	partial, err := AggregateBlsSignatures(signatures[:2])
+	assert.NoError(t, err)
+	assert.Equal(t, ErrInvalidBlsSignature, VerifyAggregateBlsSignature(publicKeys, data, partial))
+	assert.NoError(t, VerifyAggregateBlsSignature(publicKeys[:2], data, partial))
+}
+
+func TestBlsProofOfPossession(t *testing.T) {
+	key, other := NewBlsKey([]byte("producer")), NewBlsKey([]byte("other producer"))
+	assert.NoError(t, VerifyBlsProof(key.PublicKey(), key.ProofOfPossession()))
+	assert.Equal(t, ErrInvalidBlsProof, VerifyBlsProof(key.PublicKey(), other.ProofOfPossession()))
+	assert.Equal(t, ErrInvalidBlsPublicKey, VerifyBlsProof(make([]byte, BlsPublicKeyLength), key.ProofOfPossession()))
+
+	// a confirm signature is not a proof of possession
+	assert.Equal(t, ErrInvalidBlsProof, VerifyBlsProof(key.PublicKey(), key.Sign(key.PublicKey())))
+}
+
+// The vectors are the ones of RFC 9380.
+func TestBlsHashToCurve(t *testing.T) {
+	uniform, err := expandMessageXMD(nil, []byte("QUUX-V01-CS02-with-expander-SHA256-128"), 0x20)
+	assert.NoError(t, err)
+	assert.Equal(t, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235", hex.EncodeToString(uniform))
+
+	g2 := bls12381.NewG2()
+	point, err := hashToG2(g2, nil, []byte("QUUX-V01-CS02-with-BLS12381G2_XMD:SHA-256_SSWU_RO_"))
+	assert.NoError(t, err)
+	assert.Equal(t, "05cb8437535e20ecffaef7752baddf98034139c38452458baeefab379ba13dff5bf5dd71b72418717047f5b0f37da03d"+
+		"0141ebfbdca40eb85b87142e130ab689c673cf60f1a3e98d69335266f30d9b8d4ac44c1038e9dcdd5393faf5c41fb78a"+
+		"12424ac32561493f3fe3c260708a12b7c620e7be00099a974e259ddc7d1f6395c3c811cdd19f1e8dbf3e9ecfdcbab8d6"+
+		"0503921d7f6a12805e72940b963c0cf3471c7b2a524950ca195d11062ee75ec076daf2d4bc358c4b190c0c98064fdd92",
+		hex.EncodeToString(g2.ToBytes(point)))
+}
@@ -6,10 +6,13 @@
 package dpos
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/pgprotocol/pgp-chain/common"
 )
 
 func CheckConfirm(confirm *payload.Confirm, minSignCount int) error {
@@ -43,4 +46,59 @@ func CheckConfirm(confirm *payload.Confirm, minSignCount int) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// CheckProducersConfirm checks the confirm is signed by at least
+// minSignCount distinct producers of the given producer list, votes of other
+// signers are not counted.
+func CheckProducersConfirm(confirm *payload.Confirm, producers [][]byte, minSignCount int) error {
+	if err := CheckConfirm(confirm, minSignCount); err != nil {
+		return err
+	}
+	signers := make(map[string]struct{}, len(confirm.Votes))
+	for _, vote := range confirm.Votes {
+		for _, producer := range producers {
+			if len(producer) > 0 && bytes.Equal(producer, vote.Signer) {
+				signers[common.Bytes2Hex(vote.Signer)] = struct{}{}
+				break
+			}
+		}
+	}
+	if len(signers) < minSignCount {
+		str := fmt.Sprintf("[CheckProducersConfirm] error, need %d producer votes", minSignCount)
+		return errors.New(str)
+	}
+	return nil
+}
+
+// CheckAggregateConfirm checks the aggregate confirm is signed by at least
+// minSignCount producers. publicKeys holds the BLS public keys of the
+// producer list the signer bitmap indexes, nil for producers without one.
+func CheckAggregateConfirm(confirm *AggregateConfirm, publicKeys [][]byte, minSignCount int) error {
+	err := CheckProposal(&confirm.Proposal)
+	if err != nil {
+		return err
+	}
+	if len(confirm.Signers) != (len(publicKeys)+7)/8 {
+		return errors.New("[CheckAggregateConfirm] signers do not match producers")
+	}
+	signers := make([][]byte, 0, len(publicKeys))
+	for i := 0; i < len(confirm.Signers)*8; i++ {
+		if !confirm.IsSigner(i) {
+			continue
+		}
+		if i >= len(publicKeys) || publicKeys[i] == nil {
+			return fmt.Errorf("[CheckAggregateConfirm] signer %d has no bls public key", i)
+		}
+		signers = append(signers, publicKeys[i])
+	}
+	if len(signers) < minSignCount {
+		str := fmt.Sprintf("[CheckAggregateConfirm] error, need %d signers", minSignCount)
+		return errors.New(str)
+	}
+	proposalHash := confirm.Proposal.Hash()
+	if err := VerifyAggregateBlsSignature(signers, proposalHash[:], confirm.Signature); err != nil {
+		return errors.New("[CheckAggregateConfirm] invalid signature: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+// confirmTestProducers is a producer set signing both confirm formats.
+type confirmTestProducers struct {
+	keys       [][]byte
+	publicKeys [][]byte
+	blsKeys    []*BlsKey
+}
+
+func newConfirmTestProducers(tb testing.TB, count int) *confirmTestProducers {
+	producers := &confirmTestProducers{}
+	for i := 0; i < count; i++ {
+		key, err := ecdsa.GenerateKey(crypto.DefaultCurve, rand.Reader)
+		assert.NoError(tb, err)
+		producers.keys = append(producers.keys, key.D.FillBytes(make([]byte, 32)))
+	}
+	sort.Slice(producers.keys, func(i, j int) bool {
+		return bytes.Compare(testPublicKey(tb, producers.keys[i]), testPublicKey(tb, producers.keys[j])) < 0
+	})
+	for _, key := range producers.keys {
+		producers.publicKeys = append(producers.publicKeys, testPublicKey(tb, key))
+		producers.blsKeys = append(producers.blsKeys, NewBlsKey(key))
+	}
+	return producers
+}
+
+func (p *confirmTestProducers) proposal(tb testing.TB) payload.DPOSProposal {
+	proposal := payload.DPOSProposal{Sponsor: p.publicKeys[0], ViewOffset: 1}
+	rand.Read(proposal.BlockHash[:])
+	proposal.Sign = signTestData(tb, p.keys[0], proposal.Data())
+	return proposal
+}
+
+// confirm returns the classic confirm of proposal signed by the first signers.
+func (p *confirmTestProducers) confirm(tb testing.TB, proposal payload.DPOSProposal, signers int) *payload.Confirm {
+	confirm := &payload.Confirm{Proposal: proposal}
+	for i := 0; i < signers; i++ {
+		vote := payload.DPOSProposalVote{ProposalHash: proposal.Hash(), Signer: p.publicKeys[i], Accept: true}
+		vote.Sign = signTestData(tb, p.keys[i], vote.Data())
+		confirm.Votes = append(confirm.Votes, vote)
+	}
+	return confirm
+}
+
+// aggregate returns the aggregate confirm of proposal signed by the first signers.
+func (p *confirmTestProducers) aggregate(tb testing.TB, proposal payload.DPOSProposal, signers int) *AggregateConfirm {
+	hash := proposal.Hash()
+	indexes, signatures := make([]int, signers), make([][]byte, signers)
+	for i := 0; i < signers; i++ {
+		indexes[i], signatures[i] = i, p.blsKeys[i].Sign(hash[:])
+	}
+	confirm, err := NewAggregateConfirm(proposal, len(p.keys), indexes, signatures)
+	assert.NoError(tb, err)
+	return confirm
+}
+
+func (p *confirmTestProducers) blsPublicKeys() [][]byte {
+	publicKeys := make([][]byte, len(p.blsKeys))
+	for i, key := range p.blsKeys {
+		publicKeys[i] = key.PublicKey()
+	}
+	return publicKeys
+}
+
+func TestCheckAggregateConfirm(t *testing.T) {
+	producers := newConfirmTestProducers(t, 12)
+	proposal := producers.proposal(t)
+	confirm := producers.aggregate(t, proposal, 9)
+	publicKeys := producers.blsPublicKeys()
+	assert.Equal(t, 9, confirm.SignerCount())
+	assert.NoError(t, CheckAggregateConfirm(confirm, publicKeys, 9))
+	assert.Error(t, CheckAggregateConfirm(confirm, publicKeys, 10))
+
+	// round trip through the header extra data
+	buf := new(bytes.Buffer)
+	assert.NoError(t, confirm.Serialize(buf))
+	assert.True(t, IsAggregateConfirm(buf.Bytes()))
+	decoded := new(AggregateConfirm)
+	assert.NoError(t, decoded.Deserialize(bytes.NewReader(buf.Bytes())))
+	assert.NoError(t, CheckAggregateConfirm(decoded, publicKeys, 9))
+	sealed, err := SealedProposal(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, proposal.Hash(), sealed.Hash())
+
+	// a producer claimed as signer without signing
+	forged := *confirm
+	forged.Signers = append([]byte{}, confirm.Signers...)
+	forged.Signers[1] |= 1 << 1
+	assert.Error(t, CheckAggregateConfirm(&forged, publicKeys, 9))
+
+	// a signer without bls public key
+	missing := append([][]byte{}, publicKeys...)
+	missing[0] = nil
+	assert.Error(t, CheckAggregateConfirm(confirm, missing, 9))
+
+	// a bitmap for another producer set
+	assert.Error(t, CheckAggregateConfirm(confirm, publicKeys[:8], 1))
+
+	// the signatures of another proposal
+	other := producers.aggregate(t, producers.proposal(t), 9)
+	other.Proposal = proposal
+	assert.Error(t, CheckAggregateConfirm(other, publicKeys, 9))
+}
+
+func TestSealedProposal(t *testing.T) {
+	producers := newConfirmTestProducers(t, 4)
+	proposal := producers.proposal(t)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, producers.confirm(t, proposal, 3).Serialize(buf))
+	assert.False(t, IsAggregateConfirm(buf.Bytes()))
+	sealed, err := SealedProposal(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, proposal.Hash(), sealed.Hash())
+}
+
+// BenchmarkConfirm compares the header extra data size and the verification
+// time of classic and aggregate confirms signed by a majority of producers.
+func BenchmarkConfirm(b *testing.B) {
+	for _, count := range []int{12, 36, 72} {
+		producers := newConfirmTestProducers(b, count)
+		proposal := producers.proposal(b)
+		signers := count*2/3 + 1
+
+		b.Run(fmt.Sprintf("classic/%d", count), func(b *testing.B) {
+			confirm := producers.confirm(b, proposal, signers)
+			buf := new(bytes.Buffer)
+			assert.NoError(b, confirm.Serialize(buf))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := CheckConfirm(confirm, signers); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "extra-bytes")
+		})
+		b.Run(fmt.Sprintf("aggregate/%d", count), func(b *testing.B) {
+			confirm := producers.aggregate(b, proposal, signers)
+			publicKeys := producers.blsPublicKeys()
+			buf := new(bytes.Buffer)
+			assert.NoError(b, confirm.Serialize(buf))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := CheckAggregateConfirm(confirm, publicKeys, signers); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(buf.Len()), "extra-bytes")
+		})
+	}
+}
@@ -8,6 +8,7 @@ package dpos
 import (
 	"bytes"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/elastos/Elastos.ELA/common"
@@ -24,10 +25,14 @@ const (
 )
 
 type ConsensusView struct {
+	// signTolerance is the time.Duration of the views, it is updated with the
+	// duty index and read by the change view loop, so it is accessed
+	// atomically. It is first to be 64-bit aligned.
+	signTolerance   int64
 	consensusStatus uint32
 	viewOffset      uint32
 	publicKey       []byte
-	signTolerance   time.Duration
+	viewInterval    func(height uint64) time.Duration
 	viewStartTime   time.Time
 	viewChangeTime  time.Time
 	isDposOnDuty    bool
@@ -59,7 +64,7 @@ func (v *ConsensusView) IsReady() bool {
 func (v *ConsensusView) TryChangeView(now time.Time) {
 	if v.IsRunning() && now.After(v.viewChangeTime) {
 		Info("[TryChangeView] succeed", "now", now.String(), "changeTime", v.viewChangeTime.String())
-		parentTime := float64(v.viewChangeTime.Unix()) - v.signTolerance.Seconds()
+		parentTime := float64(v.viewChangeTime.Unix()) - v.GetViewInterval().Seconds()
 		v.ChangeView(now, false, uint64(parentTime))
 	}
 }
@@ -91,6 +96,10 @@ func (v *ConsensusView) ChangeCurrentProducers(changeHeight uint64, spvHeight ui
 	v.producers.ChangeCurrentProducers(changeHeight, spvHeight)
 }
 
+func (v *ConsensusView) GetNextProducers() ([][]byte, int) {
+	return v.producers.GetNextProducers()
+}
+
 func (v *ConsensusView) ProducerIndex(signer []byte) int {
 	if len(signer) <= 0 {
 		return -1
@@ -166,14 +175,18 @@ func (v *ConsensusView) IsCurrentProducers(curProducers [][]byte) bool {
 func (v *ConsensusView) calculateOffsetTime(startTime time.Time,
 	now time.Time) (uint32, time.Duration) {
 	duration := now.Sub(startTime)
-	offset := duration / v.signTolerance
-	offsetTime := duration % v.signTolerance
+	signTolerance := v.GetViewInterval()
+	offset := duration / signTolerance
+	offsetTime := duration % signTolerance
 
 	return uint32(offset), offsetTime
 }
 
+// UpdateDutyIndex moves the view to the consensus on the block after height,
+// with the view interval of that block.
 func (v *ConsensusView) UpdateDutyIndex(height uint64) {
 	v.producers.UpdateDutyIndex(height)
+	atomic.StoreInt64(&v.signTolerance, int64(v.viewInterval(height+1)))
 
 	currentProducer := v.producers.GetNextOnDutyProducer(v.viewOffset)
 	v.isDposOnDuty = bytes.Equal(currentProducer, v.publicKey)
@@ -220,7 +233,7 @@ func (v *ConsensusView) DumpInfo() {
 }
 
 func (v *ConsensusView) GetViewInterval() time.Duration {
-	return v.signTolerance
+	return time.Duration(atomic.LoadInt64(&v.signTolerance))
 }
 
 func (v *ConsensusView) GetViewStartTime() time.Time {
@@ -235,6 +248,18 @@ func (v *ConsensusView) GetDutyIndex() uint32 {
 	return v.producers.dutyIndex
 }
 
+// GetSkippedProducers returns the producers on duty at the views before
+// viewOffset at the height in consensus, empty seats are left out.
+func (v *ConsensusView) GetSkippedProducers(viewOffset uint32) [][]byte {
+	skipped := make([][]byte, 0, viewOffset)
+	for offset := uint32(0); offset < viewOffset; offset++ {
+		if producer := v.producers.GetNextOnDutyProducer(offset); len(producer) > 0 {
+			skipped = append(skipped, producer)
+		}
+	}
+	return skipped
+}
+
 func (v *ConsensusView) GetViewOffset() uint32 {
 	return v.viewOffset
 }
@@ -245,7 +270,7 @@ func (v *ConsensusView) ResetView(parentTime uint64) {
 
 func (v *ConsensusView) SetChangViewTime(parentTime uint64) {
 	headerTime := time.Unix(int64(parentTime), 0)
-	v.viewChangeTime = headerTime.Add(v.signTolerance)
+	v.viewChangeTime = headerTime.Add(v.GetViewInterval())
 	v.viewStartTime = headerTime
 }
 
@@ -295,14 +320,17 @@ func (v *ConsensusView) GetMajorityCountByTotalSigners(totalSigner int) int {
 	return v.producers.GetMajorityCountByTotalSigners(totalSigner)
 }
 
-func NewConsensusView(tolerance time.Duration, account []byte,
+// NewConsensusView creates the consensus view, viewInterval returns the
+// interval of the views at a block height.
+func NewConsensusView(viewInterval func(height uint64) time.Duration, account []byte,
 	producers *Producers, viewListener ViewListener) *ConsensusView {
 	c := &ConsensusView{
 		consensusStatus: ConsensusReady,
 		viewStartTime:   time.Unix(0, 0),
 		viewOffset:      0,
 		publicKey:       account,
-		signTolerance:   tolerance,
+		signTolerance:   int64(viewInterval(0)),
+		viewInterval:    viewInterval,
 		producers:       producers,
 		listener:        viewListener,
 		isDposOnDuty:    false,
@@ -19,6 +19,7 @@ import (
 	"github.com/elastos/Elastos.ELA/dpos/dtime"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	"github.com/elastos/Elastos.ELA/events"
 
 	dmsg "github.com/pgprotocol/pgp-chain/dpos/msg"
 )
@@ -47,6 +48,11 @@ type Dispatcher struct {
 
 	resetViewRequests map[string]struct{} // sponsors
 	resetViewMu       sync.RWMutex
+
+	equivocations  *equivocationWindow
+	equivocationMu sync.Mutex
+
+	stats *ProducerStats
 }
 
 func (d *Dispatcher) ProcessProposal(id peer.PID, proposal *payload.DPOSProposal) (err error, isSendReject bool, handled bool) {
@@ -79,6 +85,8 @@ func (d *Dispatcher) ProcessProposal(id peer.PID, proposal *payload.DPOSProposal
 		return err, true, true
 	}
 
+	d.detectIllegalProposal(proposal)
+	d.stats.addProposal(proposal.Sponsor, d.timeSource.AdjustedTime().Unix())
 	d.setProcessingProposal(proposal)
 	return nil, false, true
 }
@@ -95,6 +103,15 @@ func (d *Dispatcher) SetProposalProcessFinished() {
 	d.proposalProcessFinished = true
 }
 
+// RewindFinishedHeight moves the finished height back to height when the
+// blocks after it were rewound, so the blocks replacing them finish the
+// consensus on their heights again.
+func (d *Dispatcher) RewindFinishedHeight(height uint64) {
+	if height < d.finishedHeight {
+		d.finishedHeight = height
+	}
+}
+
 func (d *Dispatcher) GetFinishedBlockSealHash() common.Uint256 {
 	return d.finishedBlockSealHash
 }
@@ -165,6 +182,8 @@ func (d *Dispatcher) processVote(vote *payload.DPOSProposalVote) (succeed bool,
 	if err := CheckVote(vote); err != nil {
 		return false, false, err
 	}
+	d.detectIllegalVote(vote, proposal)
+	d.stats.addVote(vote.Signer, d.timeSource.AdjustedTime().Unix())
 
 	if vote.Accept {
 		d.acceptVotes[vote.Hash()] = vote
@@ -192,6 +211,46 @@ func (d *Dispatcher) processVote(vote *payload.DPOSProposalVote) (succeed bool,
 	return true, false, nil
 }
 
+// detectIllegalProposal notifies ETIllegalProposals if the sponsor already
+// signed a different proposal at the same height and view offset.
+func (d *Dispatcher) detectIllegalProposal(proposal *payload.DPOSProposal) {
+	height := d.finishedHeight + 1
+	d.equivocationMu.Lock()
+	signed := d.equivocations.addProposal(height, proposal)
+	d.equivocationMu.Unlock()
+	if signed == nil {
+		return
+	}
+	Warn("[detectIllegalProposal] sponsor signed conflicting proposals", "sponsor", common.BytesToHexString(proposal.Sponsor),
+		"height", height, "viewOffset", proposal.ViewOffset, "proposal", signed.Hash().String(), "compare", proposal.Hash().String())
+	go events.Notify(ETIllegalProposals, &IllegalProposalsEvent{
+		Height:          height,
+		Proposal:        signed,
+		CompareProposal: proposal,
+	})
+}
+
+// detectIllegalVote notifies ETIllegalVotes if the signer already voted
+// differently for a proposal of the same sponsor at the same height and view offset.
+func (d *Dispatcher) detectIllegalVote(vote *payload.DPOSProposalVote, proposal *payload.DPOSProposal) {
+	height := d.finishedHeight + 1
+	d.equivocationMu.Lock()
+	signed := d.equivocations.addVote(height, vote, proposal)
+	d.equivocationMu.Unlock()
+	if signed == nil {
+		return
+	}
+	Warn("[detectIllegalVote] producer signed conflicting votes", "signer", common.BytesToHexString(vote.Signer),
+		"height", height, "viewOffset", proposal.ViewOffset, "vote", signed.vote.Hash().String(), "compare", vote.Hash().String())
+	go events.Notify(ETIllegalVotes, &IllegalVotesEvent{
+		Height:          height,
+		Vote:            signed.vote,
+		Proposal:        signed.proposal,
+		CompareVote:     vote,
+		CompareProposal: proposal,
+	})
+}
+
 func (d *Dispatcher) FinishedProposal(height uint64, sealHash common.Uint256,
 	headerTime uint64) {
 	Info("FinishedProposal")
@@ -199,8 +258,12 @@ func (d *Dispatcher) FinishedProposal(height uint64, sealHash common.Uint256,
 		Warn("FinishedProposal received fork block", "height", height)
 		return
 	}
+	d.recordProducerStats(height, sealHash)
 	d.finishedHeight = height
 	d.finishedBlockSealHash = sealHash
+	d.equivocationMu.Lock()
+	d.equivocations.prune(height + 1)
+	d.equivocationMu.Unlock()
 	if d.processingProposal != nil {
 		d.finishedProposal = d.processingProposal.Hash()
 	}
@@ -214,6 +277,54 @@ func (d *Dispatcher) FinishedProposal(height uint64, sealHash common.Uint256,
 	d.resetViewMu.Unlock()
 }
 
+// recordProducerStats adds the finalized height to the producer statistics,
+// if the dispatcher processed the proposal of the sealed block.
+func (d *Dispatcher) recordProducerStats(height uint64, sealHash common.Uint256) {
+	proposal := d.GetProcessingProposal()
+	if proposal == nil || !proposal.BlockHash.IsEqual(sealHash) {
+		d.stats.record(nil)
+		return
+	}
+	stats := &HeightStats{
+		Height:   height,
+		Proposal: proposal.Hash(),
+		Skipped:  d.consensusView.GetSkippedProducers(proposal.ViewOffset),
+		Proposed: d.stats.proposedSponsors(),
+	}
+	voted := make(map[string]struct{})
+	d.mu.RLock()
+	for _, vote := range d.acceptVotes {
+		stats.Accepted = append(stats.Accepted, vote.Signer)
+		voted[common.BytesToHexString(vote.Signer)] = struct{}{}
+	}
+	for _, vote := range d.rejectedVotes {
+		stats.Rejected = append(stats.Rejected, vote.Signer)
+		voted[common.BytesToHexString(vote.Signer)] = struct{}{}
+	}
+	d.mu.RUnlock()
+	for _, producer := range d.consensusView.GetProducers() {
+		if _, ok := voted[common.BytesToHexString(producer)]; !ok && len(producer) > 0 {
+			stats.Absent = append(stats.Absent, producer)
+		}
+	}
+	d.stats.record(stats)
+}
+
+// RecordVote records a vote in the producer statistics. Votes that come after
+// the confirm or before their proposal never reach ProcessVote, and neither
+// does the own vote of the producer, so every vote received goes through it.
+func (d *Dispatcher) RecordVote(vote *payload.DPOSProposalVote) {
+	if !d.consensusView.IsProducers(vote.Signer) || CheckVote(vote) != nil {
+		return
+	}
+	d.stats.addUncountedVote(vote.ProposalHash, vote.Signer, vote.Accept, d.timeSource.AdjustedTime().Unix())
+}
+
+// GetProducerStats returns the liveness statistics of the producers.
+func (d *Dispatcher) GetProducerStats() *ProducerStats {
+	return d.stats
+}
+
 func (d *Dispatcher) ResetConsensus(height uint64) {
 	Info("[resetConsensus] start", "d.consensusView.IsRunning()", d.consensusView.IsRunning())
 	defer Info("[resetConsensus] end")
@@ -223,6 +334,10 @@ func (d *Dispatcher) ResetConsensus(height uint64) {
 		d.consensusView.SetReady()
 		d.CleanProposals(false)
 		d.consensusView.resetViewOffset()
+		// view offsets start over, so the signed slots can be reused
+		d.equivocationMu.Lock()
+		d.equivocations.reset()
+		d.equivocationMu.Unlock()
 		d.consensusView.UpdateDutyIndex(height)
 		d.consensusView.ChangeView(d.timeSource.AdjustedTime(), true, uint64(d.timeSource.AdjustedTime().Unix()))
 	}
@@ -518,17 +633,19 @@ func (d *Dispatcher) GetNowTime() time.Time {
 }
 
 func NewDispatcher(producers [][]byte, onConfirm func(confirm *payload.Confirm) error,
-	unConfirm func(confirm *payload.Confirm) error, checkBPosFullVoteFork func(count int) bool, tolerance time.Duration, publicKey []byte,
+	unConfirm func(confirm *payload.Confirm) error, checkBPosFullVoteFork func(count int) bool, viewInterval func(height uint64) time.Duration, publicKey []byte,
 	medianTime dtime.MedianTimeSource, viewListener ViewListener, dposStartHeight uint64) *Dispatcher {
 	return &Dispatcher{
 		acceptVotes:           make(map[common.Uint256]*payload.DPOSProposalVote),
 		rejectedVotes:         make(map[common.Uint256]*payload.DPOSProposalVote),
 		pendingVotes:          make(map[common.Uint256]*payload.DPOSProposalVote),
 		precociousProposals:   make(map[common.Uint256]*payload.DPOSProposal),
-		consensusView:         NewConsensusView(tolerance, publicKey, NewProducers(producers, dposStartHeight), viewListener),
+		consensusView:         NewConsensusView(viewInterval, publicKey, NewProducers(producers, dposStartHeight), viewListener),
 		onConfirm:             onConfirm,
 		unConfirm:             unConfirm,
 		checkBPosFullVoteFork: checkBPosFullVoteFork,
 		timeSource:            medianTime,
+		equivocations:         newEquivocationWindow(),
+		stats:                 NewProducerStats(),
 	}
 }
@@ -6,7 +6,12 @@
 package dpos
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	crand "crypto/rand"
+	"crypto/sha256"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"sync"
 	"testing"
@@ -15,10 +20,12 @@ import (
 	"github.com/elastos/Elastos.ELA/account"
 	"github.com/elastos/Elastos.ELA/common"
 	"github.com/elastos/Elastos.ELA/core/types/payload"
+	"github.com/elastos/Elastos.ELA/crypto"
 	daccount "github.com/elastos/Elastos.ELA/dpos/account"
 	"github.com/elastos/Elastos.ELA/dpos/dtime"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/msg"
 	"github.com/elastos/Elastos.ELA/dpos/p2p/peer"
+	"github.com/elastos/Elastos.ELA/events"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -42,6 +49,10 @@ func getTestWallet(prvkey []byte, passphrase string) (daccount.Account, error) {
 	return daccount.New(ac), nil
 }
 
+func testViewInterval(uint64) time.Duration {
+	return 5 * time.Second
+}
+
 func getProducerList() [][]byte {
 	ac, _ := getTestWallet(key0, "node0")
 	producers := make([][]byte, 0)
@@ -68,7 +79,7 @@ func TestExampleNormalVote(t *testing.T) {
 		Info("node0 unconfirm", confirm.Proposal.BlockHash)
 		return nil
 	}
-	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, 5*time.Second, []byte{}, dtime.NewMedianTime(), nil, 0)
+	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, testViewInterval, []byte{}, dtime.NewMedianTime(), nil, 0)
 
 	// Assume that there are Node0 and Node1 in the p2p network.
 	// Node0 is sponsor, Node1 is normal producer.
@@ -89,7 +100,7 @@ func TestExampleNormalVote(t *testing.T) {
 
 	// Node0 broadcast the proposal to p2p network.
 	fmt.Println("Node0 Broadcast proposal:", proposal.Hash().String())
-	proposalch <- &msg.Proposal{*proposal}
+	proposalch <- &msg.Proposal{Proposal: *proposal}
 
 	// Build seal
 
@@ -152,7 +163,7 @@ func Node1ProcessProposal(proposal *payload.DPOSProposal) {
 		Info("node1 unconfirm", confirm.Proposal.BlockHash)
 		return nil
 	}
-	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, 5*time.Second, []byte{}, dtime.NewMedianTime(), nil, 0)
+	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, testViewInterval, []byte{}, dtime.NewMedianTime(), nil, 0)
 	node1Wallet, err := getTestWallet(key1, "node1")
 	if err != nil {
 		fmt.Println("node1 create account error:", err)
@@ -170,7 +181,7 @@ func Node1ProcessProposal(proposal *payload.DPOSProposal) {
 		fmt.Println("Vote proposal error, ", err)
 	}
 	fmt.Println("Node1 vote the proposal:", vote.Hash().String())
-	votech <- &msg.Vote{"voteMsg", *vote}
+	votech <- &msg.Vote{Command: "voteMsg", Vote: *vote}
 
 }
 
@@ -184,7 +195,7 @@ func TestProcessVote(t *testing.T) {
 	wg := &sync.WaitGroup{}
 	wg.Add(2)
 	wallet, _ := getTestWallet(key1, "node1")
-	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, 5*time.Second, []byte{}, dtime.NewMedianTime(), nil, 0)
+	dispatcher := NewDispatcher(getProducerList(), onConfirm, unconfirm, nil, testViewInterval, []byte{}, dtime.NewMedianTime(), nil, 0)
 	go func() {
 		for i := 0; i < 1000; i++ {
 			proposal, _ := StartProposal(wallet, *randomUint256(), rand.Uint32())
@@ -211,3 +222,189 @@ func TestProcessVote(t *testing.T) {
 
 	wg.Wait()
 }
+
+var (
+	illegalOnce      sync.Once
+	illegalProposals = make(chan *IllegalProposalsEvent, 8)
+	illegalVotes     = make(chan *IllegalVotesEvent, 8)
+)
+
+func subscribeIllegalEvents() {
+	illegalOnce.Do(func() {
+		events.Subscribe(func(e *events.Event) {
+			switch e.Type {
+			case ETIllegalProposals:
+				illegalProposals <- e.Data.(*IllegalProposalsEvent)
+			case ETIllegalVotes:
+				illegalVotes <- e.Data.(*IllegalVotesEvent)
+			}
+		})
+	})
+	for len(illegalProposals) > 0 {
+		<-illegalProposals
+	}
+	for len(illegalVotes) > 0 {
+		<-illegalVotes
+	}
+}
+
+// signTestData signs data the way a dpos account does.
+func signTestData(t testing.TB, prvkey []byte, data []byte) []byte {
+	curve := crypto.DefaultCurve
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(prvkey)}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(prvkey)
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(crand.Reader, key, digest[:])
+	assert.NoError(t, err)
+	signature := make([]byte, crypto.SignatureLength)
+	copy(signature[crypto.SignerLength-len(r.Bytes()):], r.Bytes())
+	copy(signature[crypto.SignatureLength-len(s.Bytes()):], s.Bytes())
+	return signature
+}
+
+func testPublicKey(t testing.TB, prvkey []byte) []byte {
+	publicKey, err := crypto.NewPubKey(prvkey).EncodePoint(true)
+	assert.NoError(t, err)
+	return publicKey
+}
+
+// onDutyTestKey returns the key of the producer on duty at the current view.
+func onDutyTestKey(t *testing.T, dispatcher *Dispatcher) []byte {
+	onDuty := dispatcher.consensusView.producers.GetNextOnDutyProducer(dispatcher.consensusView.viewOffset)
+	for _, k := range [][]byte{key0, key1} {
+		if bytes.Equal(testPublicKey(t, k), onDuty) {
+			return k
+		}
+	}
+	t.Fatal("no test key on duty")
+	return nil
+}
+
+// equivocationStep moves the dispatcher to height and view offset, then
+// processes the proposal of the on duty sponsor for block and, if vote is
+// set, the vote of node1 for it. If finish is set the height is finished
+// afterwards.
+type equivocationStep struct {
+	height     uint64
+	viewOffset uint32
+	block      byte
+	vote       bool
+	accept     bool
+	finish     bool
+}
+
+func (s equivocationStep) run(t *testing.T, dispatcher *Dispatcher) {
+	dispatcher.finishedHeight = s.height - 1
+	dispatcher.consensusView.viewOffset = s.viewOffset
+	sponsor := onDutyTestKey(t, dispatcher)
+	proposal := &payload.DPOSProposal{
+		Sponsor:    testPublicKey(t, sponsor),
+		BlockHash:  common.Uint256{s.block},
+		ViewOffset: s.viewOffset,
+	}
+	proposal.Sign = signTestData(t, sponsor, proposal.Data())
+	dispatcher.ProcessProposal(peer.PID{}, proposal)
+	if s.finish {
+		defer dispatcher.FinishedProposal(s.height, proposal.BlockHash, uint64(time.Now().Unix()))
+	}
+	if !s.vote {
+		return
+	}
+	vote := &payload.DPOSProposalVote{
+		ProposalHash: proposal.Hash(),
+		Signer:       testPublicKey(t, key1),
+		Accept:       s.accept,
+	}
+	vote.Sign = signTestData(t, key1, vote.Data())
+	dispatcher.ProcessVote(vote)
+}
+
+func newEquivocationDispatcher() *Dispatcher {
+	noop := func(confirm *payload.Confirm) error {
+		return nil
+	}
+	return NewDispatcher(getProducerList(), noop, noop, nil, testViewInterval, []byte{}, dtime.NewMedianTime(), nil, 0)
+}
+
+func TestDispatcherIllegalProposals(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []equivocationStep
+		illegal bool
+	}{
+		{"same proposal", []equivocationStep{{height: 1, block: 1}, {height: 1, block: 1}}, false},
+		{"different blocks in one view", []equivocationStep{{height: 1, block: 1}, {height: 1, block: 2}}, true},
+		{"different views", []equivocationStep{{height: 1, block: 1}, {height: 1, viewOffset: 2, block: 2}}, false},
+		{"different heights", []equivocationStep{{height: 1, block: 1}, {height: 2, block: 2}}, false},
+		{"fallen out of window", []equivocationStep{{height: 1, block: 1}, {height: 9, block: 3, finish: true}, {height: 1, block: 2}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscribeIllegalEvents()
+			dispatcher := newEquivocationDispatcher()
+			for _, step := range test.steps {
+				step.run(t, dispatcher)
+			}
+			select {
+			case evt := <-illegalProposals:
+				assert.True(t, test.illegal, "unexpected illegal proposals")
+				assert.Equal(t, uint64(1), evt.Height)
+				assert.Equal(t, evt.Proposal.Sponsor, evt.CompareProposal.Sponsor)
+				assert.Equal(t, evt.Proposal.ViewOffset, evt.CompareProposal.ViewOffset)
+				assert.NotEqual(t, evt.Proposal.Hash(), evt.CompareProposal.Hash())
+			case <-time.After(100 * time.Millisecond):
+				assert.False(t, test.illegal, "illegal proposals not detected")
+			}
+		})
+	}
+}
+
+func TestDispatcherIllegalVotes(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []equivocationStep
+		illegal bool
+	}{
+		{"same vote", []equivocationStep{
+			{height: 1, block: 1, vote: true, accept: true},
+			{height: 1, block: 1, vote: true, accept: true},
+		}, false},
+		{"accept and reject one proposal", []equivocationStep{
+			{height: 1, block: 1, vote: true, accept: true},
+			{height: 1, block: 1, vote: true, accept: false},
+		}, true},
+		{"accept two proposals in one view", []equivocationStep{
+			{height: 1, block: 1, vote: true, accept: true},
+			{height: 1, block: 2, vote: true, accept: true},
+		}, true},
+		{"different views", []equivocationStep{
+			{height: 1, block: 1, vote: true, accept: true},
+			{height: 1, viewOffset: 2, block: 2, vote: true, accept: true},
+		}, false},
+		{"different heights", []equivocationStep{
+			{height: 1, block: 1, vote: true, accept: true},
+			{height: 2, block: 2, vote: true, accept: true},
+		}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscribeIllegalEvents()
+			dispatcher := newEquivocationDispatcher()
+			for _, step := range test.steps {
+				step.run(t, dispatcher)
+			}
+			select {
+			case evt := <-illegalVotes:
+				assert.True(t, test.illegal, "unexpected illegal votes")
+				assert.Equal(t, uint64(1), evt.Height)
+				assert.Equal(t, evt.Vote.Signer, evt.CompareVote.Signer)
+				assert.Equal(t, evt.Proposal.Hash(), evt.Vote.ProposalHash)
+				assert.Equal(t, evt.CompareProposal.Hash(), evt.CompareVote.ProposalHash)
+				assert.NotEqual(t, evt.Vote.Hash(), evt.CompareVote.Hash())
+			case <-time.After(100 * time.Millisecond):
+				assert.False(t, test.illegal, "illegal votes not detected")
+			}
+		})
+	}
+}
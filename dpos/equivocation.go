@@ -0,0 +1,148 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"bytes"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+)
+
+const (
+	// equivocationWindowHeights is the number of heights, counted back from
+	// the height in consensus, whose signed proposals and votes are kept.
+	equivocationWindowHeights = 3
+
+	// maxEquivocationRecords caps the signed proposals and votes kept in
+	// the window, so view changes without progress can not grow it forever.
+	maxEquivocationRecords = 4096
+)
+
+// IllegalProposalsEvent is notified with ETIllegalProposals when a sponsor
+// signed two different proposals at the same height and view offset.
+type IllegalProposalsEvent struct {
+	Height          uint64
+	Proposal        *payload.DPOSProposal
+	CompareProposal *payload.DPOSProposal
+}
+
+// IllegalVotesEvent is notified with ETIllegalVotes when a producer signed two
+// different votes for the proposals of one sponsor at the same height and
+// view offset. Proposal and CompareProposal are the proposals voted for.
+type IllegalVotesEvent struct {
+	Height          uint64
+	Vote            *payload.DPOSProposalVote
+	Proposal        *payload.DPOSProposal
+	CompareVote     *payload.DPOSProposalVote
+	CompareProposal *payload.DPOSProposal
+}
+
+// equivocationKey identifies one signing slot, a producer may sign only one
+// proposal and one vote per height and view offset.
+type equivocationKey struct {
+	height     uint64
+	viewOffset uint32
+	signer     string
+}
+
+type signedVote struct {
+	vote     *payload.DPOSProposalVote
+	proposal *payload.DPOSProposal
+}
+
+// equivocationWindow remembers the proposals and votes signed by every
+// producer over the last heights.
+type equivocationWindow struct {
+	proposals map[equivocationKey]*payload.DPOSProposal
+	votes     map[equivocationKey]signedVote
+}
+
+func newEquivocationWindow() *equivocationWindow {
+	return &equivocationWindow{
+		proposals: make(map[equivocationKey]*payload.DPOSProposal),
+		votes:     make(map[equivocationKey]signedVote),
+	}
+}
+
+// addProposal records a verified proposal and returns the proposal its
+// sponsor signed before in the same slot, if the two differ.
+func (w *equivocationWindow) addProposal(height uint64, proposal *payload.DPOSProposal) *payload.DPOSProposal {
+	key := equivocationKey{
+		height:     height,
+		viewOffset: proposal.ViewOffset,
+		signer:     common.BytesToHexString(proposal.Sponsor),
+	}
+	if signed, ok := w.proposals[key]; ok {
+		if signed.Hash().IsEqual(proposal.Hash()) {
+			return nil
+		}
+		return signed
+	}
+	w.limit()
+	w.proposals[key] = proposal
+	return nil
+}
+
+// addVote records a verified vote for proposal and returns the vote its
+// signer cast before in the same slot, if the two differ.
+func (w *equivocationWindow) addVote(height uint64, vote *payload.DPOSProposalVote,
+	proposal *payload.DPOSProposal) *signedVote {
+	key := equivocationKey{
+		height:     height,
+		viewOffset: proposal.ViewOffset,
+		signer:     common.BytesToHexString(vote.Signer),
+	}
+	if signed, ok := w.votes[key]; ok {
+		if signed.vote.Hash().IsEqual(vote.Hash()) ||
+			!bytes.Equal(signed.proposal.Sponsor, proposal.Sponsor) {
+			return nil
+		}
+		return &signed
+	}
+	w.limit()
+	w.votes[key] = signedVote{vote: vote, proposal: proposal}
+	return nil
+}
+
+// prune drops the records that fell out of the window ending at height.
+func (w *equivocationWindow) prune(height uint64) {
+	if height < equivocationWindowHeights {
+		return
+	}
+	oldest := height - equivocationWindowHeights + 1
+	for key := range w.proposals {
+		if key.height < oldest {
+			delete(w.proposals, key)
+		}
+	}
+	for key := range w.votes {
+		if key.height < oldest {
+			delete(w.votes, key)
+		}
+	}
+}
+
+// limit evicts a random record if adding one more would overflow
+// maxEquivocationRecords.
+func (w *equivocationWindow) limit() {
+	if len(w.proposals)+len(w.votes)+1 <= maxEquivocationRecords {
+		return
+	}
+	for key := range w.votes {
+		delete(w.votes, key)
+		return
+	}
+	for key := range w.proposals {
+		delete(w.proposals, key)
+		return
+	}
+}
+
+func (w *equivocationWindow) reset() {
+	w.proposals = make(map[equivocationKey]*payload.DPOSProposal)
+	w.votes = make(map[equivocationKey]signedVote)
+}
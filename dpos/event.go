@@ -20,4 +20,6 @@ const (
 	ETFailedWithdrawTx events.EventType = 1008
 	ETUpdateProducers  events.EventType = 1009
 	ETOnDutyEvent      events.EventType = 1010
+	ETIllegalProposals events.EventType = 1011
+	ETIllegalVotes     events.EventType = 1012
 )
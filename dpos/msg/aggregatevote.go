@@ -0,0 +1,62 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package msg
+
+import (
+	"io"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/p2p"
+)
+
+// Ensure AggregateVote implement p2p.Message interface.
+var _ p2p.Message = (*AggregateVote)(nil)
+
+// AggregateVote carries the BLS signature of a producer accepting a
+// proposal, the sponsor aggregates them into the confirm of its block.
+type AggregateVote struct {
+	ProposalHash common.Uint256
+	Signer       []byte
+	Signature    []byte
+}
+
+func NewAggregateVote(proposalHash common.Uint256, signer []byte, signature []byte) *AggregateVote {
+	return &AggregateVote{
+		ProposalHash: proposalHash,
+		Signer:       signer,
+		Signature:    signature,
+	}
+}
+
+func (msg *AggregateVote) CMD() string {
+	return CmdAggregateVote
+}
+
+func (msg *AggregateVote) MaxLength() uint32 {
+	return 32 + 1 + 33 + 3 + 192
+}
+
+func (msg *AggregateVote) Serialize(w io.Writer) error {
+	if err := msg.ProposalHash.Serialize(w); err != nil {
+		return err
+	}
+	if err := common.WriteVarBytes(w, msg.Signer); err != nil {
+		return err
+	}
+	return common.WriteVarBytes(w, msg.Signature)
+}
+
+func (msg *AggregateVote) Deserialize(r io.Reader) error {
+	if err := msg.ProposalHash.Deserialize(r); err != nil {
+		return err
+	}
+	var err error
+	if msg.Signer, err = common.ReadVarBytes(r, 33, "signer"); err != nil {
+		return err
+	}
+	msg.Signature, err = common.ReadVarBytes(r, 192, "signature")
+	return err
+}
@@ -9,4 +9,5 @@ const (
 	CmdConfirm  = "confirm"
 	CmdSmallCroTx  = "smallCroTx"
 	CmdFailedWithdrawTx  = "failedWTx"
+	CmdAggregateVote  = "aggVote"
 )
\ No newline at end of file
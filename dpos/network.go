@@ -54,10 +54,18 @@ type DPOSNetwork interface {
 	SendMessageToPeer(id dpeer.PID, msg elap2p.Message) error
 	BroadcastMessage(msg elap2p.Message)
 
-	UpdatePeers(peers []dpeer.PID)
+	UpdatePeers(currentPeers []dpeer.PID, nextPeers []dpeer.PID)
 	GetActivePeers() []p2p.Peer
+	DumpPeersInfo() []*p2p.PeerInfo
+	AddDirectLinkAddr(pid dpeer.PID, addr string)
+
+	PostChangeViewTask()
+	PostRecoverTask()
 }
 
+// Ensure the Network type implements the DPOSNetwork interface.
+var _ DPOSNetwork = (*Network)(nil)
+
 type StatusSyncEventListener interface {
 	OnPing(id dpeer.PID, height uint32)
 	OnPong(id dpeer.PID, height uint32)
@@ -87,6 +95,7 @@ type NetworkEventListener interface {
 
 	OnBlockReceived(id dpeer.PID, b *dmsg.BlockMsg, confirmed bool)
 	OnConfirmReceived(id dpeer.PID, c *payload.Confirm, height uint64)
+	OnAggregateVoteReceived(id dpeer.PID, v *dmsg.AggregateVote)
 
 	OnSmallCroTxReceived(id dpeer.PID, c *dmsg.SmallCroTx)
 	OnFailedWithdrawTxReceived(id dpeer.PID, c *dmsg.FailedWithdrawTx)
@@ -166,127 +175,137 @@ func (n *Network) PostRecoverTask() {
 }
 
 func (n *Network) processMessage(msgItem *messageItem) {
-	m := msgItem.Message
+	DispatchMessage(n.listener, msgItem.ID, msgItem.Message)
+}
+
+// DispatchMessage hands a message received from peer id to the matching
+// callback of listener.
+func DispatchMessage(listener NetworkEventListener, id peer.PID, m elap2p.Message) {
 	switch m.CMD() {
 	case msg.CmdReceivedProposal:
 		msgProposal, processed := m.(*msg.Proposal)
 		if processed {
-			n.listener.OnProposalReceived(msgItem.ID, &msgProposal.Proposal)
+			listener.OnProposalReceived(id, &msgProposal.Proposal)
 		}
 	case msg.CmdAcceptVote:
 		msgVote, processed := m.(*msg.Vote)
 		if processed {
-			n.listener.OnVoteAccepted(msgItem.ID, &msgVote.Vote)
+			listener.OnVoteAccepted(id, &msgVote.Vote)
 		}
 	case msg.CmdRejectVote:
 		msgVote, processed := m.(*msg.Vote)
 		if processed {
-			n.listener.OnVoteRejected(msgItem.ID, &msgVote.Vote)
+			listener.OnVoteRejected(id, &msgVote.Vote)
 		}
 	case msg.CmdPing:
 		msgPing, processed := m.(*msg.Ping)
 		if processed {
-			n.listener.OnPing(msgItem.ID, uint32(msgPing.Nonce))
+			listener.OnPing(id, uint32(msgPing.Nonce))
 		}
 	case msg.CmdPong:
 		msgPong, processed := m.(*msg.Pong)
 		if processed {
-			n.listener.OnPong(msgItem.ID, uint32(msgPong.Nonce))
+			listener.OnPong(id, uint32(msgPong.Nonce))
 		}
 	case elap2p.CmdBlock:
 		blockMsg, processed := m.(*dmsg.BlockMsg)
 		if processed {
-			n.listener.OnBlock(msgItem.ID, blockMsg)
+			listener.OnBlock(id, blockMsg)
 		}
 	case msg.CmdInv:
 		msgInv, processed := m.(*msg.Inventory)
 		if processed {
-			n.listener.OnInv(msgItem.ID, msgInv.BlockHash)
+			listener.OnInv(id, msgInv.BlockHash)
 		}
 	case msg.CmdGetBlock:
 		msgGetBlock, processed := m.(*msg.GetBlock)
 		if processed {
-			n.listener.OnGetBlock(msgItem.ID, msgGetBlock.BlockHash)
+			listener.OnGetBlock(id, msgGetBlock.BlockHash)
 		}
 	case msg.CmdGetBlocks:
 		msgGetBlocks, processed := m.(*msg.GetBlocks)
 		if processed {
-			n.listener.OnGetBlocks(msgItem.ID, msgGetBlocks.StartBlockHeight, msgGetBlocks.EndBlockHeight)
+			listener.OnGetBlocks(id, msgGetBlocks.StartBlockHeight, msgGetBlocks.EndBlockHeight)
 		}
 	case msg.CmdResponseBlocks:
 		//msgResponseBlocks, processed := m.(*dmsg.BlockMsg)
 		//if processed {
-		//n.listener.OnResponseBlocks(msgItem.ID, msgResponseBlocks)
+		//listener.OnResponseBlocks(id, msgResponseBlocks)
 		//}
 	case msg.CmdRequestConsensus:
 		msgRequestConsensus, processed := m.(*dmsg.RequestConsensus)
 		if processed {
-			n.listener.OnRequestConsensus(msgItem.ID, msgRequestConsensus.Height)
+			listener.OnRequestConsensus(id, msgRequestConsensus.Height)
 		}
 	case msg.CmdResponseConsensus:
 		msgResponseConsensus, processed := m.(*dmsg.ResponseConsensus)
 		if processed {
-			n.listener.OnResponseConsensus(msgItem.ID, &msgResponseConsensus.Consensus)
+			listener.OnResponseConsensus(id, &msgResponseConsensus.Consensus)
 		}
 	case msg.CmdRequestProposal:
 		msgRequestProposal, processed := m.(*msg.RequestProposal)
 		if processed {
-			n.listener.OnRequestProposal(msgItem.ID, msgRequestProposal.ProposalHash)
+			listener.OnRequestProposal(id, msgRequestProposal.ProposalHash)
 		}
 	case msg.CmdIllegalProposals:
 		msgIllegalProposals, processed := m.(*msg.IllegalProposals)
 		if processed {
-			n.listener.OnIllegalProposalReceived(msgItem.ID, &msgIllegalProposals.Proposals)
+			listener.OnIllegalProposalReceived(id, &msgIllegalProposals.Proposals)
 		}
 	case msg.CmdIllegalVotes:
 		msgIllegalVotes, processed := m.(*msg.IllegalVotes)
 		if processed {
-			n.listener.OnIllegalVotesReceived(msgItem.ID, &msgIllegalVotes.Votes)
+			listener.OnIllegalVotesReceived(id, &msgIllegalVotes.Votes)
 		}
 	case dmsg.CmdConfirm:
 		msgConfirm, processed := m.(*dmsg.ConfirmMsg)
 		if processed {
-			n.listener.OnConfirmReceived(msgItem.ID, msgConfirm.Confirm, msgConfirm.Height)
+			listener.OnConfirmReceived(id, msgConfirm.Confirm, msgConfirm.Height)
+		}
+	case dmsg.CmdAggregateVote:
+		msgVote, processed := m.(*dmsg.AggregateVote)
+		if processed {
+			listener.OnAggregateVoteReceived(id, msgVote)
 		}
 	case dmsg.CmdSmallCroTx:
 		msgCro, processed := m.(*dmsg.SmallCroTx)
 		if processed {
-			n.listener.OnSmallCroTxReceived(msgItem.ID, msgCro)
+			listener.OnSmallCroTxReceived(id, msgCro)
 		}
 	case dmsg.CmdFailedWithdrawTx:
 		withdrawTx, processed := m.(*dmsg.FailedWithdrawTx)
 		if processed {
-			n.listener.OnFailedWithdrawTxReceived(msgItem.ID, withdrawTx)
+			listener.OnFailedWithdrawTxReceived(id, withdrawTx)
 		}
 	case dpos_msg.CmdDArbiter:
 		msg, processed := m.(*dpos_msg.DArbiter)
 		if processed {
-			n.listener.OnLayer2Msg(msgItem.ID, msg)
+			listener.OnLayer2Msg(id, msg)
 		}
 	case dpos_msg.CmdRequireArbiters:
 		msg, processed := m.(*dpos_msg.RequireArbiter)
 		if processed {
-			n.listener.OnLayer2Msg(msgItem.ID, msg)
+			listener.OnLayer2Msg(id, msg)
 		}
 	case dpos_msg.CmdRequireArbitersSignature:
 		msg, processed := m.(*dpos_msg.RequireArbitersSignature)
 		if processed {
-			n.listener.OnLayer2Msg(msgItem.ID, msg)
+			listener.OnLayer2Msg(id, msg)
 		}
 	case dpos_msg.CmdFeedbackArbiterSignature:
 		msg, processed := m.(*dpos_msg.FeedBackArbitersSignature)
 		if processed {
-			n.listener.OnLayer2Msg(msgItem.ID, msg)
+			listener.OnLayer2Msg(id, msg)
 		}
 	case msg.CmdResetConsensusView:
 		msgI, processed := m.(*msg.ResetView)
 		if processed {
-			n.listener.OnResponseResetViewReceived(msgI)
+			listener.OnResponseResetViewReceived(msgI)
 		}
 	case dpos_msg.CmdProducers:
 		msgI, processed := m.(*dmsg.ProducersMsg)
 		if processed {
-			n.listener.OnProducersMsg(msgI)
+			listener.OnProducersMsg(msgI)
 		}
 	}
 }
@@ -395,7 +414,17 @@ func NewNetwork(cfg *NetworkConfig) (*Network, error) {
 }
 
 func createMessage(hdr elap2p.Header, r net.Conn) (message elap2p.Message, err error) {
-	switch hdr.GetCMD() {
+	message, err = NewMessage(hdr.GetCMD())
+	if err != nil {
+		return nil, err
+	}
+	return peer2.CheckAndCreateMessage(hdr, message, r)
+}
+
+// NewMessage returns an empty message of the command cmd to deserialize a
+// received message into.
+func NewMessage(cmd string) (message elap2p.Message, err error) {
+	switch cmd {
 	case elap2p.CmdBlock:
 		message = dmsg.NewBlockMsg([]byte{})
 	case msg.CmdAcceptVote:
@@ -428,6 +457,8 @@ func createMessage(hdr elap2p.Header, r net.Conn) (message elap2p.Message, err e
 		message = &msg.ResponseInactiveArbitrators{}
 	case dmsg.CmdConfirm:
 		message = &dmsg.ConfirmMsg{}
+	case dmsg.CmdAggregateVote:
+		message = &dmsg.AggregateVote{}
 	case dmsg.CmdSmallCroTx:
 		message = &dmsg.SmallCroTx{}
 	case dmsg.CmdFailedWithdrawTx:
@@ -445,7 +476,7 @@ func createMessage(hdr elap2p.Header, r net.Conn) (message elap2p.Message, err e
 	case dpos_msg.CmdProducers:
 		message = &dmsg.ProducersMsg{}
 	default:
-		return nil, errors.New("Received unsupported message, CMD " + hdr.GetCMD())
+		return nil, errors.New("Received unsupported message, CMD " + cmd)
 	}
-	return peer2.CheckAndCreateMessage(hdr, message, r)
+	return message, nil
 }
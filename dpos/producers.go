@@ -56,19 +56,31 @@ func (p *Producers) UpdateProducers(producers [][]byte, totalCount int, spvHeigh
 func (p *Producers) ChangeCurrentProducers(changeHeight uint64, spvHeight uint64) {
 	p.mtx.Lock()
 	defer p.mtx.Unlock()
-	p.producers = make([][]byte, len(p.nextProducers))
+	p.producers = p.nextProducerList()
+	p.SetWorkingHeight(changeHeight)
+	p.totalProducers = p.nextTotalProducers
+	p.spvHeight = spvHeight
 
+}
+
+// GetNextProducers returns the next turn producers and their total count as
+// they become the current producers on change.
+func (p *Producers) GetNextProducers() ([][]byte, int) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return p.nextProducerList(), p.nextTotalProducers
+}
+
+func (p *Producers) nextProducerList() [][]byte {
+	producers := make([][]byte, len(p.nextProducers))
 	for i, signer := range p.nextProducers {
 		if bytes.Equal(signer[:], zero) {
 			continue
 		}
-		p.producers[i] = make([]byte, len(signer))
-		copy(p.producers[i][:], signer[:])
+		producers[i] = make([]byte, len(signer))
+		copy(producers[i][:], signer[:])
 	}
-	p.SetWorkingHeight(changeHeight)
-	p.totalProducers = p.nextTotalProducers
-	p.spvHeight = spvHeight
-
+	return producers
 }
 
 func (p *Producers) SetWorkingHeight(changeHeight uint64) {
@@ -0,0 +1,318 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA/common"
+
+	"github.com/pgprotocol/pgp-chain/metrics"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+// producerStatsWindow is the number of finalized heights the producer
+// statistics cover.
+const producerStatsWindow = 1024
+
+// HeightStats is what one finalized height tells about the producers.
+type HeightStats struct {
+	Height   uint64
+	Proposal common.Uint256 // Hash of the confirmed proposal
+	Skipped  [][]byte       // Producers on duty at the views changed before the confirmed one
+	Proposed [][]byte       // Sponsors of the proposals seen at the height
+	Accepted [][]byte       // Producers that accepted the confirmed proposal
+	Rejected [][]byte       // Producers that rejected the confirmed proposal
+	Absent   [][]byte       // Producers that did not vote for the confirmed proposal
+}
+
+// ProducerStat sums the heights of the window up for one producer.
+type ProducerStat struct {
+	Producer          string `json:"producer"`
+	ProposalsMade     uint64 `json:"proposalsMade"`
+	ProposalsMissed   uint64 `json:"proposalsMissed"`
+	ViewChangesCaused uint64 `json:"viewChangesCaused"`
+	VotesAccepted     uint64 `json:"votesAccepted"`
+	VotesRejected     uint64 `json:"votesRejected"`
+	VotesAbsent       uint64 `json:"votesAbsent"`
+	LastSeen          int64  `json:"lastSeen"`
+}
+
+// ProducerStatsReport is the statistics of the producers over the finalized
+// heights from FromHeight to ToHeight.
+type ProducerStatsReport struct {
+	FromHeight uint64          `json:"fromHeight"`
+	ToHeight   uint64          `json:"toHeight"`
+	Producers  []*ProducerStat `json:"producers"`
+}
+
+// lastSeenRecord is the last seen time of a producer as it is stored on disk.
+type lastSeenRecord struct {
+	Producer []byte
+	Time     uint64
+}
+
+// producerStatsRecord is the statistics as they are stored on disk.
+type producerStatsRecord struct {
+	Heights  []*HeightStats
+	LastSeen []lastSeenRecord
+}
+
+// ProducerStats keeps the statistics of the producers over a rolling window
+// of finalized heights, and publishes them to the metrics registry.
+type ProducerStats struct {
+	mu        sync.Mutex
+	heights   []*HeightStats
+	totals    map[string]*ProducerStat
+	lastSeen  map[string]int64
+	proposed  map[string][]byte                  // Sponsors of the proposals seen at the height in consensus
+	uncounted map[common.Uint256]map[string]bool // Votes not processed by the dispatcher at the height in consensus
+}
+
+func NewProducerStats() *ProducerStats {
+	return &ProducerStats{
+		totals:    make(map[string]*ProducerStat),
+		lastSeen:  make(map[string]int64),
+		proposed:  make(map[string][]byte),
+		uncounted: make(map[common.Uint256]map[string]bool),
+	}
+}
+
+// addProposal records a verified proposal of sponsor at the height in consensus.
+func (s *ProducerStats) addProposal(sponsor []byte, now int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := common.BytesToHexString(sponsor)
+	s.proposed[key] = sponsor
+	s.seen(key, now)
+}
+
+// addVote records a verified vote of signer.
+func (s *ProducerStats) addVote(signer []byte, now int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen(common.BytesToHexString(signer), now)
+}
+
+func (s *ProducerStats) seen(key string, now int64) {
+	if now > s.lastSeen[key] {
+		s.lastSeen[key] = now
+	}
+	metrics.GetOrRegisterGauge("pbft/producers/"+key+"/lastseen", nil).Update(now)
+}
+
+// addUncountedVote records a verified vote the dispatcher did not process,
+// like the own vote of a producer or a vote that came after the confirm, so
+// its signer does not count as absent.
+func (s *ProducerStats) addUncountedVote(proposal common.Uint256, signer []byte, accept bool, now int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := common.BytesToHexString(signer)
+	s.seen(key, now)
+	if len(s.heights) > 0 && s.heights[len(s.heights)-1].Proposal.IsEqual(proposal) {
+		last := s.heights[len(s.heights)-1]
+		s.add(last, true)
+		s.vote(last, key, accept)
+		s.add(last, false)
+		updateProducerMetrics(key, s.totals[key])
+		return
+	}
+	if s.uncounted[proposal] == nil {
+		s.uncounted[proposal] = make(map[string]bool)
+	}
+	s.uncounted[proposal][key] = accept
+}
+
+// vote moves the producer of key from the absent producers of the height to
+// the ones that voted.
+func (s *ProducerStats) vote(stats *HeightStats, key string, accept bool) {
+	for i, producer := range stats.Absent {
+		if common.BytesToHexString(producer) != key {
+			continue
+		}
+		stats.Absent = append(stats.Absent[:i:i], stats.Absent[i+1:]...)
+		if accept {
+			stats.Accepted = append(stats.Accepted, producer)
+		} else {
+			stats.Rejected = append(stats.Rejected, producer)
+		}
+		return
+	}
+}
+
+// proposedSponsors returns the sponsors of the proposals seen at the height in
+// consensus.
+func (s *ProducerStats) proposedSponsors() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sponsors := make([][]byte, 0, len(s.proposed))
+	for _, sponsor := range s.proposed {
+		sponsors = append(sponsors, sponsor)
+	}
+	return sponsors
+}
+
+// record adds the finalized height to the window, dropping the oldest height
+// once the window is full. A nil stats only starts the next height.
+func (s *ProducerStats) record(stats *HeightStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uncounted := s.uncounted
+	s.proposed = make(map[string][]byte)
+	s.uncounted = make(map[common.Uint256]map[string]bool)
+	if stats == nil {
+		return
+	}
+	for key, accept := range uncounted[stats.Proposal] {
+		s.vote(stats, key, accept)
+	}
+	s.add(stats, false)
+	s.heights = append(s.heights, stats)
+	if len(s.heights) > producerStatsWindow {
+		s.add(s.heights[0], true)
+		s.heights = s.heights[1:]
+	}
+	for key, stat := range s.totals {
+		updateProducerMetrics(key, stat)
+	}
+}
+
+// add adds the height to the totals of its producers, or removes it from
+// them once it leaves the window.
+func (s *ProducerStats) add(stats *HeightStats, remove bool) {
+	count := func(producers [][]byte, field func(stat *ProducerStat) *uint64) {
+		for _, producer := range producers {
+			if len(producer) == 0 {
+				continue
+			}
+			key := common.BytesToHexString(producer)
+			stat, ok := s.totals[key]
+			if !ok {
+				stat = &ProducerStat{Producer: key}
+				s.totals[key] = stat
+			}
+			if remove {
+				*field(stat)--
+			} else {
+				*field(stat)++
+			}
+		}
+	}
+	proposed := make(map[string]struct{}, len(stats.Proposed))
+	for _, sponsor := range stats.Proposed {
+		proposed[common.BytesToHexString(sponsor)] = struct{}{}
+	}
+	missed := make([][]byte, 0, len(stats.Skipped))
+	for _, producer := range stats.Skipped {
+		if _, ok := proposed[common.BytesToHexString(producer)]; !ok {
+			missed = append(missed, producer)
+		}
+	}
+	count(stats.Proposed, func(stat *ProducerStat) *uint64 { return &stat.ProposalsMade })
+	count(missed, func(stat *ProducerStat) *uint64 { return &stat.ProposalsMissed })
+	count(stats.Skipped, func(stat *ProducerStat) *uint64 { return &stat.ViewChangesCaused })
+	count(stats.Accepted, func(stat *ProducerStat) *uint64 { return &stat.VotesAccepted })
+	count(stats.Rejected, func(stat *ProducerStat) *uint64 { return &stat.VotesRejected })
+	count(stats.Absent, func(stat *ProducerStat) *uint64 { return &stat.VotesAbsent })
+}
+
+func updateProducerMetrics(key string, stat *ProducerStat) {
+	prefix := "pbft/producers/" + key + "/"
+	metrics.GetOrRegisterGauge(prefix+"proposals/made", nil).Update(int64(stat.ProposalsMade))
+	metrics.GetOrRegisterGauge(prefix+"proposals/missed", nil).Update(int64(stat.ProposalsMissed))
+	metrics.GetOrRegisterGauge(prefix+"viewchanges", nil).Update(int64(stat.ViewChangesCaused))
+	metrics.GetOrRegisterGauge(prefix+"votes/accepted", nil).Update(int64(stat.VotesAccepted))
+	metrics.GetOrRegisterGauge(prefix+"votes/rejected", nil).Update(int64(stat.VotesRejected))
+	metrics.GetOrRegisterGauge(prefix+"votes/absent", nil).Update(int64(stat.VotesAbsent))
+}
+
+// Report returns the statistics of producers over the window, producers
+// that never showed up in it are reported with zero counts.
+func (s *ProducerStats) Report(producers [][]byte) *ProducerStatsReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := &ProducerStatsReport{Producers: make([]*ProducerStat, 0, len(producers))}
+	if len(s.heights) > 0 {
+		report.FromHeight = s.heights[0].Height
+		report.ToHeight = s.heights[len(s.heights)-1].Height
+	}
+	for _, producer := range producers {
+		if len(producer) == 0 {
+			continue
+		}
+		key := common.BytesToHexString(producer)
+		stat := &ProducerStat{Producer: key}
+		if total, ok := s.totals[key]; ok {
+			*stat = *total
+		}
+		stat.LastSeen = s.lastSeen[key]
+		report.Producers = append(report.Producers, stat)
+	}
+	sort.Slice(report.Producers, func(i, j int) bool {
+		return report.Producers[i].Producer < report.Producers[j].Producer
+	})
+	return report
+}
+
+// Load replaces the statistics with the ones saved at path, if any.
+func (s *ProducerStats) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var record producerStatsRecord
+	if err := rlp.DecodeBytes(data, &record); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.heights, s.totals = nil, make(map[string]*ProducerStat)
+	for _, stats := range record.Heights {
+		s.add(stats, false)
+		s.heights = append(s.heights, stats)
+	}
+	if len(s.heights) > producerStatsWindow {
+		for _, stats := range s.heights[:len(s.heights)-producerStatsWindow] {
+			s.add(stats, true)
+		}
+		s.heights = s.heights[len(s.heights)-producerStatsWindow:]
+	}
+	for _, seen := range record.LastSeen {
+		s.lastSeen[common.BytesToHexString(seen.Producer)] = int64(seen.Time)
+	}
+	return nil
+}
+
+// Save writes the statistics to path, replacing the ones saved before.
+func (s *ProducerStats) Save(path string) error {
+	s.mu.Lock()
+	record := producerStatsRecord{Heights: s.heights}
+	for key, seen := range s.lastSeen {
+		producer, err := common.HexStringToBytes(key)
+		if err != nil {
+			continue
+		}
+		record.LastSeen = append(record.LastSeen, lastSeenRecord{Producer: producer, Time: uint64(seen)})
+	}
+	data, err := rlp.EncodeToBytes(&record)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
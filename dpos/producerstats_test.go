@@ -0,0 +1,103 @@
+// Copyright (c) 2017-2019 The Elastos Foundation
+// Use of this source code is governed by an MIT
+// license that can be found in the LICENSE file.
+//
+
+package dpos
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func statOf(report *ProducerStatsReport, producer []byte) *ProducerStat {
+	for _, stat := range report.Producers {
+		if stat.Producer == common.BytesToHexString(producer) {
+			return stat
+		}
+	}
+	return nil
+}
+
+func TestProducerStats(t *testing.T) {
+	producers := getRandProducers()[:4]
+	sponsor, offline, rejecting, idle := producers[0], producers[1], producers[2], producers[3]
+	stats := NewProducerStats()
+
+	// the offline producer misses its slot, the idle one proposes too late
+	stats.addProposal(idle, 100)
+	stats.addProposal(sponsor, 101)
+	stats.addVote(sponsor, 102)
+	stats.addVote(rejecting, 103)
+	stats.record(&HeightStats{
+		Height:   1,
+		Skipped:  [][]byte{offline, idle},
+		Proposed: stats.proposedSponsors(),
+		Accepted: [][]byte{sponsor, idle},
+		Rejected: [][]byte{rejecting},
+		Absent:   [][]byte{offline},
+	})
+	assert.Empty(t, stats.proposedSponsors())
+
+	report := stats.Report(producers)
+	assert.Equal(t, uint64(1), report.FromHeight)
+	assert.Equal(t, uint64(1), report.ToHeight)
+	assert.Equal(t, &ProducerStat{Producer: common.BytesToHexString(offline), ProposalsMissed: 1,
+		ViewChangesCaused: 1, VotesAbsent: 1}, statOf(report, offline))
+	assert.Equal(t, &ProducerStat{Producer: common.BytesToHexString(idle), ProposalsMade: 1,
+		ViewChangesCaused: 1, VotesAccepted: 1, LastSeen: 100}, statOf(report, idle))
+	assert.Equal(t, uint64(1), statOf(report, sponsor).ProposalsMade)
+	assert.Equal(t, int64(102), statOf(report, sponsor).LastSeen)
+	assert.Equal(t, uint64(1), statOf(report, rejecting).VotesRejected)
+
+	// votes coming after the confirm count for the confirmed proposal only
+	proposal := common.Uint256{1}
+	stats.addUncountedVote(proposal, offline, true, 104)
+	stats.record(&HeightStats{Height: 2, Proposal: proposal, Proposed: [][]byte{sponsor},
+		Accepted: [][]byte{sponsor}, Absent: [][]byte{offline, rejecting, idle}})
+	stats.addUncountedVote(proposal, rejecting, false, 105)
+	stats.addUncountedVote(common.Uint256{2}, idle, true, 106)
+	report = stats.Report(producers)
+	assert.Equal(t, uint64(1), statOf(report, offline).VotesAccepted)
+	assert.Equal(t, uint64(1), statOf(report, offline).VotesAbsent)
+	assert.Equal(t, int64(104), statOf(report, offline).LastSeen)
+	assert.Equal(t, uint64(2), statOf(report, rejecting).VotesRejected)
+	assert.Zero(t, statOf(report, rejecting).VotesAbsent)
+	assert.Equal(t, uint64(1), statOf(report, idle).VotesAbsent)
+
+	// heights leave the window once it is full
+	for height := uint64(3); height <= producerStatsWindow+2; height++ {
+		stats.record(&HeightStats{Height: height, Proposed: [][]byte{sponsor}, Accepted: producers})
+	}
+	report = stats.Report(producers)
+	assert.Equal(t, uint64(3), report.FromHeight)
+	assert.Equal(t, uint64(producerStatsWindow+2), report.ToHeight)
+	assert.Zero(t, statOf(report, offline).ProposalsMissed)
+	assert.Zero(t, statOf(report, offline).VotesAbsent)
+	assert.Equal(t, uint64(producerStatsWindow), statOf(report, offline).VotesAccepted)
+	assert.Equal(t, uint64(producerStatsWindow), statOf(report, sponsor).ProposalsMade)
+}
+
+func TestProducerStatsSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "producer-stats")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pbft", "producerstats.rlp")
+
+	producers := getRandProducers()[:2]
+	stats := NewProducerStats()
+	assert.NoError(t, stats.Load(path))
+	stats.addVote(producers[0], 100)
+	stats.record(&HeightStats{Height: 7, Skipped: [][]byte{producers[1]}, Accepted: [][]byte{producers[0]},
+		Absent: [][]byte{producers[1]}})
+	assert.NoError(t, stats.Save(path))
+
+	loaded := NewProducerStats()
+	assert.NoError(t, loaded.Load(path))
+	assert.Equal(t, stats.Report(producers), loaded.Report(producers))
+}
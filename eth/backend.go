@@ -44,6 +44,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/core/rawdb"
 	"github.com/pgprotocol/pgp-chain/core/types"
 	"github.com/pgprotocol/pgp-chain/core/vm"
+	"github.com/pgprotocol/pgp-chain/crosschain/tracker"
 	"github.com/pgprotocol/pgp-chain/dpos"
 	"github.com/pgprotocol/pgp-chain/eth/downloader"
 	"github.com/pgprotocol/pgp-chain/eth/filters"
@@ -60,6 +61,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/rlp"
 	"github.com/pgprotocol/pgp-chain/rpc"
 	"github.com/pgprotocol/pgp-chain/spv"
+	"github.com/pgprotocol/pgp-chain/withdrawindex"
 
 	_interface "github.com/elastos/Elastos.ELA.SPV/interface"
 
@@ -103,6 +105,9 @@ type Ethereum struct {
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
 
+	withdrawIndexer *core.ChainIndexer     // Withdraw event indexer, nil unless enabled
+	withdrawIndex   *withdrawindex.Indexer // Withdraw event index queried by the crosschain API
+
 	APIBackend *EthAPIBackend
 
 	miner     *miner.Miner
@@ -289,6 +294,10 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	eth.bloomIndexer.Start(eth.blockchain)
+	if config.WithdrawIndex {
+		eth.withdrawIndexer, eth.withdrawIndex = withdrawindex.NewIndexer(chainDb, chainConfig)
+		eth.withdrawIndexer.Start(eth.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -378,6 +387,7 @@ func New(ctx *node.ServiceContext, config *Config, node *node.Node) (*Ethereum,
 
 	engine.SetBlockChain(eth.blockchain)
 	spv.PbftEngine = engine
+	spv.ChainConfig = chainConfig
 	dposAccount, err := dpos.GetDposAccount(chainConfig.PbftKeyStore, []byte(chainConfig.PbftKeyStorePassWord))
 	if err != nil {
 		return eth, nil
@@ -646,6 +656,11 @@ func (s *Ethereum) APIs() []rpc.API {
 
 	apis = append(apis, chainbridge_core.APIs(s.BlockChain().GetDposEngine().(*pbft.Pbft))...)
 
+	apis = append(apis, tracker.APIs()...)
+	if s.withdrawIndexer != nil {
+		apis = append(apis, withdrawindex.APIs(s.withdrawIndexer, s.withdrawIndex, s.blockchain)...)
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -791,13 +806,12 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 			}
 			log.Info("detected chain fork", "old block", oldBlock.Hash().String(), "new block", block.Hash().String(),
 				"oldBlock time", oldBlock.Time(), "newBlock time", block.Time())
-			var oldConfirm payload.Confirm
-			oldErr := oldConfirm.Deserialize(bytes.NewReader(oldBlock.Extra()))
+			oldProposal, oldErr := dpos.SealedProposal(oldBlock.Extra())
 			if oldErr != nil {
 				log.Error("old Block is error confirm")
+				oldProposal = &payload.DPOSProposal{}
 			}
-			var newConfirm payload.Confirm
-			newErr := newConfirm.Deserialize(bytes.NewReader(block.Extra()))
+			newProposal, newErr := dpos.SealedProposal(block.Extra())
 			if newErr != nil {
 				log.Error("new Block is error confirm")
 				return false
@@ -818,8 +832,8 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 				return newNonce > oldNonce
 			}
 
-			oldViewOffset := oldConfirm.Proposal.ViewOffset
-			newViewOffset := newConfirm.Proposal.ViewOffset
+			oldViewOffset := oldProposal.ViewOffset
+			newViewOffset := newProposal.ViewOffset
 			log.Info("detected chain fork", "oldViewOffset", oldViewOffset, "newViewOffset", newViewOffset, "SignersCount", s.engine.SignersCount())
 			//return newViewOffset > oldViewOffset
 		}
@@ -957,11 +971,16 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 // Ethereum protocol.
 func (s *Ethereum) Stop() error {
 	fmt.Println("ethereum stop 111111111")
+	tracker.Stop()
+	chainbridge_core.Close()
 	spv.Close()
 	fmt.Println("ethereum stop 222222222")
 	close(s.stopChan)
 	fmt.Println("ethereum stop 3333333333")
 	s.bloomIndexer.Close()
+	if s.withdrawIndexer != nil {
+		s.withdrawIndexer.Close()
+	}
 	fmt.Println("ethereum stop 44444444")
 	s.blockchain.Stop()
 	fmt.Println("ethereum stop 55555555")
@@ -137,6 +137,9 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Enables the index of the withdraw, refund and pledge bill burn events
+	WithdrawIndex bool
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 
@@ -859,6 +859,12 @@ func (s *PublicBlockChainAPI) SendInvalidWithdrawTransaction(ctx context.Context
 	return err
 }
 
+// GetFailedWithdrawTxs returns the failed withdraw txs whose refund is still
+// collecting arbiter signatures.
+func (s *PublicBlockChainAPI) GetFailedWithdrawTxs(ctx context.Context) ([]withdrawfailedtx.FailedWithdrawTxInfo, error) {
+	return withdrawfailedtx.GetFailedWithdrawTxs(), nil
+}
+
 func (s *PublicBlockChainAPI) GetFrozenAccounts(ctx context.Context) ([]string, error) {
 	list := s.b.ChainConfig().FrozeAccountList
 	return list, nil
@@ -101,6 +101,11 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicBlockChainAPI(apiBackend),
 			Public:    true,
+		}, {
+			Namespace: "oracle",
+			Version:   "1.0",
+			Service:   NewPublicOracleAPI(apiBackend),
+			Public:    true,
 		}, {
 			Namespace: "eth",
 			Version:   "1.0",
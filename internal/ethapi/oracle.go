@@ -0,0 +1,401 @@
+// Copyright 2015 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
+	"github.com/pgprotocol/pgp-chain/pledgeBill"
+	"github.com/pgprotocol/pgp-chain/rpc"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+// OracleSchemaVersion is the version of the response schemas served by the
+// oracle namespace. Responses of a new version get new types, the ones of an
+// older version are kept as they are for the arbiters still depending on them.
+const OracleSchemaVersion = 1
+
+const (
+	// oracleConfirmations is the depth the main chain arbiters wait before a
+	// side chain block is queried for withdraws and pledge bill burns.
+	oracleConfirmations = 6
+
+	// oracleCallTimeout caps the contract calls made to serve a request.
+	oracleCallTimeout = 5 * time.Second
+)
+
+// Error codes of the main chain returned by the oracle.
+const (
+	SCErrMainchainTxDuplicate = 45013
+	ErrInvalidMainchainTx     = 45022
+)
+
+// withdrawTargetDataMarker separates the withdraw call from the target data
+// the user appends to the withdraw tx input.
+var withdrawTargetDataMarker = []byte("####")
+
+var (
+	payloadReceivedEvent = spv.ELAMinterABI.Events["PayloadReceived"]
+
+	pledgeBillBurnABI, _ = pledgeBill.GetStakeTicketBurnEventABI()
+	pledgeBillBurnEvent  = pledgeBillBurnABI.Events["StakeTicketBurn"]
+)
+
+// oracleError is an error carrying a main chain error code.
+type oracleError struct {
+	code int
+	msg  string
+}
+
+func (e *oracleError) Error() string  { return e.msg }
+func (e *oracleError) ErrorCode() int { return e.code }
+
+// CrossChainAssetV1 is one output of a withdraw tx to the main chain.
+type CrossChainAssetV1 struct {
+	CrossChainAddress string `json:"crosschainaddress"`
+	CrossChainAmount  string `json:"crosschainamount"`
+	OutputAmount      string `json:"outputamount"`
+	TargetData        string `json:"targetdata,omitempty"`
+}
+
+// WithdrawTxV1 is a withdraw tx with its outputs to the main chain.
+type WithdrawTxV1 struct {
+	TxID             string              `json:"txid"`
+	CrossChainAssets []CrossChainAssetV1 `json:"crosschainassets"`
+}
+
+// PledgeBillBurnV1 is a pledge bill burnt to return the stake on the main chain.
+type PledgeBillBurnV1 struct {
+	TokenID  string `json:"tokenID"`
+	SAddress string `json:"saddress"`
+}
+
+// PublicOracleAPI serves the cross chain queries of the main chain arbiters.
+type PublicOracleAPI struct {
+	b     Backend
+	chain *PublicBlockChainAPI
+}
+
+// NewPublicOracleAPI creates a new oracle API.
+func NewPublicOracleAPI(b Backend) *PublicOracleAPI {
+	return &PublicOracleAPI{b: b, chain: NewPublicBlockChainAPI(b)}
+}
+
+// SchemaVersion returns the version of the response schemas.
+func (s *PublicOracleAPI) SchemaVersion() int {
+	return OracleSchemaVersion
+}
+
+// GetBlockCount returns the height of the current block.
+func (s *PublicOracleAPI) GetBlockCount() (uint64, error) {
+	number := s.b.CurrentBlock().NumberU64()
+	if number == 0 {
+		return 0, errors.New("InternalError")
+	}
+	return number, nil
+}
+
+// SendRechargeTransaction reports whether the main chain tx txid can still be
+// recharged, a recharged one is answered with SCErrMainchainTxDuplicate.
+func (s *PublicOracleAPI) SendRechargeTransaction(ctx context.Context, txid string) (bool, error) {
+	completed, err := s.isCompleted(ctx, txid)
+	if err != nil {
+		return false, &oracleError{ErrInvalidMainchainTx, err.Error()}
+	}
+	if completed {
+		return false, &oracleError{SCErrMainchainTxDuplicate, "main chain tx is already recharged"}
+	}
+	return false, nil
+}
+
+// GetWithdrawTransaction returns the outputs to the main chain of the withdraw tx txid.
+func (s *PublicOracleAPI) GetWithdrawTransaction(ctx context.Context, txid string) (*WithdrawTxV1, error) {
+	hash := common.HexToHash(txid)
+	tx, blockHash, _, index, err := s.b.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, fmt.Errorf("not found withdraw tx, txid:%s", hash.String())
+	}
+	receipts, err := s.b.GetReceipts(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(receipts)) <= index {
+		return nil, fmt.Errorf("not found receipt of withdraw tx, txid:%s", hash.String())
+	}
+	result := &WithdrawTxV1{TxID: common.TrimHexPrefix(hash.String())}
+	receipt := receipts[index]
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return result, nil
+	}
+	for _, l := range receipt.Logs {
+		asset, ok, err := decodePayloadReceived(l)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.CrossChainAssets = append(result.CrossChainAssets, asset)
+		}
+	}
+	return result, nil
+}
+
+// GetWithdrawTransactionsByHeight returns the successful withdraw txs of the
+// block oracleConfirmations blocks below height, the ones sent by frozen
+// accounts are left out.
+func (s *PublicOracleAPI) GetWithdrawTransactionsByHeight(ctx context.Context, height uint64) ([]WithdrawTxV1, error) {
+	result := make([]WithdrawTxV1, 0)
+	if height <= oracleConfirmations+1 {
+		return result, nil
+	}
+	block, err := s.b.BlockByNumber(ctx, rpc.BlockNumber(height-oracleConfirmations))
+	if err != nil || block == nil {
+		return result, err
+	}
+	receipts, err := s.b.GetReceipts(ctx, block.Hash())
+	if err != nil {
+		return nil, err
+	}
+	signer := types.MakeSigner(s.b.ChainConfig(), block.Number())
+	for i, tx := range block.Transactions() {
+		if i >= len(receipts) || receipts[i].Status != types.ReceiptStatusSuccessful {
+			continue
+		}
+		var assets []CrossChainAssetV1
+		for _, l := range receipts[i].Logs {
+			asset, ok, err := decodePayloadReceived(l)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				assets = append(assets, asset)
+			}
+		}
+		if len(assets) == 0 {
+			continue
+		}
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, err
+		}
+		if s.isFrozenAccount(from) {
+			continue
+		}
+		targetData := withdrawTargetData(tx.Data())
+		for j := range assets {
+			assets[j].TargetData = targetData
+		}
+		result = append(result, WithdrawTxV1{TxID: common.TrimHexPrefix(tx.Hash().String()), CrossChainAssets: assets})
+	}
+	return result, nil
+}
+
+// GetExistDepositTransactions returns the main chain txs of txs which are recharged.
+func (s *PublicOracleAPI) GetExistDepositTransactions(ctx context.Context, txs []string) ([]string, error) {
+	existed := make([]string, 0)
+	for _, tx := range txs {
+		tx = addOracleHexPrefix(tx)
+		completed, err := s.isCompleted(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		if completed {
+			existed = append(existed, tx)
+		}
+	}
+	return existed, nil
+}
+
+// GetIllegalEvidenceByHeight returns the illegal evidences found at height.
+func (s *PublicOracleAPI) GetIllegalEvidenceByHeight(height uint64) []string {
+	return []string{}
+}
+
+// CheckIllegalEvidence reports whether evidence is an illegal evidence of the side chain.
+func (s *PublicOracleAPI) CheckIllegalEvidence(evidence interface{}) bool {
+	return false
+}
+
+// SendSmallCrossTransaction hands the signature of a small cross tx to the
+// small cross tx pool. It returns true if the main chain tx txHash is
+// recharged already.
+func (s *PublicOracleAPI) SendSmallCrossTransaction(ctx context.Context, signature string, rawTx string, txHash string) (bool, error) {
+	txHash = addOracleHexPrefix(txHash)
+	completed, err := s.isCompleted(ctx, txHash)
+	if err != nil {
+		return false, err
+	}
+	if completed {
+		return true, s.chain.OnSmallCrossTxSuccess(ctx, txHash)
+	}
+	return false, s.chain.ReceivedSmallCrossTx(ctx, signature, rawTx)
+}
+
+// GetFailedDepositTransactions returns the failed recharges of the main chain block at height.
+func (s *PublicOracleAPI) GetFailedDepositTransactions(ctx context.Context, height uint64) ([]string, error) {
+	return s.chain.GetFailedRechargeTxs(ctx, height)
+}
+
+// GetFailedDepositTransactionByHash returns the failed recharge of the main chain tx hash.
+func (s *PublicOracleAPI) GetFailedDepositTransactionByHash(ctx context.Context, hash string) (string, error) {
+	return s.chain.GetFailedRechargeTxByHash(ctx, hash)
+}
+
+// SendInvalidWithdrawTransaction hands the signature of a failed withdraw tx
+// refund to the side chain. It returns true if the refund is done already.
+func (s *PublicOracleAPI) SendInvalidWithdrawTransaction(ctx context.Context, signature string, txHash string) (bool, error) {
+	completed, err := s.isCompleted(ctx, addOracleHexPrefix(txHash))
+	if err != nil {
+		return false, err
+	}
+	if completed {
+		return true, nil
+	}
+	return false, s.chain.SendInvalidWithdrawTransaction(ctx, signature, txHash)
+}
+
+// GetProcessedInvalidWithdrawTransactions returns the failed withdraw txs of txs which are refunded.
+func (s *PublicOracleAPI) GetProcessedInvalidWithdrawTransactions(ctx context.Context, txs []string) ([]string, error) {
+	processed := make([]string, 0)
+	for _, tx := range txs {
+		completed, err := s.isCompleted(ctx, addOracleHexPrefix(tx))
+		if err != nil {
+			return nil, err
+		}
+		if completed {
+			processed = append(processed, tx)
+		}
+	}
+	return processed, nil
+}
+
+// GetPledgeBillBurnTransactionByHeight returns the pledge bills burnt in the
+// block oracleConfirmations blocks below height.
+func (s *PublicOracleAPI) GetPledgeBillBurnTransactionByHeight(ctx context.Context, height uint64) ([]PledgeBillBurnV1, error) {
+	result := make([]PledgeBillBurnV1, 0)
+	contract := s.b.ChainConfig().PledgeBillContract
+	if height <= oracleConfirmations+1 || !common.IsHexAddress(contract) {
+		return result, nil
+	}
+	header, err := s.b.HeaderByNumber(ctx, rpc.BlockNumber(height-oracleConfirmations))
+	if err != nil || header == nil {
+		return result, err
+	}
+	logs, err := s.b.GetLogs(ctx, header.Hash())
+	if err != nil {
+		return nil, err
+	}
+	address := common.HexToAddress(contract)
+	for _, txLogs := range logs {
+		for _, l := range txLogs {
+			if l.Address != address || len(l.Topics) == 0 || l.Topics[0] != pledgeBillBurnEvent.ID {
+				continue
+			}
+			values, err := pledgeBillBurnEvent.Inputs.Unpack(l.Data)
+			if err != nil {
+				return nil, err
+			}
+			tokenID := values[0].(*big.Int)
+			result = append(result, PledgeBillBurnV1{
+				TokenID:  common.BigToHash(tokenID).Hex()[2:],
+				SAddress: values[1].(string),
+			})
+		}
+	}
+	return result, nil
+}
+
+// isCompleted reports whether the ELAMinter contract marks hash as completed.
+func (s *PublicOracleAPI) isCompleted(ctx context.Context, hash string) (bool, error) {
+	input, err := spv.ELAMinterABI.Pack("completed", common.HexToHash(hash))
+	if err != nil {
+		return false, err
+	}
+	data := hexutil.Bytes(input)
+	args := CallArgs{From: &common.Address{}, To: &spv.ELAMinterAddress, Data: &data}
+	result, err := DoCall(ctx, s.b, args, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil, vm.Config{}, oracleCallTimeout, s.b.RPCGasCap())
+	if err != nil {
+		return false, err
+	}
+	if result.Failed() {
+		return false, result.Err
+	}
+	return new(big.Int).SetBytes(result.Return()).Cmp(big.NewInt(1)) == 0, nil
+}
+
+func (s *PublicOracleAPI) isFrozenAccount(addr common.Address) bool {
+	for _, account := range s.b.ChainConfig().FrozeAccountList {
+		if common.HexToAddress(account) == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// decodePayloadReceived decodes l if it is a PayloadReceived event of the ELAMinter contract.
+func decodePayloadReceived(l *types.Log) (CrossChainAssetV1, bool, error) {
+	if l.Address != spv.ELAMinterAddress || len(l.Topics) == 0 || l.Topics[0] != payloadReceivedEvent.ID {
+		return CrossChainAssetV1{}, false, nil
+	}
+	values, err := payloadReceivedEvent.Inputs.NonIndexed().Unpack(l.Data)
+	if err != nil {
+		return CrossChainAssetV1{}, false, err
+	}
+	return CrossChainAssetV1{
+		CrossChainAddress: values[0].(string),
+		OutputAmount:      formatMainChainAmount(values[1].(*big.Int)),
+		CrossChainAmount:  formatMainChainAmount(values[2].(*big.Int)),
+	}, true, nil
+}
+
+// withdrawTargetData returns the target data appended to the withdraw tx input.
+func withdrawTargetData(input []byte) string {
+	index := bytes.Index(input, withdrawTargetDataMarker)
+	if index < 0 {
+		return ""
+	}
+	return string(input[index+len(withdrawTargetDataMarker):])
+}
+
+// formatMainChainAmount converts a side chain amount to the decimal string of
+// the main chain amount, which has 8 decimals.
+func formatMainChainAmount(amount *big.Int) string {
+	integer, fraction := new(big.Int).QuoRem(amount, big.NewInt(1e8), new(big.Int))
+	if fraction.Sign() == 0 {
+		return integer.String()
+	}
+	return integer.String() + "." + strings.TrimRight(fmt.Sprintf("%08d", fraction.Uint64()), "0")
+}
+
+func addOracleHexPrefix(hash string) string {
+	if strings.HasPrefix(hash, "0x") {
+		return hash
+	}
+	return "0x" + hash
+}
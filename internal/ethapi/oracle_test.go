@@ -0,0 +1,245 @@
+// Copyright 2015 The pgp-chain Authors
+// This file is part of the pgp-chain library.
+//
+// The pgp-chain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The pgp-chain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the pgp-chain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/math"
+	"github.com/pgprotocol/pgp-chain/consensus/ethash"
+	"github.com/pgprotocol/pgp-chain/core"
+	"github.com/pgprotocol/pgp-chain/core/rawdb"
+	"github.com/pgprotocol/pgp-chain/core/state"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
+	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/rpc"
+	"github.com/pgprotocol/pgp-chain/spv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oracleTestBackend serves the oracle API from a generated chain, the methods
+// the oracle does not use are left to the nil embedded Backend.
+type oracleTestBackend struct {
+	Backend
+	chain *core.BlockChain
+	db    ethdb.Database
+}
+
+func (b *oracleTestBackend) ChainConfig() *params.ChainConfig { return b.chain.Config() }
+func (b *oracleTestBackend) CurrentBlock() *types.Block       { return b.chain.CurrentBlock() }
+func (b *oracleTestBackend) RPCGasCap() *big.Int              { return nil }
+
+func (b *oracleTestBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentHeader(), nil
+	}
+	return b.chain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *oracleTestBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	if number == rpc.LatestBlockNumber {
+		return b.chain.CurrentBlock(), nil
+	}
+	return b.chain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *oracleTestBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	header := b.chain.CurrentHeader()
+	statedb, err := b.chain.StateAt(header.Root)
+	return statedb, header, err
+}
+
+func (b *oracleTestBackend) GetReceipts(ctx context.Context, hash common.Hash) (types.Receipts, error) {
+	return b.chain.GetReceiptsByHash(hash), nil
+}
+
+func (b *oracleTestBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
+	receipts := b.chain.GetReceiptsByHash(hash)
+	logs := make([][]*types.Log, len(receipts))
+	for i, receipt := range receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
+func (b *oracleTestBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.db, txHash)
+	return tx, blockHash, blockNumber, index, nil
+}
+
+func (b *oracleTestBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header) (*vm.EVM, func() error, error) {
+	state.SetBalance(msg.From(), math.MaxBig256)
+	context := core.NewEVMContext(msg, header, b.chain, nil)
+	return vm.NewEVM(context, state, b.chain.Config(), vm.Config{}), func() error { return nil }, nil
+}
+
+// oracleTestContract returns the code of a contract standing in for the
+// ELAMinter and pledge bill contracts. A call of 36 bytes is answered with the
+// storage slot named by its argument, like completed(bytes32). Any other call
+// emits its input as the data of the event id, indexed by the caller.
+func oracleTestContract(id common.Hash) []byte {
+	code := []byte{
+		byte(vm.PUSH1), 0x24, byte(vm.CALLDATASIZE), byte(vm.EQ), byte(vm.PUSH1), 0x34, byte(vm.JUMPI),
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.PUSH1), 0, byte(vm.CALLDATACOPY),
+		byte(vm.CALLER), byte(vm.PUSH32),
+	}
+	code = append(code, id.Bytes()...)
+	return append(code,
+		byte(vm.CALLDATASIZE), byte(vm.PUSH1), 0, byte(vm.LOG2), byte(vm.STOP),
+		byte(vm.JUMPDEST), byte(vm.PUSH1), 4, byte(vm.CALLDATALOAD), byte(vm.SLOAD),
+		byte(vm.PUSH1), 0, byte(vm.MSTORE), byte(vm.PUSH1), 0x20, byte(vm.PUSH1), 0, byte(vm.RETURN),
+	)
+}
+
+func TestOracleAPI(t *testing.T) {
+	var (
+		key, _       = crypto.GenerateKey()
+		frozenKey, _ = crypto.GenerateKey()
+		addr         = crypto.PubkeyToAddress(key.PublicKey)
+		frozenAddr   = crypto.PubkeyToAddress(frozenKey.PublicKey)
+		pledgeBill   = common.HexToAddress("0xb340b44112106ebAeEeBbC18C08Eac01852AD6a4")
+		completed    = common.HexToHash("0x6a2b3a6d3d0f62a5f1c0e4a07e7c0a5e9f2b87e44f1a5bb3c7f6d9e1a2b3c4d5")
+		uncompleted  = common.HexToHash("0x01")
+		db           = rawdb.NewMemoryDatabase()
+		funds        = new(big.Int).Mul(big.NewInt(params.Ether), big.NewInt(100))
+	)
+	config := *params.AllEthashProtocolChanges
+	config.FrozeAccountList = []string{frozenAddr.Hex()}
+	config.PledgeBillContract = pledgeBill.Hex()
+	gspec := &core.Genesis{
+		Config: &config,
+		Alloc: core.GenesisAlloc{
+			addr:       {Balance: funds},
+			frozenAddr: {Balance: funds},
+			spv.ELAMinterAddress: {
+				Code:    oracleTestContract(payloadReceivedEvent.ID),
+				Storage: map[common.Hash]common.Hash{completed: common.BigToHash(big.NewInt(1))},
+				Balance: big.NewInt(0),
+			},
+			pledgeBill: {Code: oracleTestContract(pledgeBillBurnEvent.ID), Balance: big.NewInt(0)},
+		},
+	}
+	genesis := gspec.MustCommit(db)
+
+	withdraw, err := payloadReceivedEvent.Inputs.NonIndexed().Pack("EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd", big.NewInt(150000000), big.NewInt(300000001))
+	if err != nil {
+		t.Fatal(err)
+	}
+	withdraw = append(withdraw, []byte("####target")...)
+	burn, err := pledgeBillBurnEvent.Inputs.Pack(big.NewInt(255), "EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := types.MakeSigner(&config, common.Big1)
+	var withdrawTx *types.Transaction
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 10, func(i int, gen *core.BlockGen) {
+		if i != 1 {
+			return
+		}
+		withdrawTx, _ = types.SignTx(types.NewTransaction(gen.TxNonce(addr), spv.ELAMinterAddress, new(big.Int), 100000, big.NewInt(1), withdraw), signer, key)
+		gen.AddTx(withdrawTx)
+		frozenTx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(frozenAddr), spv.ELAMinterAddress, new(big.Int), 100000, big.NewInt(1), withdraw), signer, frozenKey)
+		gen.AddTx(frozenTx)
+		burnTx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(addr), pledgeBill, new(big.Int), 100000, big.NewInt(1), burn), signer, key)
+		gen.AddTx(burnTx)
+	})
+	chain, err := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	api := NewPublicOracleAPI(&oracleTestBackend{chain: chain, db: db})
+	ctx := context.Background()
+
+	count, err := api.GetBlockCount()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), count)
+
+	// the withdraws are reported 6 blocks after they are packed
+	txs, err := api.GetWithdrawTransactionsByHeight(ctx, 7)
+	assert.NoError(t, err)
+	assert.Empty(t, txs)
+	txs, err = api.GetWithdrawTransactionsByHeight(ctx, 8)
+	assert.NoError(t, err)
+	asset := CrossChainAssetV1{
+		CrossChainAddress: "EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd",
+		CrossChainAmount:  "3.00000001",
+		OutputAmount:      "1.5",
+	}
+	targetAsset := asset
+	targetAsset.TargetData = "target"
+	assert.Equal(t, []WithdrawTxV1{{TxID: withdrawTx.Hash().Hex()[2:], CrossChainAssets: []CrossChainAssetV1{targetAsset}}}, txs)
+
+	tx, err := api.GetWithdrawTransaction(ctx, withdrawTx.Hash().Hex()[2:])
+	assert.NoError(t, err)
+	assert.Equal(t, &WithdrawTxV1{TxID: withdrawTx.Hash().Hex()[2:], CrossChainAssets: []CrossChainAssetV1{asset}}, tx)
+
+	burns, err := api.GetPledgeBillBurnTransactionByHeight(ctx, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []PledgeBillBurnV1{{
+		TokenID:  "00000000000000000000000000000000000000000000000000000000000000ff",
+		SAddress: "EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd",
+	}}, burns)
+
+	existed, err := api.GetExistDepositTransactions(ctx, []string{completed.Hex()[2:], uncompleted.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{completed.Hex()}, existed)
+
+	processed, err := api.GetProcessedInvalidWithdrawTransactions(ctx, []string{completed.Hex()[2:], uncompleted.Hex()})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{completed.Hex()[2:]}, processed)
+
+	_, err = api.SendRechargeTransaction(ctx, completed.Hex()[2:])
+	if assert.Error(t, err) {
+		assert.Equal(t, SCErrMainchainTxDuplicate, err.(rpc.Error).ErrorCode())
+	}
+	recharged, err := api.SendRechargeTransaction(ctx, uncompleted.Hex())
+	assert.NoError(t, err)
+	assert.False(t, recharged)
+}
+
+func TestFormatMainChainAmount(t *testing.T) {
+	tests := []struct {
+		amount int64
+		want   string
+	}{
+		{0, "0"},
+		{1, "0.00000001"},
+		{100000000, "1"},
+		{150000000, "1.5"},
+		{1234567890123, "12345.67890123"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, formatMainChainAmount(big.NewInt(tt.amount)), "amount %d", tt.amount)
+	}
+}
+
+func TestWithdrawTargetData(t *testing.T) {
+	assert.Equal(t, "", withdrawTargetData([]byte{0x01, 0x02}))
+	assert.Equal(t, "", withdrawTargetData([]byte("abc####")))
+	assert.Equal(t, "{\"a\":1}", withdrawTargetData(append([]byte{0x23, 0x00}, []byte("####{\"a\":1}")...)))
+}
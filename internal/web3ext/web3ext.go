@@ -35,6 +35,8 @@ var Modules = map[string]string{
 	"txpool":     TxpoolJs,
 	"les":        LESJs,
 	"bridge":     BridgeJs,
+	"crosschain": CrosschainJs,
+	"oracle":     OracleJs,
 }
 
 const BridgeJs = `
@@ -71,6 +73,115 @@ web3._extend({
 });
 `
 
+const CrosschainJs = `
+web3._extend({
+	property: 'crosschain',
+	methods: [
+		new web3._extend.Method({
+			name: 'getTransferStatus',
+			call: 'crosschain_getTransferStatus',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRetryStatus',
+			call: 'crosschain_getRetryStatus',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getWithdrawsByHeightRange',
+			call: 'crosschain_getWithdrawsByHeightRange',
+			params: 4,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'getWithdrawsByAddress',
+			call: 'crosschain_getWithdrawsByAddress',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+	]
+});
+`
+
+const OracleJs = `
+web3._extend({
+	property: 'oracle',
+	methods: [
+		new web3._extend.Method({
+			name: 'getBlockCount',
+			call: 'oracle_getBlockCount',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'sendRechargeTransaction',
+			call: 'oracle_sendRechargeTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getWithdrawTransaction',
+			call: 'oracle_getWithdrawTransaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getWithdrawTransactionsByHeight',
+			call: 'oracle_getWithdrawTransactionsByHeight',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getExistDepositTransactions',
+			call: 'oracle_getExistDepositTransactions',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getIllegalEvidenceByHeight',
+			call: 'oracle_getIllegalEvidenceByHeight',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'checkIllegalEvidence',
+			call: 'oracle_checkIllegalEvidence',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendSmallCrossTransaction',
+			call: 'oracle_sendSmallCrossTransaction',
+			params: 3
+		}),
+		new web3._extend.Method({
+			name: 'getFailedDepositTransactions',
+			call: 'oracle_getFailedDepositTransactions',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getFailedDepositTransactionByHash',
+			call: 'oracle_getFailedDepositTransactionByHash',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sendInvalidWithdrawTransaction',
+			call: 'oracle_sendInvalidWithdrawTransaction',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'getProcessedInvalidWithdrawTransactions',
+			call: 'oracle_getProcessedInvalidWithdrawTransactions',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getPledgeBillBurnTransactionByHeight',
+			call: 'oracle_getPledgeBillBurnTransactionByHeight',
+			params: 1
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'schemaVersion',
+			getter: 'oracle_schemaVersion'
+		}),
+	]
+});
+`
+
 const ChequebookJs = `
 web3._extend({
 	property: 'chequebook',
@@ -168,6 +279,28 @@ web3._extend({
 			name: 'getActivePeers',
 			call: 'pbft_getActivePeers',
 		}),
+		new web3._extend.Method({
+			name: 'getIllegalEvidence',
+			call: 'pbft_getIllegalEvidence',
+		}),
+		new web3._extend.Method({
+			name: 'getBlsPublicKey',
+			call: 'pbft_getBlsPublicKey',
+		}),
+		new web3._extend.Method({
+			name: 'getProducerTransitions',
+			call: 'pbft_getProducerTransitions',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getProducerStats',
+			call: 'pbft_getProducerStats',
+		}),
+		new web3._extend.Method({
+			name: 'getProducerCheckpoints',
+			call: 'pbft_getProducerCheckpoints',
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -633,6 +766,11 @@ web3._extend({
 			call: 'eth_sendInvalidWithdrawTransaction',
 			params: 2,
 		}),
+		new web3._extend.Method({
+			name: 'getFailedWithdrawTxs',
+			call: 'eth_getFailedWithdrawTxs',
+			params: 0,
+		}),
 		new web3._extend.Method({
 			name: 'getFrozenAccounts',
 			call: 'eth_getFrozenAccounts',
@@ -30,6 +30,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/core/bloombits"
 	"github.com/pgprotocol/pgp-chain/core/rawdb"
 	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/crosschain/tracker"
 	"github.com/pgprotocol/pgp-chain/eth"
 	"github.com/pgprotocol/pgp-chain/eth/downloader"
 	"github.com/pgprotocol/pgp-chain/eth/filters"
@@ -296,6 +297,7 @@ func (s *LightEthereum) Start(srvr *p2p.Server) error {
 // Stop implements node.Service, terminating all internal goroutines used by the
 // Ethereum protocol.
 func (s *LightEthereum) Stop() error {
+	tracker.Stop()
 	spv.Close()
 	close(s.closeCh)
 	s.peers.Close()
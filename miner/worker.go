@@ -34,6 +34,7 @@ import (
 	"github.com/pgprotocol/pgp-chain/core/events"
 	"github.com/pgprotocol/pgp-chain/core/state"
 	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/core/vm"
 	"github.com/pgprotocol/pgp-chain/crosschain"
 	"github.com/pgprotocol/pgp-chain/event"
 	"github.com/pgprotocol/pgp-chain/log"
@@ -709,7 +710,11 @@ func (w *worker) updateSnapshot() {
 func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Address) ([]*types.Log, error) {
 	snap := w.current.state.Snapshot()
 
-	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, *w.chain.GetVMConfig())
+	// The main chain answers served to the transactions are committed into
+	// the block being mined
+	vmConfig := *w.chain.GetVMConfig()
+	vmConfig.MainChainOracle = vm.MainChainRecord
+	receipt, err := core.ApplyTransaction(w.chainConfig, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, vmConfig)
 	if err != nil {
 		w.current.state.RevertToSnapshot(snap)
 		return nil, err
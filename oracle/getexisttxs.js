@@ -1,26 +0,0 @@
-"use strict";
-
-const common = require("./common");
-
-module.exports = async function(json_data, res) {
-    try {
-        console.log("Checking Crosschain Transactions: ");
-        let txs = json_data["params"]["txs"];
-        console.log(JSON.stringify(txs));
-        console.log("============================================================");
-        let extxs = new Array();
-        for (let tx of txs) {
-            if (tx.indexOf("0x") !== 0) tx = "0x" + tx;
-            const completed = await common.rechargeIsSuccess(tx);
-            if (completed) {
-                extxs.push(tx);
-                continue;
-            }
-        }
-        res.json({"result": extxs, "id": null, "error": null, "jsonrpc": "2.0"});
-        return;
-    } catch (err) {
-        common.reterr(err, res);
-        return;
-    }
-}
\ No newline at end of file
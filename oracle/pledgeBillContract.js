@@ -1,24 +0,0 @@
-"use strict";
-
-module.exports = {
-    abi:[{
-        "anonymous": false,
-        "inputs": [
-            {
-                "indexed": false,
-                "internalType": "uint256",
-                "name": "tokenId",
-                "type": "uint256"
-            },
-            {
-                "indexed": false,
-                "internalType": "string",
-                "name": "elaAddress",
-                "type": "string"
-            }
-        ],
-        "name": "StakeTicketBurn",
-        "type": "event"
-    }],
-    address: "0xb340b44112106ebAeEeBbC18C08Eac01852AD6a4"
-}
\ No newline at end of file
@@ -18,8 +18,10 @@ package params
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"math/big"
+	"sort"
 
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/common/math"
@@ -422,19 +424,24 @@ type ChainConfig struct {
 	EIP155Block *big.Int `json:"eip155Block,omitempty"` // EIP155 HF block
 	EIP158Block *big.Int `json:"eip158Block,omitempty"` // EIP158 HF block
 
-	ChainIDBlock        *big.Int `json:"chainIdBlock,omitempty"`
-	ByzantiumBlock      *big.Int `json:"byzantiumBlock,omitempty"`      // Byzantium switch block (nil = no fork, 0 = already on byzantium)
-	ConstantinopleBlock *big.Int `json:"constantinopleBlock,omitempty"` // Constantinople switch block (nil = no fork, 0 = already activated)
-	PetersburgBlock     *big.Int `json:"petersburgBlock,omitempty"`     // Petersburg switch block (nil = same as Constantinople)
-	IstanbulBlock       *big.Int `json:"istanbulBlock,omitempty"`       // Istanbul switch block (nil = no fork, 0 = already on istanbul)
-	EWASMBlock          *big.Int `json:"ewasmBlock,omitempty"`          // EWASM switch block (nil = no fork, 0 = already activated)
-	PBFTBlock           *big.Int `json:"pbftBlock,omitempty"`           // PBFT switch block (nil = no fork, 0 = already activated)
-	MuirGlacierBlock    *big.Int `json:"muirGlacierBlock,omitempty"`    // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
-	BerlinBlock         *big.Int `json:"berlinBlock,omitempty"`         // Berlin switch block (nil = no fork, 0 = already on berlin)
-	LondonBlock         *big.Int `json:"londonBlock,omitempty"`         // London switch block (nil = no fork, 0 = already on london)
-	ArrowGlacierBlock   *big.Int `json:"arrowGlacierBlock,omitempty"`   // Eip-4345 (bomb delay) switch block (nil = no fork, 0 = already activated)
-	GrayGlacierBlock    *big.Int `json:"grayGlacierBlock,omitempty"`    // Eip-5133 (bomb delay) switch block (nil = no fork, 0 = already activated)
-	MergeNetsplitBlock  *big.Int `json:"mergeNetsplitBlock,omitempty"`  // Virtual fork after The Merge to use as a network splitter
+	ChainIDBlock            *big.Int `json:"chainIdBlock,omitempty"`
+	ByzantiumBlock          *big.Int `json:"byzantiumBlock,omitempty"`          // Byzantium switch block (nil = no fork, 0 = already on byzantium)
+	ConstantinopleBlock     *big.Int `json:"constantinopleBlock,omitempty"`     // Constantinople switch block (nil = no fork, 0 = already activated)
+	PetersburgBlock         *big.Int `json:"petersburgBlock,omitempty"`         // Petersburg switch block (nil = same as Constantinople)
+	IstanbulBlock           *big.Int `json:"istanbulBlock,omitempty"`           // Istanbul switch block (nil = no fork, 0 = already on istanbul)
+	EWASMBlock              *big.Int `json:"ewasmBlock,omitempty"`              // EWASM switch block (nil = no fork, 0 = already activated)
+	PBFTBlock               *big.Int `json:"pbftBlock,omitempty"`               // PBFT switch block (nil = no fork, 0 = already activated)
+	MuirGlacierBlock        *big.Int `json:"muirGlacierBlock,omitempty"`        // Eip-2384 (bomb delay) switch block (nil = no fork, 0 = already activated)
+	BerlinBlock             *big.Int `json:"berlinBlock,omitempty"`             // Berlin switch block (nil = no fork, 0 = already on berlin)
+	LondonBlock             *big.Int `json:"londonBlock,omitempty"`             // London switch block (nil = no fork, 0 = already on london)
+	ArrowGlacierBlock       *big.Int `json:"arrowGlacierBlock,omitempty"`       // Eip-4345 (bomb delay) switch block (nil = no fork, 0 = already activated)
+	GrayGlacierBlock        *big.Int `json:"grayGlacierBlock,omitempty"`        // Eip-5133 (bomb delay) switch block (nil = no fork, 0 = already activated)
+	MergeNetsplitBlock      *big.Int `json:"mergeNetsplitBlock,omitempty"`      // Virtual fork after The Merge to use as a network splitter
+	BatchRechargeBlock      *big.Int `json:"batchRechargeBlock,omitempty"`      // Batched recharge switch block (nil = no fork, 0 = already activated)
+	MainChainProofBlock     *big.Int `json:"mainChainProofBlock,omitempty"`     // Main chain merkle proof precompile switch block (nil = no fork, 0 = already activated)
+	AggregateConfirmBlock   *big.Int `json:"aggregateConfirmBlock,omitempty"`   // Aggregate confirm signatures switch block (nil = no fork, 0 = already activated)
+	ProducerTransitionBlock *big.Int `json:"producerTransitionBlock,omitempty"` // Producer set commitment switch block (nil = no fork, 0 = already activated)
+	MainChainOracleBlock    *big.Int `json:"mainChainOracleBlock,omitempty"`    // Main chain snapshot commitment switch block (nil = no fork, 0 = already activated)
 
 	// Fork scheduling was switched from blocks to timestamps here
 
@@ -489,16 +496,22 @@ func (c *CliqueConfig) String() string {
 }
 
 type PbftConfig struct {
-	Producers         []string `json:"producers"` // list of producers participating the pbft consensus.
-	Magic             uint32   `json:"magic"`     // Magic defines the magic number used in the DPoS network.
-	IPAddress         string   `json:"ip"`        // IPAddress defines the IP address for the DPoS network.
-	DPoSPort          uint16   `json:"dposport"`  // DPoSPort defines the default port for the DPoS network.
-	PrintLevel        uint8    `json:"printlevel"`
-	MaxLogsSize       int64    `json:"maxlogssize"`
-	MaxPerLogSize     int64    `json:"maxperlogsize"`
-	MaxNodePerHost    uint32   `json:"maxnodeperhost"` //MaxNodePerHost defines max nodes that one host can establish.
-	DPoSV2StartHeight uint32   `json:"dposv2startheight"`
-	BPosFullVoteTime  int64    `json:"bposfullvotetime"` //BPosFullNodeTime defines the time of to collected full vote
+	Producers         []string             `json:"producers"` // list of producers participating the pbft consensus.
+	Magic             uint32               `json:"magic"`     // Magic defines the magic number used in the DPoS network.
+	IPAddress         string               `json:"ip"`        // IPAddress defines the IP address for the DPoS network.
+	DPoSPort          uint16               `json:"dposport"`  // DPoSPort defines the default port for the DPoS network.
+	PrintLevel        uint8                `json:"printlevel"`
+	MaxLogsSize       int64                `json:"maxlogssize"`
+	MaxPerLogSize     int64                `json:"maxperlogsize"`
+	MaxNodePerHost    uint32               `json:"maxnodeperhost"` //MaxNodePerHost defines max nodes that one host can establish.
+	DPoSV2StartHeight uint32               `json:"dposv2startheight"`
+	BPosFullVoteTime  int64                `json:"bposfullvotetime"`    //BPosFullNodeTime defines the time of to collected full vote
+	BlsPublicKeys     map[string]string    `json:"blspublickeys"`       // BlsPublicKeys maps producer public keys to the BLS keys they sign aggregate confirms with.
+	BlsProofs         map[string]string    `json:"blsproofs"`           // BlsProofs maps producer public keys to the proofs of possession of their BLS keys, keys without one are ignored.
+	Pipelined         bool                 `json:"pipelined"`           // Pipelined lets the producer on duty at the next height seal a block once it has a majority of votes and propose on top of it.
+	Timing            PbftTiming           `json:"timing"`              // Timing of the consensus, unset fields default to DefaultPbftTiming.
+	TimingForks       []PbftTimingFork     `json:"timingforks"`         // TimingForks override the timing from their block on, in block order.
+	Checkpoints       []ProducerCheckpoint `json:"producercheckpoints"` // Checkpoints are the producers sealing the blocks before the producer transition fork, in main chain height order.
 	NodeVersion       string
 }
 
@@ -506,6 +519,160 @@ func (p *PbftConfig) String() string {
 	return "pbft"
 }
 
+// UnmarshalJSON decodes the config and sorts its timing forks by block, so
+// TimingAt walks them in order.
+func (p *PbftConfig) UnmarshalJSON(input []byte) error {
+	type pbftConfig PbftConfig
+	var dec pbftConfig
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*p = PbftConfig(dec)
+	sort.SliceStable(p.TimingForks, func(i, j int) bool {
+		// Forks without a block never activate, they go last
+		if p.TimingForks[j].Block == nil {
+			return p.TimingForks[i].Block != nil
+		}
+		return p.TimingForks[i].Block != nil && p.TimingForks[i].Block.Cmp(p.TimingForks[j].Block) < 0
+	})
+	sort.SliceStable(p.Checkpoints, func(i, j int) bool {
+		return p.Checkpoints[i].ElaHeight < p.Checkpoints[j].ElaHeight
+	})
+	return nil
+}
+
+// ProducerCheckpoint is the producer set sealing the blocks before the
+// producer transition fork from the main chain height ElaHeight on, up to the
+// next checkpoint. Checkpoints let a node verify that history without the
+// arbiters of the main chain in its spv database.
+type ProducerCheckpoint struct {
+	ElaHeight  uint64   `json:"elaheight"`
+	TotalCount int      `json:"totalcount"` // Seats of the main chain arbiters, vacant ones included
+	Producers  []string `json:"producers"`
+}
+
+// CheckpointAt returns the producer checkpoint in force at the main chain
+// height elaHeight, nil if it is below the first one.
+func (p *PbftConfig) CheckpointAt(elaHeight uint64) *ProducerCheckpoint {
+	var checkpoint *ProducerCheckpoint
+	for i := range p.Checkpoints {
+		if p.Checkpoints[i].ElaHeight > elaHeight {
+			break
+		}
+		checkpoint = &p.Checkpoints[i]
+	}
+	return checkpoint
+}
+
+// checkCheckpointOrder checks the producer checkpoints are in main chain
+// height order, each at its own height.
+func (p *PbftConfig) checkCheckpointOrder() error {
+	for i := 1; i < len(p.Checkpoints); i++ {
+		last, cur := p.Checkpoints[i-1].ElaHeight, p.Checkpoints[i].ElaHeight
+		if last >= cur {
+			return fmt.Errorf("unsupported producer checkpoint ordering: checkpoint at %d after the one at %d", cur, last)
+		}
+	}
+	return nil
+}
+
+// PbftTiming is the timing of the pbft consensus, in seconds.
+type PbftTiming struct {
+	BlockPeriod    uint64 `json:"blockperiod"`    // Minimum time between a block and its parent
+	ViewInterval   uint64 `json:"viewinterval"`   // Time the producers wait for the proposal of a view before changing it
+	RecoverTimeout uint64 `json:"recovertimeout"` // Time a recovering producer collects the consensus status of the others
+}
+
+// DefaultPbftTiming is the timing of the pbft consensus when it is not configured.
+var DefaultPbftTiming = PbftTiming{
+	BlockPeriod:    3,
+	ViewInterval:   6,
+	RecoverTimeout: 3,
+}
+
+// PbftTimingFork overrides the set fields of the pbft timing from Block on.
+type PbftTimingFork struct {
+	Block *big.Int `json:"block"`
+	PbftTiming
+}
+
+// override returns t with the set fields of o.
+func (t PbftTiming) override(o PbftTiming) PbftTiming {
+	if o.BlockPeriod != 0 {
+		t.BlockPeriod = o.BlockPeriod
+	}
+	if o.ViewInterval != 0 {
+		t.ViewInterval = o.ViewInterval
+	}
+	if o.RecoverTimeout != 0 {
+		t.RecoverTimeout = o.RecoverTimeout
+	}
+	return t
+}
+
+// TimingAt returns the timing of the pbft consensus at block number, the
+// forks activated at or before it apply in the order of their blocks.
+func (p *PbftConfig) TimingAt(number uint64) PbftTiming {
+	timing := DefaultPbftTiming.override(p.Timing)
+	for _, fork := range p.TimingForks {
+		if fork.Block == nil || fork.Block.Uint64() > number {
+			break
+		}
+		timing = timing.override(fork.PbftTiming)
+	}
+	return timing
+}
+
+// checkTimingForkOrder checks the timing forks are in block order.
+func (p *PbftConfig) checkTimingForkOrder() error {
+	for i := 1; i < len(p.TimingForks); i++ {
+		last, cur := p.TimingForks[i-1].Block, p.TimingForks[i].Block
+		if cur != nil && (last == nil || last.Cmp(cur) > 0) {
+			return fmt.Errorf("unsupported fork ordering: pbft timing fork at %v after the one at %v", cur, last)
+		}
+	}
+	return nil
+}
+
+// firstTimingFork returns the first block at or after from where the timing
+// forks of p change the timing, nil if there is none. Block 0 is where the
+// base timing applies.
+func (p *PbftConfig) firstTimingFork(from *big.Int) *big.Int {
+	if from.Sign() == 0 {
+		return common.Big0
+	}
+	for _, fork := range p.TimingForks {
+		if fork.Block != nil && fork.Block.Cmp(from) >= 0 {
+			return fork.Block
+		}
+	}
+	return nil
+}
+
+// checkTimingCompatible returns an error if the timing of newcfg differs from
+// the one of p at or before head. The error holds the forks of both configs
+// the timing changes at, so the chain is rewound before the earliest.
+func (p *PbftConfig) checkTimingCompatible(newcfg *PbftConfig, head *big.Int) *ConfigCompatError {
+	blocks := []*big.Int{common.Big0}
+	for _, fork := range append(append([]PbftTimingFork{}, p.TimingForks...), newcfg.TimingForks...) {
+		if fork.Block != nil {
+			blocks = append(blocks, fork.Block)
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Cmp(blocks[j]) < 0
+	})
+	for _, block := range blocks {
+		if !isForked(block, head) {
+			break
+		}
+		if p.TimingAt(block.Uint64()) != newcfg.TimingAt(block.Uint64()) {
+			return newCompatError("PBFT timing fork block", p.firstTimingFork(block), newcfg.firstTimingFork(block))
+		}
+	}
+	return nil
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var engine interface{}
@@ -519,7 +686,7 @@ func (c *ChainConfig) String() string {
 	default:
 		engine = "unknown"
 	}
-	return fmt.Sprintf("{ChainID: %v OldChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v ChainIDBlock:%v PBFTBlock:%v Engine: %v DynamicArbiterHeight: %v BerlinBlock: %v ShanghaiTime:%v DeveloperContract:%v, DeveloperFeeTime:%v }",
+	return fmt.Sprintf("{ChainID: %v OldChainID: %v Homestead: %v DAO: %v DAOSupport: %v EIP150: %v EIP155: %v EIP158: %v Byzantium: %v Constantinople: %v Petersburg: %v Istanbul: %v ChainIDBlock:%v PBFTBlock:%v Engine: %v DynamicArbiterHeight: %v BerlinBlock: %v ShanghaiTime:%v DeveloperContract:%v, DeveloperFeeTime:%v BatchRechargeBlock:%v MainChainProofBlock:%v AggregateConfirmBlock:%v ProducerTransitionBlock:%v MainChainOracleBlock:%v }",
 		c.ChainID,
 		c.OldChainID,
 		c.HomesteadBlock,
@@ -540,6 +707,11 @@ func (c *ChainConfig) String() string {
 		*c.ShanghaiTime,
 		c.DeveloperContract,
 		*c.DeveloperFeeTime,
+		c.BatchRechargeBlock,
+		c.MainChainProofBlock,
+		c.AggregateConfirmBlock,
+		c.ProducerTransitionBlock,
+		c.MainChainOracleBlock,
 	)
 }
 
@@ -651,6 +823,31 @@ func (c *ChainConfig) IsPBFTFork(num *big.Int) bool {
 	return isForked(c.PBFTBlock, num)
 }
 
+// IsBatchRecharge returns whether num is either equal to the batched recharge fork block or greater.
+func (c *ChainConfig) IsBatchRecharge(num *big.Int) bool {
+	return isForked(c.BatchRechargeBlock, num)
+}
+
+// IsMainChainProof returns whether num is either equal to the main chain proof fork block or greater.
+func (c *ChainConfig) IsMainChainProof(num *big.Int) bool {
+	return isForked(c.MainChainProofBlock, num)
+}
+
+// IsAggregateConfirm returns whether num is either equal to the aggregate confirm fork block or greater.
+func (c *ChainConfig) IsAggregateConfirm(num *big.Int) bool {
+	return isForked(c.AggregateConfirmBlock, num)
+}
+
+// IsProducerTransition returns whether num is either equal to the producer transition fork block or greater.
+func (c *ChainConfig) IsProducerTransition(num *big.Int) bool {
+	return isForked(c.ProducerTransitionBlock, num)
+}
+
+// IsMainChainOracle returns whether num is either equal to the main chain oracle fork block or greater.
+func (c *ChainConfig) IsMainChainOracle(num *big.Int) bool {
+	return isForked(c.MainChainOracleBlock, num)
+}
+
 func (c *ChainConfig) GetPbftBlock() uint64 {
 	if c.PBFTBlock == nil {
 		return 0
@@ -722,6 +919,12 @@ func (c *ChainConfig) CheckConfigForkOrder() error {
 		}
 		lastFork = cur
 	}
+	if c.Pbft != nil {
+		if err := c.Pbft.checkTimingForkOrder(); err != nil {
+			return err
+		}
+		return c.Pbft.checkCheckpointOrder()
+	}
 	return nil
 }
 
@@ -767,6 +970,26 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.EWASMBlock, newcfg.EWASMBlock, head) {
 		return newCompatError("ewasm fork block", c.EWASMBlock, newcfg.EWASMBlock)
 	}
+	if isForkIncompatible(c.BatchRechargeBlock, newcfg.BatchRechargeBlock, head) {
+		return newCompatError("Batch recharge fork block", c.BatchRechargeBlock, newcfg.BatchRechargeBlock)
+	}
+	if isForkIncompatible(c.MainChainProofBlock, newcfg.MainChainProofBlock, head) {
+		return newCompatError("Main chain proof fork block", c.MainChainProofBlock, newcfg.MainChainProofBlock)
+	}
+	if isForkIncompatible(c.AggregateConfirmBlock, newcfg.AggregateConfirmBlock, head) {
+		return newCompatError("Aggregate confirm fork block", c.AggregateConfirmBlock, newcfg.AggregateConfirmBlock)
+	}
+	if isForkIncompatible(c.ProducerTransitionBlock, newcfg.ProducerTransitionBlock, head) {
+		return newCompatError("Producer transition fork block", c.ProducerTransitionBlock, newcfg.ProducerTransitionBlock)
+	}
+	if isForkIncompatible(c.MainChainOracleBlock, newcfg.MainChainOracleBlock, head) {
+		return newCompatError("Main chain oracle fork block", c.MainChainOracleBlock, newcfg.MainChainOracleBlock)
+	}
+	if c.Pbft != nil && newcfg.Pbft != nil {
+		if err := c.Pbft.checkTimingCompatible(newcfg.Pbft, head); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -863,6 +1086,7 @@ type Rules struct {
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul, IsChainIDFork bool
 	IsBerlin, IsLondon                                                     bool
 	IsMerge, IsShanghai, IsCancun, IsPrague                                bool
+	IsMainChainProof, IsMainChainOracle                                    bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -876,22 +1100,24 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool, timestamp uint64) Rules
 		oldChainID = new(big.Int)
 	}
 	return Rules{
-		ChainID:          new(big.Int).Set(chainID),
-		OldChainID:       oldChainID,
-		IsHomestead:      c.IsHomestead(num),
-		IsEIP150:         c.IsEIP150(num),
-		IsEIP155:         c.IsEIP155(num),
-		IsEIP158:         c.IsEIP158(num),
-		IsByzantium:      c.IsByzantium(num),
-		IsConstantinople: c.IsConstantinople(num),
-		IsPetersburg:     c.IsPetersburg(num),
-		IsIstanbul:       c.IsIstanbul(num),
-		IsChainIDFork:    c.IsChainIDFork(num),
-		IsBerlin:         c.IsBerlin(num),
-		IsLondon:         c.IsLondon(num),
-		IsMerge:          isMerge,
-		IsShanghai:       c.IsShanghai(timestamp),
-		IsCancun:         c.IsCancun(timestamp),
-		IsPrague:         c.IsPrague(timestamp),
+		ChainID:           new(big.Int).Set(chainID),
+		OldChainID:        oldChainID,
+		IsHomestead:       c.IsHomestead(num),
+		IsEIP150:          c.IsEIP150(num),
+		IsEIP155:          c.IsEIP155(num),
+		IsEIP158:          c.IsEIP158(num),
+		IsByzantium:       c.IsByzantium(num),
+		IsConstantinople:  c.IsConstantinople(num),
+		IsPetersburg:      c.IsPetersburg(num),
+		IsIstanbul:        c.IsIstanbul(num),
+		IsChainIDFork:     c.IsChainIDFork(num),
+		IsBerlin:          c.IsBerlin(num),
+		IsLondon:          c.IsLondon(num),
+		IsMerge:           isMerge,
+		IsShanghai:        c.IsShanghai(timestamp),
+		IsCancun:          c.IsCancun(timestamp),
+		IsPrague:          c.IsPrague(timestamp),
+		IsMainChainProof:  c.IsMainChainProof(num),
+		IsMainChainOracle: c.IsMainChainOracle(num),
 	}
 }
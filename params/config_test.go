@@ -17,6 +17,7 @@
 package params
 
 import (
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
@@ -79,3 +80,110 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestPbftTimingAt(t *testing.T) {
+	// The forks are sorted when the config is decoded
+	config := new(PbftConfig)
+	err := json.Unmarshal([]byte(`{
+		"timing": {"blockperiod": 2},
+		"timingforks": [
+			{"block": 20, "viewinterval": 8},
+			{"block": null, "blockperiod": 1},
+			{"block": 10, "blockperiod": 5, "viewinterval": 10}
+		]
+	}`), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&ChainConfig{Pbft: config}).CheckConfigForkOrder(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		number uint64
+		want   PbftTiming
+	}{
+		{0, PbftTiming{BlockPeriod: 2, ViewInterval: 6, RecoverTimeout: 3}},
+		{9, PbftTiming{BlockPeriod: 2, ViewInterval: 6, RecoverTimeout: 3}},
+		{10, PbftTiming{BlockPeriod: 5, ViewInterval: 10, RecoverTimeout: 3}},
+		{19, PbftTiming{BlockPeriod: 5, ViewInterval: 10, RecoverTimeout: 3}},
+		{20, PbftTiming{BlockPeriod: 5, ViewInterval: 8, RecoverTimeout: 3}},
+	}
+	for _, test := range tests {
+		if have := config.TimingAt(test.number); have != test.want {
+			t.Errorf("block %d: timing mismatch: have %+v, want %+v", test.number, have, test.want)
+		}
+	}
+	if have := new(PbftConfig).TimingAt(100); have != DefaultPbftTiming {
+		t.Errorf("default timing mismatch: have %+v, want %+v", have, DefaultPbftTiming)
+	}
+}
+
+func TestPbftTimingCompatible(t *testing.T) {
+	stored := &PbftConfig{
+		TimingForks: []PbftTimingFork{{Block: big.NewInt(10), PbftTiming: PbftTiming{BlockPeriod: 5}}},
+	}
+	tests := []struct {
+		new  *PbftConfig
+		head uint64
+		want *ConfigCompatError
+	}{
+		{stored, 100, nil},
+		{&PbftConfig{}, 9, nil},
+		{&PbftConfig{}, 10, &ConfigCompatError{"PBFT timing fork block", big.NewInt(10), nil, 9}},
+		{&PbftConfig{TimingForks: []PbftTimingFork{{Block: big.NewInt(20), PbftTiming: PbftTiming{BlockPeriod: 5}}}}, 15,
+			&ConfigCompatError{"PBFT timing fork block", big.NewInt(10), big.NewInt(20), 9}},
+		{&PbftConfig{TimingForks: []PbftTimingFork{{Block: big.NewInt(5), PbftTiming: PbftTiming{BlockPeriod: 5}}}}, 15,
+			&ConfigCompatError{"PBFT timing fork block", big.NewInt(10), big.NewInt(5), 4}},
+		{&PbftConfig{TimingForks: append(stored.TimingForks, PbftTimingFork{Block: big.NewInt(20), PbftTiming: PbftTiming{BlockPeriod: 4}})}, 15, nil},
+		{&PbftConfig{Timing: PbftTiming{ViewInterval: 9}, TimingForks: stored.TimingForks}, 0,
+			&ConfigCompatError{"PBFT timing fork block", big.NewInt(0), big.NewInt(0), 0}},
+	}
+	for i, test := range tests {
+		have := stored.checkTimingCompatible(test.new, new(big.Int).SetUint64(test.head))
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("test %d: compat error mismatch: have %v, want %v", i, have, test.want)
+		}
+	}
+	// The forks of a config built in code must be in block order
+	unsorted := &PbftConfig{TimingForks: []PbftTimingFork{{Block: big.NewInt(20)}, {Block: big.NewInt(10)}}}
+	if err := (&ChainConfig{Pbft: unsorted}).CheckConfigForkOrder(); err == nil {
+		t.Error("expected fork order error")
+	}
+}
+
+func TestPbftCheckpointAt(t *testing.T) {
+	// The checkpoints are sorted when the config is decoded
+	config := new(PbftConfig)
+	err := json.Unmarshal([]byte(`{
+		"producercheckpoints": [
+			{"elaheight": 200, "totalcount": 7, "producers": ["02"]},
+			{"elaheight": 100, "totalcount": 6, "producers": ["01"]}
+		]
+	}`), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&ChainConfig{Pbft: config}).CheckConfigForkOrder(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		elaHeight uint64
+		want      int
+	}{
+		{0, 0}, {99, 0}, {100, 6}, {199, 6}, {200, 7}, {1000, 7},
+	}
+	for _, test := range tests {
+		have := 0
+		if checkpoint := config.CheckpointAt(test.elaHeight); checkpoint != nil {
+			have = checkpoint.TotalCount
+		}
+		if have != test.want {
+			t.Errorf("ela height %d: checkpoint total count mismatch: have %d, want %d", test.elaHeight, have, test.want)
+		}
+	}
+	// The checkpoints of a config built in code must be in height order
+	unsorted := &PbftConfig{Checkpoints: []ProducerCheckpoint{{ElaHeight: 200}, {ElaHeight: 100}}}
+	if err := (&ChainConfig{Pbft: unsorted}).CheckConfigForkOrder(); err == nil {
+		t.Error("expected checkpoint order error")
+	}
+}
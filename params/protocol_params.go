@@ -171,6 +171,9 @@ const (
 
 	GetMainChainBlock             uint64 = 1000
 	GetMainChainBlockLatestHeight uint64 = 0
+	VerifyMainChainTxProofGas     uint64 = 3000 // Base gas for verifying a main chain transaction merkle proof
+	VerifyMainChainTxProofWordGas uint64 = 24   // Per word gas of the main chain transaction, hashed by double SHA256
+	VerifyMainChainTxProofNodeGas uint64 = 168  // Per word gas of the merkle proof, each node is hashed with its sibling by double SHA256
 )
 
 // Gas discount table for BLS12-381 G1 and G2 multi exponentiation operations
@@ -194,6 +197,7 @@ var (
 	GetMainChainRechargeData  = big.NewInt(1009)
 	GetWithdrawData           = big.NewInt(1010)
 	VerifySmallCrossTx        = big.NewInt(1011)
+	VerifyMainChainTxProof    = big.NewInt(1012)
 )
 
 var (
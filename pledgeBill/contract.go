@@ -16,3 +16,9 @@ func GetTickFromTokenIdABI() (abi.ABI, error) {
 	a, err := abi.JSON(strings.NewReader(definition))
 	return a, err
 }
+
+func GetStakeTicketBurnEventABI() (abi.ABI, error) {
+	definition := "[{\"anonymous\":false,\"inputs\":[{\"indexed\":false,\"internalType\":\"uint256\",\"name\":\"tokenId\",\"type\":\"uint256\"},{\"indexed\":false,\"internalType\":\"string\",\"name\":\"elaAddress\",\"type\":\"string\"}],\"name\":\"StakeTicketBurn\",\"type\":\"event\"}]"
+	a, err := abi.JSON(strings.NewReader(definition))
+	return a, err
+}
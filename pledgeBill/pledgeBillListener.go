@@ -9,8 +9,14 @@ import (
 	it "github.com/elastos/Elastos.ELA/core/types/interfaces"
 )
 
+// ReceiptSubmitter acknowledges the main chain transactions notified to a
+// listener.
+type ReceiptSubmitter interface {
+	SubmitTransactionReceipt(notifyId common.Uint256, txId common.Uint256) error
+}
+
 type PledgeBillListener struct {
-	Service spv.SPVService
+	Service ReceiptSubmitter
 }
 
 func (l *PledgeBillListener) Address() string {
@@ -6,13 +6,18 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 
 	elatx "github.com/elastos/Elastos.ELA/core/transaction"
 	elaCrypto "github.com/elastos/Elastos.ELA/crypto"
 	"github.com/pgprotocol/pgp-chain/common"
 	"github.com/pgprotocol/pgp-chain/core/events"
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/ethdb/leveldb"
+	"github.com/pgprotocol/pgp-chain/ethdb/memorydb"
 	"github.com/pgprotocol/pgp-chain/event"
 	"github.com/pgprotocol/pgp-chain/log"
 )
@@ -28,7 +33,7 @@ var (
 
 	smallCrossTxMsgMap = make(map[string]bool)
 
-	smallCrossTxDb = make(map[string][]byte)
+	smallCrossTxDb ethdb.KeyValueStore
 
 	SmallTxDB_SIG_PRE = "small_cross_sig"
 
@@ -38,14 +43,96 @@ var (
 
 	SmallTxDB_BLOCKHEIGHT_PRE = "small_cross_blockNumber"
 
+	SmallTxDB_ARBITER_PRE = "small_cross_arbiter"
+
+	SmallTxDB_CONFIRMED_PRE = "small_cross_confirmed"
+
 	ErrNotFound = "leveldb: not found"
 
 	ErrAllReadyConfirm = errors.New("smallCroTxConfirmed")
 )
 
+const (
+	databaseCache int = 16
+
+	handles = 16
+)
+
 // Spv database initialization
 func SmallCrossTxInit(datadir string, evtMux *event.TypeMux) {
 	eventMux = evtMux
+	db, err := openSmallCrossTxDb(datadir)
+	if err != nil {
+		log.Error("small cross tx Open db", "err", err)
+		return
+	}
+	mulCountPti.Lock()
+	defer mulCountPti.Unlock()
+	smallCrossTxDb = db
+	count, err := loadSmallCrossTxIndex()
+	if err != nil {
+		log.Error("load small cross tx index error", "error", err)
+	}
+	log.Info("loaded small cross txs", "count", count)
+}
+
+// Close closes the small cross tx database.
+func Close() {
+	mulCountPti.Lock()
+	defer mulCountPti.Unlock()
+	if smallCrossTxDb == nil {
+		return
+	}
+	smallCrossTxDb.Close()
+	smallCrossTxDb = nil
+	smallCrossTxCountMap = make(map[string]int)
+	verifiedArbiter = make(map[string][]string)
+	smallCrossTxMsgMap = make(map[string]bool)
+}
+
+func openSmallCrossTxDb(datadir string) (ethdb.KeyValueStore, error) {
+	if datadir == "" {
+		return memorydb.New(), nil
+	}
+	return leveldb.New(filepath.Join(datadir, "small_cross_tx.db"), databaseCache, handles, "eth/db/smallcrosstx/")
+}
+
+// loadSmallCrossTxIndex rebuilds the in-memory signature count, verified
+// arbiter and confirmed maps from the database.
+func loadSmallCrossTxIndex() (int, error) {
+	prefix := []byte(SmallTxDB_SIGCOUNT_PRE)
+	it := smallCrossTxDb.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	loaded := 0
+	for it.Next() {
+		elaHash := string(it.Key()[len(prefix):])
+		smallCrossTxCountMap[elaHash] = int(BytesToInt(it.Value())) + 1
+		if confirmed, _ := smallCrossTxDb.Has([]byte(SmallTxDB_CONFIRMED_PRE + elaHash)); confirmed {
+			rawTx, err := smallCrossTxDb.Get([]byte(SmallTxDB_TX_PRE + elaHash))
+			if err != nil {
+				log.Error("load small cross tx rawTx failed", "elaHash", elaHash, "error", err)
+				continue
+			}
+			smallCrossTxMsgMap[string(rawTx)] = true
+		} else {
+			verifiedArbiter[elaHash] = getVerifiedArbiters(elaHash)
+		}
+		loaded++
+	}
+	return loaded, it.Error()
+}
+
+func getVerifiedArbiters(elaHash string) []string {
+	data, err := smallCrossTxDb.Get([]byte(SmallTxDB_ARBITER_PRE + elaHash))
+	if err != nil || len(data) == 0 {
+		return []string{}
+	}
+	return strings.Split(string(data), ",")
+}
+
+func putVerifiedArbiters(elaHash string, arbiters []string) error {
+	return smallCrossTxDb.Put([]byte(SmallTxDB_ARBITER_PRE+elaHash), []byte(strings.Join(arbiters, ",")))
 }
 
 func OnSmallCrossTx(arbiters []string, total int, signature, rawTx string,
@@ -97,8 +184,8 @@ func OnSmallCrossTx(arbiters []string, total int, signature, rawTx string,
 		}
 		err = elaCrypto.Verify(*pubKey, buff, sig)
 		if err == nil {
-			list := verifiedArbiter[txn.Hash().String()]
-			verifiedArbiter[txn.Hash().String()] = append(list, pubkey)
+			list := append(verifiedArbiter[txn.Hash().String()], pubkey)
+			verifiedArbiter[txn.Hash().String()] = list
 			count++
 			smallCrossTxCountMap[txn.Hash().String()] = count
 			if count == 1 {
@@ -113,6 +200,10 @@ func OnSmallCrossTx(arbiters []string, total int, signature, rawTx string,
 				if err != nil {
 					return err
 				}
+				err = putVerifiedArbiters(txn.Hash().String(), list)
+				if err != nil {
+					return err
+				}
 			}
 			break
 		} else {
@@ -122,6 +213,10 @@ func OnSmallCrossTx(arbiters []string, total int, signature, rawTx string,
 	if count >= maxSignCount {
 		smallCrossTxMsgMap[rawTx] = true
 		delete(verifiedArbiter, txn.Hash().String())
+		err = smallCrossTxDb.Put([]byte(SmallTxDB_CONFIRMED_PRE+txn.Hash().String()), []byte{1})
+		if err != nil {
+			log.Error("save small cross tx confirmed error", "error", err)
+		}
 		eventMux.Post(events.CmallCrossTx{Tx: txn})
 	}
 	return nil
@@ -161,8 +256,7 @@ func PutSmallTxData(rawTx string, elaHash string) error {
 		elaHash = elaHash[2:]
 	}
 	key := SmallTxDB_TX_PRE + elaHash
-	smallCrossTxDb[key] = []byte(rawTx)
-	return nil
+	return smallCrossTxDb.Put([]byte(key), []byte(rawTx))
 }
 
 func PutSmallTxSignature(signature string, elaHash string, count int,
@@ -173,16 +267,19 @@ func PutSmallTxSignature(signature string, elaHash string, count int,
 	if elaHash[:2] == "0x" {
 		elaHash = elaHash[2:]
 	}
+	// write the signature and its count together, so a crash never leaves
+	// a count pointing at a missing signature
+	batch := smallCrossTxDb.NewBatch()
 	keyCount := SmallTxDB_SIGCOUNT_PRE + elaHash
-	smallCrossTxDb[keyCount] = IntToBytes(uint64(count))
+	batch.Put([]byte(keyCount), IntToBytes(uint64(count)))
 
 	num := strconv.Itoa(count)
 	key := SmallTxDB_SIG_PRE + elaHash + num
-	smallCrossTxDb[key] = []byte(signature)
+	batch.Put([]byte(key), []byte(signature))
 
 	key = SmallTxDB_BLOCKHEIGHT_PRE + elaHash
-	smallCrossTxDb[key] = IntToBytes(blockNumber)
-	return nil
+	batch.Put([]byte(key), IntToBytes(blockNumber))
+	return batch.Write()
 }
 
 func GetArbiterSignCount(elaHash string) (int, error) {
@@ -193,7 +290,7 @@ func GetArbiterSignCount(elaHash string) (int, error) {
 		elaHash = elaHash[2:]
 	}
 	keyCount := SmallTxDB_SIGCOUNT_PRE + elaHash
-	if data, ok := smallCrossTxDb[keyCount]; ok {
+	if data, err := smallCrossTxDb.Get([]byte(keyCount)); err == nil {
 		count := BytesToInt(data)
 		return int(count), nil
 	}
@@ -209,7 +306,7 @@ func GetReiceivedBlockHeight(elaHash string) (uint64, error) {
 		elaHash = elaHash[2:]
 	}
 	key := SmallTxDB_BLOCKHEIGHT_PRE + elaHash
-	if data, ok := smallCrossTxDb[key]; ok {
+	if data, err := smallCrossTxDb.Get([]byte(key)); err == nil {
 		height := BytesToInt(data)
 		return height, nil
 	}
@@ -227,7 +324,7 @@ func GetSmallCrossTxMsg(elaHash string) *SmallCrossTx {
 		elaHash = elaHash[2:]
 	}
 	key := SmallTxDB_TX_PRE + elaHash
-	rawTxData := smallCrossTxDb[key]
+	rawTxData, _ := smallCrossTxDb.Get([]byte(key))
 	if len(rawTxData) == 0 {
 		log.Error("GetSmallCrossTxMsg rawTx failed", "elaHash", elaHash)
 		return nil
@@ -242,7 +339,7 @@ func GetSmallCrossTxMsg(elaHash string) *SmallCrossTx {
 	for i := 0; i <= count; i++ {
 		num := strconv.Itoa(i)
 		key = SmallTxDB_SIG_PRE + elaHash + num
-		data := smallCrossTxDb[key]
+		data, _ := smallCrossTxDb.Get([]byte(key))
 		if len(data) == 0 {
 			log.Error("GetSmallCrossTxMsg signature failed", "elaHash", elaHash, "index", i, "count", count)
 			return nil
@@ -292,6 +389,8 @@ func BytesToInt(b []byte) uint64 {
 }
 
 func OnSmallTxSuccess(elaHash string) {
+	mulCountPti.Lock()
+	defer mulCountPti.Unlock()
 	if smallCrossTxDb == nil {
 		return
 	}
@@ -303,22 +402,28 @@ func OnSmallTxSuccess(elaHash string) {
 		log.Info("GetArbiterSignCount error", "error", err)
 		return
 	}
+	batch := smallCrossTxDb.NewBatch()
 	key := ""
 	for i := 0; i <= count; i++ {
 		num := strconv.Itoa(i)
 		key = SmallTxDB_SIG_PRE + elaHash + num
-		delete(smallCrossTxDb, key)
+		batch.Delete([]byte(key))
 	}
 	keyCount := SmallTxDB_SIGCOUNT_PRE + elaHash
-	delete(smallCrossTxDb, keyCount)
+	batch.Delete([]byte(keyCount))
 
 	key = SmallTxDB_TX_PRE + elaHash
-	rawTxData := smallCrossTxDb[key]
+	rawTxData, _ := smallCrossTxDb.Get([]byte(key))
 	delete(smallCrossTxMsgMap, string(rawTxData))
-	delete(smallCrossTxDb, key)
+	batch.Delete([]byte(key))
 
 	key = SmallTxDB_BLOCKHEIGHT_PRE + elaHash
-	delete(smallCrossTxDb, key)
+	batch.Delete([]byte(key))
+	batch.Delete([]byte(SmallTxDB_ARBITER_PRE + elaHash))
+	batch.Delete([]byte(SmallTxDB_CONFIRMED_PRE + elaHash))
+	if err := batch.Write(); err != nil {
+		log.Error("delete small cross tx error", "elaHash", elaHash, "error", err)
+	}
 	if smallCrossTxCountMap[elaHash] > 0 {
 		delete(smallCrossTxCountMap, elaHash)
 	}
@@ -0,0 +1,154 @@
+package smallcrosstx
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core/events"
+	"github.com/pgprotocol/pgp-chain/event"
+
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	elatx "github.com/elastos/Elastos.ELA/core/transaction"
+	elacom "github.com/elastos/Elastos.ELA/core/types/common"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+	elaCrypto "github.com/elastos/Elastos.ELA/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+type testArbiter struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string
+}
+
+func newTestArbiters(t *testing.T, n int) []testArbiter {
+	arbiters := make([]testArbiter, n)
+	for i := range arbiters {
+		priv, pub, err := elaCrypto.GenerateKeyPair()
+		assert.NoError(t, err)
+		pubBytes, err := pub.EncodePoint(true)
+		assert.NoError(t, err)
+		privateKey := &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{Curve: elaCrypto.DefaultCurve, X: pub.X, Y: pub.Y},
+			D:         new(big.Int).SetBytes(priv),
+		}
+		arbiters[i] = testArbiter{privateKey: privateKey, publicKey: common.Bytes2Hex(pubBytes)}
+	}
+	return arbiters
+}
+
+func newTestRawTx(t *testing.T) (string, string) {
+	txn := elatx.CreateTransaction(
+		elacom.TxVersion09,
+		elacom.TransferAsset,
+		0,
+		&payload.TransferAsset{},
+		[]*elacom.Attribute{},
+		[]*elacom.Input{},
+		[]*elacom.Output{},
+		0,
+		[]*program.Program{},
+	)
+	buf := new(bytes.Buffer)
+	assert.NoError(t, txn.Serialize(buf))
+	return hex.EncodeToString(buf.Bytes()), txn.Hash().String()
+}
+
+// sign produces the same signature format as elaCrypto.Sign.
+func (a testArbiter) sign(t *testing.T, rawTx string) string {
+	buff, err := hex.DecodeString(rawTx)
+	assert.NoError(t, err)
+	digest := sha256.Sum256(buff)
+	r, s, err := ecdsa.Sign(rand.Reader, a.privateKey, digest[:])
+	assert.NoError(t, err)
+	sig := make([]byte, elaCrypto.SignatureLength)
+	r.FillBytes(sig[:elaCrypto.SignerLength])
+	s.FillBytes(sig[elaCrypto.SignerLength:])
+	return hex.EncodeToString(sig)
+}
+
+func TestSmallCrossTxRestart(t *testing.T) {
+	datadir := t.TempDir()
+	mux := new(event.TypeMux)
+	defer mux.Stop()
+	sub := mux.Subscribe(events.CmallCrossTx{})
+	defer sub.Unsubscribe()
+
+	arbiters := newTestArbiters(t, 4)
+	pubKeys := make([]string, len(arbiters))
+	for i, a := range arbiters {
+		pubKeys[i] = a.publicKey
+	}
+	total := len(arbiters)
+	rawTx, elaHash := newTestRawTx(t)
+	blockHeight := uint64(100)
+
+	SmallCrossTxInit(datadir, mux)
+	defer Close()
+
+	// collect two of the three required signatures, then restart
+	sig0 := arbiters[0].sign(t, rawTx)
+	assert.NoError(t, OnSmallCrossTx(pubKeys, total, sig0, rawTx, blockHeight))
+	sig1 := arbiters[1].sign(t, rawTx)
+	assert.NoError(t, OnSmallCrossTx(pubKeys, total, sig1, rawTx, blockHeight))
+
+	Close()
+	assert.Nil(t, GetSmallCrossTxMsg(elaHash))
+	SmallCrossTxInit(datadir, mux)
+
+	count, err := GetArbiterSignCount(elaHash)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	height, err := GetReiceivedBlockHeight(elaHash)
+	assert.NoError(t, err)
+	assert.Equal(t, blockHeight, height)
+	ctx := GetSmallCrossTxMsg(elaHash)
+	assert.NotNil(t, ctx)
+	assert.Equal(t, rawTx, ctx.RawTx)
+	assert.Equal(t, []string{sig0, sig1}, ctx.Signatures)
+	assert.Equal(t, 2, smallCrossTxCountMap[elaHash])
+	assert.True(t, isArbiterVerified(arbiters[0].publicKey, elaHash))
+	assert.True(t, isArbiterVerified(arbiters[1].publicKey, elaHash))
+	assert.False(t, isArbiterVerified(arbiters[2].publicKey, elaHash))
+
+	// signatures received before the restart are still rejected
+	assert.Error(t, OnSmallCrossTx(pubKeys, total, sig1, rawTx, blockHeight))
+
+	// the mux delivers synchronously, so receive the confirmation concurrently
+	confirmed := make(chan string, 1)
+	go func() {
+		ev := <-sub.Chan()
+		confirmed <- ev.Data.(events.CmallCrossTx).Tx.Hash().String()
+	}()
+	sig2 := arbiters[2].sign(t, rawTx)
+	assert.NoError(t, OnSmallCrossTx(pubKeys, total, sig2, rawTx, blockHeight))
+	assert.Equal(t, elaHash, <-confirmed)
+
+	// the confirmation survives a restart as well
+	Close()
+	SmallCrossTxInit(datadir, mux)
+	sig3 := arbiters[3].sign(t, rawTx)
+	assert.Equal(t, ErrAllReadyConfirm, OnSmallCrossTx(pubKeys, total, sig3, rawTx, blockHeight))
+	data, _, err := GetSmallCrossTxBytes(elaHash)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, data)
+
+	// and everything is collected once the recharge succeeded
+	OnSmallTxSuccess(elaHash)
+	Close()
+	SmallCrossTxInit(datadir, mux)
+	assert.Nil(t, GetSmallCrossTxMsg(elaHash))
+	_, err = GetArbiterSignCount(elaHash)
+	assert.Error(t, err)
+	assert.Empty(t, smallCrossTxCountMap)
+	assert.Empty(t, smallCrossTxMsgMap)
+
+	it := smallCrossTxDb.NewIterator()
+	defer it.Release()
+	assert.False(t, it.Next())
+}
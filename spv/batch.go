@@ -0,0 +1,160 @@
+package spv
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync/atomic"
+
+	ethereum "github.com/pgprotocol/pgp-chain"
+	ethCommon "github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/smallcrosstx"
+)
+
+const (
+	// rechargeHeightPrefix + elaHash -> main chain block height of the deposit
+	rechargeHeightPrefix = "RcH-"
+)
+
+// ChainConfig is the config of the side chain, it decides when recharges are batched.
+var ChainConfig *params.ChainConfig
+
+// batchRechargeEnabled reports whether the next side chain block accepts batched recharges.
+func batchRechargeEnabled() bool {
+	if ChainConfig == nil || PbftEngine == nil {
+		return false
+	}
+	block := PbftEngine.CurrentBlock()
+	if block == nil {
+		return false
+	}
+	return ChainConfig.IsBatchRecharge(new(big.Int).Add(block.Number(), big.NewInt(1)))
+}
+
+func putRechargeHeight(elaHash string, height uint32) {
+	if spvTransactiondb == nil {
+		return
+	}
+	elaHash = ethCommon.TrimHexPrefix(elaHash)
+	enc := make([]byte, 4)
+	binary.BigEndian.PutUint32(enc, height)
+	batch := spvTransactiondb.NewBatch()
+	batch.Put([]byte(rechargeHeightPrefix+elaHash), enc)
+	batch.Put(rechargeBlockKey(height, elaHash), nil)
+	if err := batch.Write(); err != nil {
+		log.Error("save recharge height error", "elaHash", elaHash, "error", err)
+	}
+}
+
+// getRechargeHeight returns the main chain block height of the deposit elaHash.
+func getRechargeHeight(elaHash string) (uint32, bool) {
+	data, err := spvTransactiondb.Get([]byte(rechargeHeightPrefix + ethCommon.TrimHexPrefix(elaHash)))
+	if err != nil || len(data) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(data), true
+}
+
+// isBatchable reports whether the deposit elaHash can be carried by a batched
+// recharge. Small cross txs need their signatures in the recharge input and
+// are always sent alone.
+func isBatchable(elaHash string) bool {
+	if _, _, err := smallcrosstx.GetSmallCrossTxBytes(elaHash); err == nil {
+		return false
+	}
+	recharges, _, err := GetRechargeDataByTxhash(elaHash)
+	return err == nil && len(recharges) > 0
+}
+
+// collectRechargeBatch walks the untransacted index from seek and gathers the
+// deposits of the same main chain block, it stops at the first one that is
+// not batchable or belongs to another block.
+func collectRechargeBatch(seek, index uint64) (seeks []uint64, elaHashes []string) {
+	var first uint32
+	for ; seek < index && len(elaHashes) < maxRechargeBatchSize; seek++ {
+		txHash, err := spvTransactiondb.Get(append([]byte(UnTransaction), encodeUnTransactionNumber(seek)...))
+		if err != nil {
+			break
+		}
+		elaHash := string(txHash)
+		height, ok := getRechargeHeight(elaHash)
+		if !ok || !isBatchable(elaHash) {
+			break
+		}
+		if len(elaHashes) == 0 {
+			first = height
+		} else if height != first {
+			break
+		}
+		seeks = append(seeks, seek)
+		elaHashes = append(elaHashes, elaHash)
+	}
+	return seeks, elaHashes
+}
+
+// SendRechargeBatch sends one recharge tx for the deposits elaTxs to txpool,
+// deposits which are completed or failed meanwhile are left out. If the batch
+// can not be sent the deposits are sent one by one instead.
+func SendRechargeBatch(from ethCommon.Address, elaTxs []string) (err error, finished bool) {
+	elaHashes := make([]string, 0, len(elaTxs))
+	for _, elaTx := range elaTxs {
+		if IsCompleted(elaTx, ipcClient) {
+			onElaTxPacked(elaTx)
+			continue
+		}
+		failed, err := IsFailedElaTx(elaTx)
+		if err != nil {
+			return err, false
+		}
+		if !failed {
+			elaHashes = append(elaHashes, elaTx)
+		}
+	}
+	switch len(elaHashes) {
+	case 0:
+		return nil, true
+	case 1:
+		return SendTransaction(from, elaHashes[0], nil)
+	}
+
+	data := GetRechargeBatchData(elaHashes)
+	msg := ethereum.CallMsg{From: from, To: &ELAMinterAddress, Data: data}
+	gasLimit, err := ipcClient.EstimateGas(context.Background(), msg)
+	if err != nil {
+		log.Warn("IpcClient EstimateGas of recharge batch, send one by one", "err", err, "count", len(elaHashes))
+		return sendRechargesOneByOne(from, elaHashes)
+	}
+	if atomic.LoadInt32(&candSend) == 0 {
+		return errors.New("canSend is 0"), false
+	}
+	price, err := ipcClient.SuggestGasPrice(context.Background())
+	if err != nil {
+		log.Error("IpcClient SuggestGasPrice:", "err", err)
+		return err, false
+	}
+	price = price.Mul(price, big.NewInt(2))
+	callmsg := ethereum.TXMsg{From: from, To: &ELAMinterAddress, Gas: gasLimit, Data: data, GasPrice: price}
+	hash, err := ipcClient.SendPublicTransaction(context.Background(), callmsg)
+	if err != nil {
+		log.Warn("Cross chain batch Transaction failed, send one by one", "err", err, "count", len(elaHashes))
+		return sendRechargesOneByOne(from, elaHashes)
+	}
+	log.Info("Cross chain batch Transaction", "count", len(elaHashes), "ethTh", hash.String(), "gasLimit", gasLimit, "price", price.String())
+	onRechargeBatchSent(elaHashes, hash)
+	return nil, true
+}
+
+func sendRechargesOneByOne(from ethCommon.Address, elaHashes []string) (err error, finished bool) {
+	finished = true
+	for _, elaHash := range elaHashes {
+		var done bool
+		err, done = SendTransaction(from, elaHash, nil)
+		if !done {
+			return err, false
+		}
+	}
+	return err, finished
+}
@@ -0,0 +1,89 @@
+package spv
+
+import (
+	"path/filepath"
+	"testing"
+
+	ethCommon "github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/ethdb/leveldb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRechargeBatchInput(t *testing.T) {
+	elaHashes := []string{
+		"0xa3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056",
+		"0x5b6a1e0e2c4f4bd0f1b5a7c3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3",
+	}
+	to := ELAMinterAddress
+	other := ethCommon.HexToAddress("0x01")
+
+	data := GetRechargeBatchData(elaHashes)
+	assert.True(t, IsRechargeTx(data, &to))
+	assert.True(t, IsRechargeBatchTx(data, &to))
+	assert.False(t, IsRechargeBatchTx(data, &other))
+	assert.False(t, IsRechargeTx(data, nil))
+
+	hashes, err := GetElaHashesByTxInput(data)
+	assert.NoError(t, err)
+	assert.Equal(t, elaHashes, hashes)
+
+	inputs, err := SplitRechargeBatch(data)
+	assert.NoError(t, err)
+	assert.Len(t, inputs, len(elaHashes))
+	for i, input := range inputs {
+		assert.True(t, IsRechargeTx(input, &to))
+		assert.False(t, IsRechargeBatchTx(input, &to))
+		elaHash, err := GetElaHashByTxInput(input)
+		assert.NoError(t, err)
+		assert.Equal(t, elaHashes[i], elaHash)
+	}
+
+	// a single recharge is a batch of one
+	single := GetRechargeData(elaHashes[0], []byte{})
+	hashes, err = GetElaHashesByTxInput(single)
+	assert.NoError(t, err)
+	assert.Equal(t, elaHashes[:1], hashes)
+	_, err = SplitRechargeBatch(single)
+	assert.Equal(t, errNotRechargeInput, err)
+
+	_, err = GetElaHashesByTxInput(GetRechargeBatchData(nil))
+	assert.Equal(t, errEmptyRechargeBatch, err)
+}
+
+func TestRechargeBatchSent(t *testing.T) {
+	db, err := leveldb.New(filepath.Join(t.TempDir(), "spv_transaction_info.db"), 16, 16, "")
+	assert.NoError(t, err)
+	spvTransactiondb = db
+	defer func() {
+		db.Close()
+		spvTransactiondb = nil
+	}()
+
+	elaHashes := []string{
+		"a3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056",
+		"5b6a1e0e2c4f4bd0f1b5a7c3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3",
+	}
+	sideTx := ethCommon.HexToHash("0x02")
+	onRechargeBatchSent([]string{"0x" + elaHashes[0], elaHashes[1]}, sideTx)
+
+	hashes, err := GetRechargeElaHashes(sideTx)
+	assert.NoError(t, err)
+	assert.Equal(t, elaHashes, hashes)
+	hash, err := GetRechargeElaHash(sideTx)
+	assert.NoError(t, err)
+	assert.Equal(t, elaHashes[0], hash)
+	for _, elaHash := range elaHashes {
+		status, err := GetRechargeStatus(elaHash)
+		assert.NoError(t, err)
+		assert.Equal(t, RechargeSent, status.State)
+		assert.Equal(t, sideTx, status.SideTxHash)
+	}
+
+	putRechargeHeight(elaHashes[0], 1024)
+	height, ok := getRechargeHeight("0x" + elaHashes[0])
+	assert.True(t, ok)
+	assert.Equal(t, uint32(1024), height)
+	_, ok = getRechargeHeight(elaHashes[1])
+	assert.False(t, ok)
+}
@@ -157,7 +157,11 @@ func IsNexturnBlock(block interface{}) bool {
 }
 
 func InitNextTurnDposInfo() {
-	dynamicArbiterHeight := SpvService.GetBlockListener().(*BlockListener).GetDynamicArbiterHeight()
+	if SpvService == nil {
+		log.Info("init next turn dpos info error, spv is not start")
+		return
+	}
+	dynamicArbiterHeight := SpvService.GetBlockListener().GetDynamicArbiterHeight()
 	if uint64(SpvService.GetBlockListener().BlockHeight()) < dynamicArbiterHeight {
 		log.Info("init next turn dpos info error", "height", SpvService.GetBlockListener().BlockHeight(), "dynamicArbiterHeight", dynamicArbiterHeight)
 		return
@@ -3,6 +3,7 @@ package spv
 import (
 	"bytes"
 	"context"
+	"errors"
 	"math/big"
 	"strings"
 
@@ -19,12 +20,26 @@ var ELAMinterABIMetaData = "[\n    {\n      \"inputs\": [],\n      \"stateMutabi
 var ELAMinterABI abi.ABI
 var ELAMinterAddress = params.ELAMINTER
 
+// RechargeBatchABIMetaData describes the batched recharge input. The ELAMinter
+// contract does not implement it, from the BatchRecharge fork on the state
+// transition executes it as one Recharge call per main chain tx hash.
+var RechargeBatchABIMetaData = "[{\"inputs\":[{\"internalType\":\"bytes32[]\",\"name\":\"elaHashes\",\"type\":\"bytes32[]\"}],\"name\":\"RechargeBatch\",\"outputs\":[],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
+var RechargeBatchABI abi.ABI
+
+// maxRechargeBatchSize is the most main chain txs carried by one batched recharge tx.
+const maxRechargeBatchSize = 32
+
 func init() {
 	minterABI, err := abi.JSON(strings.NewReader(ELAMinterABIMetaData))
 	if err != nil {
 		panic(err)
 	}
 	ELAMinterABI = minterABI
+	batchABI, err := abi.JSON(strings.NewReader(RechargeBatchABIMetaData))
+	if err != nil {
+		panic(err)
+	}
+	RechargeBatchABI = batchABI
 }
 
 func IsWithdrawTx(input []byte, to *common.Address) bool {
@@ -41,6 +56,7 @@ func IsWithdrawTx(input []byte, to *common.Address) bool {
 	return bytes.HasPrefix(input, method.ID)
 }
 
+// IsRechargeTx reports whether the tx recharges one or a batch of main chain txs.
 func IsRechargeTx(input []byte, to *common.Address) bool {
 	if to == nil {
 		return false
@@ -52,6 +68,21 @@ func IsRechargeTx(input []byte, to *common.Address) bool {
 	if !exist {
 		return false
 	}
+	return bytes.HasPrefix(input, method.ID) || IsRechargeBatchTx(input, to)
+}
+
+// IsRechargeBatchTx reports whether the tx recharges a batch of main chain txs.
+func IsRechargeBatchTx(input []byte, to *common.Address) bool {
+	if to == nil {
+		return false
+	}
+	if to.String() != ELAMinterAddress.String() {
+		return false
+	}
+	method, exist := RechargeBatchABI.Methods["RechargeBatch"]
+	if !exist {
+		return false
+	}
 	return bytes.HasPrefix(input, method.ID)
 }
 
@@ -78,6 +109,19 @@ func GetRechargeData(elaHash string, smallCrossTx []byte) []byte {
 	return inputData
 }
 
+// GetRechargeBatchData packs the input of a batched recharge of elaHashes.
+func GetRechargeBatchData(elaHashes []string) []byte {
+	hashes := make([][32]byte, len(elaHashes))
+	for i, elaHash := range elaHashes {
+		hashes[i] = common.HexToHash(elaHash)
+	}
+	inputData, err := RechargeBatchABI.Pack("RechargeBatch", hashes)
+	if err != nil {
+		panic(err)
+	}
+	return inputData
+}
+
 func GetRefundWithdrawData(withdrawHash string) []byte {
 	hash := common.HexToHash(withdrawHash)
 	inputData, err := ELAMinterABI.Pack("refundWithdraw", hash)
@@ -87,27 +131,109 @@ func GetRefundWithdrawData(withdrawHash string) []byte {
 	return inputData
 }
 
+// IsCompletedByTxInput reports whether every main chain tx recharged by input
+// is completed, and returns the first one that is not. A batch whose txs are
+// all completed returns its first tx.
 func IsCompletedByTxInput(input []byte) (bool, string) {
+	elaHashes, err := GetElaHashesByTxInput(input)
+	if err != nil {
+		if err != errNotRechargeInput {
+			log.Error("IsCompletedByTxInput", "error", err)
+		}
+		return false, ""
+	}
+	for _, elaHash := range elaHashes {
+		if !IsCompleted(elaHash, ipcClient) {
+			return false, elaHash
+		}
+	}
+	return true, elaHashes[0]
+}
+
+// GetUncompletedByTxInput returns the main chain txs recharged by input which
+// are not completed yet.
+func GetUncompletedByTxInput(input []byte) []string {
+	elaHashes, err := GetElaHashesByTxInput(input)
+	if err != nil {
+		return nil
+	}
+	uncompleted := make([]string, 0, len(elaHashes))
+	for _, elaHash := range elaHashes {
+		if !IsCompleted(elaHash, ipcClient) {
+			uncompleted = append(uncompleted, elaHash)
+		}
+	}
+	return uncompleted
+}
+
+var (
+	errNotRechargeInput   = errors.New("not recharge tx input")
+	errEmptyRechargeBatch = errors.New("empty recharge batch")
+)
+
+// GetElaHashesByTxInput returns the main chain tx hashes carried by the input
+// of a single or batched recharge tx.
+func GetElaHashesByTxInput(input []byte) ([]string, error) {
+	method := RechargeBatchABI.Methods["RechargeBatch"]
+	if !bytes.HasPrefix(input, method.ID) {
+		elaHash, err := GetElaHashByTxInput(input)
+		if err != nil {
+			return nil, err
+		}
+		return []string{elaHash}, nil
+	}
+	unPackData, err := method.Inputs.UnpackValues(input[len(method.ID):])
+	if err != nil {
+		return nil, err
+	}
+	hashes := unPackData[0].([][32]byte)
+	if len(hashes) == 0 {
+		return nil, errEmptyRechargeBatch
+	}
+	elaHashes := make([]string, len(hashes))
+	for i, hash := range hashes {
+		elaHashes[i] = common.Hash(hash).String()
+	}
+	return elaHashes, nil
+}
+
+// SplitRechargeBatch returns the Recharge input of every main chain tx carried
+// by a batched recharge input.
+func SplitRechargeBatch(input []byte) ([][]byte, error) {
+	if !bytes.HasPrefix(input, RechargeBatchABI.Methods["RechargeBatch"].ID) {
+		return nil, errNotRechargeInput
+	}
+	elaHashes, err := GetElaHashesByTxInput(input)
+	if err != nil {
+		return nil, err
+	}
+	inputs := make([][]byte, len(elaHashes))
+	for i, elaHash := range elaHashes {
+		inputs[i] = GetRechargeData(elaHash, []byte{})
+	}
+	return inputs, nil
+}
+
+// GetElaHashByTxInput returns the main chain tx hash carried by the input of a recharge tx.
+func GetElaHashByTxInput(input []byte) (string, error) {
 	method, exist := ELAMinterABI.Methods["Recharge"]
 	if !exist {
-		return false, ""
+		return "", errNotRechargeInput
 	}
 	if len(input) < 32+len(method.ID) {
-		return false, ""
+		return "", errNotRechargeInput
 	}
 	if bytes.Compare(input[:len(method.ID)], method.ID) != 0 {
-		return false, ""
+		return "", errNotRechargeInput
 	}
 	data := input[len(method.ID):]
 	unPackData, err := method.Inputs.UnpackValues(data)
 	if err != nil {
-		log.Error("IsCompletedByTxInput", "error", err)
-		return false, ""
+		return "", err
 	}
 
 	hash := unPackData[0].([32]byte)
-	elaHash := common.Hash(hash).String()
-	return IsCompleted(elaHash, ipcClient), elaHash
+	return common.Hash(hash).String(), nil
 }
 
 func IsCompleted(elaHashOrWithdrawHash string, ipclient *ethclient.Client) bool {
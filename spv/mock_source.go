@@ -0,0 +1,419 @@
+package spv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	spv "github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.SPV/interface/iutil"
+	"github.com/elastos/Elastos.ELA.SPV/util"
+	"github.com/elastos/Elastos.ELA/common"
+	elacom "github.com/elastos/Elastos.ELA/core/types/common"
+	it "github.com/elastos/Elastos.ELA/core/types/interfaces"
+	elaCrypto "github.com/elastos/Elastos.ELA/crypto"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+)
+
+var (
+	errMockHeaderNotFound = errors.New("main chain header not found")
+	errMockTxNotFound     = errors.New("main chain transaction not found")
+)
+
+// mockBlock is a block of the scripted main chain.
+type mockBlock struct {
+	header         *util.Header
+	txs            []it.Transaction
+	crcArbiters    [][]byte
+	normalArbiters [][]byte
+	consensus      spv.ConsensusAlgorithm
+}
+
+// mockNotification is a transaction notification waiting for its receipt.
+type mockNotification struct {
+	listener spv.TransactionListener
+	proof    bloom.MerkleProof
+	tx       it.Transaction
+}
+
+// MockSource is a MainChainSource replaying a scripted main chain in process,
+// so the consensus and cross chain flows can be tested offline. The blocks
+// added before Start are replayed by Start, the later ones are delivered as
+// they are added. Every transaction matching a listener is notified again
+// with each new block until its receipt is submitted, like the spv module does.
+type MockSource struct {
+	mu sync.Mutex
+
+	blocks   []*mockBlock // the main chain, indexed by height
+	txs      map[common.Uint256]uint32
+	refunded map[common.Uint256]struct{}
+	pending  map[common.Uint256]*mockNotification
+	started  bool
+
+	// producers of the blocks added from now on
+	crcArbiters    [][]byte
+	normalArbiters [][]byte
+	consensus      spv.ConsensusAlgorithm
+
+	nextWorkingHeight  uint32
+	nextCRCArbiters    [][]byte
+	nextNormalArbiters [][]byte
+
+	txListeners    []spv.TransactionListener
+	blockListeners []spv.BlockListener
+	onRollback     []func(height uint32)
+}
+
+// NewMockSource returns a scripted main chain holding the genesis block only.
+func NewMockSource() *MockSource {
+	m := &MockSource{
+		txs:       make(map[common.Uint256]uint32),
+		refunded:  make(map[common.Uint256]struct{}),
+		pending:   make(map[common.Uint256]*mockNotification),
+		consensus: spv.DPOS,
+	}
+	m.blocks = append(m.blocks, m.newBlock(nil))
+	return m
+}
+
+// SetArbiters sets the producers of the blocks added from now on.
+func (m *MockSource) SetArbiters(crcArbiters, normalArbiters [][]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.crcArbiters, m.normalArbiters = crcArbiters, normalArbiters
+}
+
+// SetConsensus sets the consensus of the blocks added from now on.
+func (m *MockSource) SetConsensus(consensus spv.ConsensusAlgorithm) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.consensus = consensus
+}
+
+// SetNextArbiters sets the producers of the next turn.
+func (m *MockSource) SetNextArbiters(workingHeight uint32, crcArbiters, normalArbiters [][]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextWorkingHeight = workingHeight
+	m.nextCRCArbiters, m.nextNormalArbiters = crcArbiters, normalArbiters
+}
+
+// Refund marks the failed deposit txHash as refunded by the main chain.
+func (m *MockSource) Refund(txHash common.Uint256) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refunded[txHash] = struct{}{}
+}
+
+// AddBlock appends a block packing txs to the main chain and returns its
+// header.
+func (m *MockSource) AddBlock(txs ...it.Transaction) (*util.Header, error) {
+	m.mu.Lock()
+	for _, tx := range txs {
+		if _, ok := m.txs[tx.Hash()]; ok {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("main chain transaction %s already packed", tx.Hash())
+		}
+	}
+	block := m.newBlock(txs)
+	if block == nil {
+		m.mu.Unlock()
+		return nil, errors.New("can not compute the merkle root")
+	}
+	m.blocks = append(m.blocks, block)
+	for _, tx := range txs {
+		m.txs[tx.Hash()] = block.header.Height
+	}
+	started := m.started
+	m.mu.Unlock()
+
+	if started {
+		m.deliver(block)
+	}
+	return block.header, nil
+}
+
+// Rollback drops the blocks above height, the rollback listeners are invoked
+// for each of them from the tip down.
+func (m *MockSource) Rollback(height uint32) {
+	m.mu.Lock()
+	var dropped []uint32
+	for uint32(len(m.blocks)) > height+1 {
+		block := m.blocks[len(m.blocks)-1]
+		m.blocks = m.blocks[:len(m.blocks)-1]
+		for _, tx := range block.txs {
+			delete(m.txs, tx.Hash())
+		}
+		for id, n := range m.pending {
+			if n.proof.Height == block.header.Height {
+				delete(m.pending, id)
+			}
+		}
+		dropped = append(dropped, block.header.Height)
+	}
+	onRollback := m.onRollback
+	m.mu.Unlock()
+
+	for _, h := range dropped {
+		for _, fn := range onRollback {
+			fn(h)
+		}
+	}
+}
+
+// Proof returns the merkle proof of the packed main chain transaction txId.
+func (m *MockSource) Proof(txId common.Uint256) (bloom.MerkleProof, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	height, ok := m.txs[txId]
+	if !ok {
+		return bloom.MerkleProof{}, errMockTxNotFound
+	}
+	return m.blocks[height].proof(txId), nil
+}
+
+// newBlock creates the block on top of the tip with the current producers.
+func (m *MockSource) newBlock(txs []it.Transaction) *mockBlock {
+	header := &elacom.Header{Version: 0, Bits: 0x1d03ffff, Timestamp: uint32(len(m.blocks))}
+	if len(m.blocks) > 0 {
+		tip := m.blocks[len(m.blocks)-1].header
+		header.Previous = tip.Hash()
+		header.Height = tip.Height + 1
+	}
+	if len(txs) > 0 {
+		hashes := make([]common.Uint256, 0, len(txs))
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash())
+		}
+		root, err := elaCrypto.ComputeRoot(hashes)
+		if err != nil {
+			return nil
+		}
+		header.MerkleRoot = root
+	}
+	return &mockBlock{
+		header:         &util.Header{BlockHeader: iutil.NewHeader(header), Height: header.Height, NumTxs: uint32(len(txs))},
+		txs:            txs,
+		crcArbiters:    m.crcArbiters,
+		normalArbiters: m.normalArbiters,
+		consensus:      m.consensus,
+	}
+}
+
+func (b *mockBlock) utilBlock() *util.Block {
+	block := &util.Block{Header: *b.header}
+	for _, tx := range b.txs {
+		block.Transactions = append(block.Transactions, iutil.NewTx(tx))
+	}
+	return block
+}
+
+func (b *mockBlock) proof(txId common.Uint256) bloom.MerkleProof {
+	filter := bloom.NewFilter(1, 0, 0, nil)
+	filter.AddHash(&txId)
+	merkleBlock, _ := bloom.NewMerkleBlock(b.utilBlock(), filter)
+	return bloom.MerkleProof{
+		BlockHash:    b.header.Hash(),
+		Height:       b.header.Height,
+		Transactions: merkleBlock.Transactions,
+		Hashes:       merkleBlock.Hashes,
+		Flags:        merkleBlock.Flags,
+	}
+}
+
+// matches reports whether the main chain transaction tx is of interest to
+// listener.
+func matches(listener spv.TransactionListener, tx it.Transaction) bool {
+	if tx.TxType() != listener.Type() {
+		return false
+	}
+	if listener.Address() == "" {
+		return true
+	}
+	programHash, err := common.Uint168FromAddress(listener.Address())
+	if err != nil {
+		return false
+	}
+	for _, output := range tx.Outputs() {
+		if output.ProgramHash == *programHash {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver notifies the block listeners of block, then the transaction
+// listeners of the matching transactions of block and of the ones still
+// waiting for their receipts.
+func (m *MockSource) deliver(block *mockBlock) {
+	m.mu.Lock()
+	blockListeners := m.blockListeners
+	for _, tx := range block.txs {
+		for i, listener := range m.txListeners {
+			if !matches(listener, tx) {
+				continue
+			}
+			txId := tx.Hash()
+			id := common.Uint256(common.Sha256D(append(txId.Bytes(), byte(i))))
+			m.pending[id] = &mockNotification{listener: listener, proof: block.proof(txId), tx: tx}
+		}
+	}
+	notifications := make(map[common.Uint256]*mockNotification, len(m.pending))
+	for id, n := range m.pending {
+		notifications[id] = n
+	}
+	m.mu.Unlock()
+
+	for _, listener := range blockListeners {
+		listener.NotifyBlock(block.utilBlock(), true)
+	}
+	for id, n := range notifications {
+		n.listener.Notify(id, n.proof, n.tx)
+	}
+}
+
+func (m *MockSource) RegisterTransactionListener(listener spv.TransactionListener) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.txListeners = append(m.txListeners, listener)
+	return nil
+}
+
+func (m *MockSource) RegisterBlockListener(listener spv.BlockListener) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.blockListeners = append(m.blockListeners, listener)
+	return nil
+}
+
+func (m *MockSource) RegisterRollbackListener(fn func(height uint32)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onRollback = append(m.onRollback, fn)
+}
+
+func (m *MockSource) SubmitTransactionReceipt(notifyId common.Uint256, txId common.Uint256) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n, ok := m.pending[notifyId]
+	if !ok || n.tx.Hash() != txId {
+		return fmt.Errorf("unknown notification %s of transaction %s", notifyId, txId)
+	}
+	delete(m.pending, notifyId)
+	return nil
+}
+
+func (m *MockSource) BestHeader() (*util.Header, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.blocks[len(m.blocks)-1].header, nil
+}
+
+func (m *MockSource) HeaderByHeight(height uint32) (*util.Header, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if height >= uint32(len(m.blocks)) {
+		return nil, errMockHeaderNotFound
+	}
+	return m.blocks[height].header, nil
+}
+
+func (m *MockSource) HeaderByHash(hash *common.Uint256) (*util.Header, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, block := range m.blocks {
+		if block.header.Hash() == *hash {
+			return block.header, nil
+		}
+	}
+	return nil, errMockHeaderNotFound
+}
+
+func (m *MockSource) GetArbiters(height uint32) ([][]byte, [][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if height >= uint32(len(m.blocks)) {
+		return nil, nil, errMockHeaderNotFound
+	}
+	block := m.blocks[height]
+	return block.crcArbiters, block.normalArbiters, nil
+}
+
+func (m *MockSource) GetNextArbiters() (uint32, [][]byte, [][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.nextWorkingHeight, m.nextCRCArbiters, m.nextNormalArbiters, nil
+}
+
+func (m *MockSource) GetConsensusAlgorithm(height uint32) (spv.ConsensusAlgorithm, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if height >= uint32(len(m.blocks)) {
+		return 0, errMockHeaderNotFound
+	}
+	return m.blocks[height].consensus, nil
+}
+
+func (m *MockSource) GetTransaction(txId *common.Uint256) (it.Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	height, ok := m.txs[*txId]
+	if !ok {
+		return nil, errMockTxNotFound
+	}
+	for _, tx := range m.blocks[height].txs {
+		if tx.Hash() == *txId {
+			return tx, nil
+		}
+	}
+	return nil, errMockTxNotFound
+}
+
+func (m *MockSource) HaveRetSideChainDepositCoinTx(txHash common.Uint256) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.refunded[txHash]
+	return ok
+}
+
+// VerifyTransaction checks proof the way the spv module does.
+func (m *MockSource) VerifyTransaction(proof bloom.MerkleProof, tx it.Transaction) error {
+	header, err := m.HeaderByHash(&proof.BlockHash)
+	if err != nil {
+		return errors.New("can not get block from main chain")
+	}
+	txIds, err := bloom.CheckMerkleBlock(msg.MerkleBlock{
+		Header:       header.BlockHeader,
+		Transactions: proof.Transactions,
+		Hashes:       proof.Hashes,
+		Flags:        proof.Flags,
+	})
+	if err != nil {
+		return fmt.Errorf("check merkle branch failed, %s", err.Error())
+	}
+	for _, txId := range txIds {
+		if *txId == tx.Hash() {
+			return nil
+		}
+	}
+	return errors.New("transaction hash not match proof")
+}
+
+// Start replays the blocks added so far.
+func (m *MockSource) Start() {
+	m.mu.Lock()
+	if m.started {
+		m.mu.Unlock()
+		return
+	}
+	m.started = true
+	blocks := m.blocks[1:]
+	m.mu.Unlock()
+
+	for _, block := range blocks {
+		m.deliver(block)
+	}
+}
+
+func (m *MockSource) Stop() {}
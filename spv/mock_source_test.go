@@ -0,0 +1,178 @@
+package spv
+
+import (
+	"testing"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	spv "github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.SPV/util"
+	"github.com/elastos/Elastos.ELA/common"
+	"github.com/elastos/Elastos.ELA/core/contract/program"
+	elatx "github.com/elastos/Elastos.ELA/core/transaction"
+	elacom "github.com/elastos/Elastos.ELA/core/types/common"
+	it "github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/core/types/payload"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingListener records the deposits notified to it, it submits a
+// receipt only when ack is set.
+type recordingListener struct {
+	source   MainChainSource
+	ack      bool
+	notified []common.Uint256
+	heights  []uint32
+}
+
+func (l *recordingListener) Address() string     { return "" }
+func (l *recordingListener) Type() elacom.TxType { return elacom.TransferCrossChainAsset }
+func (l *recordingListener) Flags() uint64       { return spv.FlagNotifyInSyncing }
+
+func (l *recordingListener) Notify(id common.Uint256, proof bloom.MerkleProof, tx it.Transaction) {
+	l.notified = append(l.notified, tx.Hash())
+	if l.source.VerifyTransaction(proof, tx) != nil {
+		return
+	}
+	if l.ack {
+		l.source.SubmitTransactionReceipt(id, tx.Hash())
+	}
+}
+
+func (l *recordingListener) NotifyBlock(block *util.Block, isCurrent bool) {
+	l.heights = append(l.heights, block.Height)
+}
+
+func (l *recordingListener) BlockHeight() uint32 {
+	if len(l.heights) == 0 {
+		return 0
+	}
+	return l.heights[len(l.heights)-1]
+}
+
+func (l *recordingListener) StoreAuxBlock(block interface{}) {}
+
+func (l *recordingListener) RegisterFunc(handleFunc func(block interface{}) error) {}
+
+func newMockTx(txType elacom.TxType, nonce uint32) it.Transaction {
+	return elatx.CreateTransaction(
+		elacom.TxVersion09,
+		txType,
+		0,
+		&payload.TransferCrossChainAsset{},
+		[]*elacom.Attribute{},
+		[]*elacom.Input{},
+		[]*elacom.Output{},
+		nonce,
+		[]*program.Program{},
+	)
+}
+
+func TestMockSource(t *testing.T) {
+	source := NewMockSource()
+	acked := &recordingListener{source: source, ack: true}
+	unacked := &recordingListener{source: source}
+	assert.NoError(t, source.RegisterTransactionListener(acked))
+	assert.NoError(t, source.RegisterTransactionListener(unacked))
+	assert.NoError(t, source.RegisterBlockListener(acked))
+	var rolledBack []uint32
+	source.RegisterRollbackListener(func(height uint32) { rolledBack = append(rolledBack, height) })
+
+	crc := [][]byte{{0x02, 0x01}}
+	normal := [][]byte{{0x03, 0x01}}
+	source.SetArbiters(crc, normal)
+	deposit := newMockTx(elacom.TransferCrossChainAsset, 1)
+	other := newMockTx(elacom.TransferAsset, 2)
+	_, err := source.AddBlock(other, deposit)
+	assert.NoError(t, err)
+	_, err = source.AddBlock(deposit)
+	assert.Error(t, err)
+
+	// the scripted blocks are replayed by Start
+	assert.Empty(t, acked.heights)
+	source.Start()
+	assert.Equal(t, []uint32{1}, acked.heights)
+	assert.Equal(t, []common.Uint256{deposit.Hash()}, acked.notified)
+	assert.Equal(t, []common.Uint256{deposit.Hash()}, unacked.notified)
+
+	// a deposit without receipt is notified again with the next block
+	source.SetConsensus(spv.POW)
+	header, err := source.AddBlock()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), header.Height)
+	assert.Equal(t, []uint32{1, 2}, acked.heights)
+	assert.Len(t, acked.notified, 1)
+	assert.Len(t, unacked.notified, 2)
+
+	best, err := source.BestHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, header.Hash(), best.Hash())
+	block1, err := source.HeaderByHeight(1)
+	assert.NoError(t, err)
+	assert.Equal(t, block1.Hash(), header.Previous())
+	hash := block1.Hash()
+	byHash, err := source.HeaderByHash(&hash)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), byHash.Height)
+
+	_, err = source.GetTransaction(&hash)
+	assert.Error(t, err)
+	depositHash := deposit.Hash()
+	tx, err := source.GetTransaction(&depositHash)
+	assert.NoError(t, err)
+	assert.Equal(t, depositHash, tx.Hash())
+
+	// merkle proofs
+	proof, err := source.Proof(depositHash)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), proof.Height)
+	assert.NoError(t, source.VerifyTransaction(proof, deposit))
+	assert.Error(t, source.VerifyTransaction(proof, newMockTx(elacom.TransferCrossChainAsset, 3)))
+
+	// producer sets and consensus per height
+	gotCRC, gotNormal, err := source.GetArbiters(1)
+	assert.NoError(t, err)
+	assert.Equal(t, crc, gotCRC)
+	assert.Equal(t, normal, gotNormal)
+	_, _, err = source.GetArbiters(3)
+	assert.Error(t, err)
+	consensus, err := source.GetConsensusAlgorithm(1)
+	assert.NoError(t, err)
+	assert.Equal(t, spv.DPOS, consensus)
+	consensus, err = source.GetConsensusAlgorithm(2)
+	assert.NoError(t, err)
+	assert.Equal(t, spv.POW, consensus)
+	source.SetNextArbiters(10, normal, crc)
+	working, nextCRC, _, err := source.GetNextArbiters()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), working)
+	assert.Equal(t, normal, nextCRC)
+
+	assert.False(t, source.HaveRetSideChainDepositCoinTx(depositHash))
+	source.Refund(depositHash)
+	assert.True(t, source.HaveRetSideChainDepositCoinTx(depositHash))
+
+	// a rollback drops the blocks and the deposits waiting for receipts
+	source.Rollback(0)
+	assert.Equal(t, []uint32{2, 1}, rolledBack)
+	_, err = source.GetTransaction(&depositHash)
+	assert.Error(t, err)
+	_, err = source.AddBlock()
+	assert.NoError(t, err)
+	assert.Len(t, unacked.notified, 2)
+}
+
+func TestDepositConfirmedBySource(t *testing.T) {
+	defer func(depth uint32) { confirmationDepth = depth }(confirmationDepth)
+
+	source := NewMockSource()
+	for i := 0; i < 5; i++ {
+		_, err := source.AddBlock()
+		assert.NoError(t, err)
+	}
+	confirmationDepth = 3
+	assert.True(t, isDepositConfirmed(source, 2))
+	assert.False(t, isDepositConfirmed(source, 3))
+	confirmationDepth = 0
+	assert.True(t, isDepositConfirmed(source, 5))
+}
@@ -151,7 +151,7 @@ func GetProducers(elaHeight uint64) ([][]byte, int, error) {
 
 func GetSpvHeight() uint64 {
 	if SpvService != nil && SpvService.GetBlockListener() != nil {
-		header, err := SpvService.HeaderStore().GetBest()
+		header, err := SpvService.BestHeader()
 		if err != nil {
 			log.Error("SpvService getBest error", "error", err)
 			return uint64(SpvService.GetBlockListener().BlockHeight())
@@ -0,0 +1,71 @@
+package spv
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	"github.com/elastos/Elastos.ELA.SPV/interface/iutil"
+	"github.com/elastos/Elastos.ELA/common"
+	elatx "github.com/elastos/Elastos.ELA/core/transaction"
+	elatypes "github.com/elastos/Elastos.ELA/core/types/common"
+	it "github.com/elastos/Elastos.ELA/core/types/interfaces"
+	"github.com/elastos/Elastos.ELA/p2p/msg"
+)
+
+var (
+	ErrProofHeaderMismatch = errors.New("merkle proof is not of the main chain header")
+	ErrSpvNotStarted       = errors.New("spv is not start")
+	ErrProofTxMismatch     = errors.New("transaction hash not match proof")
+)
+
+// DecodeMainChainProof decodes a serialized main chain transaction and the
+// serialized merkle proof of its inclusion.
+func DecodeMainChainProof(rawTx, rawProof []byte) (it.Transaction, *bloom.MerkleProof, error) {
+	r := bytes.NewReader(rawTx)
+	tx, err := elatx.GetTransactionByBytes(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid main chain transaction: %v", err)
+	}
+	if err := tx.Deserialize(r); err != nil {
+		return nil, nil, fmt.Errorf("invalid main chain transaction: %v", err)
+	}
+	proof := new(bloom.MerkleProof)
+	if err := proof.Deserialize(bytes.NewReader(rawProof)); err != nil {
+		return nil, nil, fmt.Errorf("invalid merkle proof: %v", err)
+	}
+	return tx, proof, nil
+}
+
+// VerifyMainChainTx checks that tx is packed in the main chain block
+// headerHash by proof, the header must be accepted by VerifyElaHeader.
+func (s *Service) VerifyMainChainTx(headerHash common.Uint256, proof *bloom.MerkleProof, tx it.Transaction) error {
+	if proof.BlockHash != headerHash {
+		return ErrProofHeaderMismatch
+	}
+	if err := s.VerifyElaHeader(&headerHash); err != nil {
+		return err
+	}
+	return s.VerifyTransaction(*proof, tx)
+}
+
+// VerifyMainChainTxInBlock checks that tx is packed by proof in the main chain
+// block with merkleRoot, without the header store of the spv.
+func VerifyMainChainTxInBlock(merkleRoot common.Uint256, proof *bloom.MerkleProof, tx it.Transaction) error {
+	txIds, err := bloom.CheckMerkleBlock(msg.MerkleBlock{
+		Header:       iutil.NewHeader(&elatypes.Header{MerkleRoot: merkleRoot}),
+		Transactions: proof.Transactions,
+		Hashes:       proof.Hashes,
+		Flags:        proof.Flags,
+	})
+	if err != nil {
+		return fmt.Errorf("check merkle branch failed, %s", err.Error())
+	}
+	for _, txId := range txIds {
+		if *txId == tx.Hash() {
+			return nil
+		}
+	}
+	return ErrProofTxMismatch
+}
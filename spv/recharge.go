@@ -2,10 +2,15 @@ package spv
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"strings"
+	"sync"
 
 	ethCommon "github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/event"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
 
 	"github.com/elastos/Elastos.ELA/common"
 	typeCommon "github.com/elastos/Elastos.ELA/core/types/common"
@@ -121,3 +126,219 @@ func TrySetRechargeDataFromSpvService(elaHash string) error {
 	}
 	return nil
 }
+
+// RechargeState is the lifecycle state of a main chain deposit.
+type RechargeState uint8
+
+const (
+	RechargeUnknown RechargeState = iota
+	// RechargeSeen the deposit is reported by the spv module
+	RechargeSeen
+	// RechargePayloadSaved the recharge outputs are saved to the spv database
+	RechargePayloadSaved
+	// RechargeSent a recharge tx is sent to the sidechain tx pool
+	RechargeSent
+	// RechargePacked the recharge tx is packed into a sidechain block
+	RechargePacked
+	// RechargeFailed the recharge can not be executed on the sidechain
+	RechargeFailed
+	// RechargeRefunded the main chain has returned the deposit
+	RechargeRefunded
+	// RechargeOrphaned the main chain block of the deposit is rolled back
+	RechargeOrphaned
+)
+
+var rechargeStateNames = map[RechargeState]string{
+	RechargeUnknown:      "unknown",
+	RechargeSeen:         "seen",
+	RechargePayloadSaved: "payloadSaved",
+	RechargeSent:         "sent",
+	RechargePacked:       "packed",
+	RechargeFailed:       "failed",
+	RechargeRefunded:     "refunded",
+	RechargeOrphaned:     "orphaned",
+}
+
+func (s RechargeState) String() string {
+	if name, ok := rechargeStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("RechargeState(%d)", uint8(s))
+}
+
+func (s RechargeState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+const (
+	// rechargeStatusPrefix + elaHash -> RechargeStatus
+	rechargeStatusPrefix = "RcS-"
+	// rechargeTxPrefix + sidechain tx hash -> elaHash
+	rechargeTxPrefix = "RcT-"
+)
+
+// RechargeStatus is the state machine view of one main chain deposit.
+type RechargeStatus struct {
+	ElaHash     string         `json:"elaHash"`
+	State       RechargeState  `json:"state"`
+	SideTxHash  ethCommon.Hash `json:"sideTxHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	Reason      string         `json:"reason,omitempty"`
+}
+
+var (
+	rechargeStatusMu   sync.Mutex
+	rechargeStatusFeed event.Feed
+)
+
+// SubscribeRechargeStatus registers a subscription of recharge state transitions.
+func SubscribeRechargeStatus(ch chan<- RechargeStatus) event.Subscription {
+	return rechargeStatusFeed.Subscribe(ch)
+}
+
+// GetRechargeStatus returns the recorded lifecycle state of elaHash.
+func GetRechargeStatus(elaHash string) (*RechargeStatus, error) {
+	if spvTransactiondb == nil {
+		return nil, errors.New("spvTransactiondb is not inited")
+	}
+	elaHash = ethCommon.TrimHexPrefix(elaHash)
+	data, err := spvTransactiondb.Get([]byte(rechargeStatusPrefix + elaHash))
+	if err != nil {
+		return nil, err
+	}
+	status := new(RechargeStatus)
+	if err := rlp.DecodeBytes(data, status); err != nil {
+		return nil, err
+	}
+	status.ElaHash = elaHash
+	return status, nil
+}
+
+// GetRechargeElaHash returns the main chain tx hash of a recharge tx sent by
+// this node, the first one if the recharge tx is a batch.
+func GetRechargeElaHash(sideTxHash ethCommon.Hash) (string, error) {
+	elaHashes, err := GetRechargeElaHashes(sideTxHash)
+	if err != nil {
+		return "", err
+	}
+	return elaHashes[0], nil
+}
+
+// GetRechargeElaHashes returns the main chain tx hashes of a recharge tx sent by this node.
+func GetRechargeElaHashes(sideTxHash ethCommon.Hash) ([]string, error) {
+	if spvTransactiondb == nil {
+		return nil, errors.New("spvTransactiondb is not inited")
+	}
+	data, err := spvTransactiondb.Get(append([]byte(rechargeTxPrefix), sideTxHash.Bytes()...))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(data), ","), nil
+}
+
+// SetRechargePacked records that the recharge of elaHash is packed at blockNumber.
+func SetRechargePacked(elaHash string, sideTxHash ethCommon.Hash, blockNumber uint64) {
+	setRechargeStatus(elaHash, func(status *RechargeStatus) {
+		status.State = RechargePacked
+		status.SideTxHash = sideTxHash
+		status.BlockNumber = blockNumber
+		status.Reason = ""
+	})
+}
+
+// SetRechargeFailed records that the recharge of elaHash failed for reason.
+func SetRechargeFailed(elaHash string, reason string) {
+	setRechargeStatus(elaHash, func(status *RechargeStatus) {
+		status.State = RechargeFailed
+		status.Reason = reason
+	})
+}
+
+// SetRechargeRefunded records that the main chain has returned the deposit of elaHash.
+func SetRechargeRefunded(elaHash string) {
+	updateRechargeState(elaHash, RechargeRefunded)
+}
+
+func updateRechargeState(elaHash string, state RechargeState) {
+	setRechargeStatus(elaHash, func(status *RechargeStatus) {
+		// states only move forward, except that a failed recharge may still
+		// be sent again and packed, and an orphaned deposit may be seen again
+		// in the new main chain
+		if status.State == RechargeOrphaned {
+			if state == RechargeRefunded {
+				return
+			}
+		} else if status.State == RechargeFailed {
+			if state < RechargeSent {
+				return
+			}
+		} else if status.State >= state {
+			return
+		}
+		status.State = state
+	})
+}
+
+func onRechargeSent(elaHash string, sideTxHash ethCommon.Hash) {
+	onRechargeBatchSent([]string{elaHash}, sideTxHash)
+}
+
+// onRechargeBatchSent records that the recharges of elaHashes are sent by the
+// recharge tx sideTxHash.
+func onRechargeBatchSent(elaHashes []string, sideTxHash ethCommon.Hash) {
+	hashes := make([]string, len(elaHashes))
+	for i, elaHash := range elaHashes {
+		hashes[i] = ethCommon.TrimHexPrefix(elaHash)
+	}
+	if spvTransactiondb != nil {
+		err := spvTransactiondb.Put(append([]byte(rechargeTxPrefix), sideTxHash.Bytes()...), []byte(strings.Join(hashes, ",")))
+		if err != nil {
+			log.Error("save recharge tx hash error", "elaHashes", hashes, "txHash", sideTxHash.String(), "error", err)
+		}
+	}
+	for _, elaHash := range hashes {
+		setRechargeSent(elaHash, sideTxHash)
+	}
+}
+
+func setRechargeSent(elaHash string, sideTxHash ethCommon.Hash) {
+	setRechargeStatus(elaHash, func(status *RechargeStatus) {
+		if status.State == RechargePacked || status.State == RechargeRefunded {
+			return
+		}
+		status.State = RechargeSent
+		status.SideTxHash = sideTxHash
+		status.Reason = ""
+	})
+}
+
+// setRechargeStatus applies update to the recorded status of elaHash, then
+// persists and broadcasts it if anything changed.
+func setRechargeStatus(elaHash string, update func(status *RechargeStatus)) {
+	if spvTransactiondb == nil {
+		return
+	}
+	elaHash = ethCommon.TrimHexPrefix(elaHash)
+	rechargeStatusMu.Lock()
+	status, err := GetRechargeStatus(elaHash)
+	if err != nil {
+		status = &RechargeStatus{ElaHash: elaHash}
+	}
+	old := *status
+	update(status)
+	if old == *status {
+		rechargeStatusMu.Unlock()
+		return
+	}
+	data, err := rlp.EncodeToBytes(status)
+	if err == nil {
+		err = spvTransactiondb.Put([]byte(rechargeStatusPrefix+elaHash), data)
+	}
+	rechargeStatusMu.Unlock()
+	if err != nil {
+		log.Error("save recharge status error", "elaHash", elaHash, "error", err)
+		return
+	}
+	log.Debug("recharge state changed", "elaHash", elaHash, "from", old.State, "to", status.State)
+	rechargeStatusFeed.Send(*status)
+}
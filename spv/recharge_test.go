@@ -0,0 +1,64 @@
+package spv
+
+import (
+	"path/filepath"
+	"testing"
+
+	ethCommon "github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/ethdb/leveldb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRechargeStatusTransitions(t *testing.T) {
+	db, err := leveldb.New(filepath.Join(t.TempDir(), "spv_transaction_info.db"), 16, 16, "")
+	assert.NoError(t, err)
+	spvTransactiondb = db
+	defer func() {
+		db.Close()
+		spvTransactiondb = nil
+	}()
+
+	statuses := make(chan RechargeStatus, 16)
+	sub := SubscribeRechargeStatus(statuses)
+	defer sub.Unsubscribe()
+
+	elaHash := "a3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056"
+	sideTx := ethCommon.HexToHash("0x01")
+
+	updateRechargeState(elaHash, RechargeSeen)
+	updateRechargeState(elaHash, RechargePayloadSaved)
+	// a late spv notification does not move the state back
+	updateRechargeState(elaHash, RechargeSeen)
+	SetRechargeFailed(elaHash, "execution reverted")
+	updateRechargeState(elaHash, RechargeSeen)
+	onRechargeSent("0x"+elaHash, sideTx)
+	SetRechargePacked(elaHash, sideTx, 100)
+
+	expected := []RechargeState{RechargeSeen, RechargePayloadSaved, RechargeFailed, RechargeSent, RechargePacked}
+	for _, state := range expected {
+		status := <-statuses
+		assert.Equal(t, elaHash, status.ElaHash)
+		assert.Equal(t, state, status.State)
+	}
+	select {
+	case status := <-statuses:
+		t.Fatalf("unexpected transition to %s", status.State)
+	default:
+	}
+
+	status, err := GetRechargeStatus("0x" + elaHash)
+	assert.NoError(t, err)
+	assert.Equal(t, RechargePacked, status.State)
+	assert.Equal(t, sideTx, status.SideTxHash)
+	assert.Equal(t, uint64(100), status.BlockNumber)
+	assert.Empty(t, status.Reason)
+
+	hash, err := GetRechargeElaHash(sideTx)
+	assert.NoError(t, err)
+	assert.Equal(t, elaHash, hash)
+
+	text, err := status.State.MarshalText()
+	assert.NoError(t, err)
+	assert.Equal(t, "packed", string(text))
+}
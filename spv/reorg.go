@@ -0,0 +1,134 @@
+package spv
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/metrics"
+)
+
+const (
+	// rechargeBlockPrefix + main chain height + elaHash -> nil
+	rechargeBlockPrefix = "RcB-"
+)
+
+var (
+	// confirmationDepth is the number of main chain blocks on top of a
+	// deposit before it is recharged, 0 leaves it to the spv module.
+	confirmationDepth uint32
+
+	orphanedDepositCounter       = metrics.NewRegisteredCounter("spv/deposit/orphaned", nil)
+	orphanedPackedDepositCounter = metrics.NewRegisteredCounter("spv/deposit/orphaned/packed", nil)
+)
+
+func rechargeBlockKey(height uint32, elaHash string) []byte {
+	key := make([]byte, 0, len(rechargeBlockPrefix)+4+len(elaHash))
+	key = append(key, rechargeBlockPrefix...)
+	key = binary.BigEndian.AppendUint32(key, height)
+	return append(key, elaHash...)
+}
+
+// isDepositConfirmed reports whether a deposit in the main chain block at
+// height is buried by confirmationDepth blocks.
+func isDepositConfirmed(source MainChainSource, height uint32) bool {
+	if confirmationDepth == 0 || source == nil {
+		return true
+	}
+	best, err := source.BestHeader()
+	if err != nil {
+		log.Error("get best main chain header error", "error", err)
+		return false
+	}
+	return depositConfirmed(best.Height, height)
+}
+
+func depositConfirmed(bestHeight, height uint32) bool {
+	return bestHeight >= height && bestHeight-height >= confirmationDepth
+}
+
+// isRechargeOrphaned reports whether the deposit elaHash is rolled back by a
+// main chain reorg and not seen again since.
+func isRechargeOrphaned(elaHash string) bool {
+	status, err := GetRechargeStatus(elaHash)
+	return err == nil && status.State == RechargeOrphaned
+}
+
+// onMainChainRollback is invoked by the spv module when the main chain block
+// at height is rolled back, the deposits of the block that are not packed yet
+// are removed until the spv module reports them again.
+func onMainChainRollback(height uint32) {
+	if spvTransactiondb == nil {
+		return
+	}
+	prefix := rechargeBlockKey(height, "")
+	it := spvTransactiondb.NewIteratorWithPrefix(prefix)
+	elaHashes := make([]string, 0)
+	for it.Next() {
+		elaHashes = append(elaHashes, string(it.Key()[len(prefix):]))
+	}
+	it.Release()
+
+	for _, elaHash := range elaHashes {
+		rollbackDeposit(elaHash, height)
+	}
+}
+
+func rollbackDeposit(elaHash string, height uint32) {
+	if IsCompleted(elaHash, ipcClient) {
+		// the sidechain has minted the deposit already, it can only be
+		// settled by hand
+		orphanedPackedDepositCounter.Inc(1)
+		log.Error("packed deposit orphaned by main chain reorg", "elaHash", elaHash, "height", height)
+		if err := spvTransactiondb.Delete(rechargeBlockKey(height, elaHash)); err != nil {
+			log.Error("delete recharge block error", "elaHash", elaHash, "error", err)
+		}
+		return
+	}
+
+	transactionDBMutex.Lock()
+	batch := spvTransactiondb.NewBatch()
+	for _, suffix := range []string{"Fee", "Address", "Output", "Input"} {
+		batch.Delete([]byte(elaHash + suffix))
+	}
+	batch.Delete([]byte(rechargeHeightPrefix + elaHash))
+	batch.Delete([]byte(rechargeRetryPrefix + elaHash))
+	batch.Delete(rechargeBlockKey(height, elaHash))
+	err := batch.Write()
+	if spvTxhash == elaHash {
+		// let the deposit be saved again if it is reported again
+		spvTxhash = ""
+	}
+	transactionDBMutex.Unlock()
+	if err != nil {
+		log.Error("rollback deposit error", "elaHash", elaHash, "error", err)
+		return
+	}
+	removeUnTransaction(elaHash)
+	removeFailedElaTx(elaHash)
+	setRechargeStatus(elaHash, func(status *RechargeStatus) {
+		status.State = RechargeOrphaned
+		status.Reason = fmt.Sprintf("main chain block %d is rolled back", height)
+	})
+	orphanedDepositCounter.Inc(1)
+	log.Warn("deposit orphaned by main chain reorg", "elaHash", elaHash, "height", height)
+}
+
+// removeUnTransaction deletes elaHash from the untransacted index.
+func removeUnTransaction(elaHash string) {
+	muupti.Lock()
+	defer muupti.Unlock()
+	it := spvTransactiondb.NewIteratorWithPrefix([]byte(UnTransaction))
+	keys := make([][]byte, 0)
+	for it.Next() {
+		if string(it.Value()) == elaHash {
+			keys = append(keys, append([]byte{}, it.Key()...))
+		}
+	}
+	it.Release()
+	for _, key := range keys {
+		if err := spvTransactiondb.Delete(key); err != nil {
+			log.Error("delete UnTransaction error", "elaHash", elaHash, "error", err)
+		}
+	}
+}
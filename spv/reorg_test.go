@@ -0,0 +1,73 @@
+package spv
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/ethdb/leveldb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDepositConfirmed(t *testing.T) {
+	defer func(depth uint32) { confirmationDepth = depth }(confirmationDepth)
+
+	confirmationDepth = 10
+	assert.False(t, depositConfirmed(100, 95))
+	assert.False(t, depositConfirmed(100, 101))
+	assert.True(t, depositConfirmed(105, 95))
+	assert.True(t, depositConfirmed(200, 95))
+}
+
+func TestRollbackDeposit(t *testing.T) {
+	db, err := leveldb.New(filepath.Join(t.TempDir(), "spv_transaction_info.db"), 16, 16, "")
+	assert.NoError(t, err)
+	spvTransactiondb = db
+	defer func() {
+		db.Close()
+		spvTransactiondb = nil
+	}()
+
+	orphaned := "a3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056"
+	kept := "5b6a1e0e2c4f4bd0f1b5a7c3c2d1e0f9a8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3"
+	for height, elaHash := range map[uint32]string{100: orphaned, 99: kept} {
+		for _, suffix := range []string{"Fee", "Address", "Output", "Input"} {
+			assert.NoError(t, db.Put([]byte(elaHash+suffix), []byte("1")))
+		}
+		putRechargeHeight(elaHash, height)
+		updateRechargeState(elaHash, RechargePayloadSaved)
+		UpTransactionIndex(elaHash)
+	}
+
+	onMainChainRollback(100)
+
+	for _, suffix := range []string{"Fee", "Address", "Output", "Input"} {
+		has, _ := db.Has([]byte(orphaned + suffix))
+		assert.False(t, has)
+		has, _ = db.Has([]byte(kept + suffix))
+		assert.True(t, has)
+	}
+	_, ok := getRechargeHeight(orphaned)
+	assert.False(t, ok)
+	status, err := GetRechargeStatus(orphaned)
+	assert.NoError(t, err)
+	assert.Equal(t, RechargeOrphaned, status.State)
+	assert.True(t, isRechargeOrphaned(orphaned))
+	assert.False(t, isRechargeOrphaned(kept))
+
+	queued := make([]string, 0)
+	it := db.NewIteratorWithPrefix([]byte(UnTransaction))
+	for it.Next() {
+		queued = append(queued, string(it.Value()))
+	}
+	it.Release()
+	assert.Equal(t, []string{kept}, queued)
+
+	// an orphaned deposit is not queued again, until the new main chain has it
+	UpTransactionIndex(orphaned)
+	assert.Equal(t, uint64(3), GetUnTransactionNum(db, UnTransactionIndex))
+	updateRechargeState(orphaned, RechargeSeen)
+	assert.False(t, isRechargeOrphaned(orphaned))
+	UpTransactionIndex(orphaned)
+	assert.Equal(t, uint64(4), GetUnTransactionNum(db, UnTransactionIndex))
+}
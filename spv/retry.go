@@ -0,0 +1,249 @@
+package spv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/blocksigner"
+	ethCommon "github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+// RechargeFailure classifies why a recharge tx could not be sent.
+type RechargeFailure uint8
+
+const (
+	FailureUnknown RechargeFailure = iota
+	// FailureNonce the tx pool rejected the nonce of the recharge tx
+	FailureNonce
+	// FailureGas the recharge tx can not pay or estimate its gas
+	FailureGas
+	// FailureCompleted the recharge is already completed by another tx
+	FailureCompleted
+	// FailureRevert the ELAMinter contract reverted the recharge
+	FailureRevert
+)
+
+var rechargeFailureNames = map[RechargeFailure]string{
+	FailureUnknown:   "unknown",
+	FailureNonce:     "nonce",
+	FailureGas:       "gas",
+	FailureCompleted: "completed",
+	FailureRevert:    "revert",
+}
+
+func (f RechargeFailure) String() string {
+	if name, ok := rechargeFailureNames[f]; ok {
+		return name
+	}
+	return fmt.Sprintf("RechargeFailure(%d)", uint8(f))
+}
+
+func (f RechargeFailure) MarshalText() ([]byte, error) {
+	return []byte(f.String()), nil
+}
+
+// Retryable reports whether sending the recharge again may succeed.
+func (f RechargeFailure) Retryable() bool {
+	switch f {
+	case FailureCompleted, FailureRevert:
+		return false
+	}
+	return true
+}
+
+const (
+	// rechargeRetryPrefix + elaHash -> RechargeRetry
+	rechargeRetryPrefix = "RcR-"
+
+	retryBaseInterval  = 30 * time.Second
+	retryMaxInterval   = 30 * time.Minute
+	retryCheckInterval = 10 * time.Second
+	maxRechargeRetries = 8
+)
+
+// RechargeRetry is the persisted retry state of a recharge that failed to send.
+type RechargeRetry struct {
+	Attempts  uint32          `json:"attempts"`
+	NextRetry uint64          `json:"nextRetry"`
+	Failure   RechargeFailure `json:"failure"`
+	Reason    string          `json:"reason"`
+	GaveUp    bool            `json:"gaveUp"`
+}
+
+func classifyRechargeError(err error) RechargeFailure {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, strings.ToLower(ErrMainTxHashCompleted.Error())),
+		strings.Contains(msg, "already completed"):
+		return FailureCompleted
+	case strings.Contains(msg, "nonce"):
+		return FailureNonce
+	case strings.Contains(msg, "revert"):
+		return FailureRevert
+	case strings.Contains(msg, "gas"),
+		strings.Contains(msg, "underpriced"),
+		strings.Contains(msg, "insufficient funds"):
+		return FailureGas
+	}
+	return FailureUnknown
+}
+
+// retryBackoff returns the delay before the next retry after attempts failures.
+func retryBackoff(attempts uint32) time.Duration {
+	delay := retryBaseInterval
+	for i := uint32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMaxInterval {
+			return retryMaxInterval
+		}
+	}
+	return delay
+}
+
+// GetRechargeRetry returns the retry state of elaHash.
+func GetRechargeRetry(elaHash string) (*RechargeRetry, error) {
+	if spvTransactiondb == nil {
+		return nil, errors.New("spvTransactiondb is not inited")
+	}
+	data, err := spvTransactiondb.Get([]byte(rechargeRetryPrefix + ethCommon.TrimHexPrefix(elaHash)))
+	if err != nil {
+		return nil, err
+	}
+	retry := new(RechargeRetry)
+	if err := rlp.DecodeBytes(data, retry); err != nil {
+		return nil, err
+	}
+	return retry, nil
+}
+
+func putRechargeRetry(elaHash string, retry *RechargeRetry) error {
+	data, err := rlp.EncodeToBytes(retry)
+	if err != nil {
+		return err
+	}
+	return spvTransactiondb.Put([]byte(rechargeRetryPrefix+ethCommon.TrimHexPrefix(elaHash)), data)
+}
+
+func deleteRechargeRetry(elaHash string) {
+	if spvTransactiondb == nil {
+		return
+	}
+	key := []byte(rechargeRetryPrefix + ethCommon.TrimHexPrefix(elaHash))
+	if has, _ := spvTransactiondb.Has(key); !has {
+		return
+	}
+	if err := spvTransactiondb.Delete(key); err != nil {
+		log.Error("delete recharge retry error", "elaHash", elaHash, "error", err)
+	}
+}
+
+// onRechargeError classifies a recharge send error and schedules a retry, or
+// records the recharge as failed when it can not be retried.
+// It returns true if a retry is scheduled.
+func onRechargeError(elaTx string, sendErr error) bool {
+	if spvTransactiondb == nil {
+		OnTx2Failed(elaTx, sendErr.Error())
+		return false
+	}
+	failure := classifyRechargeError(sendErr)
+	retry, err := GetRechargeRetry(elaTx)
+	if err != nil {
+		retry = new(RechargeRetry)
+	}
+	retry.Attempts++
+	retry.Failure = failure
+	retry.Reason = sendErr.Error()
+
+	switch {
+	case failure == FailureCompleted:
+		retry.GaveUp = true
+		onElaTxPacked(ethCommon.TrimHexPrefix(elaTx))
+	case !failure.Retryable() || retry.Attempts >= maxRechargeRetries:
+		retry.GaveUp = true
+		OnTx2Failed(elaTx, fmt.Sprintf("%s: %s", failure, sendErr))
+	default:
+		retry.NextRetry = uint64(time.Now().Add(retryBackoff(retry.Attempts)).Unix())
+		SetRechargeFailed(elaTx, fmt.Sprintf("retry %d scheduled, %s: %s", retry.Attempts, failure, sendErr))
+	}
+	if err := putRechargeRetry(elaTx, retry); err != nil {
+		log.Error("save recharge retry error", "elaHash", elaTx, "error", err)
+	}
+	log.Info("recharge tx send failed", "elaHash", elaTx, "failure", failure,
+		"attempts", retry.Attempts, "gaveUp", retry.GaveUp, "error", sendErr)
+	return !retry.GaveUp
+}
+
+// retryLoop resends the due recharges while this node is the on duty producer.
+func (s *Service) retryLoop() {
+	ticker := time.NewTicker(retryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if atomic.LoadInt32(&candSend) == 0 || !blocksigner.SelfIsProducer {
+				continue
+			}
+			s.retryDueRecharges()
+		case <-stopChn:
+			return
+		}
+	}
+}
+
+func (s *Service) retryDueRecharges() {
+	if spvTransactiondb == nil {
+		return
+	}
+	now := uint64(time.Now().Unix())
+	due := make([]string, 0)
+	prefix := []byte(rechargeRetryPrefix)
+	it := spvTransactiondb.NewIteratorWithPrefix(prefix)
+	for it.Next() {
+		var retry RechargeRetry
+		if err := rlp.DecodeBytes(it.Value(), &retry); err != nil {
+			continue
+		}
+		if !retry.GaveUp && retry.NextRetry <= now {
+			due = append(due, string(it.Key()[len(prefix):]))
+		}
+	}
+	it.Release()
+
+	for _, elaHash := range due {
+		if atomic.LoadInt32(&candSend) == 0 {
+			return
+		}
+		retryRecharge(elaHash)
+	}
+}
+
+func retryRecharge(elaHash string) {
+	if IsCompleted(elaHash, ipcClient) {
+		onElaTxPacked(elaHash)
+		return
+	}
+	_, fee, err := GetRechargeDataByTxhash(elaHash)
+	if err != nil {
+		onRechargeError(elaHash, err)
+		return
+	}
+	err, _ = SendTransaction(GetDefaultSingerAddr(), elaHash, fee)
+	if err != nil {
+		// SendTransaction reports its send errors by itself
+		return
+	}
+	retry, err := GetRechargeRetry(elaHash)
+	if err != nil {
+		return
+	}
+	// check again later, the recharge is resent if it is still not packed
+	retry.NextRetry = uint64(time.Now().Add(retryBackoff(retry.Attempts + 1)).Unix())
+	if err := putRechargeRetry(elaHash, retry); err != nil {
+		log.Error("save recharge retry error", "elaHash", elaHash, "error", err)
+	}
+}
@@ -0,0 +1,79 @@
+package spv
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/ethdb/leveldb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyRechargeError(t *testing.T) {
+	tests := []struct {
+		err       string
+		failure   RechargeFailure
+		retryable bool
+	}{
+		{"execution reverted: ELAMinter: already completed", FailureCompleted, false},
+		{"nonce too low", FailureNonce, true},
+		{"replacement transaction underpriced", FailureGas, true},
+		{"intrinsic gas too low", FailureGas, true},
+		{"insufficient funds for transfer", FailureGas, true},
+		{"execution reverted", FailureRevert, false},
+		{"connection refused", FailureUnknown, true},
+	}
+	for _, test := range tests {
+		failure := classifyRechargeError(errors.New(test.err))
+		assert.Equal(t, test.failure, failure, test.err)
+		assert.Equal(t, test.retryable, failure.Retryable(), test.err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, retryBaseInterval, retryBackoff(1))
+	assert.Equal(t, 2*retryBaseInterval, retryBackoff(2))
+	assert.Equal(t, 4*retryBaseInterval, retryBackoff(3))
+	assert.Equal(t, retryMaxInterval, retryBackoff(maxRechargeRetries))
+}
+
+func TestOnRechargeError(t *testing.T) {
+	db, err := leveldb.New(filepath.Join(t.TempDir(), "spv_transaction_info.db"), 16, 16, "")
+	assert.NoError(t, err)
+	spvTransactiondb = db
+	defer func() {
+		db.Close()
+		spvTransactiondb = nil
+	}()
+
+	retried := "a3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056"
+	for i := 1; i < maxRechargeRetries; i++ {
+		before := uint64(time.Now().Unix())
+		assert.True(t, onRechargeError(retried, errors.New("nonce too low")))
+		retry, err := GetRechargeRetry("0x" + retried)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(i), retry.Attempts)
+		assert.Equal(t, FailureNonce, retry.Failure)
+		assert.False(t, retry.GaveUp)
+		assert.GreaterOrEqual(t, retry.NextRetry, before+uint64(retryBackoff(uint32(i))/time.Second))
+	}
+	// the attempts are exhausted
+	assert.False(t, onRechargeError(retried, errors.New("nonce too low")))
+	retry, err := GetRechargeRetry(retried)
+	assert.NoError(t, err)
+	assert.True(t, retry.GaveUp)
+
+	reverted := "b3c4d5dc09808adb4e01fa805f722c3c34f41ca57496c299d17b5e0477b2b056"
+	assert.False(t, onRechargeError(reverted, errors.New("execution reverted")))
+	retry, err = GetRechargeRetry(reverted)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), retry.Attempts)
+	assert.Equal(t, FailureRevert, retry.Failure)
+	assert.True(t, retry.GaveUp)
+
+	status, err := GetRechargeStatus(retried)
+	assert.NoError(t, err)
+	assert.Equal(t, RechargeFailed, status.State)
+}
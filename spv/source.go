@@ -0,0 +1,104 @@
+package spv
+
+import (
+	"github.com/elastos/Elastos.ELA.SPV/bloom"
+	spv "github.com/elastos/Elastos.ELA.SPV/interface"
+	"github.com/elastos/Elastos.ELA.SPV/util"
+	"github.com/elastos/Elastos.ELA/common"
+	it "github.com/elastos/Elastos.ELA/core/types/interfaces"
+)
+
+// MainChainSource is the source of the main chain data the side chain depends
+// on: headers, producer sets, recharge payloads and merkle proofs. The spv
+// module is one implementation, MockSource replays a scripted main chain.
+type MainChainSource interface {
+	// RegisterTransactionListener registers a listener of the main chain
+	// transactions, a notified transaction is notified again with the next
+	// block until the listener submits a receipt for it.
+	RegisterTransactionListener(spv.TransactionListener) error
+
+	// RegisterBlockListener registers a listener of the main chain blocks.
+	RegisterBlockListener(spv.BlockListener) error
+
+	// RegisterRollbackListener registers a function invoked with the height of
+	// every main chain block rolled back by a reorg.
+	RegisterRollbackListener(func(height uint32))
+
+	// SubmitTransactionReceipt acknowledges the notification notifyId of txId.
+	SubmitTransactionReceipt(notifyId common.Uint256, txId common.Uint256) error
+
+	// BestHeader returns the header of the main chain tip.
+	BestHeader() (*util.Header, error)
+
+	// HeaderByHeight returns the main chain header at height.
+	HeaderByHeight(height uint32) (*util.Header, error)
+
+	// HeaderByHash returns the main chain header with hash.
+	HeaderByHash(hash *common.Uint256) (*util.Header, error)
+
+	// GetArbiters returns the producers of the main chain block at height.
+	GetArbiters(height uint32) (crcArbiters [][]byte, normalArbiters [][]byte, err error)
+
+	// GetNextArbiters returns the producers of the next turn and the height
+	// they start working from.
+	GetNextArbiters() (workingHeight uint32, crcArbiters [][]byte, normalArbiters [][]byte, err error)
+
+	// GetConsensusAlgorithm returns the consensus of the main chain at height.
+	GetConsensusAlgorithm(height uint32) (spv.ConsensusAlgorithm, error)
+
+	// GetTransaction returns the main chain transaction txId, the recharge
+	// payloads are read from it.
+	GetTransaction(txId *common.Uint256) (it.Transaction, error)
+
+	// HaveRetSideChainDepositCoinTx reports whether the main chain refunded
+	// the failed deposit txHash.
+	HaveRetSideChainDepositCoinTx(txHash common.Uint256) bool
+
+	// VerifyTransaction checks the merkle proof of tx against the header of
+	// the main chain block it is packed in.
+	VerifyTransaction(proof bloom.MerkleProof, tx it.Transaction) error
+
+	Start()
+	Stop()
+}
+
+// spvSource is the MainChainSource backed by the spv module.
+type spvSource struct {
+	spv.SPVService
+	onRollback []func(height uint32)
+}
+
+// NewSPVSource creates the spv module with cfg as a MainChainSource. The
+// rollback callback of cfg is taken over by the source.
+func NewSPVSource(cfg *spv.Config) (MainChainSource, error) {
+	source := new(spvSource)
+	cfg.OnRollback = source.rollback
+	service, err := spv.NewSPVService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	source.SPVService = service
+	return source, nil
+}
+
+func (s *spvSource) RegisterRollbackListener(fn func(height uint32)) {
+	s.onRollback = append(s.onRollback, fn)
+}
+
+func (s *spvSource) rollback(height uint32) {
+	for _, fn := range s.onRollback {
+		fn(height)
+	}
+}
+
+func (s *spvSource) BestHeader() (*util.Header, error) {
+	return s.HeaderStore().GetBest()
+}
+
+func (s *spvSource) HeaderByHeight(height uint32) (*util.Header, error) {
+	return s.HeaderStore().GetByHeight(height)
+}
+
+func (s *spvSource) HeaderByHash(hash *common.Uint256) (*util.Header, error) {
+	return s.HeaderStore().Get(hash)
+}
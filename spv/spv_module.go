@@ -128,12 +128,17 @@ type Config struct {
 	GenesisAddress string
 
 	GenesisHash common.Uint256
+
+	// ConfirmationDepth is the number of main chain blocks confirming a
+	// deposit before it is recharged.
+	ConfirmationDepth uint32
 }
 
 type Service struct {
-	spv.SPVService
-	GenesisHash common.Uint256
-	mux         *event.TypeMux
+	MainChainSource
+	GenesisHash   common.Uint256
+	mux           *event.TypeMux
+	blockListener *BlockListener
 }
 
 // Spv database initialization
@@ -166,7 +171,6 @@ func NewService(cfg *Config, tmux *event.TypeMux, dynamicArbiterHeight uint64) (
 	spvCfg := &spv.Config{
 		DataDir:             cfg.DataDir,
 		FilterType:          filter.FTReturnSidechainDepositCoinFilter,
-		OnRollback:          nil, // Not implemented yet
 		GenesisBlockAddress: cfg.GenesisAddress,
 	}
 	ResetConfigWithReflect(chainParams, spvCfg)
@@ -174,42 +178,61 @@ func NewService(cfg *Config, tmux *event.TypeMux, dynamicArbiterHeight uint64) (
 	spvCfg.ChainParams = chainParams
 
 	spvCfg.PermanentPeers = chainParams.PermanentPeers
-	dataDir = cfg.DataDir
 	spvCfg.NodeVersion = "PGP_1.9.7"
 	initLog(cfg.DataDir)
 
-	service, err := spv.NewSPVService(spvCfg)
+	source, err := NewSPVSource(spvCfg)
 	if err != nil {
 		log.Error("Spv New DPOS SPVService: ", "err", err)
 		return nil, err
 	}
+	return NewServiceWithSource(source, cfg, tmux, dynamicArbiterHeight)
+}
 
-	SpvService = &Service{service, cfg.GenesisHash, tmux}
-	err = service.RegisterTransactionListener(&listener{
+// NewServiceWithSource initializes the spv service on top of the main chain
+// data source, the listeners of the side chain are registered to it.
+func NewServiceWithSource(source MainChainSource, cfg *Config, tmux *event.TypeMux, dynamicArbiterHeight uint64) (*Service, error) {
+	dataDir = cfg.DataDir
+	confirmationDepth = cfg.ConfirmationDepth
+
+	blockListener := &BlockListener{
+		dynamicArbiterHeight: dynamicArbiterHeight,
+	}
+	SpvService = &Service{source, cfg.GenesisHash, tmux, blockListener}
+	err := source.RegisterTransactionListener(&listener{
 		address: cfg.GenesisAddress,
-		service: service,
+		service: source,
 	})
 	if err != nil {
 		log.Error("Spv Register Transaction Listener: ", "err", err)
 		return nil, err
 	}
-	err = service.RegisterBlockListener(&BlockListener{
-		dynamicArbiterHeight: dynamicArbiterHeight,
-	})
+	err = source.RegisterBlockListener(blockListener)
 	if err != nil {
 		return nil, err
 	}
-	err = service.RegisterTransactionListener(&pledgeBill.PledgeBillListener{
-		Service: service,
+	err = source.RegisterTransactionListener(&pledgeBill.PledgeBillListener{
+		Service: source,
 	})
 	if err != nil {
 		log.Error("Spv Register Transaction PledgeBillListener: ", "err", err)
 		return nil, err
 	}
+	source.RegisterRollbackListener(onMainChainRollback)
 
+	initGenesisSigners()
+	return SpvService, nil
+}
+
+// initGenesisSigners loads the block signers of the side chain genesis block.
+func initGenesisSigners() {
+	if ipcClient == nil {
+		return
+	}
 	genesis, err := ipcClient.HeaderByNumber(context.Background(), new(big.Int).SetInt64(0))
 	if err != nil {
 		log.Error("IpcClient: ", "err", err)
+		return
 	}
 
 	signersSize := len(genesis.Extra) - ExtraVanity - ExtraSeal
@@ -227,9 +250,10 @@ func NewService(cfg *Config, tmux *event.TypeMux, dynamicArbiterHeight uint64) (
 			blocksigner.Signers[signer] = struct{}{}
 		}
 	}
-	addr := GetDefaultSingerAddr()
-	_, blocksigner.SelfIsProducer = blocksigner.Signers[addr]
-	return SpvService, nil
+	if GetDefaultSingerAddr != nil {
+		addr := GetDefaultSingerAddr()
+		_, blocksigner.SelfIsProducer = blocksigner.Signers[addr]
+	}
 }
 
 // minedBroadcastLoop Mining awareness, eth can initiate a recharge transaction after the block
@@ -280,6 +304,7 @@ func accessFailedRechargeTx() {
 			if SpvService.HaveRetSideChainDepositCoinTx(*hash) {
 				txs = append(txs[:index], txs[index+1:]...)
 				failedTxList[height] = txs
+				updateRechargeState(txHash, RechargeRefunded)
 				log.Info("failed recharge transaction is rested", "txHash", txHash, "txs.len", len(txs))
 				break
 			}
@@ -291,13 +316,23 @@ func accessFailedRechargeTx() {
 	}
 }
 
+// Start starts the spv service and the retry scheduler of failed recharges.
+func (s *Service) Start() {
+	s.MainChainSource.Start()
+	go s.retryLoop()
+}
+
 func (s *Service) GetDatabase() *leveldb.Database {
 	return spvTransactiondb
 }
 
+// GetBlockListener returns the listener of the main chain blocks.
+func (s *Service) GetBlockListener() *BlockListener {
+	return s.blockListener
+}
+
 func (s *Service) VerifyElaHeader(hash *common.Uint256) error {
-	blockChain := s.HeaderStore()
-	_, err := blockChain.Get(hash)
+	_, err := s.HeaderByHash(hash)
 	if err != nil {
 		return errors.New("[VerifyElaHeader] Verify ela header failed.")
 	}
@@ -305,12 +340,12 @@ func (s *Service) VerifyElaHeader(hash *common.Uint256) error {
 }
 
 func (s *Service) GetELAHeader(height uint32) (*util.Header, error) {
-	return s.HeaderStore().GetByHeight(height)
+	return s.HeaderByHeight(height)
 }
 
 type listener struct {
 	address string
-	service spv.SPVService
+	service MainChainSource
 }
 
 func (l *listener) Address() string {
@@ -326,6 +361,11 @@ func (l *listener) Flags() uint64 {
 }
 
 func (l *listener) Notify(id common.Uint256, proof bloom.MerkleProof, tx it.Transaction) {
+	if !isDepositConfirmed(l.service, proof.Height) {
+		// no receipt, spv notifies the deposit again with the next block
+		log.Info("deposit waits for confirmations", "elaHash", tx.Hash().String(), "height", proof.Height, "depth", confirmationDepth)
+		return
+	}
 	// Submit transaction receipt
 	log.Info("========================================================================================")
 	log.Info("mainchain transaction info")
@@ -335,6 +375,8 @@ func (l *listener) Notify(id common.Uint256, proof bloom.MerkleProof, tx it.Tran
 	if !blocksigner.SelfIsProducer {
 		atomic.StoreInt32(&candSend, 0)
 	}
+	updateRechargeState(tx.Hash().String(), RechargeSeen)
+	putRechargeHeight(tx.Hash().String(), proof.Height)
 	fee, addr, output := FindOutputFeeAndaddressByTxHash(tx.Hash().String())
 	var blackAddr ethCommon.Address
 	if fee.Cmp(new(big.Int)) <= 0 && output.Cmp(new(big.Int)) <= 0 && addr == blackAddr {
@@ -361,6 +403,7 @@ func NotifySmallCrossTx(tx it.Transaction) {
 	if !blocksigner.SelfIsProducer {
 		atomic.StoreInt32(&candSend, 0)
 	}
+	updateRechargeState(tx.Hash().String(), RechargeSeen)
 	SavePayloadInfo(tx, nil)
 }
 
@@ -432,6 +475,15 @@ func saveOutputPayload(outputs []*elacom.Output, txHash string) error {
 		log.Error("saveOutputPayload Put Input: ", "err", err, "elaHash", txHash)
 	}
 	transactionDBMutex.Unlock()
+	updateRechargeState(txHash, RechargePayloadSaved)
+	if batchRechargeEnabled() {
+		// the iterator sends the deposits of a main chain block together
+		UpTransactionIndex(txHash)
+		if atomic.LoadInt32(&candSend) == 1 {
+			IteratorUnTransaction(GetDefaultSingerAddr())
+		}
+		return nil
+	}
 	if atomic.LoadInt32(&candSend) == 1 {
 		from := GetDefaultSingerAddr()
 		IteratorUnTransaction(from)
@@ -511,6 +563,15 @@ func SavePayloadInfo(elaTx it.Transaction, l *listener) {
 	if err != nil {
 		log.Error("SpvServicedb Put Input: ", "err", err, "elaHash", elaTx.Hash().String())
 	}
+	updateRechargeState(elaTx.Hash().String(), RechargePayloadSaved)
+	if batchRechargeEnabled() {
+		// the iterator sends the deposits of a main chain block together
+		UpTransactionIndex(elaTx.Hash().String())
+		if atomic.LoadInt32(&candSend) == 1 {
+			IteratorUnTransaction(GetDefaultSingerAddr())
+		}
+		return
+	}
 	if atomic.LoadInt32(&candSend) == 1 {
 		from := GetDefaultSingerAddr()
 		IteratorUnTransaction(from)
@@ -544,6 +605,10 @@ func UpTransactionIndex(elaTx string) {
 	if strings.HasPrefix(elaTx, "0x") {
 		elaTx = elaTx[2:]
 	}
+	if isRechargeOrphaned(elaTx) {
+		log.Info("skip orphaned deposit", "elaHash", elaTx)
+		return
+	}
 	index := GetUnTransactionNum(spvTransactiondb, UnTransactionIndex)
 	if index == missingNumber {
 		index = 1
@@ -598,11 +663,27 @@ func IteratorUnTransaction(from ethCommon.Address) {
 				log.Info("send over recharge", "seek", seek, "index", index)
 				break
 			}
+			if batchRechargeEnabled() {
+				if seeks, elaHashes := collectRechargeBatch(seek, index); len(elaHashes) > 1 {
+					err, finished := SendRechargeBatch(from, elaHashes)
+					if err != nil {
+						log.Info("SendRechargeBatch failed", "error", err.Error())
+					}
+					if !finished {
+						break
+					}
+					for _, batchSeek := range seeks {
+						setNextSeek(batchSeek)
+					}
+					continue
+				}
+			}
 			txHash, err := spvTransactiondb.Get(append([]byte(UnTransaction), encodeUnTransactionNumber(seek)...))
 			if err != nil {
-				log.Error("get UnTransaction ", "err", err, "seek", seek)
+				// deposits orphaned by a main chain reorg leave holes in the index
+				log.Warn("get UnTransaction ", "err", err, "seek", seek)
 				setNextSeek(seek)
-				break
+				continue
 			}
 			//fee, _, _ := FindOutputFeeAndaddressByTxHash(string(txHash))
 			recharges, fee, err := GetRechargeDataByTxhash(string(txHash))
@@ -617,7 +698,11 @@ func IteratorUnTransaction(from ethCommon.Address) {
 					setNextSeek(seek)
 					break
 				}
-				OnTx2Failed(string(txHash))
+				reason := "recharge data not found"
+				if err != nil {
+					reason = err.Error()
+				}
+				OnTx2Failed(string(txHash), reason)
 				setNextSeek(seek)
 				break
 			}
@@ -682,15 +767,15 @@ func SendTransaction(from ethCommon.Address, elaTx string, fee *big.Int) (err er
 		if strings.Contains(err.Error(), ErrMainTxHashCompleted.Error()) {
 			return err, true
 		}
-		res, err = IsFailedElaTx(elaTx)
-		if err != nil {
-			return err, false
+		failed, ferr := IsFailedElaTx(elaTx)
+		if ferr != nil {
+			return ferr, false
 		}
-		if res {
+		if failed {
 			return err, true
 		}
-		OnTx2Failed(elaTx)
-		return err, false
+		onRechargeError(elaTx, err)
+		return err, true
 	}
 	log.Info("IpcClient EstimateGas:", "data", len(data), "main txhash", elaTx, "gasLimit", gasLimit)
 	if atomic.LoadInt32(&candSend) == 0 {
@@ -707,9 +792,11 @@ func SendTransaction(from ethCommon.Address, elaTx string, fee *big.Int) (err er
 	hash, err := ipcClient.SendPublicTransaction(context.Background(), callmsg)
 	if err != nil {
 		log.Info("Cross chain Transaction failed", "elaTx", elaTx, "ethTh", hash.String(), "gasLimit", gasLimit, "price", price.String())
+		onRechargeError(elaTx, err)
 		return err, true
 	}
 	log.Info("Cross chain Transaction", "elaTx", elaTx, "ethTh", hash.String(), "gasLimit", gasLimit, "price.String()", price.String())
+	onRechargeSent(elaTx, hash)
 	return nil, true
 }
 
@@ -840,10 +927,13 @@ func FindOutRechargeInput(transactionHash string) []byte {
 	return input
 }
 
-func OnTx2Failed(elaTx string) {
+func OnTx2Failed(elaTx string, reason string) {
 	if elaTx[:2] == "0x" {
 		elaTx = elaTx[2:]
 	}
+	if isRechargeOrphaned(elaTx) {
+		return
+	}
 	if res, err := IsPackagedElaTx(elaTx); res || err != nil {
 		return
 	}
@@ -880,7 +970,8 @@ func OnTx2Failed(elaTx string) {
 	failedTxList[height] = txList
 	data := encodeTxList(txList)
 	err = spvTransactiondb.Put(encodeUnTransactionNumber(height), data)
-	log.Info("recharge tx failed", "height", height, "tx", elaTx)
+	SetRechargeFailed(elaTx, reason)
+	log.Info("recharge tx failed", "height", height, "tx", elaTx, "reason", reason)
 }
 
 func IsPackagedElaTx(elaTx string) (bool, error) {
@@ -961,6 +1052,13 @@ func IsFailedElaTx(elaTx string) (bool, error) {
 }
 
 func onElaTxPacked(elaTx string) {
+	updateRechargeState(elaTx, RechargePacked)
+	deleteRechargeRetry(elaTx)
+	removeFailedElaTx(elaTx)
+}
+
+// removeFailedElaTx takes elaTx out of the failed recharge txs.
+func removeFailedElaTx(elaTx string) {
 	failedMutex.Lock()
 	defer failedMutex.Unlock()
 	for height, txs := range failedTxList {
@@ -1094,20 +1192,28 @@ func SendEvilProof(addr ethCommon.Address, info interface{}) {
 }
 
 func GetArbiters() ([]string, int, error) {
-	producers := make([]string, 0)
 	if PbftEngine != nil {
-		spvHeight := PbftEngine.CurrentBlock().Nonce()
-		if spvHeight == 0 {
-			producers = PbftEngine.GetPbftConfig().Producers
-			return producers, len(producers), nil
-		}
-		list, totalProducers, err := GetProducers(spvHeight)
-		for _, p := range list {
-			producers = append(producers, common.BytesToHexString(p))
+		return GetArbitersAt(PbftEngine.CurrentBlock().Nonce())
+	}
+	return make([]string, 0), 0, errors.New("pbftEngine is nil")
+}
+
+// GetArbitersAt returns the arbiters at the main chain height spvHeight, the
+// producers of the pbft config if it is zero.
+func GetArbitersAt(spvHeight uint64) ([]string, int, error) {
+	producers := make([]string, 0)
+	if spvHeight == 0 {
+		if PbftEngine == nil {
+			return producers, 0, errors.New("pbftEngine is nil")
 		}
-		return producers, totalProducers, err
+		producers = PbftEngine.GetPbftConfig().Producers
+		return producers, len(producers), nil
+	}
+	list, totalProducers, err := GetProducers(spvHeight)
+	for _, p := range list {
+		producers = append(producers, common.BytesToHexString(p))
 	}
-	return producers, 0, errors.New("pbftEngine is nil")
+	return producers, totalProducers, err
 }
 
 func IsSmallCrossTxByData(data []byte) (string, string, []string, uint64) {
@@ -1226,5 +1332,6 @@ func Close() {
 		spvdb.Close()
 		close(stopChn)
 	}
+	smallcrosstx.Close()
 	fmt.Println("spv close 33333333")
 }
@@ -0,0 +1,124 @@
+package withdrawfailedtx
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/rlp"
+)
+
+// failedTxDb is the spv transaction database, shared with spv.SpvDbInit.
+var failedTxDb ethdb.KeyValueStore
+
+// failedTxRecord is the signature collection state of one failed withdraw tx
+// as it is stored in the database.
+type failedTxRecord struct {
+	Signatures []string
+	Arbiters   []string
+}
+
+// FailedWithdrawTxInfo describes a refund that is still collecting arbiter signatures.
+type FailedWithdrawTxInfo struct {
+	Txid           string   `json:"txid"`
+	SignatureCount int      `json:"signatureCount"`
+	Signers        []string `json:"signers"`
+}
+
+func failedTxKey(txid string) []byte {
+	return []byte(FailedTxPre + txid)
+}
+
+// putFailedTx writes the in-memory signatures and arbiters of txid to the database.
+func putFailedTx(txid string) error {
+	if failedTxDb == nil {
+		return nil
+	}
+	record := failedTxRecord{
+		Signatures: failedTxList[txid],
+		Arbiters:   verifiedArbiter[txid],
+	}
+	data, err := rlp.EncodeToBytes(&record)
+	if err != nil {
+		return err
+	}
+	return failedTxDb.Put(failedTxKey(txid), data)
+}
+
+func deleteFailedTx(txid string) error {
+	if failedTxDb == nil {
+		return nil
+	}
+	return failedTxDb.Delete(failedTxKey(txid))
+}
+
+// loadFailedTxs rebuilds failedTxList and verifiedArbiter from the database,
+// dropping every record whose refund isCompleted reports as done.
+func loadFailedTxs(isCompleted func(txid string) bool) (int, error) {
+	if failedTxDb == nil {
+		return 0, errors.New("failed withdraw tx database is nil")
+	}
+	prefix := []byte(FailedTxPre)
+	it := failedTxDb.NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	var (
+		loaded    int
+		completed []string
+	)
+	for it.Next() {
+		txid := string(it.Key()[len(prefix):])
+		var record failedTxRecord
+		if err := rlp.DecodeBytes(it.Value(), &record); err != nil {
+			log.Error("decode failed withdraw tx error", "txid", txid, "error", err)
+			continue
+		}
+		if isCompleted(txid) {
+			completed = append(completed, txid)
+			continue
+		}
+		failedTxList[txid] = record.Signatures
+		verifiedArbiter[txid] = record.Arbiters
+		loaded++
+	}
+	if err := it.Error(); err != nil {
+		return loaded, err
+	}
+	for _, txid := range completed {
+		if err := deleteFailedTx(txid); err != nil {
+			log.Error("delete completed failed withdraw tx error", "txid", txid, "error", err)
+		}
+	}
+	return loaded, nil
+}
+
+// GetFailedWithdrawTxs lists the failed withdraw txs whose refund is still
+// collecting signatures, pruning the ones that have been refunded meanwhile.
+func GetFailedWithdrawTxs() []FailedWithdrawTxInfo {
+	return getFailedWithdrawTxs(isRefundCompleted)
+}
+
+func getFailedWithdrawTxs(isCompleted func(txid string) bool) []FailedWithdrawTxInfo {
+	mulFailedMux.Lock()
+	defer mulFailedMux.Unlock()
+
+	list := make([]FailedWithdrawTxInfo, 0, len(verifiedArbiter))
+	for txid, arbiters := range verifiedArbiter {
+		if isCompleted(txid) {
+			OnProcessFaildWithdrawTx(txid)
+			continue
+		}
+		signers := make([]string, len(arbiters))
+		copy(signers, arbiters)
+		list = append(list, FailedWithdrawTxInfo{
+			Txid:           txid,
+			SignatureCount: len(failedTxList[txid]),
+			Signers:        signers,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Txid < list[j].Txid
+	})
+	return list
+}
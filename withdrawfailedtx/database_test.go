@@ -0,0 +1,64 @@
+package withdrawfailedtx
+
+import (
+	"testing"
+
+	"github.com/pgprotocol/pgp-chain/ethdb/memorydb"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resetFailedTxState() {
+	failedTxList = make(map[string][]string)
+	verifiedArbiter = make(map[string][]string)
+}
+
+func TestFailedTxPersistence(t *testing.T) {
+	failedTxDb = memorydb.New()
+	defer func() {
+		failedTxDb = nil
+		resetFailedTxState()
+	}()
+	resetFailedTxState()
+
+	pending := "c9f1d1a7e2b0e0e6d3c52ab5d2ab47f6e5c9b8a6d4c3b2a1f0e9d8c7b6a59483"
+	refunded := "1f0e9d8c7b6a59483c9f1d1a7e2b0e0e6d3c52ab5d2ab47f6e5c9b8a6d4c3b2a"
+
+	failedTxList[pending] = []string{"sig1", "sig2"}
+	verifiedArbiter[pending] = []string{"arbiter1", "arbiter2"}
+	assert.NoError(t, putFailedTx(pending))
+	failedTxList[refunded] = []string{"sig3"}
+	verifiedArbiter[refunded] = []string{"arbiter3"}
+	assert.NoError(t, putFailedTx(refunded))
+
+	// Simulate a restart, the refund of one tx was finished meanwhile.
+	resetFailedTxState()
+	count, err := loadFailedTxs(func(txid string) bool {
+		return txid == refunded
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []string{"sig1", "sig2"}, failedTxList[pending])
+	assert.Equal(t, []string{"arbiter1", "arbiter2"}, verifiedArbiter[pending])
+	assert.True(t, IsSignatureVerified(pending, "sig2"))
+	assert.True(t, IsArbiterVerified(pending, "arbiter1"))
+	assert.Nil(t, failedTxList[refunded])
+
+	has, err := failedTxDb.Has(failedTxKey(refunded))
+	assert.NoError(t, err)
+	assert.False(t, has)
+
+	list := getFailedWithdrawTxs(func(string) bool { return false })
+	assert.Equal(t, []FailedWithdrawTxInfo{{
+		Txid:           pending,
+		SignatureCount: 2,
+		Signers:        []string{"arbiter1", "arbiter2"},
+	}}, list)
+
+	// Once the refund is done the tx leaves both memory and database.
+	list = getFailedWithdrawTxs(func(string) bool { return true })
+	assert.Empty(t, list)
+	has, err = failedTxDb.Has(failedTxKey(pending))
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
@@ -40,6 +40,22 @@ type FailedWithdrawEvent struct {
 // Spv database initialization
 func FailedWithrawInit(datadir string, evtMux *event.TypeMux) {
 	eventMux = evtMux
+	if spv.SpvService == nil || spv.SpvService.GetDatabase() == nil {
+		log.Warn("spv database is not opened, failed withdraw signatures will not be persisted")
+		return
+	}
+	mulFailedMux.Lock()
+	defer mulFailedMux.Unlock()
+	failedTxDb = spv.SpvService.GetDatabase()
+	count, err := loadFailedTxs(isRefundCompleted)
+	if err != nil {
+		log.Error("load failed withdraw txs error", "error", err)
+	}
+	log.Info("loaded failed withdraw txs", "count", count)
+}
+
+func isRefundCompleted(txid string) bool {
+	return spv.IsCompleted(txid, spv.GetClient())
 }
 
 func OnProcessFaildWithdrawTx(hash string) {
@@ -49,6 +65,9 @@ func OnProcessFaildWithdrawTx(hash string) {
 	if len(failedTxList[hash]) > 0 {
 		delete(failedTxList, hash)
 		delete(verifiedArbiter, hash)
+		if err := deleteFailedTx(hash); err != nil {
+			log.Error("delete failed withdraw tx error", "txid", hash, "error", err)
+		}
 	}
 }
 
@@ -97,6 +116,9 @@ func ReceivedFailedWithdrawTx(hash string, signature string) error {
 		verifiedArbiterList = append(verifiedArbiterList, arb)
 		failedTxList[hash] = verifiedSigList
 		verifiedArbiter[hash] = verifiedArbiterList
+		if err := putFailedTx(hash); err != nil {
+			log.Error("save failed withdraw tx error", "txid", hash, "error", err)
+		}
 		if len(verifiedArbiterList) >= getMaxArbitersSign(total) {
 			err := SendRefundTx(spv.GetDefaultSingerAddr(), hash)
 			if err != nil {
@@ -0,0 +1,244 @@
+package withdrawindex
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/rlp"
+	"github.com/pgprotocol/pgp-chain/rpc"
+)
+
+const (
+	// defaultPageSize is the page size of the queries which do not ask for one.
+	defaultPageSize = 100
+	// maxPageSize caps the page size of the queries.
+	maxPageSize = 1000
+	// maxUnindexedBlocks caps the blocks above the index which are scanned to
+	// answer a query.
+	maxUnindexedBlocks = 1024
+)
+
+var (
+	errInvalidRange = errors.New("invalid block range")
+	errIndexBehind  = errors.New("withdraw index is not synced")
+)
+
+// Chain is the chain the queries not covered by the index are answered from.
+type Chain interface {
+	Config() *params.ChainConfig
+	CurrentHeader() *types.Header
+	GetBlockByNumber(number uint64) *types.Block
+	GetReceiptsByHash(hash common.Hash) types.Receipts
+}
+
+// EventsPage is a page of indexed events. Next is the offset of the next
+// page, it is nil on the last page.
+type EventsPage struct {
+	Events []map[string]interface{} `json:"events"`
+	Next   *hexutil.Uint64          `json:"next"`
+}
+
+// PublicWithdrawIndexAPI serves the queries of the event index.
+type PublicWithdrawIndexAPI struct {
+	indexer *core.ChainIndexer
+	index   *Indexer
+	chain   Chain
+}
+
+// APIs returns the RPC APIs of the event index.
+func APIs(indexer *core.ChainIndexer, index *Indexer, chain Chain) []rpc.API {
+	return []rpc.API{{
+		Namespace: "crosschain",
+		Version:   "1.0",
+		Service:   &PublicWithdrawIndexAPI{indexer: indexer, index: index, chain: chain},
+		Public:    true,
+	}}
+}
+
+// GetWithdrawsByHeightRange returns the events of the blocks from to to, in
+// chain order.
+func (api *PublicWithdrawIndexAPI) GetWithdrawsByHeightRange(ctx context.Context, from, to hexutil.Uint64, offset, limit *hexutil.Uint64) (*EventsPage, error) {
+	head := api.chain.CurrentHeader().Number.Uint64()
+	if uint64(to) > head {
+		to = hexutil.Uint64(head)
+	}
+	if from > to {
+		return nil, errInvalidRange
+	}
+	p := newPager(offset, limit)
+	indexed := api.indexedBlocks()
+	if uint64(from) < indexed {
+		err := api.index.iterate(heightPrefix, heightKey(uint64(from), 0), func(key, value []byte) (bool, error) {
+			number := binary.BigEndian.Uint64(key[len(heightPrefix):])
+			if number > uint64(to) || number >= indexed {
+				return false, nil
+			}
+			return p.addEncoded(value)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	start := uint64(from)
+	if start < indexed {
+		start = indexed
+	}
+	if err := api.scan(start, uint64(to), common.Address{}, p); err != nil {
+		return nil, err
+	}
+	return p.page(), nil
+}
+
+// GetWithdrawsByAddress returns the events of the side chain account address,
+// in chain order.
+func (api *PublicWithdrawIndexAPI) GetWithdrawsByAddress(ctx context.Context, address common.Address, offset, limit *hexutil.Uint64) (*EventsPage, error) {
+	p := newPager(offset, limit)
+	indexed := api.indexedBlocks()
+	prefix := append(common.CopyBytes(addressPrefix), address.Bytes()...)
+	err := api.index.iterate(prefix, prefix, func(key, value []byte) (bool, error) {
+		if binary.BigEndian.Uint64(key[len(prefix):]) >= indexed {
+			return false, nil
+		}
+		return p.addEncoded(value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	head := api.chain.CurrentHeader().Number.Uint64()
+	if err := api.scan(indexed, head, address, p); err != nil {
+		return nil, err
+	}
+	return p.page(), nil
+}
+
+// indexedBlocks returns the number of blocks covered by the index.
+func (api *PublicWithdrawIndexAPI) indexedBlocks() uint64 {
+	sections, _, _ := api.indexer.Sections()
+	return sections * api.index.size
+}
+
+// scan adds the events of the blocks from to to which are not indexed yet,
+// restricted to the account address unless it is empty.
+func (api *PublicWithdrawIndexAPI) scan(from, to uint64, address common.Address, p *pager) error {
+	if from > to || p.full() {
+		return nil
+	}
+	if to-from >= maxUnindexedBlocks {
+		return fmt.Errorf("%w: %d blocks above the index", errIndexBehind, to-from+1)
+	}
+	for number := from; number <= to; number++ {
+		block := api.chain.GetBlockByNumber(number)
+		if block == nil {
+			return nil
+		}
+		events, err := blockEvents(api.chain.Config(), number, block.Transactions(), api.chain.GetReceiptsByHash(block.Hash()))
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if address != (common.Address{}) && event.Address != address {
+				continue
+			}
+			if !p.add(event) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// pager collects a page of events, skipping the ones before the offset.
+type pager struct {
+	offset, limit uint64
+	seen          uint64
+	events        []*Event
+	more          bool
+}
+
+func newPager(offset, limit *hexutil.Uint64) *pager {
+	p := &pager{limit: defaultPageSize}
+	if offset != nil {
+		p.offset = uint64(*offset)
+	}
+	if limit != nil && *limit > 0 {
+		p.limit = uint64(*limit)
+	}
+	if p.limit > maxPageSize {
+		p.limit = maxPageSize
+	}
+	return p
+}
+
+func (p *pager) full() bool {
+	return p.more
+}
+
+// add adds event to the page, it returns false once the page is full.
+func (p *pager) add(event *Event) bool {
+	if p.seen < p.offset {
+		p.seen++
+		return true
+	}
+	if uint64(len(p.events)) == p.limit {
+		p.more = true
+		return false
+	}
+	p.events = append(p.events, event)
+	p.seen++
+	return true
+}
+
+func (p *pager) addEncoded(enc []byte) (bool, error) {
+	if p.seen < p.offset {
+		p.seen++
+		return true, nil
+	}
+	event := new(Event)
+	if err := rlp.DecodeBytes(enc, event); err != nil {
+		return false, err
+	}
+	return p.add(event), nil
+}
+
+func (p *pager) page() *EventsPage {
+	page := &EventsPage{Events: make([]map[string]interface{}, 0, len(p.events))}
+	for _, event := range p.events {
+		page.Events = append(page.Events, event.rpcMarshal())
+	}
+	if p.more {
+		next := hexutil.Uint64(p.seen)
+		page.Next = &next
+	}
+	return page
+}
+
+// rpcMarshal converts event into the RPC representation of its kind.
+func (event *Event) rpcMarshal() map[string]interface{} {
+	fields := map[string]interface{}{
+		"kind":        event.Kind.String(),
+		"blockNumber": hexutil.Uint64(event.BlockNumber),
+		"txHash":      event.TxHash,
+		"logIndex":    hexutil.Uint(event.LogIndex),
+		"address":     event.Address,
+	}
+	switch event.Kind {
+	case KindWithdraw:
+		fields["mainChainAddress"] = event.MainChainAddress
+		fields["amount"] = (*hexutil.Big)(event.Amount)
+		fields["crossChainAmount"] = (*hexutil.Big)(event.CrossChainAmount)
+	case KindRefund:
+		fields["withdrawTxID"] = event.WithdrawTxID
+		fields["amount"] = (*hexutil.Big)(event.Amount)
+	case KindPledgeBillBurn:
+		fields["mainChainAddress"] = event.MainChainAddress
+		fields["tokenID"] = (*hexutil.Big)(event.TokenID)
+	}
+	return fields
+}
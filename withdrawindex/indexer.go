@@ -0,0 +1,265 @@
+// Package withdrawindex maintains an index of the withdraw, refund and pledge
+// bill burn events of the side chain, keyed by block height and by account.
+package withdrawindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math/big"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/core"
+	"github.com/pgprotocol/pgp-chain/core/rawdb"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/log"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/pledgeBill"
+	"github.com/pgprotocol/pgp-chain/rlp"
+	"github.com/pgprotocol/pgp-chain/spv"
+)
+
+const (
+	// SectionSize is the number of blocks indexed at once.
+	SectionSize = 32
+
+	// Confirms is the number of blocks on top of a section before it is indexed.
+	Confirms = 6
+
+	// throttling is the time to wait between processing two consecutive sections.
+	throttling = 100 * time.Millisecond
+)
+
+var (
+	// indexPrefix is the table prefix of the index in the chain database.
+	indexPrefix = "withdrawIndex-"
+
+	// heightPrefix + height (8 bytes) + log index (4 bytes) -> event
+	heightPrefix = []byte("h")
+	// addressPrefix + address + height (8 bytes) + log index (4 bytes) -> event
+	addressPrefix = []byte("a")
+)
+
+var (
+	payloadReceivedEvent = spv.ELAMinterABI.Events["PayloadReceived"]
+	refundWithdrawEvent  = spv.ELAMinterABI.Events["RefundWithdraw"]
+
+	stakeTicketBurnABI, _ = pledgeBill.GetStakeTicketBurnEventABI()
+	stakeTicketBurnEvent  = stakeTicketBurnABI.Events["StakeTicketBurn"]
+)
+
+// EventKind is the kind of an indexed event.
+type EventKind uint8
+
+const (
+	KindWithdraw       EventKind = iota // PayloadReceived of the ELAMinter contract
+	KindRefund                          // RefundWithdraw of the ELAMinter contract
+	KindPledgeBillBurn                  // StakeTicketBurn of the pledge bill contract
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case KindWithdraw:
+		return "withdraw"
+	case KindRefund:
+		return "refund"
+	case KindPledgeBillBurn:
+		return "pledgeBillBurn"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is an indexed event. Address is the side chain account of the event:
+// the sender of a withdraw or a burn, the target of a refund.
+type Event struct {
+	Kind             EventKind
+	BlockNumber      uint64
+	TxHash           common.Hash
+	LogIndex         uint32
+	Address          common.Address
+	MainChainAddress string   // withdraw target, elaAddress of a burn
+	Amount           *big.Int // withdraw output amount, refund amount
+	CrossChainAmount *big.Int // withdraw cross chain amount
+	WithdrawTxID     common.Hash
+	TokenID          *big.Int
+}
+
+func heightKey(number uint64, logIndex uint32) []byte {
+	key := make([]byte, 0, len(heightPrefix)+12)
+	key = append(key, heightPrefix...)
+	key = binary.BigEndian.AppendUint64(key, number)
+	return binary.BigEndian.AppendUint32(key, logIndex)
+}
+
+func addressKey(addr common.Address, number uint64, logIndex uint32) []byte {
+	key := make([]byte, 0, len(addressPrefix)+common.AddressLength+12)
+	key = append(key, addressPrefix...)
+	key = append(key, addr.Bytes()...)
+	key = binary.BigEndian.AppendUint64(key, number)
+	return binary.BigEndian.AppendUint32(key, logIndex)
+}
+
+// Indexer implements core.ChainIndexerBackend, indexing the events of the
+// canonical chain by height and by account.
+type Indexer struct {
+	chainDb ethdb.Database      // database the blocks and receipts are read from
+	db      ethdb.Database      // table the index is written into
+	config  *params.ChainConfig // config of the chain, names the pledge bill contract
+	size    uint64              // section size
+	batch   ethdb.Batch         // writes of the section being processed
+}
+
+// NewIndexer returns a chain indexer which maintains the event index of the
+// canonical chain in chainDb, together with its backend for queries.
+func NewIndexer(chainDb ethdb.Database, config *params.ChainConfig) (*core.ChainIndexer, *Indexer) {
+	backend := &Indexer{
+		chainDb: chainDb,
+		db:      rawdb.NewTable(chainDb, indexPrefix),
+		config:  config,
+		size:    SectionSize,
+	}
+	return core.NewChainIndexer(chainDb, backend.db, backend, SectionSize, Confirms, throttling, "withdrawindex"), backend
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new section. The
+// events left of a section rolled back by a reorg are removed here.
+func (idx *Indexer) Reset(ctx context.Context, section uint64, prevHead common.Hash) error {
+	idx.batch = idx.db.NewBatch()
+	from, to := section*idx.size, (section+1)*idx.size
+	return idx.iterate(heightPrefix, heightKey(from, 0), func(key, value []byte) (bool, error) {
+		if binary.BigEndian.Uint64(key[len(heightPrefix):]) >= to {
+			return false, nil
+		}
+		event := new(Event)
+		if err := rlp.DecodeBytes(value, event); err != nil {
+			return false, err
+		}
+		idx.batch.Delete(common.CopyBytes(key))
+		idx.batch.Delete(addressKey(event.Address, event.BlockNumber, event.LogIndex))
+		return true, nil
+	})
+}
+
+// Process implements core.ChainIndexerBackend, adding the events of a block.
+func (idx *Indexer) Process(ctx context.Context, header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+	body := rawdb.ReadBody(idx.chainDb, hash, number)
+	if body == nil {
+		return nil
+	}
+	receipts := rawdb.ReadReceipts(idx.chainDb, hash, number, idx.config)
+	events, err := blockEvents(idx.config, number, body.Transactions, receipts)
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		enc, err := rlp.EncodeToBytes(event)
+		if err != nil {
+			return err
+		}
+		idx.batch.Put(heightKey(event.BlockNumber, event.LogIndex), enc)
+		idx.batch.Put(addressKey(event.Address, event.BlockNumber, event.LogIndex), enc)
+	}
+	return nil
+}
+
+// Commit implements core.ChainIndexerBackend, writing out the section.
+func (idx *Indexer) Commit() error {
+	return idx.batch.Write()
+}
+
+// iterate walks the index entries with prefix from start on, until fn returns
+// false. The table iterators can not start at a key, so the chain database is
+// iterated instead.
+func (idx *Indexer) iterate(prefix, start []byte, fn func(key, value []byte) (bool, error)) error {
+	full := append([]byte(indexPrefix), prefix...)
+	it := idx.chainDb.NewIteratorWithStart(append([]byte(indexPrefix), start...))
+	defer it.Release()
+	for it.Next() {
+		if !bytes.HasPrefix(it.Key(), full) {
+			break
+		}
+		next, err := fn(it.Key()[len(indexPrefix):], it.Value())
+		if err != nil || !next {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// blockEvents returns the events of the block number with txs and receipts.
+func blockEvents(config *params.ChainConfig, number uint64, txs types.Transactions, receipts types.Receipts) ([]*Event, error) {
+	var (
+		events         []*Event
+		pledgeBillAddr common.Address
+		signer         = types.MakeSigner(config, new(big.Int).SetUint64(number))
+	)
+	if common.IsHexAddress(config.PledgeBillContract) {
+		pledgeBillAddr = common.HexToAddress(config.PledgeBillContract)
+	}
+	for i, receipt := range receipts {
+		if receipt.Status != types.ReceiptStatusSuccessful || i >= len(txs) {
+			continue
+		}
+		for _, l := range receipt.Logs {
+			if len(l.Topics) == 0 {
+				continue
+			}
+			event := &Event{
+				BlockNumber:      number,
+				TxHash:           l.TxHash,
+				LogIndex:         uint32(l.Index),
+				Amount:           new(big.Int),
+				CrossChainAmount: new(big.Int),
+				TokenID:          new(big.Int),
+			}
+			switch {
+			case l.Address == spv.ELAMinterAddress && l.Topics[0] == payloadReceivedEvent.ID && len(l.Topics) == 2:
+				values, err := payloadReceivedEvent.Inputs.NonIndexed().Unpack(l.Data)
+				if err != nil {
+					log.Warn("Undecodable withdraw event", "tx", l.TxHash, "err", err)
+					continue
+				}
+				event.Kind = KindWithdraw
+				event.Address = common.BytesToAddress(l.Topics[1].Bytes())
+				event.MainChainAddress = values[0].(string)
+				event.Amount = values[1].(*big.Int)
+				event.CrossChainAmount = values[2].(*big.Int)
+
+			case l.Address == spv.ELAMinterAddress && l.Topics[0] == refundWithdrawEvent.ID && len(l.Topics) == 3:
+				values, err := refundWithdrawEvent.Inputs.NonIndexed().Unpack(l.Data)
+				if err != nil {
+					log.Warn("Undecodable refund event", "tx", l.TxHash, "err", err)
+					continue
+				}
+				event.Kind = KindRefund
+				event.WithdrawTxID = l.Topics[1]
+				event.Address = common.BytesToAddress(l.Topics[2].Bytes())
+				event.Amount = values[0].(*big.Int)
+
+			case pledgeBillAddr != (common.Address{}) && l.Address == pledgeBillAddr && l.Topics[0] == stakeTicketBurnEvent.ID:
+				values, err := stakeTicketBurnEvent.Inputs.Unpack(l.Data)
+				if err != nil {
+					log.Warn("Undecodable pledge bill burn event", "tx", l.TxHash, "err", err)
+					continue
+				}
+				from, err := types.Sender(signer, txs[i])
+				if err != nil {
+					return nil, err
+				}
+				event.Kind = KindPledgeBillBurn
+				event.Address = from
+				event.TokenID = values[0].(*big.Int)
+				event.MainChainAddress = values[1].(string)
+
+			default:
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,246 @@
+package withdrawindex
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pgprotocol/pgp-chain/common"
+	"github.com/pgprotocol/pgp-chain/common/hexutil"
+	"github.com/pgprotocol/pgp-chain/core"
+	"github.com/pgprotocol/pgp-chain/core/rawdb"
+	"github.com/pgprotocol/pgp-chain/core/types"
+	"github.com/pgprotocol/pgp-chain/crypto"
+	"github.com/pgprotocol/pgp-chain/ethdb"
+	"github.com/pgprotocol/pgp-chain/event"
+	"github.com/pgprotocol/pgp-chain/params"
+	"github.com/pgprotocol/pgp-chain/spv"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKeyA, _    = crypto.GenerateKey()
+	testKeyB, _    = crypto.GenerateKey()
+	testAddrA      = crypto.PubkeyToAddress(testKeyA.PublicKey)
+	testAddrB      = crypto.PubkeyToAddress(testKeyB.PublicKey)
+	testPledgeBill = common.HexToAddress("0xb340b44112106ebAeEeBbC18C08Eac01852AD6a4")
+)
+
+// testChain is a chain written straight into a database, it feeds the indexer
+// the way core.BlockChain does.
+type testChain struct {
+	db     ethdb.Database
+	config *params.ChainConfig
+	head   *types.Block
+	feed   event.Feed
+}
+
+func (c *testChain) Config() *params.ChainConfig  { return c.config }
+func (c *testChain) CurrentHeader() *types.Header { return c.head.Header() }
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+func (c *testChain) GetBlockByNumber(number uint64) *types.Block {
+	hash := rawdb.ReadCanonicalHash(c.db, number)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return rawdb.ReadBlock(c.db, hash, number)
+}
+
+func (c *testChain) GetReceiptsByHash(hash common.Hash) types.Receipts {
+	number := rawdb.ReadHeaderNumber(c.db, hash)
+	if number == nil {
+		return nil
+	}
+	return rawdb.ReadReceipts(c.db, hash, *number, c.config)
+}
+
+// testEvent is the event emitted by the only tx of a test block.
+type testEvent struct {
+	kind EventKind
+	key  *ecdsa.PrivateKey
+}
+
+// extend writes blocks on top of parent up to number, the block n carries
+// events[n], and makes them canonical.
+func (c *testChain) extend(t *testing.T, parent *types.Block, number uint64, fork byte, events map[uint64]testEvent) {
+	signer := types.MakeSigner(c.config, common.Big0)
+	for parent.NumberU64() < number {
+		header := &types.Header{
+			ParentHash: parent.Hash(),
+			Number:     new(big.Int).Add(parent.Number(), common.Big1),
+			Difficulty: common.Big1,
+			GasLimit:   params.GenesisGasLimit,
+			Extra:      []byte{fork},
+		}
+		var (
+			txs      types.Transactions
+			receipts types.Receipts
+		)
+		if ev, ok := events[header.Number.Uint64()]; ok {
+			tx, err := types.SignTx(types.NewTransaction(0, spv.ELAMinterAddress, new(big.Int), 100000, big.NewInt(1), nil), signer, ev.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			txs = append(txs, tx)
+			receipts = append(receipts, &types.Receipt{
+				Status:            types.ReceiptStatusSuccessful,
+				CumulativeGasUsed: 21000,
+				Logs:              []*types.Log{testLog(t, ev)},
+			})
+		}
+		block := types.NewBlock(header, txs, nil, receipts)
+		rawdb.WriteBlock(c.db, block)
+		rawdb.WriteReceipts(c.db, block.Hash(), block.NumberU64(), receipts)
+		rawdb.WriteCanonicalHash(c.db, block.Hash(), block.NumberU64())
+		parent = block
+	}
+	c.head = parent
+	c.feed.Send(core.ChainHeadEvent{Block: parent})
+}
+
+func testLog(t *testing.T, ev testEvent) *types.Log {
+	addr := crypto.PubkeyToAddress(ev.key.PublicKey)
+	var (
+		l    = new(types.Log)
+		data []byte
+		err  error
+	)
+	switch ev.kind {
+	case KindWithdraw:
+		l.Address = spv.ELAMinterAddress
+		l.Topics = []common.Hash{payloadReceivedEvent.ID, addr.Hash()}
+		data, err = payloadReceivedEvent.Inputs.NonIndexed().Pack("EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd", big.NewInt(100), big.NewInt(90))
+	case KindRefund:
+		l.Address = spv.ELAMinterAddress
+		l.Topics = []common.Hash{refundWithdrawEvent.ID, common.HexToHash("0x01"), addr.Hash()}
+		data, err = refundWithdrawEvent.Inputs.NonIndexed().Pack(big.NewInt(100), []byte{})
+	case KindPledgeBillBurn:
+		l.Address = testPledgeBill
+		l.Topics = []common.Hash{stakeTicketBurnEvent.ID}
+		data, err = stakeTicketBurnEvent.Inputs.Pack(big.NewInt(7), "EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Data = data
+	return l
+}
+
+// eventBlocks returns the kinds and the block numbers of the events of page.
+func eventBlocks(page *EventsPage) ([]string, []uint64) {
+	var (
+		kinds   []string
+		numbers []uint64
+	)
+	for _, ev := range page.Events {
+		kinds = append(kinds, ev["kind"].(string))
+		numbers = append(numbers, uint64(ev["blockNumber"].(hexutil.Uint64)))
+	}
+	return kinds, numbers
+}
+
+func waitSections(t *testing.T, indexer *core.ChainIndexer, sections uint64, head common.Hash) {
+	for i := 0; i < 500; i++ {
+		if n, _, h := indexer.Sections(); n == sections && h == head {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n, _, _ := indexer.Sections()
+	t.Fatalf("indexed sections %d, want %d", n, sections)
+}
+
+func TestWithdrawIndex(t *testing.T) {
+	config := *params.TestChainConfig
+	config.PledgeBillContract = testPledgeBill.Hex()
+	db := rawdb.NewMemoryDatabase()
+	genesis := new(core.Genesis).MustCommit(db)
+	chain := &testChain{db: db, config: &config, head: genesis}
+
+	indexer, index := NewIndexer(db, &config)
+	defer indexer.Close()
+	indexer.Start(chain)
+	api := &PublicWithdrawIndexAPI{indexer: indexer, index: index, chain: chain}
+	ctx := context.Background()
+
+	// sections 0 and 1 are indexed, block 70 is served from the chain
+	chain.extend(t, genesis, 80, 0, map[uint64]testEvent{
+		5:  {KindWithdraw, testKeyA},
+		10: {KindRefund, testKeyB},
+		12: {KindPledgeBillBurn, testKeyA},
+		40: {KindWithdraw, testKeyA},
+		70: {KindWithdraw, testKeyA},
+	})
+	waitSections(t, indexer, 2, chain.GetBlockByNumber(63).Hash())
+
+	page, err := api.GetWithdrawsByHeightRange(ctx, 0, 100, nil, nil)
+	assert.NoError(t, err)
+	kinds, numbers := eventBlocks(page)
+	assert.Equal(t, []string{"withdraw", "refund", "pledgeBillBurn", "withdraw", "withdraw"}, kinds)
+	assert.Equal(t, []uint64{5, 10, 12, 40, 70}, numbers)
+	assert.Nil(t, page.Next)
+	assert.Equal(t, testAddrB, page.Events[1]["address"])
+	assert.Equal(t, testAddrA, page.Events[2]["address"])
+	assert.Equal(t, "EH4cWj8Pfq6sCVY3AwBVxDr4CBbb2wTpfd", page.Events[2]["mainChainAddress"])
+
+	// pagination
+	limit := hexutil.Uint64(2)
+	page, err = api.GetWithdrawsByHeightRange(ctx, 0, 100, nil, &limit)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{5, 10}, numbers)
+	if assert.NotNil(t, page.Next) {
+		page, err = api.GetWithdrawsByHeightRange(ctx, 0, 100, page.Next, &limit)
+		assert.NoError(t, err)
+		_, numbers = eventBlocks(page)
+		assert.Equal(t, []uint64{12, 40}, numbers)
+	}
+	if assert.NotNil(t, page.Next) {
+		page, err = api.GetWithdrawsByHeightRange(ctx, 0, 100, page.Next, &limit)
+		assert.NoError(t, err)
+		_, numbers = eventBlocks(page)
+		assert.Equal(t, []uint64{70}, numbers)
+		assert.Nil(t, page.Next)
+	}
+
+	page, err = api.GetWithdrawsByHeightRange(ctx, 11, 40, nil, nil)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{12, 40}, numbers)
+
+	page, err = api.GetWithdrawsByAddress(ctx, testAddrA, nil, nil)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{5, 12, 40, 70}, numbers)
+
+	// a reorg from block 39 on drops the events of blocks 40 and 70
+	chain.extend(t, chain.GetBlockByNumber(38), 82, 1, map[uint64]testEvent{
+		45: {KindWithdraw, testKeyB},
+	})
+	waitSections(t, indexer, 2, chain.GetBlockByNumber(63).Hash())
+
+	page, err = api.GetWithdrawsByAddress(ctx, testAddrA, nil, nil)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{5, 12}, numbers)
+
+	page, err = api.GetWithdrawsByAddress(ctx, testAddrB, nil, nil)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{10, 45}, numbers)
+
+	page, err = api.GetWithdrawsByHeightRange(ctx, 32, 100, nil, nil)
+	assert.NoError(t, err)
+	_, numbers = eventBlocks(page)
+	assert.Equal(t, []uint64{45}, numbers)
+
+	_, err = api.GetWithdrawsByHeightRange(ctx, 50, 40, nil, nil)
+	assert.Equal(t, errInvalidRange, err)
+}